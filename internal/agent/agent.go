@@ -1,13 +1,14 @@
 package agent
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -31,8 +32,30 @@ var (
 )
 
 type userConfig struct {
-	Ollama ollamaConfig `json:"ollama"`
-	OpenAI openAIConfig `json:"openai"`
+	Ollama           ollamaConfig              `json:"ollama"`
+	OpenAI           openAIConfig              `json:"openai"`
+	Anthropic        anthropicConfig           `json:"anthropic,omitempty"`
+	Endpoints        map[string]endpointConfig `json:"endpoints,omitempty"`
+	Fallback         []fallbackEntry           `json:"fallback,omitempty"`
+	Limits           limitsConfig              `json:"limits,omitempty"`
+	Shell            shellConfig               `json:"shell,omitempty"`
+	Workspace        workspaceConfig           `json:"workspace,omitempty"`
+	Plugins          pluginsConfig             `json:"plugins,omitempty"`
+	SystemPrompt     string                    `json:"system_prompt,omitempty"`
+	SystemPromptFile string                    `json:"system_prompt_file,omitempty"`
+	SystemPromptMode string                    `json:"system_prompt_mode,omitempty"`
+	Profiles         map[string]profileConfig  `json:"profiles,omitempty"`
+	DefaultProfile   string                    `json:"default_profile,omitempty"`
+}
+
+type profileConfig struct {
+	Provider     string   `json:"provider"`
+	Model        string   `json:"model,omitempty"`
+	BaseURL      string   `json:"base_url,omitempty"`
+	APIKey       string   `json:"api_key,omitempty"`
+	Temperature  *float64 `json:"temperature,omitempty"`
+	MaxTokens    int      `json:"max_tokens,omitempty"`
+	SystemPrompt string   `json:"system_prompt,omitempty"`
 }
 
 type ollamaConfig struct {
@@ -46,20 +69,37 @@ type openAIConfig struct {
 	Model   string `json:"model"`
 }
 
+type anthropicConfig struct {
+	APIKey  string `json:"api_key"`
+	BaseURL string `json:"base_url"`
+	Model   string `json:"model"`
+	Version string `json:"version,omitempty"`
+}
+
 type AskOptions struct {
+	Profile      string
 	Provider     string
 	Model        string
 	BaseURL      string
+	APIKey       string
 	Temperature  *float64
 	MaxTokens    int
 	JSONMode     bool
 	SystemPrompt string
+	// Timeout bounds a whole request including fallbacks; zero means none.
+	Timeout time.Duration
+	// ScopeNotes are toolkit prompt fragments for the active --scope.
+	ScopeNotes string
+	// Context cancels the request, e.g. when Ctrl+C interrupts an agent
+	// step; nil means context.Background().
+	Context context.Context
 }
 
 type AskResult struct {
 	Text     string
 	Provider string
 	Model    string
+	Attempts []ProviderAttempt
 }
 
 type SessionProvider struct {
@@ -80,6 +120,9 @@ type DecisionResult struct {
 	FunctionDescription string
 	Provider            string
 	Model               string
+	Attempts            []ProviderAttempt
+	// Actions holds the run_plugin/run_tool steps of a "multi" decision.
+	Actions []DecisionResult
 }
 
 func AskWithOptions(prompt string, opts AskOptions) (AskResult, error) {
@@ -92,41 +135,18 @@ func AskWithOptions(prompt string, opts AskOptions) (AskResult, error) {
 	if cfgErr != nil {
 		fmt.Fprintln(os.Stderr, "Warning: failed to load config:", cfgErr)
 	}
-
-	provider := strings.ToLower(strings.TrimSpace(opts.Provider))
-	if provider == "" {
-		provider = "openai"
+	opts, err := applyProfile(cfg, opts)
+	if err != nil {
+		return AskResult{}, err
 	}
 
-	switch provider {
-	case "ollama":
-		applyOllamaOverrides(&cfg, opts)
-		answer, model, err := askOllama(text, cfg.Ollama, opts)
-		if err != nil {
-			return AskResult{}, err
-		}
-		return AskResult{Text: answer, Provider: "ollama", Model: model}, nil
-	case "openai":
-		applyOpenAIOverrides(&cfg, opts)
-		answer, model, err := askOpenAI(text, cfg.OpenAI, opts)
-		if err != nil {
-			return AskResult{}, err
-		}
-		return AskResult{Text: answer, Provider: "openai", Model: model}, nil
-	case "auto":
-		applyOllamaOverrides(&cfg, opts)
-		if answer, model, err := askOllama(text, cfg.Ollama, opts); err == nil {
-			return AskResult{Text: answer, Provider: "ollama", Model: model}, nil
-		}
-		applyOpenAIOverrides(&cfg, opts)
-		answer, model, err := askOpenAI(text, cfg.OpenAI, opts)
-		if err != nil {
-			return AskResult{}, fmt.Errorf("ollama unavailable and openai fallback failed: %w", err)
-		}
-		return AskResult{Text: answer, Provider: "openai", Model: model}, nil
-	default:
-		return AskResult{}, fmt.Errorf("invalid provider %q (use auto|ollama|openai)", opts.Provider)
+	chain, err := providerChain(cfg, opts)
+	if err != nil {
+		return AskResult{}, err
 	}
+	return askChain(chain, opts, func(ctx context.Context, p Provider) (string, error) {
+		return p.Ask(ctx, text, opts)
+	})
 }
 
 func ResolveSessionProvider(opts AskOptions) (SessionProvider, error) {
@@ -134,46 +154,38 @@ func ResolveSessionProvider(opts AskOptions) (SessionProvider, error) {
 	if cfgErr != nil {
 		fmt.Fprintln(os.Stderr, "Warning: failed to load config:", cfgErr)
 	}
-	applyOllamaOverrides(&cfg, opts)
-	applyOpenAIOverrides(&cfg, opts)
-
-	ollamaBase, ollamaModel := resolvedOllama(cfg)
-	openAIBase, openAIModel, openAIKey := resolvedOpenAI(cfg)
-
-	if err := validateBaseURL(ollamaBase, "ollama"); err != nil {
-		fmt.Fprintln(os.Stderr, "Warning:", err)
+	opts, err := applyProfile(cfg, opts)
+	if err != nil {
+		return SessionProvider{}, err
 	}
-	if err := validateBaseURL(openAIBase, "openai"); err != nil {
-		fmt.Fprintln(os.Stderr, "Warning:", err)
+	chain, err := providerChain(cfg, opts)
+	if err != nil {
+		return SessionProvider{}, err
 	}
-
-	reqProvider := strings.ToLower(strings.TrimSpace(opts.Provider))
-	if reqProvider == "" {
-		reqProvider = "openai"
+	for _, ce := range chain {
+		if err := validateBaseURL(ce.provider.BaseURL(), ce.provider.Name()); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning:", err)
+		}
 	}
 
-	switch reqProvider {
-	case "ollama":
-		if err := pingOllama(ollamaBase); err != nil {
-			return SessionProvider{}, fmt.Errorf("ollama unavailable: %w\n  Hint: run 'dm doctor' for diagnostics", err)
-		}
-		return newSessionProvider("ollama", ollamaModel, ollamaBase), nil
-	case "openai":
-		if strings.TrimSpace(openAIKey) == "" {
-			return SessionProvider{}, fmt.Errorf("missing OpenAI API key (set in %s or OPENAI_API_KEY)\n  Hint: run 'dm doctor' for diagnostics", configPath())
-		}
-		return newSessionProvider("openai", openAIModel, openAIBase), nil
-	case "auto":
-		if err := pingOllama(ollamaBase); err == nil {
-			return newSessionProvider("ollama", ollamaModel, ollamaBase), nil
+	var pingErrs []string
+	for _, ce := range chain {
+		p := ce.provider
+		if err := p.Ping(); err != nil {
+			pingErrs = append(pingErrs, fmt.Sprintf("%s unavailable: %v", p.Name(), err))
+			continue
 		}
-		if strings.TrimSpace(openAIKey) == "" {
-			return SessionProvider{}, fmt.Errorf("ollama unavailable and OpenAI API key is missing\n  Hint: run 'dm doctor' for diagnostics")
+		session := newSessionProvider(p.Name(), p.Model(), p.BaseURL(), opts)
+		if len(chain) > 1 {
+			// Keep the whole chain for later requests; only report the
+			// first reachable entry.
+			session.Options.Provider = opts.Provider
+			session.Options.Model = opts.Model
+			session.Options.BaseURL = opts.BaseURL
 		}
-		return newSessionProvider("openai", openAIModel, openAIBase), nil
-	default:
-		return SessionProvider{}, fmt.Errorf("invalid provider %q (use auto|ollama|openai)", opts.Provider)
+		return session, nil
 	}
+	return SessionProvider{}, fmt.Errorf("%s\n  Hint: run 'dm doctor' for diagnostics", strings.Join(pingErrs, "; "))
 }
 
 func validateBaseURL(u, label string) error {
@@ -186,18 +198,102 @@ func validateBaseURL(u, label string) error {
 	return nil
 }
 
-func newSessionProvider(provider, model, baseURL string) SessionProvider {
+func newSessionProvider(provider, model, baseURL string, resolved AskOptions) SessionProvider {
 	return SessionProvider{
 		Provider: provider,
 		Model:    model,
-		Options:  AskOptions{Provider: provider, Model: model, BaseURL: baseURL},
+		Options: AskOptions{
+			Profile:      resolved.Profile,
+			Provider:     provider,
+			Model:        model,
+			BaseURL:      baseURL,
+			APIKey:       resolved.APIKey,
+			Temperature:  resolved.Temperature,
+			MaxTokens:    resolved.MaxTokens,
+			SystemPrompt: resolved.SystemPrompt,
+		},
 	}
 }
 
+// ResolveProfile fills options left empty by the caller from the selected
+// profile, see applyProfile.
+func ResolveProfile(opts AskOptions) (AskOptions, error) {
+	cfg, _ := cachedUserConfig()
+	return applyProfile(cfg, opts)
+}
+
+// applyProfile fills options left empty by the caller from the selected
+// profile (opts.Profile, else default_profile when no provider was chosen).
+// Explicit options win.
+func applyProfile(cfg userConfig, opts AskOptions) (AskOptions, error) {
+	name := strings.TrimSpace(opts.Profile)
+	if name == "" && strings.TrimSpace(opts.Provider) == "" {
+		name = strings.TrimSpace(cfg.DefaultProfile)
+	}
+	if name == "" {
+		return opts, nil
+	}
+	p, ok := cfg.Profiles[name]
+	if !ok {
+		return opts, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(profileNames(cfg), ", "))
+	}
+	opts.Profile = name
+	if strings.TrimSpace(opts.Provider) == "" {
+		opts.Provider = strings.TrimSpace(p.Provider)
+	}
+	if strings.TrimSpace(opts.Model) == "" {
+		opts.Model = strings.TrimSpace(p.Model)
+	}
+	if strings.TrimSpace(opts.BaseURL) == "" {
+		opts.BaseURL = strings.TrimSpace(p.BaseURL)
+	}
+	if strings.TrimSpace(opts.APIKey) == "" {
+		opts.APIKey = strings.TrimSpace(p.APIKey)
+	}
+	if opts.Temperature == nil && p.Temperature != nil {
+		t := *p.Temperature
+		opts.Temperature = &t
+	}
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = p.MaxTokens
+	}
+	if strings.TrimSpace(opts.SystemPrompt) == "" {
+		opts.SystemPrompt = p.SystemPrompt
+	}
+	return opts, nil
+}
+
+func profileNames(cfg userConfig) []string {
+	names := make([]string, 0, len(cfg.Profiles))
+	for n := range cfg.Profiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProfileNames lists the profiles defined in the agent config.
+func ProfileNames() []string {
+	cfg, _ := cachedUserConfig()
+	return profileNames(cfg)
+}
+
 const decisionTemperature = 0.2
 const decisionMaxTokens = 1024
 
-func buildDecisionSystemPrompt(pluginCatalog, toolCatalog string) string {
+const decisionPreamble = "You are an execution planner for a CLI assistant."
+
+func buildDecisionSystemPrompt(pluginCatalog, toolCatalog string, opts AskOptions) string {
+	cfg, _ := cachedUserConfig()
+	return renderDecisionSystemPrompt(cfg, pluginCatalog, toolCatalog, opts)
+}
+
+func renderDecisionSystemPrompt(cfg userConfig, pluginCatalog, toolCatalog string, opts AskOptions) string {
+	preamble := decisionPreamble
+	custom, mode := configSystemPrompt(cfg)
+	if custom != "" && mode == SystemPromptReplace {
+		preamble = custom
+	}
 	if strings.TrimSpace(pluginCatalog) == "" {
 		pluginCatalog = "(none)"
 	}
@@ -205,7 +301,7 @@ func buildDecisionSystemPrompt(pluginCatalog, toolCatalog string) string {
 		toolCatalog = "(none)"
 	}
 	parts := []string{
-		"You are an execution planner for a CLI assistant.",
+		preamble,
 		"You can either answer directly, run a plugin (PowerShell function), run a built-in tool, or propose creating a new function.",
 		"",
 		"Available plugins (PowerShell functions):",
@@ -219,6 +315,7 @@ func buildDecisionSystemPrompt(pluginCatalog, toolCatalog string) string {
 		`{"action":"run_plugin","plugin":"name","plugin_args":{"ParamName":"value","SwitchParam":"true"},"reason":"why","answer":"optional text"}`,
 		`{"action":"run_tool","tool":"name","tool_args":{"key":"value"},"reason":"why","answer":"optional text"}`,
 		`{"action":"create_function","function_description":"detailed description of what the function should do, its inputs and outputs","reason":"why no existing plugin fits"}`,
+		`{"action":"multi","actions":[{"action":"run_tool",...},{"action":"run_plugin",...}],"reason":"why","answer":"optional text"}`,
 		"",
 		"Catalog notation: Name* = required, Flag? = switch, Param=val = default value, Param=a|b|c = allowed values.",
 		"",
@@ -243,12 +340,19 @@ func buildDecisionSystemPrompt(pluginCatalog, toolCatalog string) string {
 		"7. Put your reasoning in the \"reason\" field.",
 		"",
 		"General rules:",
-		"- action must be answer, run_plugin, run_tool, or create_function.",
+		"- action must be answer, run_plugin, run_tool, create_function, or multi.",
 		"- Do not invent plugin or tool names; use only the catalog above.",
 		"- If the user request requires an operation that no existing plugin or tool can handle, return action=create_function.",
 		"- Only use create_function for tasks that genuinely need a new automation capability, not for general knowledge questions.",
 		"- If a plugin requires confirmation or is destructive, mention it in the answer.",
 		"- Tool arguments are already listed in the catalog after 'tool_args:'. Use those exact keys.",
+		"- Use action=multi only for independent run_plugin/run_tool steps that do not need each other's output (e.g. checking disk usage and docker status); they may run concurrently.",
+	}
+	if custom != "" && mode != SystemPromptReplace {
+		parts = append(parts, "", "Team instructions (follow these over the defaults above):", custom)
+	}
+	if notes := strings.TrimSpace(opts.ScopeNotes); notes != "" {
+		parts = append(parts, "", "Domain notes for the toolkits in scope:", notes)
 	}
 	return strings.Join(parts, "\n")
 }
@@ -262,16 +366,36 @@ func buildDecisionUserPrompt(userPrompt, envContext string) string {
 	return strings.Join(parts, "\n")
 }
 
+// decisionOpts builds the options of one planner call from base, which the
+// caller resolved against its profile already.
 func decisionOpts(base AskOptions, systemPrompt string) AskOptions {
 	temp := decisionTemperature
+	if base.Temperature != nil {
+		temp = *base.Temperature
+	}
+	maxTokens := decisionMaxTokens
+	if cfg, err := cachedUserConfig(); err == nil && cfg.Limits.DecisionTokens > 0 {
+		maxTokens = cfg.Limits.DecisionTokens
+	}
+	if base.MaxTokens > 0 {
+		maxTokens = base.MaxTokens
+	}
+	if extra := strings.TrimSpace(base.SystemPrompt); extra != "" {
+		systemPrompt += "\n\nAdditional instructions:\n" + extra
+	}
 	return AskOptions{
+		Profile:      base.Profile,
 		Provider:     base.Provider,
 		Model:        base.Model,
 		BaseURL:      base.BaseURL,
+		APIKey:       base.APIKey,
 		Temperature:  &temp,
-		MaxTokens:    decisionMaxTokens,
+		MaxTokens:    maxTokens,
 		JSONMode:     true,
 		SystemPrompt: systemPrompt,
+		Timeout:      base.Timeout,
+		ScopeNotes:   base.ScopeNotes,
+		Context:      base.Context,
 	}
 }
 
@@ -281,7 +405,7 @@ func DecideWithPlugins(userPrompt string, pluginCatalog string, toolCatalog stri
 		return DecisionResult{}, fmt.Errorf("prompt is required")
 	}
 
-	systemPrompt := buildDecisionSystemPrompt(pluginCatalog, toolCatalog)
+	systemPrompt := buildDecisionSystemPrompt(pluginCatalog, toolCatalog, opts)
 	userMsg := buildDecisionUserPrompt(p, envContext)
 	dOpts := decisionOpts(opts, systemPrompt)
 
@@ -299,7 +423,8 @@ func DecideWithPlugins(userPrompt string, pluginCatalog string, toolCatalog stri
 				slog.Warn("JSON repair succeeded", "action", parsed2.Action)
 				parsed2.Provider = repaired.Provider
 				parsed2.Model = repaired.Model
-				if parsed2.Action != "run_plugin" && parsed2.Action != "run_tool" && parsed2.Action != "create_function" {
+				parsed2.Attempts = append(raw.Attempts, repaired.Attempts...)
+				if !isActionDecision(parsed2.Action) {
 					parsed2.Action = "answer"
 				}
 				return parsed2, nil
@@ -311,11 +436,13 @@ func DecideWithPlugins(userPrompt string, pluginCatalog string, toolCatalog stri
 			Answer:   raw.Text,
 			Provider: raw.Provider,
 			Model:    raw.Model,
+			Attempts: raw.Attempts,
 		}, nil
 	}
 	parsed.Provider = raw.Provider
 	parsed.Model = raw.Model
-	if parsed.Action != "run_plugin" && parsed.Action != "run_tool" && parsed.Action != "create_function" {
+	parsed.Attempts = raw.Attempts
+	if !isActionDecision(parsed.Action) {
 		parsed.Action = "answer"
 	}
 	return parsed, nil
@@ -330,6 +457,7 @@ func askDecisionJSONRepair(rawText string, opts AskOptions) (AskResult, error) {
 		`{"action":"run_plugin","plugin":"name","plugin_args":{"ParamName":"value"},"reason":"why","answer":"optional text"}`,
 		`{"action":"run_tool","tool":"name","tool_args":{"key":"value"},"reason":"why","answer":"optional text"}`,
 		`{"action":"create_function","function_description":"description","reason":"why"}`,
+		`{"action":"multi","actions":[{"action":"run_tool","tool":"name","tool_args":{}}],"reason":"why"}`,
 		"",
 		"Text:",
 		strings.TrimSpace(rawText),
@@ -388,19 +516,23 @@ func parseDecisionJSON(text string) (DecisionResult, error) {
 		payload = m
 	}
 	var obj struct {
-		Action              string         `json:"action"`
-		Answer              string         `json:"answer"`
-		Plugin              string         `json:"plugin"`
-		PluginArgs          map[string]any `json:"plugin_args"`
-		Tool                string         `json:"tool"`
-		ToolArgs            map[string]any `json:"tool_args"`
-		Args                []string       `json:"args"`
-		Reason              string         `json:"reason"`
-		FunctionDescription string         `json:"function_description"`
+		Action              string            `json:"action"`
+		Answer              string            `json:"answer"`
+		Plugin              string            `json:"plugin"`
+		PluginArgs          map[string]any    `json:"plugin_args"`
+		Tool                string            `json:"tool"`
+		ToolArgs            map[string]any    `json:"tool_args"`
+		Args                []string          `json:"args"`
+		Reason              string            `json:"reason"`
+		FunctionDescription string            `json:"function_description"`
+		Actions             []json.RawMessage `json:"actions"`
 	}
 	if err := json.Unmarshal([]byte(payload), &obj); err != nil {
 		return DecisionResult{}, err
 	}
+	if len(obj.Actions) > 0 {
+		return parseMultiDecision(obj.Actions, strings.TrimSpace(obj.Reason), strings.TrimSpace(obj.Answer))
+	}
 	pluginArgs := sanitizeAnyMap(obj.PluginArgs)
 	toolArgs := sanitizeAnyMap(obj.ToolArgs)
 	return DecisionResult{
@@ -416,6 +548,43 @@ func parseDecisionJSON(text string) (DecisionResult, error) {
 	}, nil
 }
 
+// parseMultiDecision builds a "multi" decision from an actions array. Only
+// run_plugin and run_tool entries are kept; a single one is returned as is.
+func parseMultiDecision(raw []json.RawMessage, reason, answer string) (DecisionResult, error) {
+	var actions []DecisionResult
+	for _, r := range raw {
+		d, err := parseDecisionJSON(string(r))
+		if err != nil {
+			return DecisionResult{}, fmt.Errorf("invalid entry in actions: %w", err)
+		}
+		if d.Action != "run_plugin" && d.Action != "run_tool" {
+			continue
+		}
+		if d.Reason == "" {
+			d.Reason = reason
+		}
+		actions = append(actions, d)
+	}
+	switch len(actions) {
+	case 0:
+		return DecisionResult{Action: "answer", Answer: answer, Reason: reason}, nil
+	case 1:
+		if actions[0].Answer == "" {
+			actions[0].Answer = answer
+		}
+		return actions[0], nil
+	}
+	return DecisionResult{Action: "multi", Actions: actions, Reason: reason, Answer: answer}, nil
+}
+
+func isActionDecision(action string) bool {
+	switch action {
+	case "run_plugin", "run_tool", "create_function", "multi":
+		return true
+	}
+	return false
+}
+
 func truncateLog(s string, max int) string {
 	if len(s) <= max {
 		return s
@@ -460,24 +629,6 @@ func sanitizeAnyMap(m map[string]any) map[string]string {
 	return out
 }
 
-func applyOllamaOverrides(cfg *userConfig, opts AskOptions) {
-	if strings.TrimSpace(opts.Model) != "" {
-		cfg.Ollama.Model = strings.TrimSpace(opts.Model)
-	}
-	if strings.TrimSpace(opts.BaseURL) != "" {
-		cfg.Ollama.BaseURL = strings.TrimSpace(opts.BaseURL)
-	}
-}
-
-func applyOpenAIOverrides(cfg *userConfig, opts AskOptions) {
-	if strings.TrimSpace(opts.Model) != "" {
-		cfg.OpenAI.Model = strings.TrimSpace(opts.Model)
-	}
-	if strings.TrimSpace(opts.BaseURL) != "" {
-		cfg.OpenAI.BaseURL = strings.TrimSpace(opts.BaseURL)
-	}
-}
-
 func loadUserConfig() (userConfig, error) {
 	for _, path := range configPaths() {
 		data, err := os.ReadFile(path)
@@ -503,6 +654,12 @@ func cachedUserConfig() (userConfig, error) {
 	return configCached, configErr
 }
 
+// ConfigPath returns the agent config file resolved from DM_AGENT_CONFIG,
+// the executable directory, or ~/.config/dm/agent.json (in that order).
+func ConfigPath() string {
+	return configPath()
+}
+
 func configPath() string {
 	paths := configPaths()
 	if len(paths) == 0 {
@@ -546,12 +703,15 @@ func doWithRetry(buildReq func() (*http.Request, error)) (*http.Response, error)
 		}
 		res, err := sharedHTTPClient.Do(req)
 		if err != nil {
+			if req.Context().Err() != nil {
+				return nil, err
+			}
 			lastErr = err
 			continue
 		}
 		if res.StatusCode == 429 || res.StatusCode >= 500 {
 			_ = res.Body.Close()
-			lastErr = fmt.Errorf("server error: %s", res.Status)
+			lastErr = &statusError{prefix: "server error", code: res.StatusCode, status: res.Status}
 			continue
 		}
 		return res, nil
@@ -559,147 +719,6 @@ func doWithRetry(buildReq func() (*http.Request, error)) (*http.Response, error)
 	return nil, lastErr
 }
 
-func askOllama(prompt string, cfg ollamaConfig, opts AskOptions) (string, string, error) {
-	baseURL, model := normalizedOllamaValues(cfg)
-	slog.Debug("LLM request", "provider", "ollama", "model", model, "prompt_chars", len(prompt))
-
-	messages := []map[string]string{}
-	systemMsg := "You are a pragmatic coding assistant."
-	if strings.TrimSpace(opts.SystemPrompt) != "" {
-		systemMsg = opts.SystemPrompt
-	}
-	messages = append(messages, map[string]string{"role": "system", "content": systemMsg})
-	messages = append(messages, map[string]string{"role": "user", "content": prompt})
-
-	reqBody := map[string]any{
-		"model":    model,
-		"messages": messages,
-		"stream":   false,
-	}
-	if opts.JSONMode {
-		reqBody["format"] = "json"
-	}
-	ollamaOpts := map[string]any{}
-	if opts.Temperature != nil {
-		ollamaOpts["temperature"] = *opts.Temperature
-	}
-	if opts.MaxTokens > 0 {
-		ollamaOpts["num_predict"] = opts.MaxTokens
-	}
-	if len(ollamaOpts) > 0 {
-		reqBody["options"] = ollamaOpts
-	}
-	raw, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", model, err
-	}
-	res, err := doWithRetry(func() (*http.Request, error) {
-		req, err := http.NewRequest(http.MethodPost, baseURL+"/api/chat", bytes.NewReader(raw))
-		if err != nil {
-			return nil, err
-		}
-		req.Header.Set("Content-Type", "application/json")
-		return req, nil
-	})
-	if err != nil {
-		return "", model, err
-	}
-	defer res.Body.Close()
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return "", model, fmt.Errorf("ollama status: %s", res.Status)
-	}
-	var parsed struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
-	}
-	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
-		return "", model, err
-	}
-	answer := strings.TrimSpace(parsed.Message.Content)
-	if answer == "" {
-		return "", model, fmt.Errorf("empty ollama response")
-	}
-	return answer, model, nil
-}
-
-func askOpenAI(prompt string, cfg openAIConfig, opts AskOptions) (string, string, error) {
-	baseURL, model, apiKey := normalizedOpenAIValues(cfg)
-	if apiKey == "" {
-		return "", "", fmt.Errorf("missing OpenAI API key (set in %s or OPENAI_API_KEY)", configPath())
-	}
-	slog.Debug("LLM request", "provider", "openai", "model", model, "prompt_chars", len(prompt))
-
-	systemMsg := "You are a pragmatic coding assistant."
-	if strings.TrimSpace(opts.SystemPrompt) != "" {
-		systemMsg = opts.SystemPrompt
-	}
-
-	reqBody := map[string]any{
-		"model": model,
-		"messages": []map[string]string{
-			{"role": "system", "content": systemMsg},
-			{"role": "user", "content": prompt},
-		},
-	}
-	if opts.Temperature != nil {
-		reqBody["temperature"] = *opts.Temperature
-	}
-	if opts.MaxTokens > 0 {
-		reqBody["max_tokens"] = opts.MaxTokens
-	}
-	if opts.JSONMode {
-		reqBody["response_format"] = map[string]string{"type": "json_object"}
-	}
-	raw, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", model, err
-	}
-	res, err := doWithRetry(func() (*http.Request, error) {
-		req, err := http.NewRequest(http.MethodPost, baseURL+"/chat/completions", bytes.NewReader(raw))
-		if err != nil {
-			return nil, err
-		}
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", "Bearer "+apiKey)
-		return req, nil
-	})
-	if err != nil {
-		return "", model, err
-	}
-	defer res.Body.Close()
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return "", model, fmt.Errorf("openai status: %s", res.Status)
-	}
-
-	var parsed struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
-		return "", model, err
-	}
-	if len(parsed.Choices) == 0 {
-		return "", model, fmt.Errorf("empty openai response")
-	}
-	answer := strings.TrimSpace(parsed.Choices[0].Message.Content)
-	if answer == "" {
-		return "", model, fmt.Errorf("empty openai content")
-	}
-	return answer, model, nil
-}
-
-func resolvedOllama(cfg userConfig) (string, string) {
-	return normalizedOllamaValues(cfg.Ollama)
-}
-
-func resolvedOpenAI(cfg userConfig) (string, string, string) {
-	return normalizedOpenAIValues(cfg.OpenAI)
-}
-
 func normalizedOllamaValues(cfg ollamaConfig) (string, string) {
 	baseURL := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
 	if baseURL == "" {
@@ -711,33 +730,3 @@ func normalizedOllamaValues(cfg ollamaConfig) (string, string) {
 	}
 	return baseURL, model
 }
-
-func normalizedOpenAIValues(cfg openAIConfig) (string, string, string) {
-	apiKey := strings.TrimSpace(cfg.APIKey)
-	if apiKey == "" {
-		apiKey = strings.TrimSpace(os.Getenv("OPENAI_API_KEY"))
-	}
-	baseURL := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
-	if baseURL == "" {
-		baseURL = defaultOpenAIBaseURL
-	}
-	model := strings.TrimSpace(cfg.Model)
-	if model == "" {
-		model = defaultOpenAIModel
-	}
-	return baseURL, model, apiKey
-}
-
-func pingOllama(baseURL string) error {
-	u := strings.TrimRight(strings.TrimSpace(baseURL), "/") + "/api/tags"
-	client := &http.Client{Timeout: 3 * time.Second}
-	res, err := client.Get(u)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return fmt.Errorf("status %s", res.Status)
-	}
-	return nil
-}
@@ -201,7 +201,7 @@ func TestNormalizedOllamaValues_Custom(t *testing.T) {
 	}
 }
 
-func TestNormalizedOpenAIValues_Defaults(t *testing.T) {
+func TestOpenAIProvider_Defaults(t *testing.T) {
 	orig := os.Getenv("OPENAI_API_KEY")
 	os.Unsetenv("OPENAI_API_KEY")
 	defer func() {
@@ -210,7 +210,8 @@ func TestNormalizedOpenAIValues_Defaults(t *testing.T) {
 		}
 	}()
 
-	base, model, key := normalizedOpenAIValues(openAIConfig{})
+	p := newOpenAIProvider("openai", endpointConfig{APIKeyEnv: "OPENAI_API_KEY"}).(*openAIProvider)
+	base, model, key := p.baseURL, p.model, p.apiKey
 	if base != defaultOpenAIBaseURL {
 		t.Fatalf("expected default base url, got %q", base)
 	}
@@ -222,7 +223,7 @@ func TestNormalizedOpenAIValues_Defaults(t *testing.T) {
 	}
 }
 
-func TestNormalizedOpenAIValues_ConfigKey(t *testing.T) {
+func TestOpenAIProvider_ConfigKey(t *testing.T) {
 	orig := os.Getenv("OPENAI_API_KEY")
 	os.Unsetenv("OPENAI_API_KEY")
 	defer func() {
@@ -231,13 +232,13 @@ func TestNormalizedOpenAIValues_ConfigKey(t *testing.T) {
 		}
 	}()
 
-	_, _, key := normalizedOpenAIValues(openAIConfig{APIKey: "sk-test123"})
+	key := newOpenAIProvider("openai", endpointConfig{APIKey: "sk-test123", APIKeyEnv: "OPENAI_API_KEY"}).(*openAIProvider).apiKey
 	if key != "sk-test123" {
 		t.Fatalf("expected key from config, got %q", key)
 	}
 }
 
-func TestNormalizedOpenAIValues_EnvKey(t *testing.T) {
+func TestOpenAIProvider_EnvKey(t *testing.T) {
 	orig := os.Getenv("OPENAI_API_KEY")
 	os.Setenv("OPENAI_API_KEY", "sk-env-key")
 	defer func() {
@@ -248,7 +249,7 @@ func TestNormalizedOpenAIValues_EnvKey(t *testing.T) {
 		}
 	}()
 
-	_, _, key := normalizedOpenAIValues(openAIConfig{})
+	key := newOpenAIProvider("openai", endpointConfig{APIKeyEnv: "OPENAI_API_KEY"}).(*openAIProvider).apiKey
 	if key != "sk-env-key" {
 		t.Fatalf("expected key from env, got %q", key)
 	}
@@ -329,40 +330,40 @@ func TestLoadUserConfig_InvalidJSON(t *testing.T) {
 	}
 }
 
-func TestApplyOllamaOverrides(t *testing.T) {
+func TestProviderOverrides(t *testing.T) {
 	cfg := userConfig{}
-	applyOllamaOverrides(&cfg, AskOptions{Model: "custom-model", BaseURL: "http://host:999"})
-	if cfg.Ollama.Model != "custom-model" {
-		t.Fatalf("expected model override, got %q", cfg.Ollama.Model)
+	p, err := newProviderFromConfig(cfg, "ollama", AskOptions{Model: "custom-model", BaseURL: "http://host:999"})
+	if err != nil {
+		t.Fatal(err)
 	}
-	if cfg.Ollama.BaseURL != "http://host:999" {
-		t.Fatalf("expected base url override, got %q", cfg.Ollama.BaseURL)
+	if p.Model() != "custom-model" {
+		t.Fatalf("expected model override, got %q", p.Model())
 	}
-}
-
-func TestApplyOpenAIOverrides(t *testing.T) {
-	cfg := userConfig{}
-	applyOpenAIOverrides(&cfg, AskOptions{Model: "gpt-5", BaseURL: "https://custom.api"})
-	if cfg.OpenAI.Model != "gpt-5" {
-		t.Fatalf("expected model override, got %q", cfg.OpenAI.Model)
+	if p.BaseURL() != "http://host:999" {
+		t.Fatalf("expected base url override, got %q", p.BaseURL())
+	}
+	p, err = newProviderFromConfig(cfg, "openai", AskOptions{Model: "gpt-5", BaseURL: "https://custom.api"})
+	if err != nil {
+		t.Fatal(err)
 	}
-	if cfg.OpenAI.BaseURL != "https://custom.api" {
-		t.Fatalf("expected base url override, got %q", cfg.OpenAI.BaseURL)
+	if p.Model() != "gpt-5" || p.BaseURL() != "https://custom.api" {
+		t.Fatalf("expected openai overrides, got model=%q base=%q", p.Model(), p.BaseURL())
 	}
 }
 
-func TestApplyOverrides_EmptyDoesNotOverwrite(t *testing.T) {
+func TestProviderOverrides_EmptyDoesNotOverwrite(t *testing.T) {
 	cfg := userConfig{
 		Ollama: ollamaConfig{Model: "existing"},
 		OpenAI: openAIConfig{Model: "existing"},
 	}
-	applyOllamaOverrides(&cfg, AskOptions{})
-	applyOpenAIOverrides(&cfg, AskOptions{})
-	if cfg.Ollama.Model != "existing" {
-		t.Fatalf("expected ollama model preserved, got %q", cfg.Ollama.Model)
-	}
-	if cfg.OpenAI.Model != "existing" {
-		t.Fatalf("expected openai model preserved, got %q", cfg.OpenAI.Model)
+	for _, name := range []string{"ollama", "openai"} {
+		p, err := newProviderFromConfig(cfg, name, AskOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Model() != "existing" {
+			t.Fatalf("expected %s model preserved, got %q", name, p.Model())
+		}
 	}
 }
 
@@ -487,3 +488,96 @@ func TestDoWithRetry_ExhaustsRetries(t *testing.T) {
 		t.Fatalf("expected 3 calls (1 initial + 2 retries), got %d", calls)
 	}
 }
+
+func TestApplyProfile_DefaultProfile(t *testing.T) {
+	temp := 0.7
+	cfg := userConfig{
+		DefaultProfile: "local",
+		Profiles: map[string]profileConfig{
+			"local": {Provider: "ollama", Model: "llama3", BaseURL: "http://box:11434", Temperature: &temp, MaxTokens: 512},
+		},
+	}
+	got, err := applyProfile(cfg, AskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Profile != "local" || got.Provider != "ollama" || got.Model != "llama3" || got.BaseURL != "http://box:11434" {
+		t.Fatalf("unexpected options: %+v", got)
+	}
+	if got.Temperature == nil || *got.Temperature != 0.7 || got.MaxTokens != 512 {
+		t.Fatalf("expected temperature/max_tokens from profile, got %+v", got)
+	}
+}
+
+func TestApplyProfile_ExplicitProviderSkipsDefaultProfile(t *testing.T) {
+	cfg := userConfig{
+		DefaultProfile: "local",
+		Profiles: map[string]profileConfig{
+			"local": {Provider: "ollama", Model: "llama3", BaseURL: "http://box:11434", APIKey: "sk-local", MaxTokens: 512},
+		},
+	}
+	got, err := applyProfile(cfg, AskOptions{Provider: "openai"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Profile != "" || got.Model != "" || got.BaseURL != "" || got.APIKey != "" || got.MaxTokens != 0 {
+		t.Fatalf("expected default_profile to be skipped for an explicit provider, got %+v", got)
+	}
+	got, err = applyProfile(cfg, AskOptions{Profile: "local", Provider: "openai"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Provider != "openai" || got.Model != "llama3" {
+		t.Fatalf("expected an explicit profile to apply under the explicit provider, got %+v", got)
+	}
+}
+
+func TestApplyProfile_ExplicitOptionsWin(t *testing.T) {
+	cfg := userConfig{
+		Profiles: map[string]profileConfig{
+			"work": {Provider: "openai", Model: "gpt-4o", APIKey: "sk-work"},
+		},
+	}
+	got, err := applyProfile(cfg, AskOptions{Profile: "work", Model: "gpt-4o-mini"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Model != "gpt-4o-mini" {
+		t.Fatalf("expected explicit model to win, got %q", got.Model)
+	}
+	if got.Provider != "openai" || got.APIKey != "sk-work" {
+		t.Fatalf("expected provider and key from profile, got %+v", got)
+	}
+}
+
+func TestApplyProfile_Unknown(t *testing.T) {
+	cfg := userConfig{Profiles: map[string]profileConfig{"a": {}, "b": {}}}
+	if _, err := applyProfile(cfg, AskOptions{Profile: "missing"}); err == nil {
+		t.Fatal("expected error for unknown profile")
+	}
+	got, err := applyProfile(userConfig{}, AskOptions{Provider: "ollama"})
+	if err != nil || got.Provider != "ollama" || got.Profile != "" {
+		t.Fatalf("expected options unchanged without profiles, got %+v err=%v", got, err)
+	}
+}
+
+func TestParseDecisionJSON_MultiActions(t *testing.T) {
+	d, err := parseDecisionJSON(`{"actions":[{"action":"run_tool","tool":"system"},{"action":"run_plugin","plugin":"dc_status","plugin_args":{"All":true}},{"action":"answer","answer":"x"}],"reason":"independent checks"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Action != "multi" || len(d.Actions) != 2 {
+		t.Fatalf("expected multi with 2 actions, got %+v", d)
+	}
+	if d.Actions[1].PluginArgs["All"] != "true" || d.Actions[0].Reason != "independent checks" {
+		t.Fatalf("unexpected actions %+v", d.Actions)
+	}
+
+	d, err = parseDecisionJSON(`{"action":"multi","actions":[{"action":"run_tool","tool":"recent"}],"answer":"ok"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Action != "run_tool" || d.Tool != "recent" || d.Answer != "ok" {
+		t.Fatalf("expected single action to be flattened, got %+v", d)
+	}
+}
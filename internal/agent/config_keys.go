@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Value kinds of dm.agent.json keys, as reported by ConfigValueKind.
+const (
+	ConfigKindString   = "string"
+	ConfigKindBool     = "bool"
+	ConfigKindNumber   = "number"
+	ConfigKindDuration = "duration"
+	ConfigKindObject   = "object"
+)
+
+// ConfigValueKind reports what dm.agent.json expects at a dotted key, e.g.
+// "number" for limits.max_steps and "string" for openai.api_key. Names under
+// profiles and endpoints match any entry. Keys outside the schema return "".
+func ConfigValueKind(key string) string {
+	t := reflect.TypeOf(userConfig{})
+	for _, part := range strings.Split(key, ".") {
+		switch t.Kind() {
+		case reflect.Struct:
+			f, ok := configField(t, part)
+			if !ok {
+				return ""
+			}
+			t = f.Type
+		case reflect.Map:
+			t = t.Elem()
+		default:
+			return ""
+		}
+	}
+	if t == reflect.TypeOf(duration(0)) {
+		return ConfigKindDuration
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return ConfigKindString
+	case reflect.Bool:
+		return ConfigKindBool
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return ConfigKindNumber
+	case reflect.Struct, reflect.Map, reflect.Slice:
+		return ConfigKindObject
+	}
+	return ""
+}
+
+func configField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tag == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
@@ -0,0 +1,208 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+// Error classes that can trigger a fallback to the next chain entry.
+const (
+	FallbackOnConnection = "connection"
+	FallbackOnTimeout    = "timeout"
+	FallbackOn429        = "429"
+	FallbackOn5xx        = "5xx"
+	FallbackOnParse      = "parse"
+)
+
+// FallbackClasses lists the error classes accepted in a fallback entry's "on".
+func FallbackClasses() []string {
+	return []string{FallbackOnConnection, FallbackOnTimeout, FallbackOn429, FallbackOn5xx, FallbackOnParse}
+}
+
+// fallbackEntry is one element of the "fallback" config list. It accepts
+// either "provider:model" or an object with a timeout and error classes.
+type fallbackEntry struct {
+	Provider string   `json:"provider"`
+	Model    string   `json:"model,omitempty"`
+	Timeout  duration `json:"timeout,omitempty"`
+	On       []string `json:"on,omitempty"`
+}
+
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var secs float64
+	if err := json.Unmarshal(data, &secs); err == nil {
+		*d = duration(time.Duration(secs * float64(time.Second)))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("timeout must be a duration string or seconds")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid timeout %q: %w", s, err)
+	}
+	*d = duration(v)
+	return nil
+}
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (e *fallbackEntry) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*e = parseFallbackSpec(s)
+		return nil
+	}
+	type plain fallbackEntry
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*e = fallbackEntry(p)
+	return nil
+}
+
+func parseFallbackSpec(spec string) fallbackEntry {
+	provider, model, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	return fallbackEntry{Provider: strings.TrimSpace(provider), Model: strings.TrimSpace(model)}
+}
+
+// triggers reports whether an error of the given class moves on to the next
+// entry. An entry without "on" falls back on any error.
+func (e fallbackEntry) triggers(class string) bool {
+	if len(e.On) == 0 {
+		return true
+	}
+	for _, c := range e.On {
+		if strings.EqualFold(strings.TrimSpace(c), class) {
+			return true
+		}
+	}
+	return false
+}
+
+type chainEntry struct {
+	provider Provider
+	entry    fallbackEntry
+}
+
+// ProviderAttempt records a chain entry that failed before another one
+// produced the answer.
+type ProviderAttempt struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Class    string `json:"class,omitempty"`
+	Error    string `json:"error"`
+}
+
+type statusError struct {
+	prefix string
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return e.prefix + ": " + e.status
+}
+
+type parseError struct {
+	err error
+}
+
+func (e *parseError) Error() string { return e.err.Error() }
+func (e *parseError) Unwrap() error { return e.err }
+
+func classifyProviderError(err error) string {
+	if err == nil {
+		return ""
+	}
+	var se *statusError
+	if errors.As(err, &se) {
+		switch {
+		case se.code == 429:
+			return FallbackOn429
+		case se.code >= 500:
+			return FallbackOn5xx
+		}
+		return ""
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return FallbackOnTimeout
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return FallbackOnTimeout
+	}
+	var pe *parseError
+	var syn *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	if errors.As(err, &pe) || errors.As(err, &syn) || errors.As(err, &typ) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return FallbackOnParse
+	}
+	var oe *net.OpError
+	if errors.As(err, &oe) {
+		return FallbackOnConnection
+	}
+	return ""
+}
+
+func looksLikeJSONObject(text string) bool {
+	t := strings.TrimSpace(text)
+	if !strings.HasPrefix(t, "{") {
+		t = findFirstJSONObject(t)
+	}
+	return t != "" && json.Valid([]byte(t))
+}
+
+// askChain tries each entry in order and returns the first answer. Errors
+// whose class the entry does not fall back on stop the chain.
+func askChain(chain []chainEntry, opts AskOptions, call func(context.Context, Provider) (string, error)) (AskResult, error) {
+	parent := opts.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	if opts.Timeout > 0 {
+		var cancelAll context.CancelFunc
+		parent, cancelAll = context.WithTimeout(parent, opts.Timeout)
+		defer cancelAll()
+	}
+	var attempts []ProviderAttempt
+	for i, ce := range chain {
+		p := ce.provider
+		last := i == len(chain)-1
+		ctx, cancel := parent, context.CancelFunc(func() {})
+		if t := time.Duration(ce.entry.Timeout); t > 0 {
+			ctx, cancel = context.WithTimeout(parent, t)
+		}
+		answer, err := call(ctx, p)
+		cancel()
+		if err == nil && opts.JSONMode && !last && !looksLikeJSONObject(answer) {
+			err = &parseError{err: fmt.Errorf("%s returned invalid JSON", p.Name())}
+		}
+		if err == nil {
+			return AskResult{Text: answer, Provider: p.Name(), Model: p.Model(), Attempts: attempts}, nil
+		}
+		class := classifyProviderError(err)
+		attempts = append(attempts, ProviderAttempt{Provider: p.Name(), Model: p.Model(), Class: class, Error: err.Error()})
+		if last || !ce.entry.triggers(class) || parent.Err() != nil {
+			if i > 0 {
+				return AskResult{Attempts: attempts}, fmt.Errorf("%s unavailable and %s fallback failed: %w", chain[0].provider.Name(), p.Name(), err)
+			}
+			return AskResult{Attempts: attempts}, err
+		}
+		slog.Warn("provider failed, falling back", "provider", p.Name(), "class", class, "error", err, "next", chain[i+1].provider.Name())
+	}
+	return AskResult{Attempts: attempts}, fmt.Errorf("no provider configured")
+}
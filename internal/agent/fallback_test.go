@@ -0,0 +1,270 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func askFn(ctx context.Context, p Provider) (string, error) {
+	return p.Ask(ctx, "hi", AskOptions{})
+}
+
+func openAIStub(t *testing.T, handler func(w http.ResponseWriter)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func withFastRetry(t *testing.T) {
+	t.Helper()
+	orig := retryDelay
+	retryDelay = time.Millisecond
+	t.Cleanup(func() { retryDelay = orig })
+}
+
+func TestFallbackEntry_Unmarshal(t *testing.T) {
+	var entries []fallbackEntry
+	data := `["ollama:qwen2.5", {"provider":"openai","model":"gpt-4o-mini","timeout":"30s","on":["429","5xx"]}, {"provider":"anthropic","timeout":5}]`
+	if err := json.Unmarshal([]byte(data), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if entries[0].Provider != "ollama" || entries[0].Model != "qwen2.5" {
+		t.Fatalf("unexpected string entry %+v", entries[0])
+	}
+	if time.Duration(entries[1].Timeout) != 30*time.Second || len(entries[1].On) != 2 {
+		t.Fatalf("unexpected object entry %+v", entries[1])
+	}
+	if time.Duration(entries[2].Timeout) != 5*time.Second {
+		t.Fatalf("expected numeric timeout in seconds, got %v", time.Duration(entries[2].Timeout))
+	}
+	if err := json.Unmarshal([]byte(`[{"provider":"x","timeout":"soon"}]`), &entries); err == nil {
+		t.Fatal("expected error for invalid timeout")
+	}
+}
+
+func TestClassifyProviderError(t *testing.T) {
+	cases := map[string]error{
+		FallbackOn429:   &statusError{prefix: "server error", code: 429, status: "429 Too Many Requests"},
+		FallbackOn5xx:   fmt.Errorf("wrapped: %w", &statusError{code: 503, status: "503"}),
+		FallbackOnParse: &json.SyntaxError{},
+		"":              &statusError{code: 401, status: "401 Unauthorized"},
+	}
+	for want, err := range cases {
+		if got := classifyProviderError(err); got != want {
+			t.Fatalf("classify(%v) = %q, want %q", err, got, want)
+		}
+	}
+	if got := classifyProviderError(context.DeadlineExceeded); got != FallbackOnTimeout {
+		t.Fatalf("expected timeout class, got %q", got)
+	}
+}
+
+func TestAskChain_ConnectionRefusedFallsBack(t *testing.T) {
+	withFastRetry(t)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closedURL := closed.URL
+	closed.Close()
+	up := openAIStub(t, func(w http.ResponseWriter) {
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	})
+
+	chain := []chainEntry{
+		{provider: newOllamaProvider("ollama", endpointConfig{BaseURL: closedURL}), entry: fallbackEntry{On: []string{FallbackOnConnection}}},
+		{provider: newOpenAIProvider("openai", endpointConfig{BaseURL: up.URL, APIKey: "sk"})},
+	}
+	res, err := askChain(chain, AskOptions{}, askFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Provider != "openai" || len(res.Attempts) != 1 || res.Attempts[0].Class != FallbackOnConnection {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func TestAskChain_ClassNotListedStops(t *testing.T) {
+	withFastRetry(t)
+	failing := openAIStub(t, func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) })
+	called := false
+	up := openAIStub(t, func(w http.ResponseWriter) {
+		called = true
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	})
+
+	chain := []chainEntry{
+		{provider: newOpenAIProvider("primary", endpointConfig{BaseURL: failing.URL}), entry: fallbackEntry{On: []string{FallbackOn429}}},
+		{provider: newOpenAIProvider("secondary", endpointConfig{BaseURL: up.URL})},
+	}
+	if _, err := askChain(chain, AskOptions{}, askFn); err == nil {
+		t.Fatal("expected 5xx to stop a chain that only falls back on 429")
+	}
+	if called {
+		t.Fatal("secondary provider should not be called")
+	}
+}
+
+func TestAskChain_TimeoutFallsBack(t *testing.T) {
+	slow := openAIStub(t, func(w http.ResponseWriter) {
+		time.Sleep(300 * time.Millisecond)
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"late"}}]}`)
+	})
+	fast := openAIStub(t, func(w http.ResponseWriter) {
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"fast"}}]}`)
+	})
+
+	chain := []chainEntry{
+		{provider: newOpenAIProvider("slow", endpointConfig{BaseURL: slow.URL}), entry: fallbackEntry{Timeout: duration(50 * time.Millisecond)}},
+		{provider: newOpenAIProvider("fast", endpointConfig{BaseURL: fast.URL})},
+	}
+	res, err := askChain(chain, AskOptions{}, askFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text != "fast" || res.Attempts[0].Class != FallbackOnTimeout {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func TestAskChain_CanceledContextStops(t *testing.T) {
+	called := false
+	up := openAIStub(t, func(w http.ResponseWriter) {
+		called = true
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	chain := []chainEntry{
+		{provider: newOpenAIProvider("primary", endpointConfig{BaseURL: up.URL}), entry: fallbackEntry{On: []string{FallbackOnTimeout, FallbackOnConnection}}},
+		{provider: newOpenAIProvider("secondary", endpointConfig{BaseURL: up.URL})},
+	}
+	_, err := askChain(chain, AskOptions{Context: ctx}, askFn)
+	if err == nil || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a canceled error, got %v", err)
+	}
+	if called {
+		t.Fatal("a canceled ask must not reach any provider")
+	}
+}
+
+func TestDecide_CancelInterruptsRequest(t *testing.T) {
+	started := make(chan struct{}, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		started <- struct{}{}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	decide := map[string]func(AskOptions) error{
+		"json": func(opts AskOptions) error {
+			_, err := DecideWithPluginsStream("status?", "", "", opts, "", func(string) {})
+			return err
+		},
+		"tools": func(opts AskOptions) error {
+			_, err := DecideWithTools("status?", nil, opts, "")
+			return err
+		},
+	}
+	for name, fn := range decide {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			done <- fn(AskOptions{Provider: "openai", BaseURL: srv.URL, APIKey: "sk-test", Model: "m", Context: ctx})
+		}()
+		<-started
+		start := time.Now()
+		cancel()
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("%s: expected a canceled error, got %v", name, err)
+			}
+			if time.Since(start) > 2*time.Second {
+				t.Fatalf("%s: cancel took %s", name, time.Since(start))
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("%s: the decision call ignored the canceled context", name)
+		}
+	}
+}
+
+func TestAskChain_InvalidJSONFallsBackInJSONMode(t *testing.T) {
+	prose := openAIStub(t, func(w http.ResponseWriter) {
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"sure, here you go"}}]}`)
+	})
+	good := openAIStub(t, func(w http.ResponseWriter) {
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"{\"action\":\"answer\"}"}}]}`)
+	})
+
+	chain := []chainEntry{
+		{provider: newOpenAIProvider("prose", endpointConfig{BaseURL: prose.URL})},
+		{provider: newOpenAIProvider("good", endpointConfig{BaseURL: good.URL})},
+	}
+	opts := AskOptions{JSONMode: true}
+	res, err := askChain(chain, opts, func(ctx context.Context, p Provider) (string, error) {
+		return p.Ask(ctx, "hi", opts)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Provider != "good" || res.Attempts[0].Class != FallbackOnParse {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func TestProviderChain_UsesConfiguredFallback(t *testing.T) {
+	cfg := userConfig{Fallback: []fallbackEntry{{Provider: "openai", Model: "gpt-4o-mini"}, {Provider: "ollama", Model: "qwen2.5"}}}
+	chain, err := providerChain(cfg, AskOptions{Provider: "auto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 2 || chain[0].provider.Name() != "openai" || chain[1].provider.Model() != "qwen2.5" {
+		t.Fatalf("unexpected chain %+v", chain)
+	}
+}
+
+func TestStreamChain_FallbackResetsTokens(t *testing.T) {
+	sse := func(tokens ...string) func(http.ResponseWriter) {
+		return func(w http.ResponseWriter) {
+			for _, tok := range tokens {
+				data, _ := json.Marshal(map[string]any{"choices": []any{map[string]any{"delta": map[string]string{"content": tok}}}})
+				fmt.Fprintf(w, "data: %s\n\n", data)
+			}
+		}
+	}
+	// The primary's stream breaks off mid-object, which the JSON check catches.
+	cut := openAIStub(t, sse(`{"action":"answer",`, `"answer":"Disk is fu`))
+	good := openAIStub(t, sse(`{"action":"answer",`, `"answer":"All good"}`))
+	chain := []chainEntry{
+		{provider: newOpenAIProvider("cut", endpointConfig{BaseURL: cut.URL})},
+		{provider: newOpenAIProvider("good", endpointConfig{BaseURL: good.URL})},
+	}
+
+	var got []string
+	res, err := streamChain(chain, "hi", AskOptions{JSONMode: true}, func(token string) {
+		got = append(got, token)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Provider != "good" || len(res.Attempts) != 1 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	want := []string{`{"action":"answer",`, `"answer":"Disk is fu`, StreamReset, `{"action":"answer",`, `"answer":"All good"}`}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("tokens = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,40 @@
+package agent
+
+import "time"
+
+type limitsConfig struct {
+	MaxSteps       int      `json:"max_steps,omitempty"`
+	StepTimeout    duration `json:"step_timeout,omitempty"`
+	SessionTimeout duration `json:"session_timeout,omitempty"`
+	SessionTokens  int      `json:"session_tokens,omitempty"`
+	PromptTokens   int      `json:"prompt_tokens,omitempty"`
+	CatalogTokens  int      `json:"catalog_tokens,omitempty"`
+	DecisionTokens int      `json:"decision_tokens,omitempty"`
+}
+
+// Limits are the agent loop limits from the "limits" config section. Zero
+// values mean "not configured".
+type Limits struct {
+	MaxSteps       int
+	StepTimeout    time.Duration
+	SessionTimeout time.Duration
+	SessionTokens  int
+	PromptTokens   int
+	CatalogTokens  int
+	DecisionTokens int
+}
+
+// ConfiguredLimits returns the loop limits set in the agent config.
+func ConfiguredLimits() Limits {
+	cfg, _ := cachedUserConfig()
+	l := cfg.Limits
+	return Limits{
+		MaxSteps:       l.MaxSteps,
+		StepTimeout:    time.Duration(l.StepTimeout),
+		SessionTimeout: time.Duration(l.SessionTimeout),
+		SessionTokens:  l.SessionTokens,
+		PromptTokens:   l.PromptTokens,
+		CatalogTokens:  l.CatalogTokens,
+		DecisionTokens: l.DecisionTokens,
+	}
+}
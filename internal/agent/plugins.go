@@ -0,0 +1,48 @@
+package agent
+
+import "strings"
+
+type pluginsConfig struct {
+	PersistentHost bool   `json:"persistent_host,omitempty"`
+	OutputLimit    int    `json:"output_limit,omitempty"`
+	OutputPane     string `json:"output_pane,omitempty"`
+	PaneLines      int    `json:"pane_lines,omitempty"`
+}
+
+// Plugin output pane modes for "plugins.output_pane".
+const (
+	OutputPaneCollapse = "collapse"
+	OutputPaneExpand   = "expand"
+	OutputPaneOff      = "off"
+)
+
+// PluginOutputSettings is how agent plugin runs show and keep output.
+// OutputLimit is in bytes (0 means the plugins default); Pane is one of
+// the OutputPane modes.
+type PluginOutputSettings struct {
+	OutputLimit int
+	Pane        string
+	PaneLines   int
+}
+
+// ConfiguredPersistentHost reports whether "plugins.persistent_host" asks
+// for a long-lived pwsh worker for agent plugin calls.
+func ConfiguredPersistentHost() bool {
+	cfg, _ := cachedUserConfig()
+	return cfg.Plugins.PersistentHost
+}
+
+// ConfiguredPluginOutput returns the "plugins" output settings with
+// defaults filled in: a collapsing pane of 8 lines.
+func ConfiguredPluginOutput() PluginOutputSettings {
+	cfg, _ := cachedUserConfig()
+	s := PluginOutputSettings{OutputLimit: cfg.Plugins.OutputLimit, Pane: OutputPaneCollapse, PaneLines: 8}
+	switch mode := strings.ToLower(strings.TrimSpace(cfg.Plugins.OutputPane)); mode {
+	case OutputPaneExpand, OutputPaneOff:
+		s.Pane = mode
+	}
+	if cfg.Plugins.PaneLines > 0 {
+		s.PaneLines = cfg.Plugins.PaneLines
+	}
+	return s
+}
@@ -0,0 +1,313 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Decision protocols selectable with --protocol.
+const (
+	ProtocolJSON  = "json"
+	ProtocolTools = "tools"
+)
+
+// Kinds of functions offered to the model in tools mode.
+const (
+	FunctionPlugin = "plugin"
+	FunctionTool   = "tool"
+)
+
+const (
+	toolFunctionPrefix = "tool_"
+	createFunctionName = "create_function"
+)
+
+var functionNameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// FunctionParam is one argument of a function offered to the model.
+type FunctionParam struct {
+	Name        string
+	Type        string // string|integer|number|boolean
+	Description string
+	Required    bool
+	Enum        []string
+}
+
+// FunctionSpec describes a plugin or built-in tool as a callable function
+// for providers with native function calling.
+type FunctionSpec struct {
+	Kind        string
+	Name        string
+	Description string
+	Params      []FunctionParam
+}
+
+// ToolCall is a function call returned by the model.
+type ToolCall struct {
+	Name      string
+	Arguments map[string]any
+}
+
+type toolReply struct {
+	Text  string
+	Calls []ToolCall
+}
+
+// toolCaller is implemented by providers that support native function
+// calling (OpenAI-compatible and Ollama chat APIs).
+type toolCaller interface {
+	AskTools(ctx context.Context, prompt string, opts AskOptions, defs []map[string]any) (toolReply, error)
+}
+
+// Protocols lists the accepted --protocol values.
+func Protocols() []string {
+	return []string{ProtocolJSON, ProtocolTools}
+}
+
+func functionCallName(spec FunctionSpec) string {
+	name := functionNameUnsafe.ReplaceAllString(spec.Name, "_")
+	if spec.Kind == FunctionTool {
+		name = toolFunctionPrefix + name
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+func functionSchema(params []FunctionParam) map[string]any {
+	props := map[string]any{}
+	required := []string{}
+	for _, p := range params {
+		typ := p.Type
+		if typ == "" {
+			typ = "string"
+		}
+		prop := map[string]any{"type": typ}
+		if p.Description != "" {
+			prop["description"] = p.Description
+		}
+		if len(p.Enum) > 0 {
+			prop["enum"] = p.Enum
+		}
+		props[p.Name] = prop
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+	return map[string]any{"type": "object", "properties": props, "required": required}
+}
+
+// toolDefinitions renders specs as OpenAI-style tools[] entries (Ollama
+// accepts the same shape) plus the create_function meta function. The
+// returned map resolves call names back to their spec.
+func toolDefinitions(specs []FunctionSpec) ([]map[string]any, map[string]FunctionSpec) {
+	defs := make([]map[string]any, 0, len(specs)+1)
+	byName := make(map[string]FunctionSpec, len(specs))
+	for _, spec := range specs {
+		name := functionCallName(spec)
+		if _, dup := byName[name]; dup {
+			continue
+		}
+		byName[name] = spec
+		defs = append(defs, map[string]any{
+			"type": "function",
+			"function": map[string]any{
+				"name":        name,
+				"description": spec.Description,
+				"parameters":  functionSchema(spec.Params),
+			},
+		})
+	}
+	defs = append(defs, map[string]any{
+		"type": "function",
+		"function": map[string]any{
+			"name":        createFunctionName,
+			"description": "Propose a new PowerShell function when no existing function can handle the request.",
+			"parameters": functionSchema([]FunctionParam{
+				{Name: "function_description", Description: "what the function should do, its inputs and outputs", Required: true},
+				{Name: "reason", Description: "why no existing function fits", Required: true},
+			}),
+		},
+	})
+	return defs, byName
+}
+
+// parseToolArguments accepts arguments as a JSON object (Ollama) or as a
+// string holding a JSON object (OpenAI).
+func parseToolArguments(raw json.RawMessage) (map[string]any, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return map[string]any{}, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if strings.TrimSpace(s) == "" {
+			return map[string]any{}, nil
+		}
+		raw = json.RawMessage(s)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, &parseError{err: fmt.Errorf("invalid tool call arguments: %w", err)}
+	}
+	return out, nil
+}
+
+func toolArgString(v any) string {
+	switch tv := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return tv
+	case bool:
+		return strconv.FormatBool(tv)
+	case float64:
+		return strconv.FormatFloat(tv, 'f', -1, 64)
+	case []any:
+		parts := make([]string, 0, len(tv))
+		for _, item := range tv {
+			parts = append(parts, toolArgString(item))
+		}
+		return strings.Join(parts, ",")
+	default:
+		data, _ := json.Marshal(tv)
+		return string(data)
+	}
+}
+
+func stringArgs(args map[string]any) map[string]string {
+	out := make(map[string]string, len(args))
+	for k, v := range args {
+		out[k] = toolArgString(v)
+	}
+	return out
+}
+
+// decisionFromToolReply maps a function-calling reply onto the same
+// DecisionResult the JSON protocol produces. Several run calls in one reply
+// become a "multi" decision.
+func decisionFromToolReply(reply toolReply, byName map[string]FunctionSpec) DecisionResult {
+	text := strings.TrimSpace(reply.Text)
+	if len(reply.Calls) == 0 {
+		return DecisionResult{Action: "answer", Answer: text}
+	}
+	if len(reply.Calls) > 1 {
+		var actions []DecisionResult
+		for _, call := range reply.Calls {
+			if d := decisionFromToolCall(call, byName, text); d.Action != "create_function" {
+				actions = append(actions, d)
+			}
+		}
+		if len(actions) > 1 {
+			return DecisionResult{Action: "multi", Actions: actions, Reason: text}
+		}
+	}
+	return decisionFromToolCall(reply.Calls[0], byName, text)
+}
+
+func decisionFromToolCall(call ToolCall, byName map[string]FunctionSpec, reason string) DecisionResult {
+	if call.Name == createFunctionName {
+		return DecisionResult{
+			Action:              "create_function",
+			FunctionDescription: toolArgString(call.Arguments["function_description"]),
+			Reason:              toolArgString(call.Arguments["reason"]),
+		}
+	}
+	spec, ok := byName[call.Name]
+	if !ok {
+		spec = FunctionSpec{Kind: FunctionPlugin, Name: call.Name}
+		if strings.HasPrefix(call.Name, toolFunctionPrefix) {
+			spec = FunctionSpec{Kind: FunctionTool, Name: strings.TrimPrefix(call.Name, toolFunctionPrefix)}
+		}
+	}
+	if spec.Kind == FunctionTool {
+		return DecisionResult{Action: "run_tool", Tool: spec.Name, ToolArgs: stringArgs(call.Arguments), Reason: reason}
+	}
+	return DecisionResult{Action: "run_plugin", Plugin: spec.Name, PluginArgs: stringArgs(call.Arguments), Reason: reason}
+}
+
+func renderToolsSystemPrompt(cfg userConfig, opts AskOptions) string {
+	preamble := decisionPreamble
+	custom, mode := configSystemPrompt(cfg)
+	if custom != "" && mode == SystemPromptReplace {
+		preamble = custom
+	}
+	parts := []string{
+		preamble,
+		"Act by calling one of the provided functions, or reply with plain text to answer directly.",
+		"You may call several functions at once only when they are independent and read-only; they may run concurrently.",
+		"Functions named tool_* are built-in tools; the others are PowerShell plugins.",
+		"",
+		"Decision process (follow in order):",
+		"1. Identify the user's INTENT: what do they want to accomplish?",
+		"2. Pick the function whose name and description best match the intent.",
+		"3. Fill ALL required parameters from the user request. If one cannot be inferred, reply in text and ask the user.",
+		"4. Use enum values exactly as listed; leave optional parameters out unless the user specified them.",
+		"5. If a previous step failed with 'missing mandatory parameters', the NEXT call MUST include those parameters.",
+		"6. If no function fits, answer knowledge questions in text or call create_function for new automation.",
+		"",
+		"General rules:",
+		"- Do not invent function names; use only the functions provided.",
+		"- Only use create_function for tasks that genuinely need a new automation capability.",
+		"- Explain briefly in your text reply why you chose the function.",
+	}
+	if custom != "" && mode != SystemPromptReplace {
+		parts = append(parts, "", "Team instructions (follow these over the defaults above):", custom)
+	}
+	if notes := strings.TrimSpace(opts.ScopeNotes); notes != "" {
+		parts = append(parts, "", "Domain notes for the toolkits in scope:", notes)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// DecideWithTools plans the next step with native function calling instead
+// of the JSON prompt protocol. Providers without function calling fail with
+// an error, which lets a fallback chain move on.
+func DecideWithTools(userPrompt string, specs []FunctionSpec, opts AskOptions, envContext string) (DecisionResult, error) {
+	p := strings.TrimSpace(userPrompt)
+	if p == "" {
+		return DecisionResult{}, fmt.Errorf("prompt is required")
+	}
+	cfg, _ := cachedUserConfig()
+	dOpts := decisionOpts(opts, renderToolsSystemPrompt(cfg, opts))
+	dOpts.JSONMode = false
+	dOpts, err := applyProfile(cfg, dOpts)
+	if err != nil {
+		return DecisionResult{}, err
+	}
+	chain, err := providerChain(cfg, dOpts)
+	if err != nil {
+		return DecisionResult{}, err
+	}
+
+	defs, byName := toolDefinitions(specs)
+	userMsg := buildDecisionUserPrompt(p, envContext)
+	var reply toolReply
+	raw, err := askChain(chain, dOpts, func(ctx context.Context, prov Provider) (string, error) {
+		tc, ok := prov.(toolCaller)
+		if !ok {
+			return "", fmt.Errorf("%s does not support --protocol tools", prov.Name())
+		}
+		r, err := tc.AskTools(ctx, userMsg, dOpts, defs)
+		if err != nil {
+			return "", err
+		}
+		if len(r.Calls) == 0 && strings.TrimSpace(r.Text) == "" {
+			return "", &parseError{err: fmt.Errorf("empty %s response", prov.Name())}
+		}
+		reply = r
+		return r.Text, nil
+	})
+	if err != nil {
+		return DecisionResult{Attempts: raw.Attempts}, err
+	}
+	decision := decisionFromToolReply(reply, byName)
+	decision.Provider = raw.Provider
+	decision.Model = raw.Model
+	decision.Attempts = raw.Attempts
+	return decision, nil
+}
@@ -0,0 +1,126 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func testFunctionSpecs() []FunctionSpec {
+	return []FunctionSpec{
+		{Kind: FunctionPlugin, Name: "stibs_db_query", Description: "Run a query", Params: []FunctionParam{
+			{Name: "Query", Type: "string", Required: true},
+			{Name: "Format", Type: "string", Enum: []string{"table", "json"}},
+			{Name: "Raw", Type: "boolean"},
+		}},
+		{Kind: FunctionTool, Name: "search", Description: "Search files", Params: []FunctionParam{{Name: "ext"}}},
+	}
+}
+
+func TestToolDefinitions_Schema(t *testing.T) {
+	defs, byName := toolDefinitions(testFunctionSpecs())
+	if len(defs) != 3 {
+		t.Fatalf("expected 2 functions plus create_function, got %d", len(defs))
+	}
+	fn := defs[0]["function"].(map[string]any)
+	if fn["name"] != "stibs_db_query" {
+		t.Fatalf("unexpected name %v", fn["name"])
+	}
+	params := fn["parameters"].(map[string]any)
+	if !reflect.DeepEqual(params["required"], []string{"Query"}) {
+		t.Fatalf("unexpected required %v", params["required"])
+	}
+	format := params["properties"].(map[string]any)["Format"].(map[string]any)
+	if !reflect.DeepEqual(format["enum"], []string{"table", "json"}) {
+		t.Fatalf("unexpected enum %v", format["enum"])
+	}
+	if _, ok := byName["tool_search"]; !ok {
+		t.Fatalf("expected tools to be prefixed, got %v", byName)
+	}
+}
+
+func TestDecisionFromToolReply(t *testing.T) {
+	_, byName := toolDefinitions(testFunctionSpecs())
+	d := decisionFromToolReply(toolReply{Text: "querying", Calls: []ToolCall{{Name: "stibs_db_query", Arguments: map[string]any{"Query": "select 1", "Raw": true}}}}, byName)
+	if d.Action != "run_plugin" || d.Plugin != "stibs_db_query" || d.Reason != "querying" {
+		t.Fatalf("unexpected decision %+v", d)
+	}
+	if !reflect.DeepEqual(d.PluginArgs, map[string]string{"Query": "select 1", "Raw": "true"}) {
+		t.Fatalf("unexpected args %v", d.PluginArgs)
+	}
+	d = decisionFromToolReply(toolReply{Calls: []ToolCall{{Name: "tool_search", Arguments: map[string]any{"limit": float64(5)}}}}, byName)
+	if d.Action != "run_tool" || d.Tool != "search" || d.ToolArgs["limit"] != "5" {
+		t.Fatalf("unexpected tool decision %+v", d)
+	}
+	d = decisionFromToolReply(toolReply{Calls: []ToolCall{{Name: "create_function", Arguments: map[string]any{"function_description": "x", "reason": "y"}}}}, byName)
+	if d.Action != "create_function" || d.FunctionDescription != "x" || d.Reason != "y" {
+		t.Fatalf("unexpected create_function decision %+v", d)
+	}
+	d = decisionFromToolReply(toolReply{Text: "just text"}, byName)
+	if d.Action != "answer" || d.Answer != "just text" {
+		t.Fatalf("unexpected answer decision %+v", d)
+	}
+}
+
+func TestParseToolArguments(t *testing.T) {
+	for _, raw := range []string{`"{\"a\":\"b\"}"`, `{"a":"b"}`} {
+		got, err := parseToolArguments(json.RawMessage(raw))
+		if err != nil || got["a"] != "b" {
+			t.Fatalf("parse %s = %v, %v", raw, got, err)
+		}
+	}
+	if _, err := parseToolArguments(json.RawMessage(`"{broken"`)); classifyProviderError(err) != FallbackOnParse {
+		t.Fatalf("expected parse error, got %v", err)
+	}
+}
+
+func TestOpenAIProvider_AskTools(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if tools, _ := body["tools"].([]any); len(tools) != 3 || body["tool_choice"] != "auto" {
+			t.Errorf("unexpected tools in request: %v", body["tools"])
+		}
+		if _, ok := body["response_format"]; ok {
+			t.Error("tools mode must not request JSON output")
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"content":null,"tool_calls":[{"type":"function","function":{"name":"stibs_db_query","arguments":"{\"Query\":\"show tables\"}"}}]}}]}`)
+	}))
+	defer srv.Close()
+
+	p := newOpenAIProvider("lmstudio", endpointConfig{BaseURL: srv.URL}).(*openAIProvider)
+	defs, _ := toolDefinitions(testFunctionSpecs())
+	reply, err := p.AskTools(context.Background(), "list tables", AskOptions{}, defs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reply.Calls) != 1 || reply.Calls[0].Name != "stibs_db_query" || reply.Calls[0].Arguments["Query"] != "show tables" {
+		t.Fatalf("unexpected reply %+v", reply)
+	}
+}
+
+func TestOllamaProvider_AskTools(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if _, ok := body["tools"]; !ok || body["stream"] != false {
+			t.Errorf("unexpected request %v", body)
+		}
+		fmt.Fprint(w, `{"message":{"content":"","tool_calls":[{"function":{"name":"tool_search","arguments":{"ext":"pdf"}}}]}}`)
+	}))
+	defer srv.Close()
+
+	p := newOllamaProvider("ollama", endpointConfig{BaseURL: srv.URL, Model: "qwen2.5"}).(*ollamaProvider)
+	defs, _ := toolDefinitions(testFunctionSpecs())
+	reply, err := p.AskTools(context.Background(), "find pdfs", AskOptions{}, defs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reply.Calls) != 1 || reply.Calls[0].Arguments["ext"] != "pdf" {
+		t.Fatalf("unexpected reply %+v", reply)
+	}
+}
@@ -0,0 +1,238 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Provider is a chat backend the agent can talk to. Backends register a
+// factory for their endpoint type with registerProviderType.
+type Provider interface {
+	Name() string
+	Model() string
+	BaseURL() string
+	Ask(ctx context.Context, prompt string, opts AskOptions) (string, error)
+	Stream(ctx context.Context, prompt string, opts AskOptions, onToken TokenCallback) (string, error)
+	Ping() error
+}
+
+type providerFactory func(name string, ep endpointConfig) Provider
+
+type endpointConfig struct {
+	Type      string            `json:"type"`
+	BaseURL   string            `json:"base_url,omitempty"`
+	Model     string            `json:"model,omitempty"`
+	APIKey    string            `json:"api_key,omitempty"`
+	APIKeyEnv string            `json:"api_key_env,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Version   string            `json:"version,omitempty"`
+}
+
+var (
+	providerTypes = map[string]providerFactory{}
+	pingClient    = &http.Client{Timeout: 3 * time.Second}
+)
+
+func registerProviderType(kind string, f providerFactory) {
+	providerTypes[kind] = f
+}
+
+// ProviderTypes lists the registered backend types usable in "endpoints".
+func ProviderTypes() []string {
+	out := make([]string, 0, len(providerTypes))
+	for k := range providerTypes {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ProviderNames lists the provider names accepted by --provider: the
+// built-in backends, "auto" and every named endpoint from the config.
+func ProviderNames() []string {
+	cfg, _ := cachedUserConfig()
+	names := []string{"openai", "ollama", "anthropic", "auto"}
+	extra := make([]string, 0, len(cfg.Endpoints))
+	for n := range cfg.Endpoints {
+		if !isBuiltinProvider(n) {
+			extra = append(extra, n)
+		}
+	}
+	sort.Strings(extra)
+	return append(names, extra...)
+}
+
+// NewProvider returns the backend registered under name ("ollama", "openai",
+// "anthropic" or a named endpoint) configured from the agent config.
+func NewProvider(name string) (Provider, error) {
+	cfg, err := cachedUserConfig()
+	if err != nil {
+		return nil, err
+	}
+	return newProviderFromConfig(cfg, name, AskOptions{})
+}
+
+// ResolveProviders applies the selected profile and returns the providers
+// opts resolves to, in the order they are tried.
+func ResolveProviders(opts AskOptions) ([]Provider, error) {
+	cfg, err := cachedUserConfig()
+	if err != nil {
+		return nil, err
+	}
+	opts, err = applyProfile(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := providerChain(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Provider, len(chain))
+	for i, ce := range chain {
+		out[i] = ce.provider
+	}
+	return out, nil
+}
+
+func isBuiltinProvider(name string) bool {
+	switch name {
+	case "ollama", "openai", "anthropic":
+		return true
+	}
+	return false
+}
+
+func providerChain(cfg userConfig, opts AskOptions) ([]chainEntry, error) {
+	name := strings.ToLower(strings.TrimSpace(opts.Provider))
+	if name == "" {
+		name = "openai"
+	}
+	entries := []fallbackEntry{{Provider: name}}
+	if name == "auto" {
+		entries = cfg.Fallback
+		if len(entries) == 0 {
+			entries = []fallbackEntry{{Provider: "ollama"}, {Provider: "openai"}}
+		}
+	}
+	chain := make([]chainEntry, 0, len(entries))
+	for _, e := range entries {
+		entryOpts := opts
+		if strings.TrimSpace(entryOpts.Model) == "" {
+			entryOpts.Model = e.Model
+		}
+		p, err := newProviderFromConfig(cfg, strings.ToLower(strings.TrimSpace(e.Provider)), entryOpts)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, chainEntry{provider: p, entry: e})
+	}
+	return chain, nil
+}
+
+func newProviderFromConfig(cfg userConfig, name string, opts AskOptions) (Provider, error) {
+	ep, ok := endpointFor(cfg, name)
+	if !ok {
+		return nil, fmt.Errorf("invalid provider %q (use auto|ollama|openai|anthropic or a name from endpoints)", name)
+	}
+	factory, ok := providerTypes[strings.ToLower(strings.TrimSpace(ep.Type))]
+	if !ok {
+		return nil, fmt.Errorf("provider %q has unknown type %q (use %s)", name, ep.Type, strings.Join(ProviderTypes(), "|"))
+	}
+	if v := strings.TrimSpace(opts.Model); v != "" {
+		ep.Model = v
+	}
+	if v := strings.TrimSpace(opts.BaseURL); v != "" {
+		ep.BaseURL = v
+	}
+	if v := strings.TrimSpace(opts.APIKey); v != "" {
+		ep.APIKey = v
+	}
+	return factory(name, ep), nil
+}
+
+func endpointFor(cfg userConfig, name string) (endpointConfig, bool) {
+	switch name {
+	case "ollama":
+		return endpointConfig{Type: "ollama", BaseURL: cfg.Ollama.BaseURL, Model: cfg.Ollama.Model}, true
+	case "openai":
+		return endpointConfig{
+			Type:      "openai",
+			BaseURL:   cfg.OpenAI.BaseURL,
+			Model:     cfg.OpenAI.Model,
+			APIKey:    cfg.OpenAI.APIKey,
+			APIKeyEnv: "OPENAI_API_KEY",
+		}, true
+	case "anthropic":
+		return endpointConfig{
+			Type:      "anthropic",
+			BaseURL:   cfg.Anthropic.BaseURL,
+			Model:     cfg.Anthropic.Model,
+			APIKey:    cfg.Anthropic.APIKey,
+			APIKeyEnv: "ANTHROPIC_API_KEY",
+			Version:   cfg.Anthropic.Version,
+		}, true
+	}
+	ep, ok := cfg.Endpoints[name]
+	return ep, ok
+}
+
+func (ep endpointConfig) resolvedKey() string {
+	if k := strings.TrimSpace(ep.APIKey); k != "" {
+		return k
+	}
+	if env := strings.TrimSpace(ep.APIKeyEnv); env != "" {
+		return strings.TrimSpace(os.Getenv(env))
+	}
+	return ""
+}
+
+func (ep endpointConfig) baseURLOr(def string) string {
+	u := strings.TrimRight(strings.TrimSpace(ep.BaseURL), "/")
+	if u == "" {
+		return def
+	}
+	return u
+}
+
+func (ep endpointConfig) modelOr(def string) string {
+	m := strings.TrimSpace(ep.Model)
+	if m == "" {
+		return def
+	}
+	return m
+}
+
+func systemMessage(opts AskOptions) string {
+	if strings.TrimSpace(opts.SystemPrompt) != "" {
+		return opts.SystemPrompt
+	}
+	return "You are a pragmatic coding assistant."
+}
+
+func setHeaders(req *http.Request, headers map[string]string) {
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+}
+
+func pingURL(u string, headers map[string]string) error {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	setHeaders(req, headers)
+	res, err := pingClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("status %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultAnthropicBaseURL   = "https://api.anthropic.com/v1"
+	defaultAnthropicModel     = "claude-3-5-haiku-latest"
+	defaultAnthropicVersion   = "2023-06-01"
+	defaultAnthropicMaxTokens = 4096
+)
+
+func init() {
+	registerProviderType("anthropic", newAnthropicProvider)
+}
+
+// anthropicProvider speaks the Messages API. It has no JSON response mode,
+// so JSONMode is requested through the system prompt instead.
+type anthropicProvider struct {
+	name    string
+	baseURL string
+	model   string
+	apiKey  string
+	version string
+	headers map[string]string
+}
+
+func newAnthropicProvider(name string, ep endpointConfig) Provider {
+	version := strings.TrimSpace(ep.Version)
+	if version == "" {
+		version = defaultAnthropicVersion
+	}
+	return &anthropicProvider{
+		name:    name,
+		baseURL: ep.baseURLOr(defaultAnthropicBaseURL),
+		model:   ep.modelOr(defaultAnthropicModel),
+		apiKey:  ep.resolvedKey(),
+		version: version,
+		headers: ep.Headers,
+	}
+}
+
+func (p *anthropicProvider) Name() string    { return p.name }
+func (p *anthropicProvider) Model() string   { return p.model }
+func (p *anthropicProvider) BaseURL() string { return p.baseURL }
+
+func (p *anthropicProvider) requestHeaders() (map[string]string, error) {
+	if p.apiKey == "" {
+		return nil, fmt.Errorf("missing Anthropic API key (set %s.api_key in %s or ANTHROPIC_API_KEY)", p.name, configPath())
+	}
+	h := map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": p.version,
+	}
+	for k, v := range p.headers {
+		h[k] = v
+	}
+	return h, nil
+}
+
+func (p *anthropicProvider) Ping() error {
+	headers, err := p.requestHeaders()
+	if err != nil {
+		return err
+	}
+	return pingURL(p.baseURL+"/models", headers)
+}
+
+func (p *anthropicProvider) request(ctx context.Context, prompt string, opts AskOptions, stream bool) (*http.Response, error) {
+	headers, err := p.requestHeaders()
+	if err != nil {
+		return nil, err
+	}
+	system := systemMessage(opts)
+	if opts.JSONMode {
+		system += "\n\nRespond with a single JSON object and nothing else."
+	}
+	maxTokens := opts.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultAnthropicMaxTokens
+	}
+	reqBody := map[string]any{
+		"model":      p.model,
+		"system":     system,
+		"max_tokens": maxTokens,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+	}
+	if stream {
+		reqBody["stream"] = true
+	}
+	if opts.Temperature != nil {
+		reqBody["temperature"] = *opts.Temperature
+	}
+	raw, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+	res, err := doWithRetry(func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/messages", bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		setHeaders(req, headers)
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		res.Body.Close()
+		return nil, &statusError{prefix: p.name + " status", code: res.StatusCode, status: res.Status}
+	}
+	return res, nil
+}
+
+func (p *anthropicProvider) Ask(ctx context.Context, prompt string, opts AskOptions) (string, error) {
+	slog.Debug("LLM request", "provider", p.name, "model", p.model, "prompt_chars", len(prompt))
+	res, err := p.request(ctx, prompt, opts, false)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var parsed struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	for _, c := range parsed.Content {
+		if c.Type == "text" {
+			buf.WriteString(c.Text)
+		}
+	}
+	answer := strings.TrimSpace(buf.String())
+	if answer == "" {
+		return "", fmt.Errorf("empty %s response", p.name)
+	}
+	return answer, nil
+}
+
+func (p *anthropicProvider) Stream(ctx context.Context, prompt string, opts AskOptions, onToken TokenCallback) (string, error) {
+	slog.Debug("LLM stream request", "provider", p.name, "model", p.model, "prompt_chars", len(prompt))
+	res, err := p.request(ctx, prompt, opts, true)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var buf strings.Builder
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var event struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			continue
+		}
+		if event.Type == "message_stop" {
+			break
+		}
+		if event.Type != "content_block_delta" || event.Delta.Type != "text_delta" || event.Delta.Text == "" {
+			continue
+		}
+		buf.WriteString(event.Delta.Text)
+		if onToken != nil {
+			onToken(event.Delta.Text)
+		}
+	}
+
+	answer := strings.TrimSpace(buf.String())
+	if answer == "" {
+		return "", fmt.Errorf("empty %s stream response", p.name)
+	}
+	return answer, nil
+}
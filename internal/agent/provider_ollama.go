@@ -0,0 +1,175 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	registerProviderType("ollama", newOllamaProvider)
+}
+
+type ollamaProvider struct {
+	name    string
+	baseURL string
+	model   string
+	headers map[string]string
+}
+
+func newOllamaProvider(name string, ep endpointConfig) Provider {
+	baseURL, model := normalizedOllamaValues(ollamaConfig{BaseURL: ep.BaseURL, Model: ep.Model})
+	return &ollamaProvider{name: name, baseURL: baseURL, model: model, headers: ep.Headers}
+}
+
+func (p *ollamaProvider) Name() string    { return p.name }
+func (p *ollamaProvider) Model() string   { return p.model }
+func (p *ollamaProvider) BaseURL() string { return p.baseURL }
+
+func (p *ollamaProvider) Ping() error {
+	return pingURL(p.baseURL+"/api/tags", p.headers)
+}
+
+func (p *ollamaProvider) request(ctx context.Context, prompt string, opts AskOptions, stream bool, tools []map[string]any) (*http.Response, error) {
+	reqBody := map[string]any{
+		"model": p.model,
+		"messages": []map[string]string{
+			{"role": "system", "content": systemMessage(opts)},
+			{"role": "user", "content": prompt},
+		},
+		"stream": stream,
+	}
+	if opts.JSONMode {
+		reqBody["format"] = "json"
+	}
+	if len(tools) > 0 {
+		reqBody["tools"] = tools
+	}
+	ollamaOpts := map[string]any{}
+	if opts.Temperature != nil {
+		ollamaOpts["temperature"] = *opts.Temperature
+	}
+	if opts.MaxTokens > 0 {
+		ollamaOpts["num_predict"] = opts.MaxTokens
+	}
+	if len(ollamaOpts) > 0 {
+		reqBody["options"] = ollamaOpts
+	}
+	raw, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+	res, err := doWithRetry(func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/api/chat", bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		setHeaders(req, p.headers)
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		res.Body.Close()
+		return nil, &statusError{prefix: p.name + " status", code: res.StatusCode, status: res.Status}
+	}
+	return res, nil
+}
+
+func (p *ollamaProvider) Ask(ctx context.Context, prompt string, opts AskOptions) (string, error) {
+	slog.Debug("LLM request", "provider", p.name, "model", p.model, "prompt_chars", len(prompt))
+	res, err := p.request(ctx, prompt, opts, false, nil)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	var parsed struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return "", err
+	}
+	answer := strings.TrimSpace(parsed.Message.Content)
+	if answer == "" {
+		return "", fmt.Errorf("empty %s response", p.name)
+	}
+	return answer, nil
+}
+
+func (p *ollamaProvider) AskTools(ctx context.Context, prompt string, opts AskOptions, defs []map[string]any) (toolReply, error) {
+	slog.Debug("LLM tools request", "provider", p.name, "model", p.model, "prompt_chars", len(prompt), "functions", len(defs))
+	res, err := p.request(ctx, prompt, opts, false, defs)
+	if err != nil {
+		return toolReply{}, err
+	}
+	defer res.Body.Close()
+	var parsed struct {
+		Message struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Function struct {
+					Name      string          `json:"name"`
+					Arguments json.RawMessage `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"message"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return toolReply{}, &parseError{err: err}
+	}
+	reply := toolReply{Text: strings.TrimSpace(parsed.Message.Content)}
+	for _, tc := range parsed.Message.ToolCalls {
+		args, err := parseToolArguments(tc.Function.Arguments)
+		if err != nil {
+			return toolReply{}, err
+		}
+		reply.Calls = append(reply.Calls, ToolCall{Name: tc.Function.Name, Arguments: args})
+	}
+	return reply, nil
+}
+
+func (p *ollamaProvider) Stream(ctx context.Context, prompt string, opts AskOptions, onToken TokenCallback) (string, error) {
+	slog.Debug("LLM stream request", "provider", p.name, "model", p.model, "prompt_chars", len(prompt))
+	res, err := p.request(ctx, prompt, opts, true, nil)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var buf strings.Builder
+	decoder := json.NewDecoder(res.Body)
+	for decoder.More() {
+		var chunk struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			Done bool `json:"done"`
+		}
+		if err := decoder.Decode(&chunk); err != nil {
+			break
+		}
+		if chunk.Message.Content != "" {
+			buf.WriteString(chunk.Message.Content)
+			if onToken != nil {
+				onToken(chunk.Message.Content)
+			}
+		}
+		if chunk.Done {
+			break
+		}
+	}
+
+	answer := strings.TrimSpace(buf.String())
+	if answer == "" {
+		return "", fmt.Errorf("empty %s stream response", p.name)
+	}
+	return answer, nil
+}
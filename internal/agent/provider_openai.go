@@ -0,0 +1,233 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	registerProviderType("openai", newOpenAIProvider)
+}
+
+// openAIProvider speaks the chat completions API. Besides OpenAI itself it
+// serves any compatible server (LM Studio, vLLM, llama.cpp server) declared
+// under "endpoints" with type "openai"; those may run without a key.
+type openAIProvider struct {
+	name       string
+	baseURL    string
+	model      string
+	apiKey     string
+	headers    map[string]string
+	requireKey bool
+}
+
+func newOpenAIProvider(name string, ep endpointConfig) Provider {
+	return &openAIProvider{
+		name:       name,
+		baseURL:    ep.baseURLOr(defaultOpenAIBaseURL),
+		model:      ep.modelOr(defaultOpenAIModel),
+		apiKey:     ep.resolvedKey(),
+		headers:    ep.Headers,
+		requireKey: name == "openai",
+	}
+}
+
+func (p *openAIProvider) Name() string    { return p.name }
+func (p *openAIProvider) Model() string   { return p.model }
+func (p *openAIProvider) BaseURL() string { return p.baseURL }
+
+func (p *openAIProvider) checkKey() error {
+	if p.requireKey && p.apiKey == "" {
+		return fmt.Errorf("missing OpenAI API key (set in %s or OPENAI_API_KEY)", configPath())
+	}
+	return nil
+}
+
+func (p *openAIProvider) authHeaders() map[string]string {
+	h := map[string]string{}
+	if p.apiKey != "" {
+		h["Authorization"] = "Bearer " + p.apiKey
+	}
+	for k, v := range p.headers {
+		h[k] = v
+	}
+	return h
+}
+
+func (p *openAIProvider) Ping() error {
+	if err := p.checkKey(); err != nil {
+		return err
+	}
+	return pingURL(p.baseURL+"/models", p.authHeaders())
+}
+
+func (p *openAIProvider) request(ctx context.Context, prompt string, opts AskOptions, stream bool, tools []map[string]any) (*http.Response, error) {
+	if err := p.checkKey(); err != nil {
+		return nil, err
+	}
+	reqBody := map[string]any{
+		"model": p.model,
+		"messages": []map[string]string{
+			{"role": "system", "content": systemMessage(opts)},
+			{"role": "user", "content": prompt},
+		},
+	}
+	if stream {
+		reqBody["stream"] = true
+	}
+	if opts.Temperature != nil {
+		reqBody["temperature"] = *opts.Temperature
+	}
+	if opts.MaxTokens > 0 {
+		reqBody["max_tokens"] = opts.MaxTokens
+	}
+	if opts.JSONMode {
+		reqBody["response_format"] = map[string]string{"type": "json_object"}
+	}
+	if len(tools) > 0 {
+		reqBody["tools"] = tools
+		reqBody["tool_choice"] = "auto"
+	}
+	raw, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+	headers := p.authHeaders()
+	res, err := doWithRetry(func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		setHeaders(req, headers)
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		res.Body.Close()
+		return nil, &statusError{prefix: p.name + " status", code: res.StatusCode, status: res.Status}
+	}
+	return res, nil
+}
+
+func (p *openAIProvider) Ask(ctx context.Context, prompt string, opts AskOptions) (string, error) {
+	slog.Debug("LLM request", "provider", p.name, "model", p.model, "prompt_chars", len(prompt))
+	res, err := p.request(ctx, prompt, opts, false, nil)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var parsed struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return "", err
+	}
+	if len(parsed.Choices) == 0 {
+		return "", fmt.Errorf("empty %s response", p.name)
+	}
+	answer := strings.TrimSpace(parsed.Choices[0].Message.Content)
+	if answer == "" {
+		return "", fmt.Errorf("empty %s content", p.name)
+	}
+	return answer, nil
+}
+
+func (p *openAIProvider) AskTools(ctx context.Context, prompt string, opts AskOptions, defs []map[string]any) (toolReply, error) {
+	slog.Debug("LLM tools request", "provider", p.name, "model", p.model, "prompt_chars", len(prompt), "functions", len(defs))
+	res, err := p.request(ctx, prompt, opts, false, defs)
+	if err != nil {
+		return toolReply{}, err
+	}
+	defer res.Body.Close()
+
+	var parsed struct {
+		Choices []struct {
+			Message struct {
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					Function struct {
+						Name      string          `json:"name"`
+						Arguments json.RawMessage `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return toolReply{}, &parseError{err: err}
+	}
+	if len(parsed.Choices) == 0 {
+		return toolReply{}, fmt.Errorf("empty %s response", p.name)
+	}
+	msg := parsed.Choices[0].Message
+	reply := toolReply{Text: strings.TrimSpace(msg.Content)}
+	for _, tc := range msg.ToolCalls {
+		args, err := parseToolArguments(tc.Function.Arguments)
+		if err != nil {
+			return toolReply{}, err
+		}
+		reply.Calls = append(reply.Calls, ToolCall{Name: tc.Function.Name, Arguments: args})
+	}
+	return reply, nil
+}
+
+func (p *openAIProvider) Stream(ctx context.Context, prompt string, opts AskOptions, onToken TokenCallback) (string, error) {
+	slog.Debug("LLM stream request", "provider", p.name, "model", p.model, "prompt_chars", len(prompt))
+	res, err := p.request(ctx, prompt, opts, true, nil)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var buf strings.Builder
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		data := strings.TrimPrefix(line, "data: ")
+		if data == "[DONE]" {
+			break
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			continue
+		}
+		if len(chunk.Choices) > 0 {
+			token := chunk.Choices[0].Delta.Content
+			if token != "" {
+				buf.WriteString(token)
+				if onToken != nil {
+					onToken(token)
+				}
+			}
+		}
+	}
+
+	answer := strings.TrimSpace(buf.String())
+	if answer == "" {
+		return "", fmt.Errorf("empty %s stream response", p.name)
+	}
+	return answer, nil
+}
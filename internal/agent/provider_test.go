@@ -0,0 +1,192 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOllamaProvider_AskStreamPing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			fmt.Fprint(w, `{"models":[]}`)
+		case "/api/chat":
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["model"] != "llama3" {
+				t.Errorf("unexpected model %v", body["model"])
+			}
+			if body["stream"] == true {
+				fmt.Fprintln(w, `{"message":{"content":"hel"}}`)
+				fmt.Fprintln(w, `{"message":{"content":"lo"},"done":true}`)
+				return
+			}
+			fmt.Fprint(w, `{"message":{"content":"hello"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p := newOllamaProvider("ollama", endpointConfig{BaseURL: srv.URL, Model: "llama3"})
+	if err := p.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.Ask(context.Background(), "hi", AskOptions{})
+	if err != nil || got != "hello" {
+		t.Fatalf("Ask = %q, %v", got, err)
+	}
+	var tokens []string
+	got, err = p.Stream(context.Background(), "hi", AskOptions{}, func(tok string) { tokens = append(tokens, tok) })
+	if err != nil || got != "hello" || len(tokens) != 2 {
+		t.Fatalf("Stream = %q, %v (tokens %v)", got, err, tokens)
+	}
+}
+
+func TestOpenAICompatibleEndpoint_HeadersAndNoKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Team") != "ops" {
+			t.Errorf("missing custom header on %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("unexpected Authorization header %q", r.Header.Get("Authorization"))
+		}
+		switch r.URL.Path {
+		case "/v1/models":
+			fmt.Fprint(w, `{"data":[]}`)
+		case "/v1/chat/completions":
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["stream"] == true {
+				fmt.Fprintln(w, `data: {"choices":[{"delta":{"content":"o"}}]}`)
+				fmt.Fprintln(w, `data: {"choices":[{"delta":{"content":"k"}}]}`)
+				fmt.Fprintln(w, `data: [DONE]`)
+				return
+			}
+			fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := userConfig{Endpoints: map[string]endpointConfig{
+		"lmstudio": {Type: "openai", BaseURL: srv.URL + "/v1", Model: "qwen", Headers: map[string]string{"X-Team": "ops"}},
+	}}
+	p, err := newProviderFromConfig(cfg, "lmstudio", AskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name() != "lmstudio" || p.Model() != "qwen" {
+		t.Fatalf("unexpected provider %s/%s", p.Name(), p.Model())
+	}
+	if err := p.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := p.Ask(context.Background(), "hi", AskOptions{}); err != nil || got != "ok" {
+		t.Fatalf("Ask = %q, %v", got, err)
+	}
+	if got, err := p.Stream(context.Background(), "hi", AskOptions{}, nil); err != nil || got != "ok" {
+		t.Fatalf("Stream = %q, %v", got, err)
+	}
+}
+
+func TestOpenAIProvider_MissingKey(t *testing.T) {
+	p := newOpenAIProvider("openai", endpointConfig{})
+	if _, err := p.Ask(context.Background(), "hi", AskOptions{}); err == nil || !strings.Contains(err.Error(), "missing OpenAI API key") {
+		t.Fatalf("expected missing key error, got %v", err)
+	}
+}
+
+func TestAnthropicProvider_AskStreamPing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "sk-ant" || r.Header.Get("anthropic-version") != defaultAnthropicVersion {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/models":
+			fmt.Fprint(w, `{"data":[]}`)
+		case "/messages":
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["max_tokens"] == nil {
+				t.Error("max_tokens is required by the Messages API")
+			}
+			if sys, _ := body["system"].(string); !strings.Contains(sys, "JSON") {
+				t.Errorf("expected JSON instruction in system prompt, got %q", sys)
+			}
+			if body["stream"] == true {
+				fmt.Fprintln(w, "event: content_block_delta")
+				fmt.Fprintln(w, `data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"{\"a\""}}`)
+				fmt.Fprintln(w, `data: {"type":"content_block_delta","delta":{"type":"text_delta","text":":1}"}}`)
+				fmt.Fprintln(w, `data: {"type":"message_stop"}`)
+				return
+			}
+			fmt.Fprint(w, `{"content":[{"type":"text","text":"{\"a\":1}"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p := newAnthropicProvider("anthropic", endpointConfig{BaseURL: srv.URL, APIKey: "sk-ant"})
+	if err := p.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	opts := AskOptions{JSONMode: true}
+	if got, err := p.Ask(context.Background(), "hi", opts); err != nil || got != `{"a":1}` {
+		t.Fatalf("Ask = %q, %v", got, err)
+	}
+	if got, err := p.Stream(context.Background(), "hi", opts, nil); err != nil || got != `{"a":1}` {
+		t.Fatalf("Stream = %q, %v", got, err)
+	}
+	if err := newAnthropicProvider("anthropic", endpointConfig{BaseURL: srv.URL}).Ping(); err == nil {
+		t.Fatal("expected error without API key")
+	}
+}
+
+func TestAskChain_FallsBackInOrder(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"from fallback"}}]}`)
+	}))
+	defer up.Close()
+
+	chain := []chainEntry{
+		{provider: newOllamaProvider("ollama", endpointConfig{BaseURL: down.URL})},
+		{provider: newOpenAIProvider("openai", endpointConfig{BaseURL: up.URL, APIKey: "sk"})},
+	}
+	res, err := askChain(chain, AskOptions{}, askFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Provider != "openai" || res.Text != "from fallback" {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func TestProviderChain(t *testing.T) {
+	chain, err := providerChain(userConfig{}, AskOptions{Provider: "auto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 2 || chain[0].provider.Name() != "ollama" || chain[1].provider.Name() != "openai" {
+		t.Fatalf("unexpected auto chain")
+	}
+	if _, err := providerChain(userConfig{}, AskOptions{Provider: "nope"}); err == nil {
+		t.Fatal("expected error for unknown provider")
+	}
+	cfg := userConfig{Endpoints: map[string]endpointConfig{"odd": {Type: "carrier-pigeon"}}}
+	if _, err := providerChain(cfg, AskOptions{Provider: "odd"}); err == nil {
+		t.Fatal("expected error for unknown endpoint type")
+	}
+}
@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Defaults used by dm init when the config does not set a value.
+const (
+	DefaultOllamaBaseURL = defaultOllamaBaseURL
+	DefaultOllamaModel   = defaultOllamaModel
+	DefaultOpenAIBaseURL = defaultOpenAIBaseURL
+	DefaultOpenAIModel   = defaultOpenAIModel
+)
+
+// OllamaModels lists the models installed on the Ollama server at baseURL
+// (GET /api/tags).
+func OllamaModels(baseURL string) ([]string, error) {
+	u := strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if u == "" {
+		u = defaultOllamaBaseURL
+	}
+	if err := validateBaseURL(u, "ollama"); err != nil {
+		return nil, err
+	}
+	res, err := pingClient.Get(u + "/api/tags")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("status %s", res.Status)
+	}
+	var body struct {
+		Models []struct {
+			Name  string `json:"name"`
+			Model string `json:"model"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid /api/tags response: %w", err)
+	}
+	out := make([]string, 0, len(body.Models))
+	for _, m := range body.Models {
+		name := strings.TrimSpace(m.Name)
+		if name == "" {
+			name = strings.TrimSpace(m.Model)
+		}
+		if name != "" {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// CheckOpenAIKey verifies key against the /models endpoint of an
+// OpenAI-compatible API.
+func CheckOpenAIKey(baseURL, key string) error {
+	u := strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if u == "" {
+		u = defaultOpenAIBaseURL
+	}
+	if err := validateBaseURL(u, "openai"); err != nil {
+		return err
+	}
+	if strings.TrimSpace(key) == "" {
+		return fmt.Errorf("API key is empty")
+	}
+	req, err := http.NewRequest(http.MethodGet, u+"/models", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(key))
+	res, err := pingClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("key rejected (%s)", res.Status)
+	case res.StatusCode < 200 || res.StatusCode >= 300:
+		return fmt.Errorf("status %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package agent
+
+import "time"
+
+type shellConfig struct {
+	Allow    []string `json:"allow,omitempty"`
+	Deny     []string `json:"deny,omitempty"`
+	Timeout  duration `json:"timeout,omitempty"`
+	MaxBytes int      `json:"max_bytes,omitempty"`
+}
+
+// ShellSettings is the "shell" config section used by the shell tool.
+// Allow and Deny are command prefixes; zero values mean "not configured".
+type ShellSettings struct {
+	Allow    []string
+	Deny     []string
+	Timeout  time.Duration
+	MaxBytes int
+}
+
+// ConfiguredShell returns the shell tool settings from the agent config.
+func ConfiguredShell() ShellSettings {
+	cfg, _ := cachedUserConfig()
+	s := cfg.Shell
+	return ShellSettings{
+		Allow:    s.Allow,
+		Deny:     s.Deny,
+		Timeout:  time.Duration(s.Timeout),
+		MaxBytes: s.MaxBytes,
+	}
+}
@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// System prompt modes for the config's system_prompt_mode.
+const (
+	SystemPromptAppend  = "append"
+	SystemPromptReplace = "replace"
+)
+
+// configSystemPrompt returns the planner prompt from system_prompt and
+// system_prompt_file (both are used, inline text first) and its mode.
+// The file is re-read on every call so edits apply without a restart.
+func configSystemPrompt(cfg userConfig) (string, string) {
+	var parts []string
+	if s := strings.TrimSpace(cfg.SystemPrompt); s != "" {
+		parts = append(parts, s)
+	}
+	if f := strings.TrimSpace(cfg.SystemPromptFile); f != "" {
+		data, err := os.ReadFile(ResolveConfigRelativePath(f))
+		if err != nil {
+			slog.Warn("cannot read system_prompt_file", "path", f, "error", err)
+		} else if s := strings.TrimSpace(string(data)); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	mode := strings.ToLower(strings.TrimSpace(cfg.SystemPromptMode))
+	if mode != SystemPromptReplace {
+		mode = SystemPromptAppend
+	}
+	return strings.Join(parts, "\n\n"), mode
+}
+
+// ResolveConfigRelativePath resolves p against the directory of the agent
+// config file; absolute paths and ~/ paths are returned expanded.
+func ResolveConfigRelativePath(p string) string {
+	if strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[2:])
+		}
+	}
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(filepath.Dir(configPath()), p)
+}
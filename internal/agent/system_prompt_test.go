@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderDecisionSystemPrompt_Append(t *testing.T) {
+	cfg := userConfig{SystemPrompt: "Always use the staging DB."}
+	got := renderDecisionSystemPrompt(cfg, "- a", "- b", AskOptions{})
+	if !strings.HasPrefix(got, decisionPreamble) {
+		t.Fatalf("expected built-in preamble first, got %q", got[:60])
+	}
+	if !strings.HasSuffix(got, "Team instructions (follow these over the defaults above):\nAlways use the staging DB.") {
+		t.Fatalf("expected custom prompt appended, got %q", got[len(got)-120:])
+	}
+}
+
+func TestRenderDecisionSystemPrompt_ReplaceFromFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "planner.md")
+	if err := os.WriteFile(path, []byte("You plan ops tasks for the STIBS team.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := userConfig{SystemPromptFile: path, SystemPromptMode: "replace"}
+	got := renderDecisionSystemPrompt(cfg, "- a", "- b", AskOptions{ScopeNotes: "[STIBS DB]\nuse staging"})
+	if !strings.HasPrefix(got, "You plan ops tasks for the STIBS team.\nYou can either") {
+		t.Fatalf("expected preamble replaced, got %q", got[:80])
+	}
+	if strings.Contains(got, decisionPreamble) || strings.Contains(got, "Team instructions") {
+		t.Fatal("replace mode must not keep the built-in preamble or append the prompt")
+	}
+	if !strings.Contains(got, "Return ONLY valid JSON") {
+		t.Fatal("expected the action schemas to be kept")
+	}
+	if !strings.HasSuffix(got, "Domain notes for the toolkits in scope:\n[STIBS DB]\nuse staging") {
+		t.Fatalf("expected scope notes at the end, got %q", got[len(got)-80:])
+	}
+}
+
+func TestConfigSystemPrompt_InlineAndMissingFile(t *testing.T) {
+	cfg := userConfig{SystemPrompt: "inline", SystemPromptFile: filepath.Join(t.TempDir(), "missing.md"), SystemPromptMode: "bogus"}
+	text, mode := configSystemPrompt(cfg)
+	if text != "inline" || mode != SystemPromptAppend {
+		t.Fatalf("got %q / %q", text, mode)
+	}
+}
+
+func TestRenderDecisionSystemPrompt_ActionRuleListsEverySchema(t *testing.T) {
+	got := renderDecisionSystemPrompt(userConfig{}, "- a", "- b", AskOptions{})
+	_, rest, ok := strings.Cut(got, "- action must be ")
+	if !ok {
+		t.Fatal("expected the action rule in the prompt")
+	}
+	rule, _, _ := strings.Cut(rest, "\n")
+	for _, action := range []string{"answer", "run_plugin", "run_tool", "create_function", "multi"} {
+		if !strings.Contains(got, `{"action":"`+action+`"`) || !strings.Contains(rule, action) {
+			t.Fatalf("expected %s in both the schemas and the action rule %q", action, rule)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type workspaceConfig struct {
+	Roots []string `json:"roots,omitempty"`
+}
+
+// ConfiguredWorkspaceRoots returns the "workspace.roots" directories the
+// file tools may write to, with ~ expanded. Nil means "not configured".
+func ConfiguredWorkspaceRoots() []string {
+	cfg, _ := cachedUserConfig()
+	var roots []string
+	home, _ := os.UserHomeDir()
+	for _, r := range cfg.Workspace.Roots {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		if home != "" && (r == "~" || strings.HasPrefix(r, "~/") || strings.HasPrefix(r, `~\`)) {
+			r = filepath.Join(home, r[1:])
+		}
+		roots = append(roots, filepath.Clean(r))
+	}
+	return roots
+}
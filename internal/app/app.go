@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,7 +14,7 @@ func runPluginOrSuggest(baseDir string, args []string) int {
 	if len(args) == 0 {
 		return 0
 	}
-	if err := plugins.Run(baseDir, args[0], args[1:]); err != nil {
+	if err := plugins.Run(context.Background(), baseDir, args[0], args[1:]); err != nil {
 		if plugins.IsNotFound(err) {
 			fmt.Fprintln(os.Stderr, "Error:", err)
 			if suggestion := suggestTopLevelName(baseDir, args[0]); suggestion != "" {
@@ -54,6 +55,33 @@ func fileExists(path string) bool {
 	return false
 }
 
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
 type runtimeContext struct {
 	BaseDir string
 }
@@ -153,29 +181,54 @@ func runPlugin(baseDir string, args []string) int {
 				fmt.Println("-", ex)
 			}
 		}
+		printPluginManifest(info)
 		return 0
 	case "run":
-		if len(args) < 2 {
-			fmt.Println("Usage: dm plugins run <name> [args...]")
+		rest := args[1:]
+		jsonOut := len(rest) > 0 && rest[0] == "--json"
+		if jsonOut {
+			rest = rest[1:]
+		}
+		if len(rest) < 1 {
+			fmt.Println("Usage: dm plugins run [--json] <name> [args...]")
 			return 0
 		}
-		if err := plugins.Run(baseDir, args[1], args[2:]); err != nil {
+		if jsonOut {
+			return runPluginJSON(baseDir, rest[0], rest[1:])
+		}
+		if err := plugins.Run(context.Background(), baseDir, rest[0], rest[1:]); err != nil {
 			fmt.Fprintln(os.Stderr, "Error:", err)
 			return 1
 		}
 		return 0
+	case "toolkits":
+		return runPluginToolkits(baseDir)
+	case "install":
+		return runPluginInstall(baseDir, args[1:])
+	case "remove":
+		return runPluginRemove(baseDir, args[1:])
+	case "enable":
+		return runPluginSetEnabled(baseDir, args[1:], true)
+	case "disable":
+		return runPluginSetEnabled(baseDir, args[1:], false)
+	case "update":
+		return runPluginUpdate(baseDir, args[1:])
 	default:
-		if suggestion := suggestClosest(args[0], []string{"list", "info", "run", "menu"}, 3); suggestion != "" {
+		subcommands := []string{"list", "info", "run", "menu", "toolkits", "install", "remove", "enable", "disable", "update"}
+		if suggestion := suggestClosest(args[0], subcommands, 3); suggestion != "" {
 			fmt.Printf("Did you mean: dm plugins %s\n", suggestion)
 		}
-		fmt.Println("Usage: dm plugins <list|info|run|menu> ...")
+		fmt.Println("Usage: dm plugins <list|info|run|menu|toolkits|install|remove|enable|disable|update> ...")
 		return 0
 	}
 }
 
 func suggestTopLevelName(baseDir string, input string) string {
 	candidates := []string{
-		"ps_profile", "cp", "open", "doctor", "plugins", "tools", "ask", "completion", "help",
+		"ps_profile", "cp", "open", "doctor", "plugins", "tools", "ask", "history", "config", "completion", "help", "run", "init", "undo",
+	}
+	if macros, err := loadMacroFiles(shortcutMacroFilePaths(baseDir)); err == nil {
+		candidates = append(candidates, macroNames(macros)...)
 	}
 	if items, err := plugins.ListEntries(baseDir, true); err == nil {
 		for _, it := range items {
@@ -101,3 +101,22 @@ func TestSuggestClosestNoMatch(t *testing.T) {
 		t.Fatalf("expected empty suggestion, got %q", got)
 	}
 }
+
+func TestInterruptStepCancelsOnlyTheRunningStep(t *testing.T) {
+	if interruptStep() {
+		t.Fatal("expected no step to interrupt outside a step")
+	}
+	ctx, end := beginInterruptibleStep()
+	if !interruptStep() {
+		t.Fatal("expected the running step to be interrupted")
+	}
+	if ctx.Err() == nil {
+		t.Fatal("expected the step context to be canceled")
+	}
+	end()
+	next, endNext := beginInterruptibleStep()
+	defer endNext()
+	if next.Err() != nil {
+		t.Fatal("a new step must start with a live context")
+	}
+}
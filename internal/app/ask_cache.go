@@ -3,6 +3,7 @@ package app
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"strings"
 	"sync"
 	"time"
@@ -59,6 +60,7 @@ func decideWithCacheStream(prompt, pluginCatalog, toolCatalog string, opts agent
 	key := decisionCacheKey(prompt, pluginCatalog, toolCatalog, opts, envContext)
 	now := time.Now()
 	if cached, ok := askDecisionCache.Get(key, now); ok {
+		cached.Attempts = nil
 		return cached, true, nil
 	}
 	var decision agent.DecisionResult
@@ -75,15 +77,33 @@ func decideWithCacheStream(prompt, pluginCatalog, toolCatalog string, opts agent
 	return decision, false, nil
 }
 
+func decideWithCacheTools(prompt string, functions []agent.FunctionSpec, opts agent.AskOptions, envContext string) (agent.DecisionResult, bool, error) {
+	data, _ := json.Marshal(functions)
+	key := decisionCacheKey(prompt, "protocol=tools\n"+string(data), "", opts, envContext)
+	now := time.Now()
+	if cached, ok := askDecisionCache.Get(key, now); ok {
+		cached.Attempts = nil
+		return cached, true, nil
+	}
+	decision, err := agent.DecideWithTools(prompt, functions, opts, envContext)
+	if err != nil {
+		return agent.DecisionResult{}, false, err
+	}
+	askDecisionCache.Set(key, decision, now)
+	return decision, false, nil
+}
+
 func decisionCacheKey(prompt, pluginCatalog, toolCatalog string, opts agent.AskOptions, envContext string) string {
 	normalized := strings.Join([]string{
 		strings.TrimSpace(prompt),
+		strings.TrimSpace(opts.Profile),
 		strings.TrimSpace(pluginCatalog),
 		strings.TrimSpace(toolCatalog),
 		strings.ToLower(strings.TrimSpace(opts.Provider)),
 		strings.TrimSpace(opts.Model),
 		strings.TrimSpace(opts.BaseURL),
 		strings.TrimSpace(envContext),
+		strings.TrimSpace(opts.ScopeNotes),
 	}, "\n---\n")
 	sum := sha256.Sum256([]byte(normalized))
 	return hex.EncodeToString(sum[:])
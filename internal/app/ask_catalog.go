@@ -8,13 +8,14 @@ import (
 	"sort"
 	"strings"
 
+	"cli/internal/agent"
 	"cli/internal/plugins"
 	"cli/tools"
 )
 
 const catalogTokenBudget = 6000
 
-func buildPluginCatalogScoped(baseDir, scope string) string {
+func buildPluginCatalogScoped(baseDir, scope string, tokenBudget int) string {
 	items, err := plugins.ListEntries(baseDir, true)
 	if err != nil || len(items) == 0 {
 		return "(none)"
@@ -79,15 +80,51 @@ func buildPluginCatalogScoped(baseDir, scope string) string {
 
 	tokens := estimateTokens(catalog)
 	slog.Debug("plugin catalog built", "tokens", tokens, "functions", countCatalogFunctions(out), "scope", scope)
-	if tokens > catalogTokenBudget {
+	if tokenBudget <= 0 {
+		tokenBudget = catalogTokenBudget
+	}
+	if tokens > tokenBudget {
 		slog.Warn("plugin catalog exceeds token budget",
-			"tokens", tokens, "budget", catalogTokenBudget,
+			"tokens", tokens, "budget", tokenBudget,
 			"hint", "use --scope to reduce catalog size")
 	}
 
 	return catalog
 }
 
+// buildScopePromptNotes collects the prompt fragments of the toolkits the
+// scope selects. Without a scope no notes are injected.
+func buildScopePromptNotes(baseDir, scope string) string {
+	scopeLower := strings.ToLower(strings.TrimSpace(scope))
+	if scopeLower == "" {
+		return ""
+	}
+	items, err := plugins.ListEntries(baseDir, true)
+	if err != nil {
+		return ""
+	}
+	paths := map[string]string{}
+	for _, item := range items {
+		key := toolkitGroupKey(item.Path)
+		if _, seen := paths[key]; seen || !scopeMatches(item.Name, toolkitLabel(key), scopeLower) {
+			continue
+		}
+		paths[key] = item.Path
+	}
+	keys := make([]string, 0, len(paths))
+	for k := range paths {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var out []string
+	for _, key := range keys {
+		if notes := plugins.ParseToolkitPrompt(paths[key]); notes != "" {
+			out = append(out, fmt.Sprintf("[%s]\n%s", toolkitLabel(key), notes))
+		}
+	}
+	return strings.Join(out, "\n\n")
+}
+
 func scopeMatches(funcName, groupLabel, scope string) bool {
 	if strings.HasPrefix(strings.ToLower(funcName), scope+"_") {
 		return true
@@ -163,6 +200,76 @@ func formatParamDetailsForCatalog(details []plugins.ParamDetail) string {
 	return strings.Join(parts, ", ")
 }
 
+// buildDecisionFunctions describes the scoped plugins and the built-in tools
+// as function specs for --protocol tools.
+func buildDecisionFunctions(baseDir, scope string) []agent.FunctionSpec {
+	var out []agent.FunctionSpec
+	scopeLower := strings.ToLower(strings.TrimSpace(scope))
+	items, _ := plugins.ListEntries(baseDir, true)
+	for _, item := range items {
+		label := toolkitLabel(toolkitGroupKey(item.Path))
+		if scopeLower != "" && !scopeMatches(item.Name, label, scopeLower) {
+			continue
+		}
+		info, _ := plugins.GetInfo(baseDir, item.Name)
+		desc := strings.TrimSpace(info.Synopsis)
+		if desc == "" {
+			desc = item.Name
+		}
+		spec := agent.FunctionSpec{Kind: agent.FunctionPlugin, Name: item.Name, Description: desc + " [" + label + "]"}
+		if len(info.ParamDetails) > 0 {
+			for _, d := range info.ParamDetails {
+				spec.Params = append(spec.Params, functionParamFromDetail(d))
+			}
+		} else {
+			for _, name := range info.Parameters {
+				spec.Params = append(spec.Params, agent.FunctionParam{Name: name, Type: "string"})
+			}
+		}
+		out = append(out, spec)
+	}
+	for _, t := range tools.ToolRegistry {
+		spec := agent.FunctionSpec{Kind: agent.FunctionTool, Name: t.Name, Description: t.Synopsis}
+		for _, p := range t.AgentParams() {
+			spec.Params = append(spec.Params, agent.FunctionParam{
+				Name: p.Name, Type: "string", Description: p.Description, Required: p.Required, Enum: p.Enum,
+			})
+		}
+		out = append(out, spec)
+	}
+	return out
+}
+
+func functionParamFromDetail(d plugins.ParamDetail) agent.FunctionParam {
+	p := agent.FunctionParam{Name: d.Name, Type: "string", Required: d.Mandatory, Enum: d.ValidateSet}
+	switch strings.ToLower(strings.Trim(d.Type, "[]")) {
+	case "switch", "bool", "boolean":
+		p.Type = "boolean"
+	case "int", "int32", "int64", "long":
+		p.Type = "integer"
+	case "double", "float", "single", "decimal":
+		p.Type = "number"
+	}
+	if d.Switch {
+		p.Type = "boolean"
+	}
+	if len(p.Enum) > 0 {
+		p.Type = "string"
+	}
+	var desc []string
+	if d.HelpMessage != "" {
+		desc = append(desc, d.HelpMessage)
+	}
+	if len(d.Aliases) > 0 {
+		desc = append(desc, "alias "+strings.Join(d.Aliases, ", "))
+	}
+	if d.Default != "" {
+		desc = append(desc, "default "+d.Default)
+	}
+	p.Description = strings.Join(desc, "; ")
+	return p
+}
+
 func buildToolsCatalog() string {
 	return tools.BuildAgentCatalog()
 }
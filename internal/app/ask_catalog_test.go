@@ -2,6 +2,8 @@ package app
 
 import (
 	"testing"
+
+	"cli/internal/plugins"
 )
 
 func TestToolkitLabel(t *testing.T) {
@@ -29,3 +31,17 @@ func TestToolkitLabel(t *testing.T) {
 		}
 	}
 }
+
+func TestParamDetailAliasesAndHelpMessage(t *testing.T) {
+	d := plugins.ParamDetail{Name: "ComputerName", Type: "string", Mandatory: true, Aliases: []string{"Host"}, HelpMessage: "Target host", Position: -1}
+	if got := functionParamFromDetail(d).Description; got != "Target host; alias Host" {
+		t.Fatalf("unexpected description %q", got)
+	}
+	info := plugins.Info{ParamDetails: []plugins.ParamDetail{d}}
+	if missing := missingMandatoryParams(info, map[string]string{"host": "example.com"}); len(missing) != 0 {
+		t.Fatalf("an alias should satisfy a mandatory param, got missing %v", missing)
+	}
+	if missing := missingMandatoryParams(info, map[string]string{"Port": "80"}); len(missing) != 1 || missing[0] != "ComputerName" {
+		t.Fatalf("expected ComputerName to be missing, got %v", missing)
+	}
+}
@@ -99,6 +99,12 @@ func plannedActionSummary(decision agent.DecisionResult) string {
 			s += " (" + args + ")"
 		}
 		return s
+	case "multi":
+		parts := make([]string, 0, len(decision.Actions))
+		for _, a := range decision.Actions {
+			parts = append(parts, plannedActionSummary(a))
+		}
+		return fmt.Sprintf("%d actions: %s", len(decision.Actions), strings.Join(parts, "; "))
 	case "create_function":
 		desc := strings.TrimSpace(decision.FunctionDescription)
 		if len(desc) > askDescMaxLen {
@@ -119,11 +125,13 @@ func missingMandatoryParams(info plugins.Info, pluginArgs map[string]string) []s
 		if !p.Mandatory {
 			continue
 		}
+		names := append([]string{p.Name}, p.Aliases...)
 		found := false
 		for k, v := range pluginArgs {
-			if strings.EqualFold(k, p.Name) && strings.TrimSpace(v) != "" {
-				found = true
-				break
+			for _, name := range names {
+				if strings.EqualFold(k, name) && strings.TrimSpace(v) != "" {
+					found = true
+				}
 			}
 		}
 		if !found {
@@ -174,12 +182,11 @@ func trimToTokenBudget(prompt, sessionBlock, previousBlock string, budget int) (
 	return sessionBlock, previousBlock
 }
 
+// truncateForHistory keeps the beginning and the end of s, where errors in
+// long logs usually are, within about maxLen bytes.
 func truncateForHistory(s string, maxLen int) string {
-	s = strings.TrimSpace(s)
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "\n... (truncated)"
+	s, _ = plugins.HeadTail(strings.TrimSpace(s), maxLen)
+	return s
 }
 
 func printAgentActionError(err error) {
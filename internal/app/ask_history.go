@@ -0,0 +1,223 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const askHistoryFileExt = ".json"
+
+type askSessionTurn struct {
+	At       time.Time         `json:"at"`
+	Prompt   string            `json:"prompt"`
+	Provider string            `json:"provider,omitempty"`
+	Model    string            `json:"model,omitempty"`
+	Answer   string            `json:"answer,omitempty"`
+	Actions  []askActionRecord `json:"actions,omitempty"`
+}
+
+type askSessionFile struct {
+	ID        string           `json:"id"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+	Provider  string           `json:"provider,omitempty"`
+	Model     string           `json:"model,omitempty"`
+	Scope     string           `json:"scope,omitempty"`
+	Turns     []askSessionTurn `json:"turns"`
+}
+
+func askHistoryDir() (string, error) {
+	if p := strings.TrimSpace(os.Getenv("DM_HISTORY_DIR")); p != "" {
+		return p, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || strings.TrimSpace(home) == "" {
+		return "", fmt.Errorf("cannot resolve user home directory for history")
+	}
+	return filepath.Join(home, ".config", "dm", "history"), nil
+}
+
+func newAskSessionID(now time.Time) string {
+	buf := make([]byte, 3)
+	_, _ = rand.Read(buf)
+	return now.Format("20060102-150405") + "-" + hex.EncodeToString(buf)
+}
+
+func newAskSessionFile(provider, model, scope string) *askSessionFile {
+	now := time.Now()
+	return &askSessionFile{
+		ID:        newAskSessionID(now),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Provider:  provider,
+		Model:     model,
+		Scope:     scope,
+		Turns:     []askSessionTurn{},
+	}
+}
+
+func askSessionPath(dir, id string) (string, error) {
+	id = strings.TrimSpace(id)
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+		return "", fmt.Errorf("invalid session id %q", id)
+	}
+	return filepath.Join(dir, id+askHistoryFileExt), nil
+}
+
+func saveAskSession(dir string, s *askSessionFile) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path, err := askSessionPath(dir, s.ID)
+	if err != nil {
+		return err
+	}
+	s.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0600)
+}
+
+func loadAskSession(dir, id string) (*askSessionFile, error) {
+	path, err := askSessionPath(dir, id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("session not found: %s", id)
+		}
+		return nil, err
+	}
+	var s askSessionFile
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid session file %s: %w", path, err)
+	}
+	if s.ID == "" {
+		s.ID = id
+	}
+	return &s, nil
+}
+
+func listAskSessions(dir string) ([]*askSessionFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []*askSessionFile
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), askHistoryFileExt) {
+			continue
+		}
+		s, err := loadAskSession(dir, strings.TrimSuffix(e.Name(), askHistoryFileExt))
+		if err != nil {
+			continue
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].UpdatedAt.After(out[j].UpdatedAt)
+	})
+	return out, nil
+}
+
+func resolveAskSession(dir, id string) (*askSessionFile, error) {
+	id = strings.TrimSpace(id)
+	if id != "" && id != "last" {
+		return loadAskSession(dir, id)
+	}
+	sessions, err := listAskSessions(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, fmt.Errorf("no saved sessions in %s", dir)
+	}
+	return sessions[0], nil
+}
+
+func rebuildAskSessionState(s *askSessionFile) ([]string, []askActionRecord) {
+	var prompts []string
+	var history []askActionRecord
+	for _, t := range s.Turns {
+		history = appendSessionHistory(history, t.Actions)
+		if strings.TrimSpace(t.Prompt) != "" {
+			prompts = append(prompts, t.Prompt)
+		}
+	}
+	if len(prompts) > askPreviousPromptsMax {
+		prompts = prompts[len(prompts)-askPreviousPromptsMax:]
+	}
+	return prompts, history
+}
+
+func (s *askSessionFile) addTurn(prompt string, turn askTurnResult) {
+	s.Turns = append(s.Turns, askSessionTurn{
+		At:       time.Now(),
+		Prompt:   prompt,
+		Provider: turn.Provider,
+		Model:    turn.Model,
+		Answer:   turn.Answer,
+		Actions:  turn.History,
+	})
+}
+
+func (s *askSessionFile) title() string {
+	for _, t := range s.Turns {
+		if p := strings.TrimSpace(t.Prompt); p != "" {
+			p = strings.ReplaceAll(p, "\n", " ")
+			if r := []rune(p); len(r) > askDescMaxLen {
+				p = string(r[:askDescMaxLen]) + "..."
+			}
+			return p
+		}
+	}
+	return "(empty)"
+}
+
+func renderAskSessionMarkdown(s *askSessionFile) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Session %s\n\n", s.ID)
+	fmt.Fprintf(&b, "- Created: %s\n", s.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&b, "- Updated: %s\n", s.UpdatedAt.Local().Format("2006-01-02 15:04:05"))
+	if s.Provider != "" {
+		fmt.Fprintf(&b, "- Provider: %s/%s\n", s.Provider, s.Model)
+	}
+	if s.Scope != "" {
+		fmt.Fprintf(&b, "- Scope: %s\n", s.Scope)
+	}
+	for i, t := range s.Turns {
+		fmt.Fprintf(&b, "\n## Turn %d (%s)\n\n", i+1, t.At.Local().Format("2006-01-02 15:04:05"))
+		fmt.Fprintf(&b, "**Prompt:** %s\n", strings.TrimSpace(t.Prompt))
+		for _, a := range t.Actions {
+			line := fmt.Sprintf("- step %d: %s `%s`", a.Step, a.Action, a.Target)
+			if strings.TrimSpace(a.Args) != "" {
+				line += " args: `" + a.Args + "`"
+			}
+			b.WriteString("\n" + line + "\n")
+			if strings.TrimSpace(a.Reason) != "" {
+				fmt.Fprintf(&b, "  - reason: %s\n", a.Reason)
+			}
+			if strings.TrimSpace(a.Result) != "" {
+				fmt.Fprintf(&b, "\n```\n%s\n```\n", strings.TrimSpace(a.Result))
+			}
+		}
+		if strings.TrimSpace(t.Answer) != "" {
+			fmt.Fprintf(&b, "\n**Answer:**\n\n%s\n", strings.TrimSpace(t.Answer))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestAskSessionSaveLoadRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	s := newAskSessionFile("openai", "gpt-4o-mini", "stibs")
+	s.addTurn("check db", askTurnResult{
+		Provider: "openai",
+		Model:    "gpt-4o-mini",
+		Answer:   "db is up",
+		History: []askActionRecord{
+			{Step: 1, Action: "run_plugin", Target: "stibs_db_status", Reason: "status", Result: "ok"},
+		},
+	})
+	if err := saveAskSession(dir, s); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := loadAskSession(dir, s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Scope != "stibs" || len(got.Turns) != 1 {
+		t.Fatalf("unexpected session: %+v", got)
+	}
+	if got.Turns[0].Actions[0].Target != "stibs_db_status" {
+		t.Fatalf("expected action target preserved, got %+v", got.Turns[0].Actions)
+	}
+	if got.Turns[0].Answer != "db is up" {
+		t.Fatalf("expected answer preserved, got %q", got.Turns[0].Answer)
+	}
+}
+
+func TestResolveAskSessionLatest(t *testing.T) {
+	dir := t.TempDir()
+	older := newAskSessionFile("openai", "m", "")
+	older.ID = "older"
+	if err := saveAskSession(dir, older); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	newer := newAskSessionFile("openai", "m", "")
+	newer.ID = "newer"
+	if err := saveAskSession(dir, newer); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := resolveAskSession(dir, "last")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != "newer" {
+		t.Fatalf("expected newest session, got %q", got.ID)
+	}
+	if _, err := resolveAskSession(t.TempDir(), ""); err == nil {
+		t.Fatal("expected error when no sessions exist")
+	}
+}
+
+func TestAskSessionPathRejectsTraversal(t *testing.T) {
+	for _, id := range []string{"", "../x", `a\b`, "a/b"} {
+		if _, err := askSessionPath("dir", id); err == nil {
+			t.Fatalf("expected error for id %q", id)
+		}
+	}
+}
+
+func TestRebuildAskSessionState(t *testing.T) {
+	s := newAskSessionFile("ollama", "m", "")
+	s.addTurn("first", askTurnResult{History: []askActionRecord{
+		{Step: 1, Action: "run_tool", Target: "search", Result: "ok"},
+		{Step: 2, Action: "run_tool", Target: "read", Result: "error: boom"},
+	}})
+	s.addTurn("second", askTurnResult{})
+
+	prompts, history := rebuildAskSessionState(s)
+	if strings.Join(prompts, ",") != "first,second" {
+		t.Fatalf("unexpected prompts: %v", prompts)
+	}
+	if len(history) != 1 || history[0].Target != "search" {
+		t.Fatalf("expected only successful actions in history, got %+v", history)
+	}
+}
+
+func TestRenderAskSessionMarkdown(t *testing.T) {
+	s := newAskSessionFile("openai", "gpt", "")
+	s.addTurn("find pdfs", askTurnResult{
+		Answer:  "found 2",
+		History: []askActionRecord{{Step: 1, Action: "run_tool", Target: "search", Args: "ext=pdf"}},
+	})
+	got := renderAskSessionMarkdown(s)
+	for _, want := range []string{"# Session " + s.ID, "find pdfs", "run_tool `search`", "found 2"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in markdown, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestWriteFileAtomicLeavesNoTemp(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.json")
+	if err := writeFileAtomic(path, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "out.json" {
+		t.Fatalf("expected only the target file, got %v", entries)
+	}
+}
+
+func TestAskSessionTitleCutsOnRunes(t *testing.T) {
+	s := &askSessionFile{Turns: []askSessionTurn{{Prompt: strings.Repeat("é", askDescMaxLen+5)}}}
+	got := s.title()
+	if !utf8.ValidString(got) || got != strings.Repeat("é", askDescMaxLen)+"..." {
+		t.Fatalf("expected %d whole characters and an ellipsis, got %q", askDescMaxLen, got)
+	}
+}
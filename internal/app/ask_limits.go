@@ -0,0 +1,182 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"cli/internal/agent"
+)
+
+const (
+	askStopMaxSteps       = "max_steps"
+	askStopStepTimeout    = "step_timeout"
+	askStopSessionTimeout = "session_timeout"
+	askStopTokenBudget    = "token_budget"
+)
+
+type askLimits struct {
+	MaxSteps       int
+	StepTimeout    time.Duration
+	SessionTimeout time.Duration
+	SessionTokens  int
+	PromptTokens   int
+	CatalogTokens  int
+	DecisionTokens int
+}
+
+// askStop describes why the agent loop ended early and what it got done.
+type askStop struct {
+	Reason    string   `json:"reason"`
+	Message   string   `json:"message"`
+	Steps     int      `json:"steps"`
+	ElapsedMS int64    `json:"elapsed_ms"`
+	Tokens    int      `json:"tokens"`
+	Summary   []string `json:"summary,omitempty"`
+}
+
+// resolveAskLimits layers built-in defaults, the config's "limits" section
+// and flags (non-zero values win).
+func resolveAskLimits(cfg agent.Limits, flags askLimits) askLimits {
+	l := askLimits{
+		MaxSteps:      askMaxSteps,
+		PromptTokens:  promptTokenBudget,
+		CatalogTokens: catalogTokenBudget,
+	}
+	pickInt := func(dst *int, vals ...int) {
+		for _, v := range vals {
+			if v > 0 {
+				*dst = v
+			}
+		}
+	}
+	pickDur := func(dst *time.Duration, vals ...time.Duration) {
+		for _, v := range vals {
+			if v > 0 {
+				*dst = v
+			}
+		}
+	}
+	pickInt(&l.MaxSteps, cfg.MaxSteps, flags.MaxSteps)
+	pickDur(&l.StepTimeout, cfg.StepTimeout, flags.StepTimeout)
+	pickDur(&l.SessionTimeout, cfg.SessionTimeout, flags.SessionTimeout)
+	pickInt(&l.SessionTokens, cfg.SessionTokens, flags.SessionTokens)
+	pickInt(&l.PromptTokens, cfg.PromptTokens, flags.PromptTokens)
+	pickInt(&l.CatalogTokens, cfg.CatalogTokens, flags.CatalogTokens)
+	pickInt(&l.DecisionTokens, cfg.DecisionTokens, flags.DecisionTokens)
+	return l
+}
+
+// askBudget tracks time and tokens spent by the agent across the turns of
+// one dm ask invocation. Idle time at the prompt is not counted.
+type askBudget struct {
+	limits  askLimits
+	elapsed time.Duration
+	tokens  int
+}
+
+func newAskBudget(l askLimits) *askBudget {
+	if l.MaxSteps <= 0 {
+		l.MaxSteps = askMaxSteps
+	}
+	return &askBudget{limits: l}
+}
+
+func (b *askBudget) addStep(d time.Duration, tokens int) {
+	b.elapsed += d
+	b.tokens += tokens
+}
+
+// decisionOpts applies the per-step limits to the options of one planner
+// call; the decision token limit wins over a profile's max_tokens.
+func (b *askBudget) decisionOpts(opts agent.AskOptions) agent.AskOptions {
+	opts.Timeout = b.requestTimeout()
+	if b.limits.DecisionTokens > 0 {
+		opts.MaxTokens = b.limits.DecisionTokens
+	}
+	return opts
+}
+
+// requestTimeout bounds the next LLM call by the step timeout and what is
+// left of the session timeout; zero means no bound.
+func (b *askBudget) requestTimeout() time.Duration {
+	t := b.limits.StepTimeout
+	if b.limits.SessionTimeout > 0 {
+		left := b.limits.SessionTimeout - b.elapsed
+		if left <= 0 {
+			left = time.Millisecond
+		}
+		if t == 0 || left < t {
+			t = left
+		}
+	}
+	return t
+}
+
+// actionTimeout bounds the action of a step whose decision took spent: the
+// rest of the step timeout, capped by what is left of the session (the
+// decision is already counted). Zero means no bound.
+func (b *askBudget) actionTimeout(spent time.Duration) time.Duration {
+	t := b.requestTimeout()
+	if b.limits.StepTimeout > 0 {
+		left := b.limits.StepTimeout - spent
+		if left <= 0 {
+			left = time.Millisecond
+		}
+		if left < t {
+			t = left
+		}
+	}
+	return t
+}
+
+// beforeStep reports the limit that prevents running step, if any.
+func (b *askBudget) beforeStep(step int) (string, string, bool) {
+	switch {
+	case step > b.limits.MaxSteps:
+		return askStopMaxSteps, fmt.Sprintf("reached max steps (%d)", b.limits.MaxSteps), true
+	case b.limits.SessionTimeout > 0 && b.elapsed >= b.limits.SessionTimeout:
+		return askStopSessionTimeout, fmt.Sprintf("session time budget of %s used up", b.limits.SessionTimeout), true
+	case b.limits.SessionTokens > 0 && b.tokens >= b.limits.SessionTokens:
+		return askStopTokenBudget, fmt.Sprintf("token budget of %d used up (~%d tokens)", b.limits.SessionTokens, b.tokens), true
+	}
+	return "", "", false
+}
+
+func (b *askBudget) stepExceeded(d time.Duration) bool {
+	return b.limits.StepTimeout > 0 && d > b.limits.StepTimeout
+}
+
+func (b *askBudget) stop(reason, message string, steps int, history []askActionRecord) askStop {
+	return askStop{
+		Reason:    reason,
+		Message:   message,
+		Steps:     steps,
+		ElapsedMS: b.elapsed.Milliseconds(),
+		Tokens:    b.tokens,
+		Summary:   summarizeAskSteps(history),
+	}
+}
+
+func summarizeAskSteps(history []askActionRecord) []string {
+	out := make([]string, 0, len(history))
+	for _, h := range history {
+		status := "ok"
+		if strings.HasPrefix(h.Result, "error:") {
+			status = "error"
+		}
+		line := fmt.Sprintf("step %d: %s %s", h.Step, h.Action, h.Target)
+		if strings.TrimSpace(h.Args) != "" {
+			line += " " + h.Args
+		}
+		out = append(out, line+" ("+status+")")
+	}
+	return out
+}
+
+func decisionTokenEstimate(prompt, catalog, toolsCatalog, envContext string, d agent.DecisionResult) int {
+	in := estimateTokens(prompt) + estimateTokens(catalog) + estimateTokens(toolsCatalog) + estimateTokens(envContext)
+	out := estimateTokens(d.Answer) + estimateTokens(d.Reason) +
+		estimateTokens(formatPluginArgs(d.PluginArgs)) + estimateTokens(formatToolArgs(d.ToolArgs))
+	return in + out
+}
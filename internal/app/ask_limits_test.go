@@ -0,0 +1,135 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"cli/internal/agent"
+)
+
+func TestResolveAskLimitsPrecedence(t *testing.T) {
+	got := resolveAskLimits(agent.Limits{MaxSteps: 8, StepTimeout: time.Minute, SessionTokens: 5000}, askLimits{MaxSteps: 12})
+	if got.MaxSteps != 12 {
+		t.Fatalf("expected flag to win, got %d", got.MaxSteps)
+	}
+	if got.StepTimeout != time.Minute || got.SessionTokens != 5000 {
+		t.Fatalf("expected config values, got %+v", got)
+	}
+	if got.PromptTokens != promptTokenBudget || got.CatalogTokens != catalogTokenBudget {
+		t.Fatalf("expected built-in defaults, got %+v", got)
+	}
+}
+
+func TestAskBudgetBeforeStep(t *testing.T) {
+	b := newAskBudget(askLimits{MaxSteps: 2, SessionTimeout: time.Second, SessionTokens: 100})
+	if _, _, stop := b.beforeStep(2); stop {
+		t.Fatal("step 2 should be allowed")
+	}
+	if reason, _, stop := b.beforeStep(3); !stop || reason != askStopMaxSteps {
+		t.Fatalf("expected max_steps, got %q", reason)
+	}
+	b.addStep(0, 150)
+	if reason, _, _ := b.beforeStep(1); reason != askStopTokenBudget {
+		t.Fatalf("expected token_budget, got %q", reason)
+	}
+	b.addStep(2*time.Second, 0)
+	if reason, _, _ := b.beforeStep(1); reason != askStopSessionTimeout {
+		t.Fatalf("expected session_timeout, got %q", reason)
+	}
+}
+
+func TestAskBudgetRequestTimeout(t *testing.T) {
+	b := newAskBudget(askLimits{StepTimeout: time.Minute, SessionTimeout: 90 * time.Second})
+	if got := b.requestTimeout(); got != time.Minute {
+		t.Fatalf("expected step timeout, got %s", got)
+	}
+	b.addStep(time.Minute, 0)
+	if got := b.requestTimeout(); got != 30*time.Second {
+		t.Fatalf("expected remaining session time, got %s", got)
+	}
+	if got := newAskBudget(askLimits{}).requestTimeout(); got != 0 {
+		t.Fatalf("expected no timeout, got %s", got)
+	}
+	opts := newAskBudget(askLimits{DecisionTokens: 2048}).decisionOpts(agent.AskOptions{})
+	if opts.MaxTokens != 2048 {
+		t.Fatalf("expected decision tokens applied, got %d", opts.MaxTokens)
+	}
+	opts = newAskBudget(askLimits{DecisionTokens: 2048}).decisionOpts(agent.AskOptions{Profile: "local", MaxTokens: 512})
+	if opts.MaxTokens != 2048 {
+		t.Fatalf("expected decision tokens to win over the profile's max_tokens, got %d", opts.MaxTokens)
+	}
+}
+
+func TestAskBudgetStopSummary(t *testing.T) {
+	b := newAskBudget(askLimits{MaxSteps: 2})
+	stop := b.stop(askStopMaxSteps, "reached max steps (2)", 2, []askActionRecord{
+		{Step: 1, Action: "run_tool", Target: "search", Args: "ext=pdf", Result: "ok"},
+		{Step: 2, Action: "run_plugin", Target: "stibs_db", Result: "error: boom"},
+	})
+	if len(stop.Summary) != 2 {
+		t.Fatalf("expected 2 summary lines, got %v", stop.Summary)
+	}
+	if !strings.HasSuffix(stop.Summary[0], "(ok)") || !strings.HasSuffix(stop.Summary[1], "(error)") {
+		t.Fatalf("unexpected summary %v", stop.Summary)
+	}
+}
+
+func TestAskBudgetActionTimeout(t *testing.T) {
+	b := newAskBudget(askLimits{StepTimeout: time.Minute, SessionTimeout: 10 * time.Minute})
+	if got := b.actionTimeout(20 * time.Second); got != 40*time.Second {
+		t.Fatalf("expected the rest of the step, got %s", got)
+	}
+	if got := b.actionTimeout(2 * time.Minute); got != time.Millisecond {
+		t.Fatalf("expected an already expired step to stop at once, got %s", got)
+	}
+	b.addStep(595*time.Second, 0)
+	if got := b.actionTimeout(time.Second); got != 5*time.Second {
+		t.Fatalf("expected the rest of the session, got %s", got)
+	}
+	if got := newAskBudget(askLimits{}).actionTimeout(time.Hour); got != 0 {
+		t.Fatalf("expected no timeout, got %s", got)
+	}
+}
+
+func TestHandleRunPluginStopsAtStepDeadline(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh scripts")
+	}
+	base := t.TempDir()
+	dir := filepath.Join(base, "plugins")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeBatchScript(t, dir, "slow_check", "exec sleep 5")
+	askRiskBaseDir = base
+
+	run, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	var history []askActionRecord
+	catalog := ""
+	out := newAskJSONWriter()
+	ctx := askStepContext{
+		run: run, baseDir: base, riskPolicy: riskPolicyNormal, jsonOut: true,
+		step: 1, out: out, history: &history, catalog: &catalog,
+		limits: askLimits{MaxSteps: 4},
+	}
+	t0 := time.Now()
+	cont, code := handleRunPlugin(ctx, agent.DecisionResult{Action: "run_plugin", Plugin: "slow_check"})
+	if elapsed := time.Since(t0); elapsed > 3*time.Second {
+		t.Fatalf("the plugin outlived the step deadline (%s)", elapsed)
+	}
+	if cont || code != 0 {
+		t.Fatalf("expected the step to end for the limit report, got %v/%d", cont, code)
+	}
+	if len(out.result.Steps) != 1 || out.result.Steps[0].Status != "timeout" {
+		t.Fatalf("expected a timed out step, got %+v", out.result)
+	}
+	if len(history) != 1 || history[0].Result != askTimedOutResult {
+		t.Fatalf("unexpected history %+v", history)
+	}
+}
@@ -6,19 +6,22 @@ import (
 	"log/slog"
 	"os"
 	"strings"
+	"time"
 
+	"cli/internal/agent"
 	"cli/internal/ui"
 )
 
 type askOutputWriter interface {
 	ProviderInfo(provider, model string)
+	ProviderFallback(attempts []agent.ProviderAttempt)
 	StepInfo(step, maxSteps int, summary, reason, risk, riskReason string)
 	Answer(answer string)
 	PartialAnswer(answer string)
 	Error(msg string)
 	ErrorWithAnswer(msg, answer string)
 	Canceled(answer string)
-	MaxStepsReached(answer string)
+	LimitReached(stop askStop, answer string)
 	LoopDetected(answer string)
 	AddStep(step askJSONStep)
 	Finalize()
@@ -34,6 +37,16 @@ func (w *askTTYWriter) ProviderInfo(provider, model string) {
 	}
 }
 
+func (w *askTTYWriter) ProviderFallback(attempts []agent.ProviderAttempt) {
+	for _, a := range attempts {
+		reason := a.Class
+		if reason == "" {
+			reason = a.Error
+		}
+		fmt.Printf("  %s\n", ui.Muted(fmt.Sprintf("%s/%s failed (%s), fell back", a.Provider, a.Model, reason)))
+	}
+}
+
 func (w *askTTYWriter) StepInfo(step, maxSteps int, summary, reason, risk, riskReason string) {
 	slog.Debug("agent step",
 		"step", fmt.Sprintf("%d/%d", step, maxSteps),
@@ -85,9 +98,16 @@ func (w *askTTYWriter) Canceled(answer string) {
 	}
 }
 
-func (w *askTTYWriter) MaxStepsReached(_ string) {
+func (w *askTTYWriter) LimitReached(stop askStop, _ string) {
 	fmt.Println()
-	fmt.Println(ui.Warn("Reached max steps."))
+	fmt.Println(ui.Warn("Stopped: " + stop.Message + "."))
+	fmt.Println(ui.Muted(fmt.Sprintf("  %d steps, %s, ~%d tokens", stop.Steps, (time.Duration(stop.ElapsedMS) * time.Millisecond).Round(100*time.Millisecond), stop.Tokens)))
+	if len(stop.Summary) > 0 {
+		fmt.Println(ui.Muted("  Done so far:"))
+		for _, line := range stop.Summary {
+			fmt.Println(ui.Muted("    - " + line))
+		}
+	}
 }
 
 func (w *askTTYWriter) LoopDetected(answer string) {
@@ -145,6 +165,10 @@ func (w *askJSONWriter) ProviderInfo(provider, model string) {
 	w.result.Model = model
 }
 
+func (w *askJSONWriter) ProviderFallback(attempts []agent.ProviderAttempt) {
+	w.result.Fallbacks = append(w.result.Fallbacks, attempts...)
+}
+
 func (w *askJSONWriter) StepInfo(_, _ int, _, _, _, _ string) {}
 
 func (w *askJSONWriter) Answer(answer string) {
@@ -178,13 +202,14 @@ func (w *askJSONWriter) Canceled(answer string) {
 	w.emit()
 }
 
-func (w *askJSONWriter) MaxStepsReached(answer string) {
+func (w *askJSONWriter) LimitReached(stop askStop, answer string) {
 	w.result.Action = "answer"
+	w.result.Stopped = &stop
 	if strings.TrimSpace(answer) != "" {
 		w.result.Answer = answer
 	}
 	if strings.TrimSpace(w.result.Answer) == "" {
-		w.result.Answer = "Reached max agent steps; stopping."
+		w.result.Answer = "Stopped: " + stop.Message + "."
 	}
 	w.emit()
 }
@@ -36,6 +36,16 @@ func shouldConfirmAction(confirmTools bool, riskPolicy, risk string) bool {
 	}
 }
 
+// configureTools applies the "shell" and "workspace" config sections to the
+// shell and write tools, and "plugins" to plugin execution.
+func configureTools() {
+	s := agent.ConfiguredShell()
+	tools.SetShellPolicy(tools.ShellPolicy{Allow: s.Allow, Deny: s.Deny, Timeout: s.Timeout, MaxBytes: s.MaxBytes})
+	tools.SetWorkspaceRoots(agent.ConfiguredWorkspaceRoots())
+	plugins.SetPersistentHost(agent.ConfiguredPersistentHost())
+	plugins.SetOutputLimit(agent.ConfiguredPluginOutput().OutputLimit)
+}
+
 func confirmAgentAction(reader *bufio.Reader, risk string) bool {
 	if strings.ToLower(risk) == "high" {
 		fmt.Print("  " + ui.Error("!") + " " + ui.Prompt("Confirm? [y/N] "))
@@ -52,15 +62,19 @@ func assessDecisionRisk(decision agent.DecisionResult) (string, string) {
 		return tools.ToolRisk(decision.Tool, decision.ToolArgs)
 	}
 	if decision.Action == "run_plugin" {
+		info, infoErr := plugins.GetInfo(askRiskBaseDir, decision.Plugin)
+		if infoErr == nil {
+			if _, override := info.Manifest.RiskOverride(info.Name); override {
+				return info.Manifest.FunctionRisk(info.Name)
+			}
+		}
 		name := strings.ToLower(strings.TrimSpace(decision.Plugin))
 		if strings.Contains(name, "reset") || strings.Contains(name, "delete") || strings.Contains(name, "drop") || strings.Contains(name, "rm") {
 			return "high", "plugin may perform destructive operations"
 		}
-		if info, err := plugins.GetInfo(askRiskBaseDir, decision.Plugin); err == nil {
-			safety := plugins.ParseToolkitSafety(info.Path)
-			if safety != "" {
-				risk := plugins.ToolkitRiskLevel(safety)
-				return risk, safety
+		if infoErr == nil {
+			if risk, reason := info.Manifest.FunctionRisk(info.Name); risk != "" {
+				return risk, reason
 			}
 		}
 		return "medium", "external plugin execution"
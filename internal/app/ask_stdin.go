@@ -0,0 +1,86 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"cli/internal/platform"
+)
+
+func stdinIsPiped() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice == 0
+}
+
+// askStdinArg is the prompt argument that asks dm ask to read stdin.
+const askStdinArg = "-"
+
+// askStdinInput is what dm ask read from a piped stdin.
+type askStdinInput struct {
+	text      string
+	truncated bool
+}
+
+// readAskStdin reads a piped stdin only when no prompt is given as
+// arguments, or when "-" or --stdin asks for it: a parent that leaves stdin
+// open (CI runners, ssh without -n, while-read loops) would block ReadAll
+// forever. It returns the prompt arguments without "-".
+func readAskStdin(args []string, explicit, piped bool, r io.Reader) ([]string, askStdinInput, error) {
+	words := make([]string, 0, len(args))
+	for _, a := range args {
+		if a == askStdinArg {
+			explicit = true
+			continue
+		}
+		words = append(words, a)
+	}
+	if !piped || (!explicit && strings.TrimSpace(strings.Join(words, " ")) != "") {
+		return words, askStdinInput{}, nil
+	}
+	text, truncated, err := readPipedInput(r, fileContextMaxBytes)
+	return words, askStdinInput{text: text, truncated: truncated}, err
+}
+
+func readPipedInput(r io.Reader, maxBytes int) (string, bool, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(maxBytes)+1))
+	if err != nil {
+		return "", false, fmt.Errorf("cannot read stdin: %w", err)
+	}
+	truncated := len(data) > maxBytes
+	if truncated {
+		data = data[:maxBytes]
+	}
+	return string(data), truncated, nil
+}
+
+func buildStdinContext(text string, truncated bool) string {
+	body := strings.TrimRight(text, "\r\n")
+	if truncated {
+		body += fmt.Sprintf("\n... (truncated at %d bytes)", fileContextMaxBytes)
+	}
+	return "Attached stdin context:\n--- stdin ---\n" + body + "\n--- end ---"
+}
+
+func joinContextBlocks(blocks ...string) string {
+	var parts []string
+	for _, b := range blocks {
+		if strings.TrimSpace(b) != "" {
+			parts = append(parts, b)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func reopenTTYStdin() bool {
+	tty, err := platform.OpenTTY()
+	if err != nil {
+		return false
+	}
+	os.Stdin = tty
+	return true
+}
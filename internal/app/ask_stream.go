@@ -2,9 +2,11 @@ package app
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 	"sync"
 
+	"cli/internal/agent"
 	"cli/internal/ui"
 )
 
@@ -18,8 +20,15 @@ type answerStreamer struct {
 	escaped   bool
 	spinner   *ui.Spinner
 	jsonOut   bool
+	// live renders the answer line by line while it streams; it is only
+	// enabled once the action is known to be "answer".
+	live    bool
+	started bool
+	md      *ui.MarkdownStream
 }
 
+var answerActionPattern = regexp.MustCompile(`"action"\s*:\s*"answer"`)
+
 func newAnswerStreamer(spinner *ui.Spinner, jsonOut bool) *answerStreamer {
 	return &answerStreamer{spinner: spinner, jsonOut: jsonOut}
 }
@@ -28,6 +37,10 @@ func (s *answerStreamer) OnToken(token string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if token == agent.StreamReset {
+		s.reset()
+		return
+	}
 	s.buf.WriteString(token)
 
 	if s.jsonOut {
@@ -53,6 +66,10 @@ func (s *answerStreamer) OnToken(token string) {
 	s.detected = true
 	s.printing = true
 	s.printed = true
+	if answerActionPattern.MatchString(accumulated[:idx]) {
+		s.live = true
+		s.md = ui.NewMarkdownStream()
+	}
 
 	tail := accumulated[idx:]
 	if tail != "" {
@@ -60,7 +77,27 @@ func (s *answerStreamer) OnToken(token string) {
 	}
 }
 
+// reset drops a failed provider's partial answer before the next provider
+// in the fallback chain starts over. Text already rendered cannot be taken
+// back, so it is closed off and marked as abandoned.
+func (s *answerStreamer) reset() {
+	if s.started {
+		fmt.Print(s.md.Flush())
+		fmt.Println(ui.Muted("  (provider failed mid-answer, retrying with the next one)"))
+	}
+	s.buf.Reset()
+	s.answerBuf.Reset()
+	s.detected, s.printing, s.printed, s.escaped = false, false, false, false
+	s.live, s.started, s.md = false, false, nil
+}
+
 func (s *answerStreamer) emitAnswerChars(token string) {
+	start := s.answerBuf.Len()
+	defer func() {
+		if s.live {
+			s.renderLive(s.answerBuf.String()[start:])
+		}
+	}()
 	for _, ch := range token {
 		if s.escaped {
 			s.escaped = false
@@ -90,6 +127,18 @@ func (s *answerStreamer) emitAnswerChars(token string) {
 	}
 }
 
+func (s *answerStreamer) renderLive(chunk string) {
+	if !s.started {
+		chunk = strings.TrimLeft(chunk, " \t\r\n")
+		if chunk == "" {
+			return
+		}
+		s.started = true
+		fmt.Println()
+	}
+	fmt.Print(s.md.Write(chunk))
+}
+
 func (s *answerStreamer) DidStream() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -102,6 +151,12 @@ func (s *answerStreamer) Finish() {
 	if !s.printed {
 		return
 	}
+	if s.live {
+		if s.started {
+			fmt.Print(s.md.Flush())
+		}
+		return
+	}
 	text := strings.TrimSpace(s.answerBuf.String())
 	if text != "" {
 		fmt.Println()
@@ -109,6 +164,12 @@ func (s *answerStreamer) Finish() {
 	}
 }
 
+func (s *answerStreamer) Text() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strings.TrimSpace(s.answerBuf.String())
+}
+
 func findAnswerValueStart(s string) int {
 	patterns := []string{`"answer":"`, `"answer": "`}
 	for _, p := range patterns {
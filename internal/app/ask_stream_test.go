@@ -0,0 +1,20 @@
+package app
+
+import (
+	"testing"
+
+	"cli/internal/agent"
+)
+
+func TestAnswerStreamerDropsFailedAttempt(t *testing.T) {
+	s := newAnswerStreamer(nil, false)
+	for _, tok := range []string{`{"action":"answer",`, `"answer":"Disk is fu`, agent.StreamReset, `{"action":"answer",`, `"answer":"All good"}`} {
+		s.OnToken(tok)
+	}
+	if got := s.Text(); got != "All good" {
+		t.Fatalf("expected only the fallback's answer, got %q", got)
+	}
+	if !s.DidStream() {
+		t.Fatal("expected the fallback's answer to stream")
+	}
+}
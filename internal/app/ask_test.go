@@ -2,6 +2,7 @@ package app
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -14,7 +15,7 @@ func TestBuildAskPlannerPromptWithHistory(t *testing.T) {
 	history := []askActionRecord{
 		{Step: 1, Action: "run_tool", Target: "search", Args: "name=report, ext=pdf", Result: "ok"},
 	}
-	got := buildAskPlannerPrompt("trova i pdf recenti", history, []string{"prima richiesta"}, nil)
+	got := buildAskPlannerPrompt("trova i pdf recenti", history, []string{"prima richiesta"}, nil, promptTokenBudget)
 	if !strings.Contains(got, "Original user request:") {
 		t.Fatalf("expected original request section, got: %s", got)
 	}
@@ -330,3 +331,159 @@ func TestIsKnownTool(t *testing.T) {
 		}
 	}
 }
+
+func TestReadPipedInput_UnderCap(t *testing.T) {
+	text, truncated, err := readPipedInput(strings.NewReader("diff --git a b"), 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if truncated || text != "diff --git a b" {
+		t.Fatalf("unexpected result text=%q truncated=%v", text, truncated)
+	}
+}
+
+func TestReadPipedInput_Truncates(t *testing.T) {
+	text, truncated, err := readPipedInput(strings.NewReader(strings.Repeat("x", 100)), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !truncated || len(text) != 10 {
+		t.Fatalf("expected 10 bytes truncated, got len=%d truncated=%v", len(text), truncated)
+	}
+}
+
+func TestReadAskStdin_ArgsSkipOpenStdin(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	if _, err := w.WriteString("ctx"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The writer stays open, as a CI runner or ssh without -n leaves it.
+	done := make(chan []string, 1)
+	go func() {
+		words, in, _ := readAskStdin([]string{"what", "changed?"}, false, true, r)
+		if in.text != "" {
+			words = nil
+		}
+		done <- words
+	}()
+	select {
+	case words := <-done:
+		if strings.Join(words, " ") != "what changed?" {
+			t.Fatalf("expected the prompt args without stdin, got %q", words)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("dm ask read an open stdin although the prompt was given as arguments")
+	}
+
+	w.Close()
+	words, in, err := readAskStdin([]string{"summarize", "-"}, false, true, r)
+	if err != nil || strings.Join(words, " ") != "summarize" || in.text != "ctx" {
+		t.Fatalf("expected - to read stdin, got %q %+v %v", words, in, err)
+	}
+	if _, in, _ := readAskStdin(nil, false, true, strings.NewReader("prompt")); in.text != "prompt" {
+		t.Fatalf("expected stdin to be read without prompt args, got %+v", in)
+	}
+}
+
+func TestBuildStdinContext(t *testing.T) {
+	got := buildStdinContext("line1\nline2\n", true)
+	if !strings.Contains(got, "--- stdin ---\nline1\nline2\n") {
+		t.Fatalf("expected stdin block, got %q", got)
+	}
+	if !strings.Contains(got, "truncated") {
+		t.Fatalf("expected truncation note, got %q", got)
+	}
+}
+
+func TestJoinContextBlocksSkipsEmpty(t *testing.T) {
+	got := joinContextBlocks("", "a", "  ", "b")
+	if got != "a\nb" {
+		t.Fatalf("expected %q, got %q", "a\nb", got)
+	}
+}
+
+func TestBuildScopePromptNotes(t *testing.T) {
+	base := t.TempDir()
+	dir := filepath.Join(base, "plugins")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	stibs := "# STIBS DB TOOLKIT\n#\n# AGENT NOTES\n#   Use the staging DB.\n#\n\nfunction stibs_db_query {}\n"
+	other := "# NET TOOLKIT\n\nfunction net_ping {}\n"
+	if err := os.WriteFile(filepath.Join(dir, "STIBS_DB_Toolkit.ps1"), []byte(stibs), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Network_Toolkit.ps1"), []byte(other), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := buildScopePromptNotes(base, "stibs"); got != "[STIBS DB]\nUse the staging DB." {
+		t.Fatalf("unexpected notes %q", got)
+	}
+	if got := buildScopePromptNotes(base, ""); got != "" {
+		t.Fatalf("expected no notes without scope, got %q", got)
+	}
+}
+
+func TestAssessDecisionRiskUsesToolkitManifest(t *testing.T) {
+	baseDir := t.TempDir()
+	kit := "# KIT TOOLKIT\n# Safety: Read-only defaults.\n#\n# ---\n# risk: kit_reset=medium, kit_export=high\n# ---\n" +
+		"function kit_reset {}\nfunction kit_export {}\nfunction kit_status {}\n"
+	if err := os.MkdirAll(filepath.Join(baseDir, "plugins"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(baseDir, "plugins", "Kit_Toolkit.ps1"), []byte(kit), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	prev := askRiskBaseDir
+	askRiskBaseDir = baseDir
+	defer func() { askRiskBaseDir = prev }()
+
+	for plugin, want := range map[string]string{"kit_reset": "medium", "kit_export": "high", "kit_status": "low"} {
+		risk, reason := assessDecisionRisk(agent.DecisionResult{Action: "run_plugin", Plugin: plugin})
+		if risk != want {
+			t.Fatalf("%s: expected %s risk, got %q (%s)", plugin, want, risk, reason)
+		}
+	}
+}
+
+func TestHandleRunToolWriteAsksOnceAfterDiff(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("DM_JOURNAL_DIR", t.TempDir())
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, []byte("old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A single "y": a generic confirmation before the diff would use it up
+	// and the write's own question would then read EOF and cancel.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.WriteString("y\n")
+	w.Close()
+	prevStdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = prevStdin }()
+
+	var history []askActionRecord
+	catalog := ""
+	ctx := askStepContext{
+		baseDir: dir, confirmTools: true, riskPolicy: riskPolicyNormal, jsonOut: true,
+		step: 1, out: newAskJSONWriter(), history: &history, catalog: &catalog,
+		limits: askLimits{MaxSteps: 4},
+	}
+	decision := agent.DecisionResult{Action: "run_tool", Tool: "write", ToolArgs: map[string]string{"path": path, "search": "old", "replace": "new"}}
+	if cont, code := handleRunTool(ctx, decision); !cont || code != 0 {
+		t.Fatalf("expected the step to continue, got %v/%d", cont, code)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new\n" {
+		t.Fatalf("expected the file to be written after one confirmation, got %q", data)
+	}
+}
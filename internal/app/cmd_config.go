@@ -0,0 +1,191 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"cli/internal/agent"
+	"cli/internal/doctor"
+
+	"github.com/spf13/cobra"
+)
+
+func newConfigCommand() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "View and edit the agent config (dm.agent.json)",
+		Long: "Read and write dm.agent.json with dotted keys (e.g. openai.model). " +
+			"The file is resolved like 'dm ask': DM_AGENT_CONFIG, next to the executable, then ~/.config/dm/agent.json.",
+		Example: "dm config show\n" +
+			"dm config get openai.model\n" +
+			"dm config set openai.model gpt-4o\n" +
+			"dm config unset ollama.base_url\n" +
+			"dm config path\n" +
+			"dm config validate",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigShow(false)
+		},
+	}
+
+	var showReveal bool
+	showCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the whole config (secrets masked)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigShow(showReveal)
+		},
+	}
+	showCmd.Flags().BoolVar(&showReveal, "reveal", false, "print secrets such as api_key unmasked")
+	configCmd.AddCommand(showCmd)
+
+	var getReveal bool
+	getCmd := &cobra.Command{
+		Use:               "get <key>",
+		Short:             "Print a single value",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeConfigKeys(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := readConfigMap(agent.ConfigPath())
+			if err != nil {
+				return err
+			}
+			v, ok := configGet(cfg, args[0])
+			if !ok {
+				return fmt.Errorf("key not set: %s", args[0])
+			}
+			if !getReveal {
+				parts, _ := splitConfigKey(args[0])
+				v = maskConfigValue(parts[len(parts)-1], v)
+			}
+			fmt.Println(formatConfigValue(v))
+			return nil
+		},
+	}
+	getCmd.Flags().BoolVar(&getReveal, "reveal", false, "print secrets such as api_key unmasked")
+	configCmd.AddCommand(getCmd)
+
+	var setJSON bool
+	setCmd := &cobra.Command{
+		Use:               "set <key> <value>",
+		Short:             "Set a value and save the config",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeConfigKeys(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := agent.ConfigPath()
+			cfg, err := readConfigMap(path)
+			if err != nil {
+				return err
+			}
+			value, err := parseConfigValue(args[0], args[1], setJSON)
+			if err != nil {
+				return err
+			}
+			if err := configSet(cfg, args[0], value); err != nil {
+				return err
+			}
+			if err := writeConfigMap(path, cfg); err != nil {
+				return err
+			}
+			fmt.Printf("Set %s in %s\n", args[0], path)
+			return nil
+		},
+	}
+	setCmd.Flags().BoolVar(&setJSON, "json", false, "parse value as a JSON literal (objects, arrays, keys outside the schema)")
+	configCmd.AddCommand(setCmd)
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:               "unset <key>",
+		Short:             "Remove a value and save the config",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeConfigKeys(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := agent.ConfigPath()
+			cfg, err := readConfigMap(path)
+			if err != nil {
+				return err
+			}
+			removed, err := configUnset(cfg, args[0])
+			if err != nil {
+				return err
+			}
+			if !removed {
+				fmt.Println("Key not set:", args[0])
+				return nil
+			}
+			if err := writeConfigMap(path, cfg); err != nil {
+				return err
+			}
+			fmt.Printf("Unset %s in %s\n", args[0], path)
+			return nil
+		},
+	})
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "path",
+		Short: "Print the resolved config file path",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := agent.ConfigPath()
+			fmt.Println(path)
+			if !fileExists(path) {
+				fmt.Fprintln(os.Stderr, "(file does not exist yet; 'dm config set' will create it)")
+			}
+			return nil
+		},
+	})
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "validate",
+		Short: "Check the config file for errors",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := doctor.CheckAgentConfig()
+			fmt.Printf("[%s] %s\n", c.Level, c.Message)
+			if c.Level == doctor.LevelError {
+				return exitCodeError{code: 1}
+			}
+			return nil
+		},
+	})
+
+	return configCmd
+}
+
+func runConfigShow(reveal bool) error {
+	path := agent.ConfigPath()
+	cfg, err := readConfigMap(path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "# "+path)
+	var v any = cfg
+	if !reveal {
+		v = maskConfigValue("", cfg)
+	}
+	fmt.Println(formatConfigValue(v))
+	return nil
+}
+
+func completeConfigKeys() func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		cfg, err := readConfigMap(agent.ConfigPath())
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var keys []string
+		flattenConfigKeys("", cfg, &keys)
+		out := make([]string, 0, len(keys))
+		for _, k := range keys {
+			if strings.HasPrefix(k, toComplete) {
+				out = append(out, k)
+			}
+		}
+		return out, cobra.ShellCompDirectiveNoFileComp
+	}
+}
@@ -2,6 +2,8 @@ package app
 
 import (
 	"fmt"
+	"log/slog"
+	"os"
 	"strings"
 
 	"cli/internal/agent"
@@ -57,7 +59,7 @@ func addCobraSubcommands(root *cobra.Command) {
 			if err != nil {
 				return err
 			}
-			report := doctor.Run(rt.BaseDir)
+			report := doctor.Run(rt.BaseDir, Version)
 			if doctorJSON {
 				if err := doctor.RenderJSON(report); err != nil {
 					return err
@@ -73,6 +75,7 @@ func addCobraSubcommands(root *cobra.Command) {
 	}
 	doctorCmd.Flags().BoolVar(&doctorJSON, "json", false, "render diagnostics as JSON")
 	root.AddCommand(doctorCmd)
+	var askProfile string
 	var askProvider string
 	var askModel string
 	var askBaseURL string
@@ -81,19 +84,33 @@ func addCobraSubcommands(root *cobra.Command) {
 	var askRiskPolicy string
 	var askJSON bool
 	var askFiles []string
+	var askStdin bool
 	var askScope string
+	var askResume string
+	var askLimitFlags askLimits
+	var askProtocol string
 	askCmd := &cobra.Command{
 		Use:   "ask <prompt...>",
-		Short: "Ask AI (openai|ollama|auto)",
+		Short: "Ask AI (openai|ollama|anthropic|auto or a configured endpoint)",
 		Long: "Uses provider selected by --provider (default: openai). " +
-			"With --provider auto, dm tries Ollama first and falls back to OpenAI.",
+			"With --provider auto, dm walks the config's \"fallback\" chain (default: Ollama, then OpenAI). " +
+			"Named OpenAI-compatible or Anthropic endpoints from the config's \"endpoints\" section can be selected by name. " +
+			"--profile selects a named profile from config; explicit flags override its values.",
 		Args: cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			askOpts := agent.AskOptions{
+				Profile:  askProfile,
 				Provider: askProvider,
 				Model:    askModel,
 				BaseURL:  askBaseURL,
 			}
+			if !cmd.Flags().Changed("provider") {
+				askOpts.Provider = ""
+			}
+			askOpts, err := agent.ResolveProfile(askOpts)
+			if err != nil {
+				return err
+			}
 			confirmTools := askConfirmTools
 			if askNoConfirmTools {
 				confirmTools = false
@@ -102,6 +119,16 @@ func addCobraSubcommands(root *cobra.Command) {
 			if riskErr != nil {
 				return riskErr
 			}
+			if askLimitFlags.MaxSteps < 0 || askLimitFlags.SessionTokens < 0 || askLimitFlags.DecisionTokens < 0 ||
+				askLimitFlags.PromptTokens < 0 || askLimitFlags.CatalogTokens < 0 ||
+				askLimitFlags.StepTimeout < 0 || askLimitFlags.SessionTimeout < 0 {
+				return fmt.Errorf("limits must not be negative")
+			}
+			limits := resolveAskLimits(agent.ConfiguredLimits(), askLimitFlags)
+			protocol := strings.ToLower(strings.TrimSpace(askProtocol))
+			if protocol != agent.ProtocolJSON && protocol != agent.ProtocolTools {
+				return fmt.Errorf("invalid --protocol %q (use %s)", askProtocol, strings.Join(agent.Protocols(), "|"))
+			}
 			rt, err := loadRuntime()
 			if err != nil {
 				return err
@@ -114,32 +141,58 @@ func addCobraSubcommands(root *cobra.Command) {
 				}
 				fileCtx = fc
 			}
+			piped := stdinIsPiped()
+			words, stdin, readErr := readAskStdin(args, askStdin, piped, os.Stdin)
+			if readErr != nil {
+				return readErr
+			}
+			prompt := strings.TrimSpace(strings.Join(words, " "))
+			if stdin.truncated {
+				fmt.Fprintf(os.Stderr, "Warning: stdin truncated to %d bytes\n", fileContextMaxBytes)
+			}
+			if text := stdin.text; strings.TrimSpace(text) != "" {
+				if prompt == "" {
+					prompt = strings.TrimSpace(text)
+				} else {
+					fileCtx = joinContextBlocks(fileCtx, buildStdinContext(text, stdin.truncated))
+				}
+			}
 			if askJSON {
-				if len(args) == 0 {
-					return fmt.Errorf("--json requires a prompt (non-interactive mode)")
+				if askResume != "" {
+					return fmt.Errorf("--resume is only available in interactive mode")
+				}
+				if prompt == "" {
+					return fmt.Errorf("--json requires a prompt as arguments or on stdin (non-interactive mode)")
 				}
 				code, _ := runAskOnceWithSession(askSessionParams{
-					baseDir: rt.BaseDir, prompt: strings.Join(args, " "), opts: askOpts,
+					baseDir: rt.BaseDir, prompt: prompt, opts: askOpts,
 					confirmTools: confirmTools, riskPolicy: riskPolicy, jsonOut: true,
-					fileContext: fileCtx, scope: askScope,
+					fileContext: fileCtx, scope: askScope, budget: newAskBudget(limits),
+					protocol: protocol,
 				})
 				if code != 0 {
 					return exitCodeError{code: code}
 				}
 				return nil
 			}
-			var initialPrompt string
-			if len(args) > 0 {
-				initialPrompt = strings.Join(args, " ")
+			if piped && !reopenTTYStdin() {
+				slog.Debug("no terminal available after reading stdin; session will end after the first turn")
 			}
-			code := runAskInteractiveWithRisk(rt.BaseDir, askOpts, confirmTools, riskPolicy, initialPrompt, fileCtx, askScope)
+			code := runAskInteractiveWithRisk(rt.BaseDir, askOpts, confirmTools, riskPolicy, prompt, fileCtx, askScope, askResume, limits, protocol)
 			if code != 0 {
 				return exitCodeError{code: code}
 			}
 			return nil
 		},
 	}
-	askCmd.Flags().StringVar(&askProvider, "provider", "openai", "provider: openai|auto|ollama")
+	askCmd.Flags().StringVar(&askProfile, "profile", "", "use a named provider profile from config (default: default_profile)")
+	_ = askCmd.RegisterFlagCompletionFunc("profile", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return agent.ProfileNames(), cobra.ShellCompDirectiveNoFileComp
+	})
+	askCmd.Flags().StringVar(&askProvider, "provider", "openai", "provider: openai|auto|ollama|anthropic or an endpoint name from config")
+	_ = askCmd.RegisterFlagCompletionFunc("provider", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return agent.ProviderNames(), cobra.ShellCompDirectiveNoFileComp
+	})
 	askCmd.Flags().StringVar(&askModel, "model", "", "override model for selected provider")
 	askCmd.Flags().StringVar(&askBaseURL, "base-url", "", "override base URL for selected provider")
 	askCmd.Flags().BoolVar(&askConfirmTools, "confirm-tools", true, "ask confirmation before agent runs a plugin/function/tool")
@@ -148,8 +201,27 @@ func addCobraSubcommands(root *cobra.Command) {
 	askCmd.Flags().StringVar(&askRiskPolicy, "risk-policy", riskPolicyNormal, "risk policy: strict|normal|off")
 	askCmd.Flags().BoolVar(&askJSON, "json", false, "print structured JSON output (non-interactive only)")
 	askCmd.Flags().StringArrayVarP(&askFiles, "file", "f", nil, "attach file as context (repeatable)")
+	askCmd.Flags().BoolVar(&askStdin, "stdin", false, "attach piped stdin as context even when the prompt is given as arguments (same as a - argument)")
 	askCmd.Flags().StringVarP(&askScope, "scope", "s", "", "limit plugin catalog to a toolkit prefix or domain (e.g. stibs, m365, docker)")
+	askCmd.Flags().StringVar(&askResume, "resume", "", "resume a saved session (--resume for the latest, --resume=<id> for a specific one)")
+	askCmd.Flags().Lookup("resume").NoOptDefVal = "last"
+	askCmd.Flags().IntVar(&askLimitFlags.MaxSteps, "max-steps", 0, fmt.Sprintf("max agent steps per request (default %d, or limits.max_steps)", askMaxSteps))
+	askCmd.Flags().DurationVar(&askLimitFlags.StepTimeout, "step-timeout", 0, "wall-clock limit per agent step, e.g. 90s (0 = none)")
+	askCmd.Flags().DurationVar(&askLimitFlags.SessionTimeout, "session-timeout", 0, "total time the agent may spend in this session, e.g. 10m (0 = none)")
+	askCmd.Flags().IntVar(&askLimitFlags.SessionTokens, "token-budget", 0, "total estimated tokens for this session (0 = none)")
+	askCmd.Flags().IntVar(&askLimitFlags.DecisionTokens, "decision-tokens", 0, "max tokens per planner response (default 1024)")
+	askCmd.Flags().IntVar(&askLimitFlags.PromptTokens, "prompt-tokens", 0, fmt.Sprintf("token budget for the planner prompt history (default %d)", promptTokenBudget))
+	askCmd.Flags().IntVar(&askLimitFlags.CatalogTokens, "catalog-tokens", 0, fmt.Sprintf("warn when the plugin catalog exceeds this many tokens (default %d)", catalogTokenBudget))
+	askCmd.Flags().StringVar(&askProtocol, "protocol", agent.ProtocolJSON, "decision protocol: json (prompted JSON) or tools (native function calling, OpenAI-compatible and Ollama)")
+	_ = askCmd.RegisterFlagCompletionFunc("protocol", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return agent.Protocols(), cobra.ShellCompDirectiveNoFileComp
+	})
 	root.AddCommand(askCmd)
+	root.AddCommand(newHistoryCommand())
+	root.AddCommand(newRunCommand())
+	root.AddCommand(newInitCommand())
+	root.AddCommand(newUndoCommand())
+	root.AddCommand(newConfigCommand())
 }
 
 func newPluginCommand() *cobra.Command {
@@ -168,12 +240,15 @@ func newPluginCommand() *cobra.Command {
 	pluginCmd := &cobra.Command{
 		Use:   "plugins",
 		Short: "Manage plugins",
-		Long:  "List and execute scripts/functions from the plugins directory.",
+		Long: "List and execute scripts/functions from the plugins directory, and install, update, remove, " +
+			"enable or disable toolkits. Installed toolkits are recorded in plugins/" + plugins.LockFileName + ".",
 		Example: "dm plugins list\n" +
 			"dm plugins list --functions\n" +
 			"dm plugins info restart_backend\n" +
 			"dm plugins menu\n" +
-			"dm plugins run paint",
+			"dm plugins run paint\n" +
+			"dm plugins install https://github.com/me/dm-toolkits.git --ref v1.2.0\n" +
+			"dm plugins disable Docker_Toolkit",
 		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runPluginArgs()
@@ -211,20 +286,131 @@ func newPluginCommand() *cobra.Command {
 			return runPluginArgs("menu")
 		},
 	})
-	pluginCmd.AddCommand(&cobra.Command{
+	var runJSON bool
+	runCmd := &cobra.Command{
 		Use:               "run <name> [args...]",
 		Short:             "Run a plugin",
+		Example:           "dm plugins run sys_ping -ComputerName localhost\n" + "dm plugins run --json dc_ps",
 		Args:              cobra.MinimumNArgs(1),
 		ValidArgsFunction: completePluginEntryNames(),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			out := append([]string{"run"}, args...)
+			out := []string{"run"}
+			if runJSON {
+				out = append(out, "--json")
+			}
+			out = append(out, args...)
 			return runPluginArgs(out...)
 		},
+	}
+	runCmd.Flags().BoolVar(&runJSON, "json", false, "print returned data, stdout, stderr and duration as JSON")
+	// Everything after the plugin name belongs to the plugin.
+	runCmd.Flags().SetInterspersed(false)
+	pluginCmd.AddCommand(runCmd)
+	pluginCmd.AddCommand(&cobra.Command{
+		Use:   "toolkits",
+		Short: "List toolkits with their state and install source",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPluginArgs("toolkits")
+		},
 	})
 
+	var installName, installRef string
+	installCmd := &cobra.Command{
+		Use:   "install <path|git-url|zip>",
+		Short: "Install a toolkit from a file, directory, git repository or zip archive",
+		Example: "dm plugins install ./Jira_Toolkit.ps1\n" +
+			"dm plugins install https://github.com/me/dm-toolkits.git --ref main\n" +
+			"dm plugins install https://example.com/toolkits.zip --name Vendor",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := []string{"install", args[0]}
+			if installName != "" {
+				out = append(out, "--name", installName)
+			}
+			if installRef != "" {
+				out = append(out, "--ref", installRef)
+			}
+			return runPluginArgs(out...)
+		},
+	}
+	installCmd.Flags().StringVarP(&installName, "name", "n", "", "toolkit name (default: base name of the source)")
+	installCmd.Flags().StringVar(&installRef, "ref", "", "git branch or tag to install")
+	pluginCmd.AddCommand(installCmd)
+
+	var updateForce bool
+	updateCmd := &cobra.Command{
+		Use:               "update [toolkit...]",
+		Short:             "Update git-sourced toolkits (all when no name is given)",
+		ValidArgsFunction: completeToolkitNames(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := append([]string{"update"}, args...)
+			if updateForce {
+				out = append(out, "--force")
+			}
+			return runPluginArgs(out...)
+		},
+	}
+	updateCmd.Flags().BoolVar(&updateForce, "force", false, "overwrite local changes to the toolkit")
+	pluginCmd.AddCommand(updateCmd)
+
+	var removeYes bool
+	removeCmd := &cobra.Command{
+		Use:               "remove <toolkit>",
+		Aliases:           []string{"uninstall"},
+		Short:             "Delete a toolkit",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeToolkitNames(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if removeYes {
+				return runPluginArgs("remove", args[0], "--yes")
+			}
+			return runPluginArgs("remove", args[0])
+		},
+	}
+	removeCmd.Flags().BoolVarP(&removeYes, "yes", "y", false, "do not ask for confirmation")
+	pluginCmd.AddCommand(removeCmd)
+
+	for _, verb := range []string{"enable", "disable"} {
+		short := "Show a disabled toolkit again"
+		if verb == "disable" {
+			short = "Hide a toolkit from dm and the agent without deleting it"
+		}
+		pluginCmd.AddCommand(&cobra.Command{
+			Use:               verb + " <toolkit>",
+			Short:             short,
+			Args:              cobra.ExactArgs(1),
+			ValidArgsFunction: completeToolkitNames(),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runPluginArgs(verb, args[0])
+			},
+		})
+	}
+
 	return pluginCmd
 }
 
+func completeToolkitNames() func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		rt, rtErr := loadRuntime()
+		if rtErr != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		items, err := plugins.Toolkits(rt.BaseDir)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		prefix := strings.ToLower(strings.TrimSpace(toComplete))
+		out := make([]string, 0, len(items))
+		for _, tk := range items {
+			if prefix == "" || strings.HasPrefix(strings.ToLower(tk.Name), prefix) {
+				out = append(out, tk.Name)
+			}
+		}
+		return out, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
 func completePluginEntryNames() func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
 	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		rt, rtErr := loadRuntime()
@@ -0,0 +1,181 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+func newHistoryCommand() *cobra.Command {
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "Browse saved ask sessions",
+		Long:  "List, show, remove, and export sessions saved by 'dm ask'. Resume one with 'dm ask --resume=<id>'.",
+		Example: "dm history list\n" +
+			"dm history show last\n" +
+			"dm history export 20260222-101500-a1b2c3 --format md -o session.md\n" +
+			"dm history rm 20260222-101500-a1b2c3",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runHistoryList(0)
+		},
+	}
+
+	var listLimit int
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List saved sessions (newest first)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runHistoryList(listLimit)
+		},
+	}
+	listCmd.Flags().IntVarP(&listLimit, "limit", "n", 0, "max sessions to list (0 = all)")
+	historyCmd.AddCommand(listCmd)
+
+	historyCmd.AddCommand(&cobra.Command{
+		Use:               "show <id|last>",
+		Short:             "Show a saved session",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeHistorySessionIDs(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := askHistoryDir()
+			if err != nil {
+				return err
+			}
+			s, err := resolveAskSession(dir, args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Println(ui.RenderMarkdown(renderAskSessionMarkdown(s)))
+			return nil
+		},
+	})
+
+	historyCmd.AddCommand(&cobra.Command{
+		Use:               "rm <id...>",
+		Aliases:           []string{"remove", "delete"},
+		Short:             "Delete saved sessions",
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeHistorySessionIDs(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := askHistoryDir()
+			if err != nil {
+				return err
+			}
+			for _, id := range args {
+				s, err := resolveAskSession(dir, id)
+				if err != nil {
+					return err
+				}
+				path, err := askSessionPath(dir, s.ID)
+				if err != nil {
+					return err
+				}
+				if err := os.Remove(path); err != nil {
+					return err
+				}
+				fmt.Println("Removed:", s.ID)
+			}
+			return nil
+		},
+	})
+
+	var exportFormat string
+	var exportOut string
+	exportCmd := &cobra.Command{
+		Use:               "export <id|last>",
+		Short:             "Export a saved session as markdown or JSON",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeHistorySessionIDs(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := askHistoryDir()
+			if err != nil {
+				return err
+			}
+			s, err := resolveAskSession(dir, args[0])
+			if err != nil {
+				return err
+			}
+			var data []byte
+			switch strings.ToLower(strings.TrimSpace(exportFormat)) {
+			case "md", "markdown":
+				data = []byte(renderAskSessionMarkdown(s))
+			case "json":
+				raw, err := json.MarshalIndent(s, "", "  ")
+				if err != nil {
+					return err
+				}
+				data = append(raw, '\n')
+			default:
+				return fmt.Errorf("invalid --format %q (use md|json)", exportFormat)
+			}
+			if strings.TrimSpace(exportOut) == "" {
+				_, err = os.Stdout.Write(data)
+				return err
+			}
+			if err := os.WriteFile(exportOut, data, 0644); err != nil {
+				return err
+			}
+			fmt.Println("Exported:", exportOut)
+			return nil
+		},
+	}
+	exportCmd.Flags().StringVar(&exportFormat, "format", "md", "export format: md|json")
+	exportCmd.Flags().StringVarP(&exportOut, "output", "o", "", "write to file instead of stdout")
+	historyCmd.AddCommand(exportCmd)
+
+	return historyCmd
+}
+
+func runHistoryList(limit int) error {
+	dir, err := askHistoryDir()
+	if err != nil {
+		return err
+	}
+	sessions, err := listAskSessions(dir)
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		fmt.Println("No saved sessions.")
+		return nil
+	}
+	if limit > 0 && len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+	for _, s := range sessions {
+		fmt.Printf("%s  %s  %s  %s\n",
+			ui.Accent(s.ID),
+			ui.Muted(s.UpdatedAt.Local().Format("2006-01-02 15:04")),
+			ui.Muted(fmt.Sprintf("%2d turns", len(s.Turns))),
+			s.title(),
+		)
+	}
+	return nil
+}
+
+func completeHistorySessionIDs() func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		dir, err := askHistoryDir()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		sessions, err := listAskSessions(dir)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		out := []string{"last"}
+		for _, s := range sessions {
+			if strings.HasPrefix(s.ID, toComplete) {
+				out = append(out, s.ID)
+			}
+		}
+		return out, cobra.ShellCompDirectiveNoFileComp
+	}
+}
@@ -0,0 +1,349 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"cli/internal/agent"
+	"cli/internal/ui"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// openAIKeyPlaceholder is the api_key value shipped in dm.agent.example.json.
+const openAIKeyPlaceholder = "OPENAI_KEY"
+
+type initOptions struct {
+	Yes         bool
+	Path        string
+	OllamaURL   string
+	OllamaModel string
+	OpenAIKey   string
+	OpenAIURL   string
+	OpenAIModel string
+	Provider    string
+	Completion  string
+}
+
+func newInitCommand() *cobra.Command {
+	var o initOptions
+	initCmd := &cobra.Command{
+		Use:   "init",
+		Short: "Set up the agent config (providers, models, completion)",
+		Long: "Guided first-run setup: detects a local Ollama and its models, tests an OpenAI key, " +
+			"picks the default provider and writes the agent config to the path 'dm config path' prints. " +
+			"Existing settings are kept unless changed. With --yes no questions are asked.",
+		Example: "dm init\n" +
+			"dm init --yes\n" +
+			"dm init --yes --provider ollama --ollama-model llama3:8b --completion powershell",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runInit(cmd.Root(), o, bufio.NewReader(os.Stdin))
+		},
+	}
+	initCmd.Flags().BoolVarP(&o.Yes, "yes", "y", false, "accept defaults without prompting")
+	initCmd.Flags().StringVar(&o.OllamaURL, "ollama-url", "", "Ollama base URL")
+	initCmd.Flags().StringVar(&o.OllamaModel, "ollama-model", "", "Ollama model")
+	initCmd.Flags().StringVar(&o.OpenAIKey, "openai-key", "", "OpenAI API key (default: OPENAI_API_KEY)")
+	initCmd.Flags().StringVar(&o.OpenAIURL, "openai-base-url", "", "OpenAI-compatible base URL")
+	initCmd.Flags().StringVar(&o.OpenAIModel, "openai-model", "", "OpenAI model")
+	initCmd.Flags().StringVar(&o.Provider, "provider", "", "default provider: ollama|openai|auto")
+	initCmd.Flags().StringVar(&o.Completion, "completion", "", "install shell completion: powershell|bash|zsh|fish")
+	_ = initCmd.RegisterFlagCompletionFunc("provider", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"ollama", "openai", "auto"}, cobra.ShellCompDirectiveNoFileComp
+	})
+	_ = initCmd.RegisterFlagCompletionFunc("completion", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"powershell", "bash", "zsh", "fish"}, cobra.ShellCompDirectiveNoFileComp
+	})
+	return initCmd
+}
+
+type initPrompter struct {
+	yes    bool
+	reader *bufio.Reader
+}
+
+func (p initPrompter) ask(label, def string) string {
+	if p.yes {
+		return def
+	}
+	if def != "" {
+		fmt.Print(ui.Prompt(fmt.Sprintf("%s [%s]: ", label, def)))
+	} else {
+		fmt.Print(ui.Prompt(label + ": "))
+	}
+	if v := readLine(p.reader); v != "" {
+		return v
+	}
+	return def
+}
+
+func (p initPrompter) secret(label string) string {
+	if p.yes {
+		return ""
+	}
+	fmt.Print(ui.Prompt(label + ": "))
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		b, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err == nil {
+			return strings.TrimSpace(string(b))
+		}
+	}
+	return readLine(p.reader)
+}
+
+func (p initPrompter) confirm(label string, def bool) bool {
+	if p.yes {
+		return def
+	}
+	hint := "[y/N]"
+	if def {
+		hint = "[Y/n]"
+	}
+	fmt.Print(ui.Prompt(label + " " + hint + " "))
+	switch strings.ToLower(readLine(p.reader)) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	}
+	return def
+}
+
+func configString(cfg map[string]any, key string) string {
+	v, ok := configGet(cfg, key)
+	if !ok {
+		return ""
+	}
+	s, _ := v.(string)
+	return strings.TrimSpace(s)
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+func runInit(root *cobra.Command, o initOptions, reader *bufio.Reader) error {
+	p := initPrompter{yes: o.Yes, reader: reader}
+	path := firstNonEmpty(o.Path, agent.ConfigPath())
+	cfg, err := readConfigMap(path)
+	if err != nil {
+		return err
+	}
+	ui.PrintSection("dm init")
+	if fileExists(path) {
+		fmt.Println("Updating", path)
+	} else {
+		fmt.Println("Creating", path)
+	}
+
+	ollamaOK := initOllama(p, o, cfg)
+	openAIOK := initOpenAI(p, o, cfg)
+
+	provider, err := initDefaultProvider(p, o, ollamaOK, openAIOK)
+	if err != nil {
+		return err
+	}
+	profile := firstNonEmpty(configString(cfg, "default_profile"), "default")
+	if prev := configString(cfg, "profiles."+profile+".provider"); prev != "" && prev != provider {
+		// A model pinned for the old provider would not exist on the new one.
+		_, _ = configUnset(cfg, "profiles."+profile+".model")
+	}
+	if err := configSet(cfg, "profiles."+profile+".provider", provider); err != nil {
+		return err
+	}
+	if err := configSet(cfg, "default_profile", profile); err != nil {
+		return err
+	}
+	if err := writeConfigMap(path, cfg); err != nil {
+		return err
+	}
+	fmt.Println(ui.OK("OK:"), "config written to", path)
+	fmt.Printf("Default provider: %s (profile %q)\n", provider, profile)
+
+	shell := strings.ToLower(strings.TrimSpace(o.Completion))
+	if shell == "" && !o.Yes && p.confirm("Install shell completion?", false) {
+		shell = p.ask("Shell (powershell|bash|zsh|fish)", defaultCompletionShell())
+	}
+	if shell != "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		scriptPath, profilePath, err := installCompletion(root, home, shell)
+		if err != nil {
+			return fmt.Errorf("completion install failed: %w", err)
+		}
+		fmt.Println(ui.OK("OK:"), "completion installed:", scriptPath)
+		if profilePath != "" {
+			fmt.Println("Profile:", profilePath)
+		}
+	}
+	fmt.Println(ui.Muted("Next: dm doctor, then dm ask \"hello\""))
+	return nil
+}
+
+// initOllama probes Ollama and stores its base URL and model. It reports
+// whether the server answered.
+func initOllama(p initPrompter, o initOptions, cfg map[string]any) bool {
+	fmt.Println()
+	fmt.Println(ui.Accent("Ollama"))
+	baseURL := firstNonEmpty(o.OllamaURL, configString(cfg, "ollama.base_url"), agent.DefaultOllamaBaseURL)
+	if o.OllamaURL == "" {
+		baseURL = p.ask("Base URL", baseURL)
+	}
+	models, err := agent.OllamaModels(baseURL)
+	if err != nil {
+		fmt.Println(ui.Warn("!"), "not reachable at", baseURL+":", err)
+		if o.OllamaURL != "" || o.OllamaModel != "" {
+			_ = configSet(cfg, "ollama.base_url", baseURL)
+			if o.OllamaModel != "" {
+				_ = configSet(cfg, "ollama.model", o.OllamaModel)
+			}
+		}
+		return false
+	}
+	fmt.Printf("%s reachable at %s (%d models)\n", ui.OK("OK:"), baseURL, len(models))
+	for i, m := range models {
+		fmt.Printf("%3d) %s\n", i+1, m)
+	}
+	current := configString(cfg, "ollama.model")
+	def := firstNonEmpty(o.OllamaModel, agent.DefaultOllamaModel)
+	if o.OllamaModel == "" {
+		switch {
+		case current != "" && containsString(models, current):
+			def = current
+		case containsString(models, agent.DefaultOllamaModel):
+		case len(models) > 0:
+			def = models[0]
+		}
+	}
+	model := def
+	if o.OllamaModel == "" && len(models) > 0 {
+		model = p.ask("Model (number or name)", def)
+		if n, err := strconv.Atoi(model); err == nil && n >= 1 && n <= len(models) {
+			model = models[n-1]
+		}
+	}
+	if len(models) == 0 {
+		fmt.Println(ui.Warn("!"), "no models installed; run 'ollama pull "+model+"'")
+	}
+	_ = configSet(cfg, "ollama.base_url", baseURL)
+	_ = configSet(cfg, "ollama.model", model)
+	return true
+}
+
+// initOpenAI asks for and tests an OpenAI key. Keys taken from
+// OPENAI_API_KEY are tested but not written to the config.
+func initOpenAI(p initPrompter, o initOptions, cfg map[string]any) bool {
+	fmt.Println()
+	fmt.Println(ui.Accent("OpenAI"))
+	baseURL := firstNonEmpty(o.OpenAIURL, configString(cfg, "openai.base_url"), agent.DefaultOpenAIBaseURL)
+	stored := configString(cfg, "openai.api_key")
+	if stored == openAIKeyPlaceholder {
+		stored = ""
+	}
+	envKey := strings.TrimSpace(os.Getenv("OPENAI_API_KEY"))
+
+	key, source := strings.TrimSpace(o.OpenAIKey), "flag"
+	if key == "" {
+		switch {
+		case stored != "":
+			key, source = stored, "config"
+		case envKey != "":
+			key, source = envKey, "env"
+		}
+		label := "API key (empty to skip)"
+		if key != "" {
+			label = fmt.Sprintf("API key (empty keeps %s from %s)", maskSecret(key), source)
+		}
+		if typed := p.secret(label); typed != "" {
+			key, source = typed, "prompt"
+		}
+	}
+	if key == "" {
+		fmt.Println(ui.Muted("skipped (no key)"))
+		return false
+	}
+
+	ok := true
+	if err := agent.CheckOpenAIKey(baseURL, key); err != nil {
+		fmt.Println(ui.Warn("!"), "key check failed:", err)
+		ok = false
+	} else {
+		fmt.Println(ui.OK("OK:"), "key accepted by", baseURL+"/models")
+	}
+	switch source {
+	case "flag", "prompt":
+		_ = configSet(cfg, "openai.api_key", key)
+	case "env":
+		if stored == "" {
+			_, _ = configUnset(cfg, "openai.api_key")
+		}
+	}
+	model := firstNonEmpty(o.OpenAIModel, configString(cfg, "openai.model"), agent.DefaultOpenAIModel)
+	if o.OpenAIModel == "" {
+		model = p.ask("Model", model)
+	}
+	_ = configSet(cfg, "openai.model", model)
+	if baseURL != agent.DefaultOpenAIBaseURL || configString(cfg, "openai.base_url") != "" {
+		_ = configSet(cfg, "openai.base_url", baseURL)
+	}
+	return ok
+}
+
+func initDefaultProvider(p initPrompter, o initOptions, ollamaOK, openAIOK bool) (string, error) {
+	def := "openai"
+	switch {
+	case ollamaOK && openAIOK:
+		def = "auto"
+	case ollamaOK:
+		def = "ollama"
+	}
+	provider := strings.ToLower(strings.TrimSpace(o.Provider))
+	if provider == "" {
+		fmt.Println()
+		provider = strings.ToLower(p.ask("Default provider (ollama|openai|auto)", def))
+	}
+	if !containsString(agent.ProviderNames(), provider) {
+		return "", fmt.Errorf("invalid provider %q (use %s)", provider, strings.Join(agent.ProviderNames(), "|"))
+	}
+	if (provider == "ollama" && !ollamaOK) || (provider == "openai" && !openAIOK) {
+		fmt.Println(ui.Warn("!"), provider, "did not pass its check; run 'dm doctor' after fixing it")
+	}
+	return provider, nil
+}
+
+func defaultCompletionShell() string {
+	if runtime.GOOS == "windows" {
+		return "powershell"
+	}
+	switch filepath.Base(os.Getenv("SHELL")) {
+	case "zsh":
+		return "zsh"
+	case "fish":
+		return "fish"
+	}
+	return "bash"
+}
+
+func containsString(items []string, v string) bool {
+	for _, it := range items {
+		if it == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newInitTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			fmt.Fprint(w, `{"models":[{"name":"qwen2.5:7b"},{"name":"llama3:8b"}]}`)
+		case "/v1/models":
+			if r.Header.Get("Authorization") != "Bearer sk-good" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"data":[]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRunInit_YesWritesConfig(t *testing.T) {
+	srv := newInitTestServer(t)
+	path := filepath.Join(t.TempDir(), "agent.json")
+	t.Setenv("OPENAI_API_KEY", "")
+	o := initOptions{Yes: true, Path: path, OllamaURL: srv.URL, OpenAIURL: srv.URL + "/v1", OpenAIKey: "sk-good"}
+	if err := runInit(nil, o, bufio.NewReader(strings.NewReader(""))); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := readConfigMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"ollama.base_url":           srv.URL,
+		"ollama.model":              "llama3:8b",
+		"openai.api_key":            "sk-good",
+		"openai.base_url":           srv.URL + "/v1",
+		"openai.model":              "gpt-4o-mini",
+		"default_profile":           "default",
+		"profiles.default.provider": "auto",
+	}
+	for k, v := range want {
+		if got := configString(cfg, k); got != v {
+			t.Fatalf("%s = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestRunInit_KeepsExistingProfileAndEnvKey(t *testing.T) {
+	srv := newInitTestServer(t)
+	path := filepath.Join(t.TempDir(), "agent.json")
+	if err := writeConfigMap(path, map[string]any{
+		"default_profile": "work",
+		"profiles":        map[string]any{"work": map[string]any{"provider": "ollama", "model": "x"}},
+		"openai":          map[string]any{"api_key": openAIKeyPlaceholder},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OPENAI_API_KEY", "sk-bad")
+	o := initOptions{Yes: true, Path: path, OllamaURL: srv.URL + "/missing", OpenAIURL: srv.URL + "/v1"}
+	if err := runInit(nil, o, bufio.NewReader(strings.NewReader(""))); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := readConfigMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := configString(cfg, "profiles.work.provider"); got != "openai" {
+		t.Fatalf("profiles.work.provider = %q, want openai", got)
+	}
+	if _, ok := configGet(cfg, "profiles.work.model"); ok {
+		t.Fatal("model pinned for the previous provider should be dropped")
+	}
+	if _, ok := configGet(cfg, "openai.api_key"); ok {
+		t.Fatal("placeholder api_key must be dropped in favour of OPENAI_API_KEY")
+	}
+
+	o.Provider = "nope"
+	if err := runInit(nil, o, bufio.NewReader(strings.NewReader(""))); err == nil {
+		t.Fatal("expected invalid provider error")
+	}
+}
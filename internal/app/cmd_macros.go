@@ -0,0 +1,89 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newRunCommand() *cobra.Command {
+	var dryRun bool
+	var list bool
+	runCmd := &cobra.Command{
+		Use:   "run <macro> [name=value...]",
+		Short: "Run a macro from dm.macros.json",
+		Long: "Runs a named sequence of plugin, tool and shell steps defined in dm.macros.json " +
+			"(next to dm and in the current directory; DM_MACROS overrides both). " +
+			"Pass name=value to set ${name} variables. Macros from the current directory run only through dm run.",
+		Example: "dm run --list\n" +
+			"dm run morning\n" +
+			"dm run deploy env=prod --dry-run",
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeMacroNames(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rt, err := loadRuntime()
+			if err != nil {
+				return err
+			}
+			macros, err := loadMacros(rt.BaseDir)
+			if err != nil {
+				return err
+			}
+			if list || len(args) == 0 {
+				printMacroList(macros)
+				return nil
+			}
+			m, ok := macros[args[0]]
+			if !ok {
+				msg := fmt.Sprintf("unknown macro %q", args[0])
+				if s := suggestClosest(args[0], macroNames(macros), 3); s != "" {
+					msg += fmt.Sprintf(" (did you mean %s?)", s)
+				}
+				return fmt.Errorf("%s", msg)
+			}
+			rest := args[1:]
+			if dryRun {
+				rest = append(rest, macroDryRunFlag)
+			}
+			if code := runMacro(rt.BaseDir, args[0], m, rest); code != 0 {
+				return exitCodeError{code: code}
+			}
+			return nil
+		},
+	}
+	runCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "print the resolved steps without running them")
+	runCmd.Flags().BoolVarP(&list, "list", "l", false, "list defined macros")
+	return runCmd
+}
+
+func completeMacroNames() func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		rt, rtErr := loadRuntime()
+		if rtErr != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		macros, err := loadMacros(rt.BaseDir)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		prefix := strings.ToLower(strings.TrimSpace(toComplete))
+		out := make([]string, 0, len(macros))
+		for _, name := range macroNames(macros) {
+			if prefix == "" || strings.HasPrefix(strings.ToLower(name), prefix) {
+				out = append(out, name+"\t"+macroCompletionHint(macros[name]))
+			}
+		}
+		return out, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func macroCompletionHint(m macroDef) string {
+	if d := strings.TrimSpace(m.Description); d != "" {
+		return d
+	}
+	return fmt.Sprintf("macro (%d steps)", len(m.Steps))
+}
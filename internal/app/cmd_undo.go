@@ -0,0 +1,95 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"cli/internal/journal"
+	"cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+func newUndoCommand() *cobra.Command {
+	var list bool
+	var limit int
+	undoCmd := &cobra.Command{
+		Use:   "undo [op-id|last]",
+		Short: "Revert a journaled rename, cleanup or file edit",
+		Long: "Reverts a filesystem change recorded by the rename, clean and write tools, including those run by 'dm ask'. " +
+			"The current state is checked first; nothing is changed if files moved since. " +
+			"Without an id the newest operation that is not undone yet is reverted.",
+		Example: "dm undo --list\n" +
+			"dm undo\n" +
+			"dm undo 20260222-101500-a1b2c3",
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeJournalOpIDs(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if list {
+				return runUndoList(limit)
+			}
+			id := ""
+			if len(args) > 0 {
+				id = args[0]
+			}
+			op, err := journal.Find(id)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Undoing %s: %s %s (%s)\n", op.ID, op.Tool, op.Summary(), op.CreatedAt.Local().Format("2006-01-02 15:04"))
+			n, err := journal.Undo(op)
+			if err != nil {
+				return err
+			}
+			fmt.Println(ui.OK("OK:"), fmt.Sprintf("reverted %d change(s)", n))
+			return nil
+		},
+	}
+	undoCmd.Flags().BoolVarP(&list, "list", "l", false, "list journaled operations (newest first)")
+	undoCmd.Flags().IntVarP(&limit, "limit", "n", 20, "max operations to list (0 = all)")
+	return undoCmd
+}
+
+func runUndoList(limit int) error {
+	ops, err := journal.List()
+	if err != nil {
+		return err
+	}
+	if len(ops) == 0 {
+		fmt.Println("No journaled operations.")
+		return nil
+	}
+	if limit > 0 && len(ops) > limit {
+		ops = ops[:limit]
+	}
+	for _, op := range ops {
+		origin := op.Source
+		if op.Session != "" {
+			origin += " " + op.Session
+		}
+		fmt.Printf("%s  %-7s %-8s %s %s\n", ui.Accent(op.ID), op.Status, op.Tool, op.Summary(), ui.Muted("("+origin+")"))
+	}
+	return nil
+}
+
+func completeJournalOpIDs() func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		ops, err := journal.List()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		out := []string{"last"}
+		for _, op := range ops {
+			if op.Status == journal.StatusUndone || op.Status == journal.StatusFailed {
+				continue
+			}
+			if strings.HasPrefix(op.ID, toComplete) {
+				out = append(out, op.ID+"\t"+op.Tool+" "+op.Summary())
+			}
+		}
+		return out, cobra.ShellCompDirectiveNoFileComp
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"strings"
 
+	"cli/internal/plugins"
 	"cli/internal/ui"
 
 	"github.com/spf13/cobra"
@@ -22,6 +23,7 @@ func (e exitCodeError) Error() string {
 
 func Run(args []string) int {
 	setupSignalHandler()
+	defer plugins.ClosePersistentHost()
 	root := &cobra.Command{
 		Use:   "dm",
 		Short: "Personal CLI for tools, plugins, and AI helpers",
@@ -59,8 +61,10 @@ func Run(args []string) int {
 			level = slog.LevelDebug
 		}
 		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+		configureTools()
 	}
 
+	root.ValidArgsFunction = completeMacroNames()
 	addCobraSubcommands(root)
 	addPluginAwareHelpCommand(root)
 	addCompletionCommands(root)
@@ -95,6 +99,9 @@ func Run(args []string) int {
 			if len(rest) > 0 && rest[0] == "$profile" {
 				return showPowerShellSymbols(resolveUserPowerShellProfilePath(), "$PROFILE")
 			}
+			if code, ok := runMacroIfDefined(rt.BaseDir, rest); ok {
+				return code
+			}
 			return runPluginOrSuggest(rt.BaseDir, rest)
 		}
 		if msg != "" {
@@ -0,0 +1,248 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cli/internal/agent"
+)
+
+var configSecretKeys = []string{"api_key", "token", "password", "secret"}
+
+func readConfigMap(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]any{}, nil
+		}
+		return nil, err
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return map[string]any{}, nil
+	}
+	var out map[string]any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("invalid JSON in %s: %w", path, err)
+	}
+	if out == nil {
+		out = map[string]any{}
+	}
+	return out, nil
+}
+
+func writeConfigMap(path string, cfg map[string]any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0600)
+}
+
+func splitConfigKey(key string) ([]string, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, fmt.Errorf("config key is required")
+	}
+	parts := strings.Split(key, ".")
+	for _, p := range parts {
+		if strings.TrimSpace(p) == "" {
+			return nil, fmt.Errorf("invalid config key %q", key)
+		}
+	}
+	return parts, nil
+}
+
+func configGet(cfg map[string]any, key string) (any, bool) {
+	parts, err := splitConfigKey(key)
+	if err != nil {
+		return nil, false
+	}
+	var cur any = cfg
+	for _, p := range parts {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		cur, ok = m[p]
+		if !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func configSet(cfg map[string]any, key string, value any) error {
+	parts, err := splitConfigKey(key)
+	if err != nil {
+		return err
+	}
+	cur := cfg
+	for i, p := range parts[:len(parts)-1] {
+		next, ok := cur[p]
+		if !ok {
+			child := map[string]any{}
+			cur[p] = child
+			cur = child
+			continue
+		}
+		child, ok := next.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s is not an object", strings.Join(parts[:i+1], "."))
+		}
+		cur = child
+	}
+	cur[parts[len(parts)-1]] = value
+	return nil
+}
+
+func configUnset(cfg map[string]any, key string) (bool, error) {
+	parts, err := splitConfigKey(key)
+	if err != nil {
+		return false, err
+	}
+	cur := cfg
+	for _, p := range parts[:len(parts)-1] {
+		child, ok := cur[p].(map[string]any)
+		if !ok {
+			return false, nil
+		}
+		cur = child
+	}
+	last := parts[len(parts)-1]
+	if _, ok := cur[last]; !ok {
+		return false, nil
+	}
+	delete(cur, last)
+	return true, nil
+}
+
+// parseConfigValue converts a value from the command line to the type the
+// config schema has at key, so strings such as API keys or model names that
+// look like numbers stay strings. Keys outside the schema are stored as
+// strings; --json sets any JSON value.
+func parseConfigValue(key, raw string, asJSON bool) (any, error) {
+	if asJSON {
+		var v any
+		if err := json.Unmarshal([]byte(raw), &v); err != nil {
+			return nil, fmt.Errorf("invalid JSON value: %w", err)
+		}
+		return v, nil
+	}
+	trimmed := strings.TrimSpace(raw)
+	switch agent.ConfigValueKind(key) {
+	case agent.ConfigKindBool:
+		b, err := strconv.ParseBool(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be true or false, got %q", key, raw)
+		}
+		return b, nil
+	case agent.ConfigKindNumber:
+		n, err := strconv.ParseFloat(trimmed, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number, got %q", key, raw)
+		}
+		return n, nil
+	case agent.ConfigKindDuration:
+		if n, err := strconv.ParseFloat(trimmed, 64); err == nil {
+			return n, nil
+		}
+		if _, err := time.ParseDuration(trimmed); err != nil {
+			return nil, fmt.Errorf("%s must be a duration such as 45s or a number of seconds, got %q", key, raw)
+		}
+		return trimmed, nil
+	case agent.ConfigKindObject:
+		return nil, fmt.Errorf("%s is a list or object; set it with --json", key)
+	}
+	return raw, nil
+}
+
+func isSecretConfigKey(key string) bool {
+	lk := strings.ToLower(key)
+	for _, s := range configSecretKeys {
+		if lk == s || strings.HasSuffix(lk, "_"+s) {
+			return true
+		}
+	}
+	return false
+}
+
+func maskSecret(v string) string {
+	if len(v) <= 10 {
+		return strings.Repeat("*", len(v))
+	}
+	return v[:3] + "..." + v[len(v)-4:]
+}
+
+func maskConfigValue(key string, v any) any {
+	switch tv := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(tv))
+		for k, child := range tv {
+			out[k] = maskConfigValue(k, child)
+		}
+		return out
+	case []any:
+		out := make([]any, len(tv))
+		for i, child := range tv {
+			out[i] = maskConfigValue(key, child)
+		}
+		return out
+	case string:
+		if isSecretConfigKey(key) && tv != "" {
+			return maskSecret(tv)
+		}
+		return tv
+	default:
+		return v
+	}
+}
+
+func formatConfigValue(v any) string {
+	switch tv := v.(type) {
+	case string:
+		return tv
+	case map[string]any, []any:
+		data, err := json.MarshalIndent(tv, "", "  ")
+		if err != nil {
+			return fmt.Sprint(tv)
+		}
+		return string(data)
+	default:
+		data, err := json.Marshal(tv)
+		if err != nil {
+			return fmt.Sprint(tv)
+		}
+		return string(data)
+	}
+}
+
+func flattenConfigKeys(prefix string, v any, out *[]string) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		if prefix != "" {
+			*out = append(*out, prefix)
+		}
+		return
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		next := k
+		if prefix != "" {
+			next = prefix + "." + k
+		}
+		flattenConfigKeys(next, m[k], out)
+	}
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigSetGetUnsetDotted(t *testing.T) {
+	cfg := map[string]any{}
+	if err := configSet(cfg, "openai.model", "gpt-4o"); err != nil {
+		t.Fatal(err)
+	}
+	v, ok := configGet(cfg, "openai.model")
+	if !ok || v != "gpt-4o" {
+		t.Fatalf("expected gpt-4o, got %v (ok=%v)", v, ok)
+	}
+	removed, err := configUnset(cfg, "openai.model")
+	if err != nil || !removed {
+		t.Fatalf("expected key removed, got removed=%v err=%v", removed, err)
+	}
+	if _, ok := configGet(cfg, "openai.model"); ok {
+		t.Fatal("expected key to be gone after unset")
+	}
+}
+
+func TestConfigSetThroughScalarFails(t *testing.T) {
+	cfg := map[string]any{"openai": "oops"}
+	if err := configSet(cfg, "openai.model", "x"); err == nil {
+		t.Fatal("expected error when parent is not an object")
+	}
+	if _, err := splitConfigKey("openai..model"); err == nil {
+		t.Fatal("expected error for empty key segment")
+	}
+}
+
+func TestParseConfigValue(t *testing.T) {
+	if v, _ := parseConfigValue("plugins.persistent_host", "true", false); v != true {
+		t.Fatalf("expected bool true, got %#v", v)
+	}
+	if v, _ := parseConfigValue("profiles.local.temperature", "0.5", false); v != 0.5 {
+		t.Fatalf("expected 0.5, got %#v", v)
+	}
+	if v, _ := parseConfigValue("ollama.model", "qwen2.5", false); v != "qwen2.5" {
+		t.Fatalf("expected string, got %#v", v)
+	}
+	for key, raw := range map[string]string{"openai.api_key": "12345", "ollama.model": "3.5", "my.custom": "true"} {
+		if v, err := parseConfigValue(key, raw, false); err != nil || v != raw {
+			t.Fatalf("expected %s to stay the string %q, got %#v (%v)", key, raw, v, err)
+		}
+	}
+	if v, _ := parseConfigValue("limits.step_timeout", "45s", false); v != "45s" {
+		t.Fatalf("expected duration string, got %#v", v)
+	}
+	for key, raw := range map[string]string{"limits.max_steps": "many", "plugins.persistent_host": "yes please", "limits.step_timeout": "soon", "workspace.roots": "/tmp"} {
+		if _, err := parseConfigValue(key, raw, false); err == nil {
+			t.Fatalf("expected %s=%q to be rejected", key, raw)
+		}
+	}
+	v, err := parseConfigValue("workspace.roots", `["a","b"]`, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arr, ok := v.([]any); !ok || len(arr) != 2 {
+		t.Fatalf("expected JSON array, got %#v", v)
+	}
+}
+
+func TestMaskConfigValue(t *testing.T) {
+	cfg := map[string]any{
+		"openai": map[string]any{"api_key": "sk-1234567890abcdef", "model": "gpt-4o"},
+	}
+	masked := maskConfigValue("", cfg).(map[string]any)
+	openai := masked["openai"].(map[string]any)
+	if openai["api_key"] != "sk-...cdef" {
+		t.Fatalf("expected masked key, got %v", openai["api_key"])
+	}
+	if openai["model"] != "gpt-4o" {
+		t.Fatalf("expected model untouched, got %v", openai["model"])
+	}
+	if cfg["openai"].(map[string]any)["api_key"] != "sk-1234567890abcdef" {
+		t.Fatal("expected original config not to be modified")
+	}
+}
+
+func TestWriteAndReadConfigMap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "agent.json")
+	cfg, err := readConfigMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := configSet(cfg, "ollama.model", "llama3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeConfigMap(path, cfg); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readConfigMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := configGet(got, "ollama.model"); v != "llama3" {
+		t.Fatalf("expected llama3 after round trip, got %v", v)
+	}
+}
@@ -0,0 +1,399 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"cli/internal/plugins"
+	"cli/internal/ui"
+	"cli/tools"
+)
+
+const (
+	macrosFileName  = "dm.macros.json"
+	macroOnErrStop  = "stop"
+	macroOnErrCont  = "continue"
+	macroDryRunFlag = "--dry-run"
+)
+
+var macroVarPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_.:-]*)\}`)
+
+// macroFile is the layout of dm.macros.json. Top-level vars are shared by
+// every macro in the file.
+type macroFile struct {
+	Vars   map[string]string   `json:"vars,omitempty"`
+	Macros map[string]macroDef `json:"macros"`
+}
+
+type macroDef struct {
+	Description string            `json:"description,omitempty"`
+	Vars        map[string]string `json:"vars,omitempty"`
+	OnError     string            `json:"on_error,omitempty"`
+	Steps       []macroStep       `json:"steps"`
+
+	Source string `json:"-"`
+}
+
+// macroStep runs exactly one of a plugin, a built-in tool or a shell command.
+type macroStep struct {
+	Name    string            `json:"name,omitempty"`
+	Plugin  string            `json:"plugin,omitempty"`
+	Tool    string            `json:"tool,omitempty"`
+	Shell   string            `json:"shell,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Params  map[string]string `json:"params,omitempty"`
+	OnError string            `json:"on_error,omitempty"`
+}
+
+func (s macroStep) kind() string {
+	switch {
+	case strings.TrimSpace(s.Plugin) != "":
+		return "plugin"
+	case strings.TrimSpace(s.Tool) != "":
+		return "tool"
+	case strings.TrimSpace(s.Shell) != "":
+		return "shell"
+	}
+	return ""
+}
+
+func (s macroStep) describe() string {
+	switch s.kind() {
+	case "plugin":
+		parts := append([]string{s.Plugin}, s.Args...)
+		parts = append(parts, pluginArgsToPS(s.Params)...)
+		return "plugin " + strings.Join(parts, " ")
+	case "tool":
+		line := "tool " + s.Tool
+		if args := formatToolArgs(s.Params); args != "" {
+			line += " " + args
+		}
+		return line
+	case "shell":
+		return "shell " + s.Shell
+	}
+	return "(empty step)"
+}
+
+// macroFilePaths lists the macro files in load order: DM_MACROS alone when
+// set, otherwise the file next to the executable and then the one in the
+// working directory (later files override macros with the same name).
+func macroFilePaths(baseDir string) []string {
+	paths := shortcutMacroFilePaths(baseDir)
+	if strings.TrimSpace(os.Getenv("DM_MACROS")) != "" {
+		return paths
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		local := filepath.Join(cwd, macrosFileName)
+		if filepath.Clean(local) != filepath.Clean(paths[0]) {
+			paths = append(paths, local)
+		}
+	}
+	return paths
+}
+
+// shortcutMacroFilePaths lists the macro files whose macros also run as
+// dm <name>. The file in the working directory comes with whatever was
+// cloned there, so its macros only run through dm run.
+func shortcutMacroFilePaths(baseDir string) []string {
+	if p := strings.TrimSpace(os.Getenv("DM_MACROS")); p != "" {
+		return []string{p}
+	}
+	return []string{filepath.Join(baseDir, macrosFileName)}
+}
+
+func loadMacroFile(path string) (macroFile, error) {
+	var mf macroFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return mf, err
+	}
+	if err := json.Unmarshal(data, &mf); err != nil {
+		return mf, fmt.Errorf("invalid JSON in %s: %w", path, err)
+	}
+	for name, m := range mf.Macros {
+		if err := validateMacro(name, m); err != nil {
+			return mf, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return mf, nil
+}
+
+func validateMacro(name string, m macroDef) error {
+	if strings.TrimSpace(name) == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("invalid macro name %q", name)
+	}
+	if len(m.Steps) == 0 {
+		return fmt.Errorf("macro %s has no steps", name)
+	}
+	if err := validateMacroOnError(m.OnError); err != nil {
+		return fmt.Errorf("macro %s: %w", name, err)
+	}
+	for i, s := range m.Steps {
+		set := 0
+		for _, v := range []string{s.Plugin, s.Tool, s.Shell} {
+			if strings.TrimSpace(v) != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return fmt.Errorf("macro %s step %d: set exactly one of plugin, tool or shell", name, i+1)
+		}
+		if err := validateMacroOnError(s.OnError); err != nil {
+			return fmt.Errorf("macro %s step %d: %w", name, i+1, err)
+		}
+	}
+	return nil
+}
+
+func validateMacroOnError(v string) error {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "", macroOnErrStop, macroOnErrCont:
+		return nil
+	}
+	return fmt.Errorf("invalid on_error %q (use stop|continue)", v)
+}
+
+// loadMacros merges every macro file into one set; file-level vars are
+// folded into each macro so later files cannot change earlier macros.
+func loadMacros(baseDir string) (map[string]macroDef, error) {
+	return loadMacroFiles(macroFilePaths(baseDir))
+}
+
+func loadMacroFiles(paths []string) (map[string]macroDef, error) {
+	out := map[string]macroDef{}
+	for _, p := range paths {
+		mf, err := loadMacroFile(p)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for name, m := range mf.Macros {
+			vars := map[string]string{}
+			for k, v := range mf.Vars {
+				vars[k] = v
+			}
+			for k, v := range m.Vars {
+				vars[k] = v
+			}
+			m.Vars = vars
+			m.Source = p
+			out[name] = m
+		}
+	}
+	return out, nil
+}
+
+func macroNames(macros map[string]macroDef) []string {
+	names := make([]string, 0, len(macros))
+	for name := range macros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseMacroArgs splits the arguments after a macro name into key=value
+// variable overrides and the --dry-run switch.
+func parseMacroArgs(args []string) (map[string]string, bool, error) {
+	vars := map[string]string{}
+	dryRun := false
+	for _, a := range args {
+		if a == macroDryRunFlag || a == "-n" {
+			dryRun = true
+			continue
+		}
+		k, v, ok := strings.Cut(a, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, false, fmt.Errorf("invalid macro argument %q (use name=value)", a)
+		}
+		vars[strings.TrimSpace(k)] = v
+	}
+	return vars, dryRun, nil
+}
+
+// expandMacroVars replaces ${name} with vars[name] and ${env:NAME} with the
+// environment variable. Unknown names are collected into missing.
+func expandMacroVars(s string, vars map[string]string, missing map[string]struct{}) string {
+	return macroVarPattern.ReplaceAllStringFunc(s, func(m string) string {
+		name := m[2 : len(m)-1]
+		if env, ok := strings.CutPrefix(name, "env:"); ok {
+			return os.Getenv(env)
+		}
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		missing[name] = struct{}{}
+		return m
+	})
+}
+
+// resolveMacroSteps returns the steps with variables substituted.
+func resolveMacroSteps(m macroDef, overrides map[string]string) ([]macroStep, error) {
+	vars := map[string]string{}
+	for k, v := range m.Vars {
+		vars[k] = v
+	}
+	for k, v := range overrides {
+		vars[k] = v
+	}
+	missing := map[string]struct{}{}
+	steps := make([]macroStep, len(m.Steps))
+	for i, s := range m.Steps {
+		r := macroStep{
+			Name:    s.Name,
+			Plugin:  expandMacroVars(s.Plugin, vars, missing),
+			Tool:    expandMacroVars(s.Tool, vars, missing),
+			Shell:   expandMacroVars(s.Shell, vars, missing),
+			OnError: strings.ToLower(strings.TrimSpace(s.OnError)),
+		}
+		if r.OnError == "" {
+			r.OnError = strings.ToLower(strings.TrimSpace(m.OnError))
+		}
+		if r.OnError == "" {
+			r.OnError = macroOnErrStop
+		}
+		for _, a := range s.Args {
+			r.Args = append(r.Args, expandMacroVars(a, vars, missing))
+		}
+		if len(s.Params) > 0 {
+			r.Params = make(map[string]string, len(s.Params))
+			for k, v := range s.Params {
+				r.Params[k] = expandMacroVars(v, vars, missing)
+			}
+		}
+		steps[i] = r
+	}
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for n := range missing {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("undefined macro variables: %s (pass name=value)", strings.Join(names, ", "))
+	}
+	return steps, nil
+}
+
+func macroShellCommand(line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		ps := "powershell"
+		if _, err := exec.LookPath("pwsh"); err == nil {
+			ps = "pwsh"
+		}
+		return exec.Command(ps, "-NoProfile", "-Command", line)
+	}
+	return exec.Command("sh", "-c", line)
+}
+
+func runMacroStep(baseDir string, s macroStep) error {
+	switch s.kind() {
+	case "plugin":
+		args := append(append([]string{}, s.Args...), pluginArgsToPS(s.Params)...)
+		return plugins.Run(context.Background(), baseDir, s.Plugin, args)
+	case "tool":
+		if !isKnownTool(s.Tool) {
+			return fmt.Errorf("unknown tool: %s", s.Tool)
+		}
+		if code := tools.RunByNameWithParams(context.Background(), baseDir, s.Tool, s.Params); code != 0 {
+			return fmt.Errorf("tool %s failed (exit code %d)", s.Tool, code)
+		}
+		return nil
+	case "shell":
+		cmd := macroShellCommand(s.Shell)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		return cmd.Run()
+	}
+	return fmt.Errorf("empty step")
+}
+
+// runMacro runs the steps of a macro in order. With dryRun the resolved
+// steps are only printed.
+func runMacro(baseDir, name string, m macroDef, args []string) int {
+	overrides, dryRun, err := parseMacroArgs(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	steps, err := resolveMacroSteps(m, overrides)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	if dryRun {
+		fmt.Printf("Macro %s (%d steps, dry run)\n", name, len(steps))
+		for i, s := range steps {
+			fmt.Printf("%2d. %s%s\n", i+1, s.describe(), ui.Muted(" [on_error: "+s.OnError+"]"))
+		}
+		return 0
+	}
+
+	failed := 0
+	for i, s := range steps {
+		label := s.describe()
+		if strings.TrimSpace(s.Name) != "" {
+			label = s.Name + ": " + label
+		}
+		fmt.Println(ui.Accent(fmt.Sprintf("[%d/%d]", i+1, len(steps))), label)
+		if err := runMacroStep(baseDir, s); err != nil {
+			failed++
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			if s.OnError != macroOnErrCont {
+				fmt.Fprintf(os.Stderr, "Macro %s stopped at step %d of %d.\n", name, i+1, len(steps))
+				return 1
+			}
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "Macro %s finished with %d failed step(s).\n", name, failed)
+		return 1
+	}
+	return 0
+}
+
+// runMacroIfDefined runs args[0] as a macro when no plugin has that name
+// and a shortcut macro file defines it. A broken macro file only warns, so
+// it cannot take the plugin shortcuts down with it.
+func runMacroIfDefined(baseDir string, args []string) (int, bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	if _, err := plugins.GetInfo(baseDir, args[0]); err == nil {
+		return 0, false
+	}
+	macros, err := loadMacroFiles(shortcutMacroFilePaths(baseDir))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: ignoring macros:", err)
+		return 0, false
+	}
+	m, ok := macros[args[0]]
+	if !ok {
+		return 0, false
+	}
+	return runMacro(baseDir, args[0], m, args[1:]), true
+}
+
+func printMacroList(macros map[string]macroDef) {
+	if len(macros) == 0 {
+		fmt.Println("No macros defined. Add them to " + macrosFileName + ".")
+		return
+	}
+	for _, name := range macroNames(macros) {
+		m := macros[name]
+		desc := strings.TrimSpace(m.Description)
+		if desc == "" {
+			desc = fmt.Sprintf("%d steps", len(m.Steps))
+		}
+		fmt.Printf("%s %s\n", ui.Accent(name), ui.Muted("- "+desc))
+	}
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func writeMacroFile(t *testing.T, dir, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, macrosFileName)
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLoadMacros_ValidatesSteps(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DM_MACROS", writeMacroFile(t, dir, `{"macros":{"bad":{"steps":[{"plugin":"a","shell":"b"}]}}}`))
+	if _, err := loadMacros(dir); err == nil || !strings.Contains(err.Error(), "exactly one") {
+		t.Fatalf("expected step validation error, got %v", err)
+	}
+	writeMacroFile(t, dir, `{"macros":{"bad":{"on_error":"retry","steps":[{"tool":"read"}]}}}`)
+	if _, err := loadMacros(dir); err == nil || !strings.Contains(err.Error(), "on_error") {
+		t.Fatalf("expected on_error validation error, got %v", err)
+	}
+}
+
+func TestLoadMacros_MergesVars(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DM_MACROS", writeMacroFile(t, dir, `{
+		"vars": {"env": "dev", "svc": "api"},
+		"macros": {"up": {"vars": {"env": "test"}, "steps": [{"plugin": "stibs_docker_up", "args": ["-Env", "${env}", "${svc}"]}]}}
+	}`))
+	macros, err := loadMacros(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, ok := macros["up"]
+	if !ok {
+		t.Fatalf("macro not loaded: %v", macros)
+	}
+	steps, err := resolveMacroSteps(m, map[string]string{"svc": "db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(steps[0].Args, " "); got != "-Env test db" {
+		t.Fatalf("args = %q", got)
+	}
+	if steps[0].OnError != macroOnErrStop {
+		t.Fatalf("default on_error = %q", steps[0].OnError)
+	}
+}
+
+func TestResolveMacroSteps_UndefinedVars(t *testing.T) {
+	t.Setenv("DM_MACRO_TEST", "from-env")
+	m := macroDef{Steps: []macroStep{
+		{Tool: "grep", Params: map[string]string{"pattern": "${pattern}", "base": "${env:DM_MACRO_TEST}"}},
+		{Shell: "echo ${missing} ${other}"},
+	}}
+	if _, err := resolveMacroSteps(m, map[string]string{"pattern": "x"}); err == nil || !strings.Contains(err.Error(), "missing, other") {
+		t.Fatalf("expected undefined variables error, got %v", err)
+	}
+	steps, err := resolveMacroSteps(m, map[string]string{"pattern": "x", "missing": "1", "other": "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if steps[0].Params["base"] != "from-env" || steps[1].Shell != "echo 1 2" {
+		t.Fatalf("unexpected steps: %+v", steps)
+	}
+}
+
+func TestParseMacroArgs(t *testing.T) {
+	vars, dry, err := parseMacroArgs([]string{"env=prod", "--dry-run", "tag=a=b"})
+	if err != nil || !dry || vars["env"] != "prod" || vars["tag"] != "a=b" {
+		t.Fatalf("unexpected parse: %v %v %v", vars, dry, err)
+	}
+	if _, _, err := parseMacroArgs([]string{"prod"}); err == nil {
+		t.Fatal("expected error for argument without name=value")
+	}
+}
+
+func TestRunMacro_OnError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell steps use sh in this test")
+	}
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "ran")
+	m := macroDef{Steps: []macroStep{
+		{Shell: "exit 2", OnError: macroOnErrCont},
+		{Shell: "touch " + marker},
+		{Shell: "exit 1"},
+		{Shell: "touch " + marker + ".after"},
+	}}
+	if code := runMacro(dir, "t", m, nil); code != 1 {
+		t.Fatalf("code = %d, want 1", code)
+	}
+	if !fileExists(marker) {
+		t.Fatal("step after a continue failure did not run")
+	}
+	if fileExists(marker + ".after") {
+		t.Fatal("step after a stop failure ran")
+	}
+
+	m = macroDef{Steps: []macroStep{{Shell: "touch " + marker + ".dry"}}}
+	if code := runMacro(dir, "t", m, []string{macroDryRunFlag}); code != 0 || fileExists(marker+".dry") {
+		t.Fatalf("dry run executed steps (code %d)", code)
+	}
+}
+
+func TestRunMacroIfDefined_PluginsAndLocalFileFirst(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh scripts")
+	}
+	base := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(base, "plugins"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeBatchScript(t, filepath.Join(base, "plugins"), "db_status", "echo db-ok")
+	work := t.TempDir()
+	marker := filepath.Join(work, "ran")
+	writeMacroFile(t, work, `{"macros":{
+		"db_status": {"steps": [{"shell": "touch `+marker+`"}]},
+		"local_only": {"steps": [{"shell": "touch `+marker+`"}]}
+	}}`)
+	t.Setenv("DM_MACROS", "")
+	t.Chdir(work)
+
+	for _, name := range []string{"db_status", "local_only"} {
+		if _, ok := runMacroIfDefined(base, []string{name}); ok {
+			t.Fatalf("%s ran as a macro from the working directory", name)
+		}
+	}
+	if fileExists(marker) {
+		t.Fatal("a macro from the working directory ran as a shortcut")
+	}
+	if macros, err := loadMacros(base); err != nil || macros["local_only"].Source == "" {
+		t.Fatalf("expected dm run to still see local macros, got %v (%v)", macros, err)
+	}
+
+	writeMacroFile(t, base, `{"macros":`)
+	if _, ok := runMacroIfDefined(base, []string{"db_status"}); ok {
+		t.Fatal("a broken macro file must fall through to plugins")
+	}
+	writeMacroFile(t, base, `{"macros":{"base_only":{"steps":[{"shell":"touch `+marker+`"}]}}}`)
+	if code, ok := runMacroIfDefined(base, []string{"base_only"}); !ok || code != 0 || !fileExists(marker) {
+		t.Fatalf("expected the macro next to dm to run, got %d/%v", code, ok)
+	}
+}
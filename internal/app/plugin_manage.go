@@ -0,0 +1,241 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"cli/internal/plugins"
+	"cli/internal/ui"
+)
+
+// splitPluginFlags separates "--flag value" / "--flag=value" pairs (for
+// the names in valued) and bare switches from positional arguments.
+func splitPluginFlags(args []string, valued ...string) ([]string, map[string]string) {
+	flags := map[string]string{}
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			rest = append(rest, arg)
+			continue
+		}
+		key, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !hasValue && containsString(valued, key) && i+1 < len(args) {
+			value = args[i+1]
+			i++
+		}
+		flags[key] = value
+	}
+	return rest, flags
+}
+
+func runPluginInstall(baseDir string, args []string) int {
+	rest, flags := splitPluginFlags(args, "name", "n", "ref")
+	if len(rest) != 1 {
+		fmt.Println("Usage: dm plugins install <path|git-url|zip> [--name <name>] [--ref <branch|tag>]")
+		return 1
+	}
+	name := flags["name"]
+	if name == "" {
+		name = flags["n"]
+	}
+	entry, err := plugins.Install(baseDir, rest[0], plugins.InstallOptions{Name: name, Ref: flags["ref"]})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	fmt.Println(ui.OK("Installed:"), entry.Name, describeLockEntry(entry))
+	fmt.Println("Path      :", entry.Path)
+	fmt.Println("Checksum  :", entry.Checksum)
+	if entry.Disabled {
+		fmt.Println(ui.Warn("Note:"), "toolkit is disabled; run dm plugins enable", entry.Name)
+	}
+	return 0
+}
+
+func runPluginRemove(baseDir string, args []string) int {
+	rest, flags := splitPluginFlags(args)
+	if len(rest) != 1 {
+		fmt.Println("Usage: dm plugins remove <toolkit> [--yes]")
+		return 1
+	}
+	tk, err := plugins.FindToolkit(baseDir, rest[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	_, yes := flags["yes"]
+	if _, y := flags["y"]; !yes && !y {
+		fmt.Print(ui.Prompt(fmt.Sprintf("Delete toolkit %s (%s)? [y/N] ", tk.Name, tk.Path)))
+		answer := strings.ToLower(readLine(bufio.NewReader(os.Stdin)))
+		if answer != "y" && answer != "yes" {
+			fmt.Println("Canceled.")
+			return 0
+		}
+	}
+	if _, err := plugins.Remove(baseDir, tk.Name); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	fmt.Println(ui.OK("Removed:"), tk.Name)
+	return 0
+}
+
+func runPluginSetEnabled(baseDir string, args []string, enabled bool) int {
+	verb := "enable"
+	if !enabled {
+		verb = "disable"
+	}
+	if len(args) != 1 {
+		fmt.Printf("Usage: dm plugins %s <toolkit>\n", verb)
+		return 1
+	}
+	tk, err := plugins.SetEnabled(baseDir, args[0], enabled)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	fmt.Println(ui.OK(strings.ToUpper(verb[:1])+verb[1:]+"d:"), tk.Name)
+	return 0
+}
+
+func runPluginUpdate(baseDir string, args []string) int {
+	names, flags := splitPluginFlags(args)
+	_, force := flags["force"]
+	if len(names) == 0 {
+		entries, err := plugins.LockEntries(baseDir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 1
+		}
+		for _, e := range entries {
+			if e.Type == "git" {
+				names = append(names, e.Name)
+			}
+		}
+		if len(names) == 0 {
+			fmt.Println("No git-sourced toolkits installed.")
+			return 0
+		}
+	}
+	code := 0
+	for _, name := range names {
+		before, _ := plugins.FindToolkit(baseDir, name)
+		entry, changed, err := plugins.Update(baseDir, name, force)
+		switch {
+		case err != nil:
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			code = 1
+		case changed:
+			fmt.Printf("%s %s: %s -> %s\n", ui.OK("Updated:"), entry.Name, emptyAs(before.Lock.Version, "?"), emptyAs(entry.Version, "?"))
+		default:
+			fmt.Printf("%s is up to date (%s)\n", entry.Name, emptyAs(entry.Version, "?"))
+		}
+	}
+	return code
+}
+
+func runPluginToolkits(baseDir string) int {
+	items, err := plugins.Toolkits(baseDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	if len(items) == 0 {
+		fmt.Println("No toolkits found.")
+		return 0
+	}
+	for _, tk := range items {
+		state := ui.OK("enabled ")
+		if !tk.Enabled {
+			state = ui.Muted("disabled")
+		}
+		line := fmt.Sprintf("%s  %s", state, tk.Name)
+		if tk.Lock.Type != "" {
+			line += " " + ui.Muted(describeLockEntry(tk.Lock)+" "+tk.Lock.Source)
+		}
+		fmt.Println(line)
+	}
+	return 0
+}
+
+func describeLockEntry(e plugins.LockEntry) string {
+	parts := []string{e.Type}
+	if e.Ref != "" {
+		parts = append(parts, "@"+e.Ref)
+	}
+	if e.Version != "" {
+		parts = append(parts, e.Version)
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+func emptyAs(s, fallback string) string {
+	if strings.TrimSpace(s) == "" {
+		return fallback
+	}
+	return s
+}
+
+// printPluginManifest adds the toolkit manifest fields to dm plugins info.
+func printPluginManifest(info plugins.Info) {
+	m := info.Manifest
+	if m.Source == "" {
+		return
+	}
+	if m.Version != "" {
+		fmt.Println("Version   :", m.Version)
+	}
+	if m.Prefix != "" {
+		fmt.Println("Prefix    :", m.Prefix+"_*")
+	}
+	if risk, reason := m.FunctionRisk(info.Name); risk != "" {
+		fmt.Printf("Risk      : %s (%s)\n", risk, reason)
+	}
+	if len(m.Requires) > 0 {
+		fmt.Println("Requires  :", strings.Join(m.Requires, ", "))
+	}
+	if len(m.Env) > 0 {
+		fmt.Println("Env       :", strings.Join(m.Env, ", "))
+	}
+	if m.MinDMVersion != "" {
+		fmt.Println("Min dm    :", m.MinDMVersion)
+	}
+	if problems := m.Check(Version); len(problems) > 0 {
+		fmt.Println(ui.Warn("Missing   :"), strings.Join(problems, "; "))
+	}
+}
+
+type pluginRunJSON struct {
+	Name       string `json:"name"`
+	OK         bool   `json:"ok"`
+	Data       any    `json:"data,omitempty"`
+	Stdout     string `json:"stdout,omitempty"`
+	Stderr     string `json:"stderr,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// runPluginJSON runs a plugin non-interactively and prints one JSON object
+// with its returned data and captured streams instead of the console output.
+func runPluginJSON(baseDir, name string, args []string) int {
+	res := plugins.RunStructured(context.Background(), baseDir, name, args)
+	out := pluginRunJSON{
+		Name: name, OK: res.Err == nil, Data: res.Data,
+		Stdout: res.Stdout, Stderr: res.Stderr, DurationMS: res.Duration.Milliseconds(),
+	}
+	if res.Err != nil {
+		out.Error = res.Err.Error()
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(out)
+	if res.Err != nil {
+		return 1
+	}
+	return 0
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
@@ -13,6 +14,8 @@ var (
 	signalOnce    sync.Once
 	cleanupFuncs  []func()
 	cleanupMu     sync.Mutex
+	stepMu        sync.Mutex
+	stepCancel    context.CancelFunc
 )
 
 func setupSignalHandler() {
@@ -20,14 +23,47 @@ func setupSignalHandler() {
 		sigCh := make(chan os.Signal, 1)
 		signal.Notify(sigCh, os.Interrupt)
 		go func() {
-			<-sigCh
-			fmt.Fprintln(os.Stderr, "\nInterrupted. Cleaning up...")
-			runCleanup()
-			os.Exit(130)
+			for range sigCh {
+				if interruptStep() {
+					fmt.Fprintln(os.Stderr, "\nInterrupted. Canceling the current step...")
+					continue
+				}
+				fmt.Fprintln(os.Stderr, "\nInterrupted. Cleaning up...")
+				runCleanup()
+				os.Exit(130)
+			}
 		}()
 	})
 }
 
+// beginInterruptibleStep returns a context that the next Ctrl+C cancels
+// instead of exiting dm. A second Ctrl+C, or one outside a step, still
+// exits. Call end once the step is over.
+func beginInterruptibleStep() (ctx context.Context, end func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stepMu.Lock()
+	stepCancel = cancel
+	stepMu.Unlock()
+	return ctx, func() {
+		stepMu.Lock()
+		stepCancel = nil
+		stepMu.Unlock()
+		cancel()
+	}
+}
+
+// interruptStep cancels the running step, if any, and reports whether it did.
+func interruptStep() bool {
+	stepMu.Lock()
+	defer stepMu.Unlock()
+	if stepCancel == nil {
+		return false
+	}
+	stepCancel()
+	stepCancel = nil
+	return true
+}
+
 func runCleanup() {
 	cleanupMu.Lock()
 	fns := make([]func(), len(cleanupFuncs))
@@ -3,12 +3,13 @@ package doctor
 import (
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"cli/internal/agent"
 	"cli/internal/plugins"
 )
 
@@ -34,12 +35,24 @@ type Report struct {
 	ErrorCount  int       `json:"error_count"`
 }
 
-func Run(baseDir string) Report {
+// Run collects all checks. dmVersion is compared against the
+// min_dm_version of toolkit manifests.
+func Run(baseDir, dmVersion string) Report {
 	r := Report{GeneratedAt: time.Now()}
-	r.add(checkAgentConfig())
-	r.add(checkOllama())
-	r.add(checkOpenAI())
+	r.add(CheckAgentConfig())
+	for _, c := range checkProviders() {
+		r.add(c)
+	}
+	for _, c := range checkFallback() {
+		r.add(c)
+	}
+	for _, c := range checkProfiles() {
+		r.add(c)
+	}
 	r.add(checkPlugins(baseDir))
+	for _, c := range checkToolkitManifests(baseDir, dmVersion) {
+		r.add(c)
+	}
 	r.add(checkCommonToolPaths())
 	return r
 }
@@ -70,7 +83,9 @@ func RenderJSON(r Report) error {
 	return enc.Encode(r)
 }
 
-func checkAgentConfig() Check {
+// CheckAgentConfig verifies that the agent config file exists, parses as JSON,
+// and that known provider settings have the expected shape.
+func CheckAgentConfig() Check {
 	path := agentConfigPath()
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -95,6 +110,13 @@ func checkAgentConfig() Check {
 			Message: fmt.Sprintf("invalid JSON in %s: %v", path, err),
 		}
 	}
+	if problems := validateAgentConfigMap(raw); len(problems) > 0 {
+		return Check{
+			Level:   LevelError,
+			Name:    "agent-config",
+			Message: fmt.Sprintf("%s: %s", path, strings.Join(problems, "; ")),
+		}
+	}
 	return Check{
 		Level:   LevelOK,
 		Name:    "agent-config",
@@ -102,46 +124,304 @@ func checkAgentConfig() Check {
 	}
 }
 
-func checkOllama() Check {
-	baseURL, model := ollamaConfig()
-	client := &http.Client{Timeout: 3 * time.Second}
-	res, err := client.Get(strings.TrimRight(baseURL, "/") + "/api/tags")
-	if err != nil {
-		return Check{
-			Level:   LevelWarn,
-			Name:    "ollama",
-			Message: fmt.Sprintf("unreachable at %s (%v)", baseURL, err),
+func validateAgentConfigMap(raw map[string]any) []string {
+	var problems []string
+	for _, provider := range []string{"ollama", "openai", "anthropic"} {
+		v, ok := raw[provider]
+		if !ok {
+			continue
+		}
+		section, ok := v.(map[string]any)
+		if !ok {
+			problems = append(problems, provider+" must be an object")
+			continue
+		}
+		for key, val := range section {
+			s, isString := val.(string)
+			if !isString {
+				problems = append(problems, fmt.Sprintf("%s.%s must be a string", provider, key))
+				continue
+			}
+			if key == "base_url" && strings.TrimSpace(s) != "" &&
+				!strings.HasPrefix(s, "http://") && !strings.HasPrefix(s, "https://") {
+				problems = append(problems, fmt.Sprintf("%s.base_url %q has no http(s) scheme", provider, s))
+			}
 		}
 	}
-	defer res.Body.Close()
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return Check{
-			Level:   LevelWarn,
-			Name:    "ollama",
-			Message: fmt.Sprintf("endpoint returned %s", res.Status),
+	if v, ok := raw["endpoints"]; ok {
+		problems = append(problems, validateEndpoints(v)...)
+	}
+	if v, ok := raw["fallback"]; ok {
+		problems = append(problems, validateFallback(v, raw)...)
+	}
+	problems = append(problems, validateSystemPrompt(raw)...)
+	if v, ok := raw["limits"]; ok {
+		problems = append(problems, validateLimits(v)...)
+	}
+	if v, ok := raw["profiles"]; ok {
+		profiles, ok := v.(map[string]any)
+		if !ok {
+			problems = append(problems, "profiles must be an object")
+		} else {
+			for name, pv := range profiles {
+				p, ok := pv.(map[string]any)
+				if !ok {
+					problems = append(problems, fmt.Sprintf("profiles.%s must be an object", name))
+					continue
+				}
+				provider, _ := p["provider"].(string)
+				provider = strings.ToLower(strings.TrimSpace(provider))
+				endpoints, _ := raw["endpoints"].(map[string]any)
+				if _, isEndpoint := endpoints[provider]; !isEndpoint {
+					switch provider {
+					case "ollama", "openai", "anthropic", "auto":
+					default:
+						problems = append(problems, fmt.Sprintf("profiles.%s.provider %q is invalid (use auto|ollama|openai|anthropic or an endpoint name)", name, provider))
+					}
+				}
+			}
 		}
 	}
-	return Check{
-		Level:   LevelOK,
-		Name:    "ollama",
-		Message: fmt.Sprintf("reachable at %s (model=%s)", baseURL, model),
+	if v, ok := raw["default_profile"]; ok {
+		name, _ := v.(string)
+		profiles, _ := raw["profiles"].(map[string]any)
+		if _, exists := profiles[name]; !exists {
+			problems = append(problems, fmt.Sprintf("default_profile %q is not defined in profiles", name))
+		}
 	}
+	return problems
 }
 
-func checkOpenAI() Check {
-	baseURL, model, key := openAIConfig()
-	if strings.TrimSpace(key) == "" {
+func validateEndpoints(v any) []string {
+	endpoints, ok := v.(map[string]any)
+	if !ok {
+		return []string{"endpoints must be an object"}
+	}
+	known := agent.ProviderTypes()
+	var problems []string
+	for name, ev := range endpoints {
+		ep, ok := ev.(map[string]any)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("endpoints.%s must be an object", name))
+			continue
+		}
+		switch name {
+		case "auto", "ollama", "openai", "anthropic":
+			problems = append(problems, fmt.Sprintf("endpoints.%s shadows a built-in provider name", name))
+		}
+		typ, _ := ep["type"].(string)
+		if !containsString(known, typ) {
+			problems = append(problems, fmt.Sprintf("endpoints.%s.type %q is invalid (use %s)", name, typ, strings.Join(known, "|")))
+		}
+		base, _ := ep["base_url"].(string)
+		if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+			problems = append(problems, fmt.Sprintf("endpoints.%s.base_url %q has no http(s) scheme", name, base))
+		}
+		if h, ok := ep["headers"]; ok {
+			headers, ok := h.(map[string]any)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("endpoints.%s.headers must be an object", name))
+				continue
+			}
+			for k, hv := range headers {
+				if _, ok := hv.(string); !ok {
+					problems = append(problems, fmt.Sprintf("endpoints.%s.headers.%s must be a string", name, k))
+				}
+			}
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+func validateFallback(v any, raw map[string]any) []string {
+	entries, ok := v.([]any)
+	if !ok {
+		return []string{"fallback must be an array"}
+	}
+	endpoints, _ := raw["endpoints"].(map[string]any)
+	knownProvider := func(name string) bool {
+		if _, ok := endpoints[name]; ok {
+			return true
+		}
+		return containsString([]string{"ollama", "openai", "anthropic"}, name)
+	}
+	var problems []string
+	for i, ev := range entries {
+		var provider string
+		switch e := ev.(type) {
+		case string:
+			provider, _, _ = strings.Cut(e, ":")
+		case map[string]any:
+			provider, _ = e["provider"].(string)
+			if t, ok := e["timeout"]; ok {
+				switch tv := t.(type) {
+				case float64:
+				case string:
+					if _, err := time.ParseDuration(tv); err != nil {
+						problems = append(problems, fmt.Sprintf("fallback[%d].timeout %q is not a duration", i, tv))
+					}
+				default:
+					problems = append(problems, fmt.Sprintf("fallback[%d].timeout must be a duration string or seconds", i))
+				}
+			}
+			if on, ok := e["on"].([]any); ok {
+				for _, c := range on {
+					cs, _ := c.(string)
+					if !containsString(agent.FallbackClasses(), strings.ToLower(cs)) {
+						problems = append(problems, fmt.Sprintf("fallback[%d].on %q is invalid (use %s)", i, cs, strings.Join(agent.FallbackClasses(), "|")))
+					}
+				}
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("fallback[%d] must be \"provider:model\" or an object", i))
+			continue
+		}
+		provider = strings.ToLower(strings.TrimSpace(provider))
+		if !knownProvider(provider) {
+			problems = append(problems, fmt.Sprintf("fallback[%d] provider %q is unknown", i, provider))
+		}
+	}
+	return problems
+}
+
+func validateSystemPrompt(raw map[string]any) []string {
+	var problems []string
+	for _, key := range []string{"system_prompt", "system_prompt_file", "system_prompt_mode"} {
+		if v, ok := raw[key]; ok {
+			if _, isString := v.(string); !isString {
+				problems = append(problems, key+" must be a string")
+			}
+		}
+	}
+	if mode, ok := raw["system_prompt_mode"].(string); ok {
+		switch strings.ToLower(strings.TrimSpace(mode)) {
+		case "", agent.SystemPromptAppend, agent.SystemPromptReplace:
+		default:
+			problems = append(problems, fmt.Sprintf("system_prompt_mode %q is invalid (use %s|%s)", mode, agent.SystemPromptAppend, agent.SystemPromptReplace))
+		}
+	}
+	if f, ok := raw["system_prompt_file"].(string); ok && strings.TrimSpace(f) != "" {
+		if _, err := os.Stat(agent.ResolveConfigRelativePath(strings.TrimSpace(f))); err != nil {
+			problems = append(problems, fmt.Sprintf("system_prompt_file %q is not readable", f))
+		}
+	}
+	return problems
+}
+
+func validateLimits(v any) []string {
+	limits, ok := v.(map[string]any)
+	if !ok {
+		return []string{"limits must be an object"}
+	}
+	var problems []string
+	for key, lv := range limits {
+		switch key {
+		case "max_steps", "session_tokens", "prompt_tokens", "catalog_tokens", "decision_tokens":
+			n, ok := lv.(float64)
+			if !ok || n < 0 || n != float64(int(n)) {
+				problems = append(problems, fmt.Sprintf("limits.%s must be a non-negative integer", key))
+			}
+		case "step_timeout", "session_timeout":
+			switch tv := lv.(type) {
+			case float64:
+				if tv < 0 {
+					problems = append(problems, fmt.Sprintf("limits.%s must not be negative", key))
+				}
+			case string:
+				if d, err := time.ParseDuration(tv); err != nil || d < 0 {
+					problems = append(problems, fmt.Sprintf("limits.%s %q is not a duration", key, tv))
+				}
+			default:
+				problems = append(problems, fmt.Sprintf("limits.%s must be a duration string or seconds", key))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("limits.%s is not a known limit", key))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func checkProviders() []Check {
+	var out []Check
+	for _, name := range agent.ProviderNames() {
+		if name == "auto" || (name == "anthropic" && !anthropicConfigured()) {
+			continue
+		}
+		out = append(out, checkProvider(name))
+	}
+	return out
+}
+
+func checkProvider(name string) Check {
+	p, err := agent.NewProvider(name)
+	if err != nil {
+		return Check{Level: LevelError, Name: name, Message: err.Error()}
+	}
+	if err := p.Ping(); err != nil {
 		return Check{
 			Level:   LevelWarn,
-			Name:    "openai",
-			Message: "missing API key (set in dm.agent.json or OPENAI_API_KEY)",
+			Name:    name,
+			Message: fmt.Sprintf("unreachable at %s (%v)", p.BaseURL(), err),
 		}
 	}
 	return Check{
 		Level:   LevelOK,
-		Name:    "openai",
-		Message: fmt.Sprintf("configured (base_url=%s model=%s)", baseURL, model),
+		Name:    name,
+		Message: fmt.Sprintf("reachable at %s (model=%s)", p.BaseURL(), p.Model()),
+	}
+}
+
+func anthropicConfigured() bool {
+	if strings.TrimSpace(os.Getenv("ANTHROPIC_API_KEY")) != "" {
+		return true
 	}
+	_, ok := readAgentConfigMap()["anthropic"]
+	return ok
+}
+
+func checkProfiles() []Check {
+	var out []Check
+	for _, name := range agent.ProfileNames() {
+		out = append(out, checkChain("profile:"+name, agent.AskOptions{Profile: name}))
+	}
+	return out
+}
+
+func checkFallback() []Check {
+	if _, ok := readAgentConfigMap()["fallback"]; !ok {
+		return nil
+	}
+	return []Check{checkChain("fallback", agent.AskOptions{Provider: "auto"})}
+}
+
+func checkChain(checkName string, opts agent.AskOptions) Check {
+	chain, err := agent.ResolveProviders(opts)
+	if err != nil {
+		return Check{Level: LevelError, Name: checkName, Message: err.Error()}
+	}
+	var failures []string
+	for i, p := range chain {
+		if err := p.Ping(); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", p.Name(), err))
+			continue
+		}
+		if i > 0 {
+			return Check{Level: LevelWarn, Name: checkName, Message: fmt.Sprintf("%s; falling back to %s (model=%s)", strings.Join(failures, "; "), p.Name(), p.Model())}
+		}
+		return Check{Level: LevelOK, Name: checkName, Message: fmt.Sprintf("%s reachable at %s (model=%s)", p.Name(), p.BaseURL(), p.Model())}
+	}
+	return Check{Level: LevelWarn, Name: checkName, Message: strings.Join(failures, "; ")}
 }
 
 func checkPlugins(baseDir string) Check {
@@ -167,6 +447,50 @@ func checkPlugins(baseDir string) Check {
 	}
 }
 
+// checkToolkitManifests reports invalid manifests and unmet requirements
+// of enabled toolkits, one check per affected toolkit.
+func checkToolkitManifests(baseDir, dmVersion string) []Check {
+	var paths []string
+	if scripts, err := plugins.ListEntries(baseDir, false); err == nil {
+		for _, it := range scripts {
+			paths = append(paths, it.Path)
+		}
+	}
+	files, err := plugins.ListFunctionFiles(baseDir)
+	if err != nil {
+		return []Check{{Level: LevelError, Name: "toolkits", Message: fmt.Sprintf("scan failed: %v", err)}}
+	}
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	var out []Check
+	declared := 0
+	seen := map[string]bool{}
+	for _, p := range paths {
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		m, err := plugins.ParseManifest(p)
+		name := "toolkit:" + m.Name
+		if err != nil {
+			out = append(out, Check{Level: LevelError, Name: name, Message: err.Error()})
+			continue
+		}
+		if len(m.Requires) == 0 && len(m.Env) == 0 && m.MinDMVersion == "" {
+			continue
+		}
+		declared++
+		if problems := m.Check(dmVersion); len(problems) > 0 {
+			out = append(out, Check{Level: LevelWarn, Name: name, Message: strings.Join(problems, "; ")})
+		}
+	}
+	if len(out) == 0 && declared > 0 {
+		out = append(out, Check{Level: LevelOK, Name: "toolkits", Message: fmt.Sprintf("requirements met for %d toolkit manifest(s)", declared)})
+	}
+	return out
+}
+
 func checkCommonToolPaths() Check {
 	home, err := os.UserHomeDir()
 	if err != nil || strings.TrimSpace(home) == "" {
@@ -202,18 +526,7 @@ func checkCommonToolPaths() Check {
 }
 
 func agentConfigPath() string {
-	if p := strings.TrimSpace(os.Getenv("DM_AGENT_CONFIG")); p != "" {
-		return p
-	}
-	exe, err := os.Executable()
-	if err == nil && strings.TrimSpace(exe) != "" {
-		return filepath.Join(filepath.Dir(exe), "dm.agent.json")
-	}
-	home, err := os.UserHomeDir()
-	if err != nil || strings.TrimSpace(home) == "" {
-		return "dm.agent.json"
-	}
-	return filepath.Join(home, ".config", "dm", "agent.json")
+	return agent.ConfigPath()
 }
 
 func readAgentConfigMap() map[string]any {
@@ -228,37 +541,3 @@ func readAgentConfigMap() map[string]any {
 	}
 	return out
 }
-
-func ollamaConfig() (baseURL, model string) {
-	baseURL = "http://127.0.0.1:11434"
-	model = "deepseek-coder-v2:latest"
-	raw := readAgentConfigMap()
-	if ollama, ok := raw["ollama"].(map[string]any); ok {
-		if v, ok := ollama["base_url"].(string); ok && strings.TrimSpace(v) != "" {
-			baseURL = strings.TrimSpace(v)
-		}
-		if v, ok := ollama["model"].(string); ok && strings.TrimSpace(v) != "" {
-			model = strings.TrimSpace(v)
-		}
-	}
-	return baseURL, model
-}
-
-func openAIConfig() (baseURL, model, key string) {
-	baseURL = "https://api.openai.com/v1"
-	model = "gpt-4o-mini"
-	key = strings.TrimSpace(os.Getenv("OPENAI_API_KEY"))
-	raw := readAgentConfigMap()
-	if openai, ok := raw["openai"].(map[string]any); ok {
-		if v, ok := openai["base_url"].(string); ok && strings.TrimSpace(v) != "" {
-			baseURL = strings.TrimSpace(v)
-		}
-		if v, ok := openai["model"].(string); ok && strings.TrimSpace(v) != "" {
-			model = strings.TrimSpace(v)
-		}
-		if k, ok := openai["api_key"].(string); ok && strings.TrimSpace(k) != "" {
-			key = strings.TrimSpace(k)
-		}
-	}
-	return baseURL, model, key
-}
@@ -0,0 +1,455 @@
+// Package journal records filesystem changes made by dm tools so they can be
+// reversed with 'dm undo'. An operation is written before anything changes
+// and updated once the change is applied.
+package journal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry kinds.
+const (
+	KindRename    = "rename"
+	KindRemoveDir = "rmdir"
+	KindWrite     = "write"
+)
+
+// Operation states.
+const (
+	StatusPending = "pending"
+	StatusApplied = "applied"
+	StatusPartial = "partial"
+	StatusFailed  = "failed"
+	StatusUndone  = "undone"
+)
+
+// Entry is one filesystem change. For renames OldPath moved to NewPath; for
+// removed directories only OldPath is set. For writes OldPath is the file,
+// Backup a copy of its previous content (empty when the file was created)
+// and Checksum the hash of the content written.
+type Entry struct {
+	Kind     string `json:"kind"`
+	OldPath  string `json:"old_path"`
+	NewPath  string `json:"new_path,omitempty"`
+	Backup   string `json:"backup,omitempty"`
+	Checksum string `json:"checksum,omitempty"`
+	Done     bool   `json:"done"`
+}
+
+// Op is one journaled operation, e.g. an applied rename plan.
+type Op struct {
+	ID        string     `json:"id"`
+	Tool      string     `json:"tool"`
+	Source    string     `json:"source"`
+	Session   string     `json:"session,omitempty"`
+	Status    string     `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	UndoneAt  *time.Time `json:"undone_at,omitempty"`
+	Entries   []Entry    `json:"entries"`
+
+	dir string
+}
+
+var (
+	sessionMu sync.Mutex
+	sessionID string
+)
+
+// SetSession tags operations recorded from now on with an ask session id;
+// an empty id marks them as direct CLI use again.
+func SetSession(id string) {
+	sessionMu.Lock()
+	sessionID = strings.TrimSpace(id)
+	sessionMu.Unlock()
+}
+
+func currentSession() string {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	return sessionID
+}
+
+// Dir returns DM_JOURNAL_DIR or ~/.config/dm/journal.
+func Dir() (string, error) {
+	if p := strings.TrimSpace(os.Getenv("DM_JOURNAL_DIR")); p != "" {
+		return p, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || strings.TrimSpace(home) == "" {
+		return "", fmt.Errorf("cannot resolve user home directory for the undo journal")
+	}
+	return filepath.Join(home, ".config", "dm", "journal"), nil
+}
+
+func newOpID(now time.Time) string {
+	buf := make([]byte, 3)
+	_, _ = rand.Read(buf)
+	return now.Format("20060102-150405") + "-" + hex.EncodeToString(buf)
+}
+
+// Begin writes a pending operation before any of its entries is applied.
+func Begin(tool string, entries []Entry) (*Op, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	op := &Op{
+		ID:        newOpID(now),
+		Tool:      tool,
+		Source:    "cli",
+		Session:   currentSession(),
+		Status:    StatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Entries:   make([]Entry, len(entries)),
+		dir:       dir,
+	}
+	if op.Session != "" {
+		op.Source = "agent"
+	}
+	for i, e := range entries {
+		e.OldPath = absPath(e.OldPath)
+		if e.NewPath != "" {
+			e.NewPath = absPath(e.NewPath)
+		}
+		e.Done = false
+		if e.Kind == KindWrite {
+			backup, err := op.backup(i, e.OldPath)
+			if err != nil {
+				return nil, fmt.Errorf("cannot back up %s: %w", e.OldPath, err)
+			}
+			e.Backup = backup
+		}
+		op.Entries[i] = e
+	}
+	if err := op.save(); err != nil {
+		return nil, fmt.Errorf("cannot write undo journal: %w", err)
+	}
+	return op, nil
+}
+
+// backup copies path into the journal's backups folder; it returns "" when
+// the file does not exist yet.
+func (op *Op) backup(i int, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(op.dir, "backups")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(dir, fmt.Sprintf("%s-%d", op.ID, i))
+	return dst, os.WriteFile(dst, data, 0644)
+}
+
+// Checksum is the hash stored in Entry.Checksum.
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
+}
+
+// MarkDone flags entry i as applied.
+func (op *Op) MarkDone(i int) {
+	if i >= 0 && i < len(op.Entries) {
+		op.Entries[i].Done = true
+	}
+}
+
+// Finish stores the outcome: applied when every entry is done, partial when
+// some are, failed otherwise.
+func (op *Op) Finish(applyErr error) error {
+	done := 0
+	for _, e := range op.Entries {
+		if e.Done {
+			done++
+		}
+	}
+	switch {
+	case done == len(op.Entries):
+		op.Status = StatusApplied
+	case done > 0:
+		op.Status = StatusPartial
+	default:
+		op.Status = StatusFailed
+	}
+	if applyErr != nil {
+		op.Error = applyErr.Error()
+	}
+	op.UpdatedAt = time.Now()
+	return op.save()
+}
+
+func (op *Op) save() error {
+	if err := os.MkdirAll(op.dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(op, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(op.dir, op.ID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// List returns the journaled operations, newest first.
+func List() ([]Op, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var ops []Op
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		op, err := load(dir, strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].ID > ops[j].ID })
+	return ops, nil
+}
+
+func load(dir, id string) (Op, error) {
+	var op Op
+	data, err := os.ReadFile(filepath.Join(dir, id+".json"))
+	if err != nil {
+		return op, err
+	}
+	if err := json.Unmarshal(data, &op); err != nil {
+		return op, fmt.Errorf("invalid journal entry %s: %w", id, err)
+	}
+	op.dir = dir
+	return op, nil
+}
+
+// Find resolves an operation id. "last" or an empty id picks the newest
+// operation that has not been undone; a unique id prefix is accepted.
+func Find(id string) (Op, error) {
+	ops, err := List()
+	if err != nil {
+		return Op{}, err
+	}
+	id = strings.TrimSpace(id)
+	if id == "" || id == "last" {
+		for _, op := range ops {
+			if op.Status != StatusUndone && op.Status != StatusFailed {
+				return op, nil
+			}
+		}
+		return Op{}, fmt.Errorf("nothing to undo")
+	}
+	var matches []Op
+	for _, op := range ops {
+		if op.ID == id {
+			return op, nil
+		}
+		if strings.HasPrefix(op.ID, id) {
+			matches = append(matches, op)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Op{}, fmt.Errorf("operation not found: %s", id)
+	case 1:
+		return matches[0], nil
+	}
+	return Op{}, fmt.Errorf("operation id %q is ambiguous (%d matches)", id, len(matches))
+}
+
+type undoStep struct {
+	entry Entry
+	skip  bool
+}
+
+// plan checks that the filesystem still matches the state the operation
+// left behind and returns the steps to revert it, newest change first.
+func (op Op) plan() ([]undoStep, []string) {
+	var steps []undoStep
+	var conflicts []string
+	for i := len(op.Entries) - 1; i >= 0; i-- {
+		e := op.Entries[i]
+		oldExists := pathExists(e.OldPath)
+		switch e.Kind {
+		case KindRename:
+			newExists := pathExists(e.NewPath)
+			switch {
+			case newExists && !oldExists:
+				steps = append(steps, undoStep{entry: e})
+			case oldExists && !newExists:
+				// never applied or already reverted
+				steps = append(steps, undoStep{entry: e, skip: true})
+			case oldExists && newExists:
+				conflicts = append(conflicts, fmt.Sprintf("both %s and %s exist", e.OldPath, e.NewPath))
+			default:
+				conflicts = append(conflicts, fmt.Sprintf("%s no longer exists", e.NewPath))
+			}
+		case KindRemoveDir:
+			info, err := os.Stat(e.OldPath)
+			switch {
+			case err != nil:
+				steps = append(steps, undoStep{entry: e})
+			case info.IsDir():
+				steps = append(steps, undoStep{entry: e, skip: true})
+			default:
+				conflicts = append(conflicts, fmt.Sprintf("%s exists and is not a directory", e.OldPath))
+			}
+		case KindWrite:
+			skip, err := e.writeState()
+			if err != nil {
+				conflicts = append(conflicts, err.Error())
+			} else {
+				steps = append(steps, undoStep{entry: e, skip: skip})
+			}
+		default:
+			conflicts = append(conflicts, fmt.Sprintf("unsupported entry kind %q", e.Kind))
+		}
+	}
+	return steps, conflicts
+}
+
+// writeState reports whether a write entry is already reverted, or an error
+// when the file changed since dm wrote it.
+func (e Entry) writeState() (bool, error) {
+	current, err := os.ReadFile(e.OldPath)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if exists && Checksum(current) == e.Checksum {
+		return false, nil
+	}
+	if e.Backup == "" {
+		if !exists {
+			return true, nil
+		}
+	} else if original, err := os.ReadFile(e.Backup); err != nil {
+		return false, fmt.Errorf("backup of %s is missing: %w", e.OldPath, err)
+	} else if exists && bytes.Equal(current, original) {
+		return true, nil
+	}
+	if !exists {
+		return false, fmt.Errorf("%s no longer exists", e.OldPath)
+	}
+	return false, fmt.Errorf("%s was modified after it was written", e.OldPath)
+}
+
+func (e Entry) restore() error {
+	if e.Backup == "" {
+		return os.Remove(e.OldPath)
+	}
+	data, err := os.ReadFile(e.Backup)
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(e.OldPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	return os.WriteFile(e.OldPath, data, mode)
+}
+
+func pathExists(p string) bool {
+	_, err := os.Lstat(p)
+	return err == nil
+}
+
+// Undo reverts op after verifying the current state. Nothing is changed when
+// any entry conflicts.
+func Undo(op Op) (int, error) {
+	if op.Status == StatusUndone {
+		return 0, fmt.Errorf("operation %s was already undone", op.ID)
+	}
+	steps, conflicts := op.plan()
+	if len(conflicts) > 0 {
+		return 0, fmt.Errorf("state changed since operation %s, not undoing:\n  %s", op.ID, strings.Join(conflicts, "\n  "))
+	}
+	reverted := 0
+	for _, s := range steps {
+		if s.skip {
+			continue
+		}
+		var err error
+		switch s.entry.Kind {
+		case KindRename:
+			err = os.Rename(s.entry.NewPath, s.entry.OldPath)
+		case KindRemoveDir:
+			err = os.MkdirAll(s.entry.OldPath, 0755)
+		case KindWrite:
+			err = s.entry.restore()
+		}
+		if err != nil {
+			return reverted, fmt.Errorf("undo stopped after %d change(s): %w", reverted, err)
+		}
+		reverted++
+	}
+	now := time.Now()
+	op.Status = StatusUndone
+	op.UndoneAt = &now
+	op.UpdatedAt = now
+	if op.dir == "" {
+		dir, err := Dir()
+		if err != nil {
+			return reverted, err
+		}
+		op.dir = dir
+	}
+	return reverted, op.save()
+}
+
+// Summary is a one-line description of op for listings.
+func (op Op) Summary() string {
+	counts := map[string]int{}
+	for _, e := range op.Entries {
+		counts[e.Kind]++
+	}
+	var parts []string
+	if n := counts[KindRename]; n > 0 {
+		parts = append(parts, fmt.Sprintf("%d rename(s)", n))
+	}
+	if n := counts[KindRemoveDir]; n > 0 {
+		parts = append(parts, fmt.Sprintf("%d dir removal(s)", n))
+	}
+	if n := counts[KindWrite]; n > 0 {
+		parts = append(parts, fmt.Sprintf("%d file write(s)", n))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,173 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func touch(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBeginFinishUndo_Rename(t *testing.T) {
+	t.Setenv("DM_JOURNAL_DIR", t.TempDir())
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	touch(t, a)
+
+	SetSession("20260101-000000-abcdef")
+	defer SetSession("")
+	op, err := Begin("rename", []Entry{{Kind: KindRename, OldPath: a, NewPath: b}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.Source != "agent" || op.Session == "" || op.Status != StatusPending {
+		t.Fatalf("unexpected op %+v", op)
+	}
+	if err := os.Rename(a, b); err != nil {
+		t.Fatal(err)
+	}
+	op.MarkDone(0)
+	if err := op.Finish(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := Find("last")
+	if err != nil || found.ID != op.ID || found.Status != StatusApplied {
+		t.Fatalf("Find(last) = %+v, %v", found, err)
+	}
+	n, err := Undo(found)
+	if err != nil || n != 1 {
+		t.Fatalf("Undo = %d, %v", n, err)
+	}
+	if !pathExists(a) || pathExists(b) {
+		t.Fatal("rename was not reverted")
+	}
+	again, _ := Find(op.ID)
+	if again.Status != StatusUndone {
+		t.Fatalf("status after undo = %s", again.Status)
+	}
+	if _, err := Undo(again); err == nil {
+		t.Fatal("expected error when undoing twice")
+	}
+	if _, err := Find(""); err == nil {
+		t.Fatal("expected nothing to undo")
+	}
+}
+
+func TestUndo_RefusesChangedState(t *testing.T) {
+	t.Setenv("DM_JOURNAL_DIR", t.TempDir())
+	dir := t.TempDir()
+	a, b, c := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")
+	touch(t, b)
+	touch(t, c)
+	touch(t, filepath.Join(dir, "c.old"))
+	op, err := Begin("rename", []Entry{
+		{Kind: KindRename, OldPath: a, NewPath: b},
+		{Kind: KindRename, OldPath: filepath.Join(dir, "c.old"), NewPath: c},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = op.Finish(nil)
+	if _, err := Undo(*op); err == nil || !strings.Contains(err.Error(), "both") {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+	if pathExists(a) {
+		t.Fatal("undo changed files despite a conflict")
+	}
+}
+
+func TestUndo_RemovedDirs(t *testing.T) {
+	t.Setenv("DM_JOURNAL_DIR", t.TempDir())
+	base := t.TempDir()
+	deep := filepath.Join(base, "x", "y")
+	if err := os.MkdirAll(deep, 0755); err != nil {
+		t.Fatal(err)
+	}
+	op, err := Begin("clean", []Entry{
+		{Kind: KindRemoveDir, OldPath: deep},
+		{Kind: KindRemoveDir, OldPath: filepath.Join(base, "x")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, d := range []string{deep, filepath.Join(base, "x")} {
+		if err := os.Remove(d); err != nil {
+			t.Fatal(err)
+		}
+		op.MarkDone(i)
+	}
+	_ = op.Finish(nil)
+	if op.Summary() != "2 dir removal(s)" || op.Source != "cli" {
+		t.Fatalf("unexpected op summary %q source %q", op.Summary(), op.Source)
+	}
+	if _, err := Undo(*op); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(deep); err != nil || !info.IsDir() {
+		t.Fatal("directories were not recreated")
+	}
+}
+
+func TestFinish_Partial(t *testing.T) {
+	t.Setenv("DM_JOURNAL_DIR", t.TempDir())
+	op, err := Begin("rename", []Entry{{Kind: KindRename, OldPath: "a", NewPath: "b"}, {Kind: KindRename, OldPath: "c", NewPath: "d"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	op.MarkDone(0)
+	if err := op.Finish(os.ErrPermission); err != nil {
+		t.Fatal(err)
+	}
+	ops, err := List()
+	if err != nil || len(ops) != 1 || ops[0].Status != StatusPartial || ops[0].Error == "" {
+		t.Fatalf("List = %+v, %v", ops, err)
+	}
+	if !filepath.IsAbs(ops[0].Entries[0].OldPath) {
+		t.Fatalf("paths should be stored absolute: %q", ops[0].Entries[0].OldPath)
+	}
+}
+
+func TestUndo_WriteRefusesLaterEdits(t *testing.T) {
+	t.Setenv("DM_JOURNAL_DIR", t.TempDir())
+	path := filepath.Join(t.TempDir(), "f.txt")
+	if err := os.WriteFile(path, []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	op, err := Begin("write", []Entry{{Kind: KindWrite, OldPath: path, Checksum: Checksum([]byte("v2"))}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	op.MarkDone(0)
+	_ = op.Finish(nil)
+	if op.Summary() != "1 file write(s)" {
+		t.Fatalf("summary = %q", op.Summary())
+	}
+	if err := os.WriteFile(path, []byte("v3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Undo(*op); err == nil || !strings.Contains(err.Error(), "modified") {
+		t.Fatalf("expected modified conflict, got %v", err)
+	}
+	if err := os.WriteFile(path, []byte("v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := Undo(*op); err != nil || n != 1 {
+		t.Fatalf("Undo = %d, %v", n, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "v1" {
+		t.Fatalf("content after undo = %q", data)
+	}
+}
@@ -2,6 +2,7 @@ package platform
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
 	"runtime"
 	"strings"
@@ -48,6 +49,15 @@ func OpenTerminal(path string) {
 	_ = exec.Command("x-terminal-emulator", "--working-directory", path).Start()
 }
 
+// OpenTTY opens the controlling terminal for reading, so interactive prompts
+// keep working after stdin has been consumed by a pipe.
+func OpenTTY() (*os.File, error) {
+	if runtime.GOOS == "windows" {
+		return os.OpenFile("CONIN$", os.O_RDWR, 0)
+	}
+	return os.Open("/dev/tty")
+}
+
 func EscapeQuotes(s string) string {
 	return strings.ReplaceAll(s, `"`, `\"`)
 }
@@ -1,8 +1,10 @@
 package plugins
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 )
@@ -99,3 +101,78 @@ func BenchmarkGetInfoFunctionWarm(b *testing.B) {
 		}
 	}
 }
+
+func silenceStdout(b *testing.B) {
+	b.Helper()
+	devnull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	prev := os.Stdout
+	os.Stdout = devnull
+	b.Cleanup(func() {
+		os.Stdout = prev
+		devnull.Close()
+	})
+}
+
+func benchmarkPwshToolkit(b *testing.B) string {
+	b.Helper()
+	if firstAvailableBinary("pwsh", "powershell") == "" {
+		b.Skip("pwsh/powershell not installed")
+	}
+	path := filepath.Join(b.TempDir(), "Bench_Toolkit.ps1")
+	if err := os.WriteFile(path, []byte("function bench_echo { param([string]$Name) \"hello $Name\" }\n"), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+// BenchmarkHostRoundTripFake measures the framing protocol against the fake
+// worker from pwsh_host_test.go; compare with BenchmarkProcessPerCallFake.
+func BenchmarkHostRoundTripFake(b *testing.B) {
+	withFakePwshHost(b)
+	silenceStdout(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := runPowerShellFunctionCapture(context.Background(), nil, "echo", []string{"-Name", "db"}, execOptions{quiet: true}).Err; err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProcessPerCallFake(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^$")
+		if err := cmd.Run(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPwshFreshProcess(b *testing.B) {
+	toolkit := benchmarkPwshToolkit(b)
+	silenceStdout(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := runPowerShellFunctionCapture(context.Background(), []string{toolkit}, "bench_echo", []string{"-Name", "db"}, execOptions{quiet: true}).Err; err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPwshPersistentHost(b *testing.B) {
+	toolkit := benchmarkPwshToolkit(b)
+	silenceStdout(b)
+	SetPersistentHost(true)
+	b.Cleanup(func() { SetPersistentHost(false) })
+	if err := runPowerShellFunctionCapture(context.Background(), []string{toolkit}, "bench_echo", nil, execOptions{quiet: true}).Err; err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := runPowerShellFunctionCapture(context.Background(), []string{toolkit}, "bench_echo", []string{"-Name", "db"}, execOptions{quiet: true}).Err; err != nil {
+			b.Fatal(err)
+		}
+	}
+}
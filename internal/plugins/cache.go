@@ -95,6 +95,7 @@ func cloneInfo(info Info) Info {
 	out.Sources = append([]string(nil), info.Sources...)
 	out.Parameters = append([]string(nil), info.Parameters...)
 	out.Examples = append([]string(nil), info.Examples...)
+	out.Manifest = cloneManifest(info.Manifest)
 	return out
 }
 
@@ -0,0 +1,141 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LockFileName is the plugins lockfile, kept inside the plugins directory.
+const LockFileName = "plugins.lock.json"
+
+// LockEntry records where an installed toolkit came from. Bundled toolkits
+// only get an entry when they are disabled.
+type LockEntry struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	Type      string    `json:"type,omitempty"` // file|dir|git|zip
+	Source    string    `json:"source,omitempty"`
+	Ref       string    `json:"ref,omitempty"`
+	Version   string    `json:"version,omitempty"`
+	Checksum  string    `json:"checksum,omitempty"`
+	Installed time.Time `json:"installed,omitzero"`
+	Disabled  bool      `json:"disabled,omitempty"`
+}
+
+type lockFile struct {
+	Toolkits []LockEntry `json:"toolkits"`
+}
+
+func lockPath(pluginsDir string) string {
+	return filepath.Join(pluginsDir, LockFileName)
+}
+
+func readLock(pluginsDir string) (lockFile, error) {
+	data, err := os.ReadFile(lockPath(pluginsDir))
+	if os.IsNotExist(err) {
+		return lockFile{}, nil
+	}
+	if err != nil {
+		return lockFile{}, err
+	}
+	var lf lockFile
+	if err := json.Unmarshal(data, &lf); err != nil {
+		return lockFile{}, fmt.Errorf("invalid %s: %w", LockFileName, err)
+	}
+	return lf, nil
+}
+
+func writeLock(pluginsDir string, lf lockFile) error {
+	sort.Slice(lf.Toolkits, func(i, j int) bool {
+		return strings.ToLower(lf.Toolkits[i].Name) < strings.ToLower(lf.Toolkits[j].Name)
+	})
+	if len(lf.Toolkits) == 0 {
+		err := os.Remove(lockPath(pluginsDir))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	data, err := json.MarshalIndent(lf, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(pluginsDir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(pluginsDir, "."+LockFileName+".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), lockPath(pluginsDir)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func (lf *lockFile) find(name string) int {
+	for i, e := range lf.Toolkits {
+		if strings.EqualFold(e.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (lf *lockFile) put(entry LockEntry) {
+	if i := lf.find(entry.Name); i >= 0 {
+		lf.Toolkits[i] = entry
+		return
+	}
+	lf.Toolkits = append(lf.Toolkits, entry)
+}
+
+func (lf *lockFile) drop(name string) {
+	if i := lf.find(name); i >= 0 {
+		lf.Toolkits = append(lf.Toolkits[:i], lf.Toolkits[i+1:]...)
+	}
+}
+
+// disabledPaths returns the absolute paths (files or directories) of the
+// toolkits disabled in the lockfile. A broken lockfile disables nothing.
+func disabledPaths(pluginsDir string) []string {
+	lf, err := readLock(pluginsDir)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, e := range lf.Toolkits {
+		if e.Disabled && e.Path != "" {
+			out = append(out, filepath.Join(pluginsDir, filepath.FromSlash(e.Path)))
+		}
+	}
+	return out
+}
+
+func isDisabledPath(path string, disabled []string) bool {
+	for _, d := range disabled {
+		if path == d || strings.HasPrefix(path, d+string(filepath.Separator)) {
+			return true
+		}
+		// a disabled toolkit hides its siblings with other extensions too
+		if filepath.Ext(d) != "" && filepath.Dir(path) == filepath.Dir(d) && pluginName(filepath.Base(path)) == pluginName(filepath.Base(d)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,720 @@
+package plugins
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const toolkitDownloadMaxBytes = 64 * 1024 * 1024 // 64 MB
+
+// Toolkit is one installable unit below plugins/: a toolkit file, a
+// directory installed with Install, or a domain directory.
+type Toolkit struct {
+	Name    string // path below plugins/ without extension, e.g. M365/SharePoint_Toolkit
+	Path    string
+	Enabled bool
+	Lock    LockEntry // zero for bundled toolkits that were never disabled
+}
+
+type InstallOptions struct {
+	Name string // defaults to the base name of the source
+	Ref  string // git branch or tag
+}
+
+// Toolkits lists installed and bundled toolkits, including disabled ones.
+func Toolkits(baseDir string) ([]Toolkit, error) {
+	dir := filepath.Join(baseDir, "plugins")
+	lf, err := readLock(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []Toolkit
+	seen := map[string]bool{}
+	disabled := disabledPaths(dir)
+	var installedDirs []string
+	for _, e := range lf.Toolkits {
+		if e.Type == "" {
+			continue
+		}
+		p := filepath.Join(dir, filepath.FromSlash(e.Path))
+		out = append(out, Toolkit{Name: e.Name, Path: p, Enabled: !e.Disabled, Lock: e})
+		seen[strings.ToLower(e.Name)] = true
+		installedDirs = append(installedDirs, p)
+	}
+	err = filepath.WalkDir(dir, func(p string, d os.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if p == dir {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if isDisabledPath(p, installedDirs) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		top := filepath.Dir(p) == dir
+		if (top && !isSupportedPlugin(d.Name())) || (!top && !isToolkitFile(d.Name())) || isDisabledPath(p, installedDirs) {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+		name := filepath.ToSlash(pluginName(rel))
+		if seen[strings.ToLower(name)] {
+			return nil
+		}
+		seen[strings.ToLower(name)] = true
+		tk := Toolkit{Name: name, Path: p, Enabled: !isDisabledPath(p, disabled)}
+		if i := lf.find(name); i >= 0 {
+			tk.Lock = lf.Toolkits[i]
+		}
+		out = append(out, tk)
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	// disabled domain directories have no file of their own to walk
+	for _, e := range lf.Toolkits {
+		if e.Type == "" && !seen[strings.ToLower(e.Name)] {
+			out = append(out, Toolkit{Name: e.Name, Path: filepath.Join(dir, filepath.FromSlash(e.Path)), Enabled: !e.Disabled, Lock: e})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return strings.ToLower(out[i].Name) < strings.ToLower(out[j].Name)
+	})
+	return out, nil
+}
+
+// FindToolkit resolves a toolkit by its full name, its base name when that
+// is unique, or a directory directly below plugins/.
+func FindToolkit(baseDir, name string) (Toolkit, error) {
+	name = strings.Trim(filepath.ToSlash(strings.TrimSpace(name)), "/")
+	if name == "" {
+		return Toolkit{}, fmt.Errorf("toolkit name is required")
+	}
+	all, err := Toolkits(baseDir)
+	if err != nil {
+		return Toolkit{}, err
+	}
+	var byBase []Toolkit
+	for _, tk := range all {
+		if strings.EqualFold(tk.Name, name) {
+			return tk, nil
+		}
+		if strings.EqualFold(path.Base(tk.Name), name) {
+			byBase = append(byBase, tk)
+		}
+	}
+	if len(byBase) == 1 {
+		return byBase[0], nil
+	}
+	if len(byBase) > 1 {
+		names := make([]string, 0, len(byBase))
+		for _, tk := range byBase {
+			names = append(names, tk.Name)
+		}
+		return Toolkit{}, fmt.Errorf("toolkit name %q is ambiguous: %s", name, strings.Join(names, ", "))
+	}
+	dirPath := filepath.Join(baseDir, "plugins", filepath.FromSlash(name))
+	if info, err := os.Stat(dirPath); err == nil && info.IsDir() && !strings.Contains(name, "..") {
+		return Toolkit{Name: name, Path: dirPath, Enabled: true}, nil
+	}
+	return Toolkit{}, fmt.Errorf("toolkit not found: %s", name)
+}
+
+// Install copies a toolkit from a local file or directory, a git URL or a
+// zip archive (path or URL) into plugins/ and records it in the lockfile.
+func Install(baseDir, source string, opts InstallOptions) (LockEntry, error) {
+	dir := filepath.Join(baseDir, "plugins")
+	source = strings.TrimSpace(source)
+	typ, err := toolkitSourceType(source)
+	if err != nil {
+		return LockEntry{}, err
+	}
+	if opts.Ref != "" && typ != "git" {
+		return LockEntry{}, fmt.Errorf("--ref only applies to git sources")
+	}
+	if typ == "file" || typ == "dir" {
+		if source, err = filepath.Abs(source); err != nil {
+			return LockEntry{}, err
+		}
+	}
+	name := strings.TrimSpace(opts.Name)
+	if name == "" {
+		name = defaultToolkitName(typ, source)
+	}
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\:`) {
+		return LockEntry{}, fmt.Errorf("invalid toolkit name %q (use --name)", name)
+	}
+
+	lf, err := readLock(dir)
+	if err != nil {
+		return LockEntry{}, err
+	}
+	if i := lf.find(name); i >= 0 && lf.Toolkits[i].Type != "" {
+		return LockEntry{}, fmt.Errorf("toolkit %s is already installed (use dm plugins update or remove it first)", name)
+	}
+
+	tmp, err := os.MkdirTemp("", "dm-plugin-")
+	if err != nil {
+		return LockEntry{}, err
+	}
+	defer os.RemoveAll(tmp)
+	root, version, err := fetchToolkitSource(typ, source, opts.Ref, tmp)
+	if err != nil {
+		return LockEntry{}, err
+	}
+	if err := checkToolkitSource(typ, root, source); err != nil {
+		return LockEntry{}, err
+	}
+
+	rel := name
+	if typ == "file" {
+		rel = name + filepath.Ext(root)
+	}
+	dest := filepath.Join(dir, rel)
+	if _, err := os.Lstat(dest); err == nil {
+		return LockEntry{}, fmt.Errorf("%s already exists", dest)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return LockEntry{}, err
+	}
+	if err := copyToolkit(root, dest); err != nil {
+		os.RemoveAll(dest)
+		return LockEntry{}, err
+	}
+	if version == "" {
+		version = toolkitHeaderVersion(dest)
+	}
+	sum, err := toolkitChecksum(dest)
+	if err != nil {
+		return LockEntry{}, err
+	}
+	entry := LockEntry{
+		Name:      name,
+		Path:      filepath.ToSlash(rel),
+		Type:      typ,
+		Source:    source,
+		Ref:       opts.Ref,
+		Version:   version,
+		Checksum:  sum,
+		Installed: time.Now().UTC().Truncate(time.Second),
+	}
+	if i := lf.find(name); i >= 0 {
+		entry.Disabled = lf.Toolkits[i].Disabled
+	}
+	lf.put(entry)
+	if err := writeLock(dir, lf); err != nil {
+		return LockEntry{}, err
+	}
+	return entry, nil
+}
+
+// checkToolkitSource rejects a fetched toolkit that dm could not run.
+// Inside a toolkit folder only PowerShell functions are discovered;
+// standalone scripts are run from the top level of plugins/ only.
+func checkToolkitSource(typ, root, source string) error {
+	if !containsToolkitFiles(root) {
+		return fmt.Errorf("no toolkit files (.ps1, .psm1, .sh, .cmd, .bat) found in %s", source)
+	}
+	if typ == "file" {
+		return nil
+	}
+	fns, _, err := collectPowerShellFunctions(root)
+	if err != nil {
+		return err
+	}
+	if len(fns) == 0 {
+		return fmt.Errorf("no PowerShell functions found in %s: a toolkit folder must define functions in .ps1/.psm1 files; install standalone scripts (.sh, .cmd, .bat) as single files", source)
+	}
+	return nil
+}
+
+// Update fetches a git-sourced toolkit again and replaces the installed
+// copy. It refuses to overwrite local edits unless force is set. The bool
+// reports whether anything changed.
+func Update(baseDir, name string, force bool) (LockEntry, bool, error) {
+	dir := filepath.Join(baseDir, "plugins")
+	lf, err := readLock(dir)
+	if err != nil {
+		return LockEntry{}, false, err
+	}
+	i := lf.find(name)
+	if i < 0 || lf.Toolkits[i].Type == "" {
+		return LockEntry{}, false, fmt.Errorf("toolkit %s was not installed with dm plugins install", name)
+	}
+	entry := lf.Toolkits[i]
+	if entry.Type != "git" {
+		return entry, false, fmt.Errorf("toolkit %s was installed from a %s source; only git toolkits can be updated (remove and install it again)", entry.Name, entry.Type)
+	}
+	dest := filepath.Join(dir, filepath.FromSlash(entry.Path))
+	if current, err := toolkitChecksum(dest); err == nil && current != entry.Checksum && !force {
+		return entry, false, fmt.Errorf("toolkit %s has local changes (checksum mismatch); use --force to overwrite them", entry.Name)
+	}
+
+	// Stage inside plugins/ so the backup and restore renames stay on one
+	// filesystem (the system temp dir is often a tmpfs); dot directories
+	// are not scanned for toolkits.
+	tmp, err := os.MkdirTemp(dir, ".dm-update-*")
+	if err != nil {
+		return entry, false, err
+	}
+	defer os.RemoveAll(tmp)
+	root, version, err := fetchToolkitSource("git", entry.Source, entry.Ref, tmp)
+	if err != nil {
+		return entry, false, err
+	}
+	if err := checkToolkitSource("git", root, entry.Source); err != nil {
+		return entry, false, err
+	}
+	sum, err := toolkitChecksum(root)
+	if err != nil {
+		return entry, false, err
+	}
+	if current, err := toolkitChecksum(dest); err == nil && current == sum {
+		entry.Version, entry.Checksum = version, sum
+		lf.put(entry)
+		return entry, false, writeLock(dir, lf)
+	}
+
+	backup := filepath.Join(tmp, "previous")
+	if err := os.Rename(dest, backup); err != nil && !os.IsNotExist(err) {
+		return entry, false, err
+	}
+	if err := copyToolkit(root, dest); err != nil {
+		os.RemoveAll(dest)
+		os.Rename(backup, dest)
+		return entry, false, err
+	}
+	entry.Version, entry.Checksum = version, sum
+	entry.Installed = time.Now().UTC().Truncate(time.Second)
+	lf.put(entry)
+	if err := writeLock(dir, lf); err != nil {
+		return entry, true, err
+	}
+	return entry, true, nil
+}
+
+// Remove deletes a toolkit (with its sidecar files) and its lock entry.
+func Remove(baseDir, name string) (Toolkit, error) {
+	dir := filepath.Join(baseDir, "plugins")
+	tk, err := FindToolkit(baseDir, name)
+	if err != nil {
+		return Toolkit{}, err
+	}
+	rel, err := filepath.Rel(dir, tk.Path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return tk, fmt.Errorf("refusing to remove %s: not inside %s", tk.Path, dir)
+	}
+	lf, err := readLock(dir)
+	if err != nil {
+		return tk, err
+	}
+	if err := os.RemoveAll(tk.Path); err != nil {
+		return tk, err
+	}
+	for _, side := range toolkitSidecars(tk.Path) {
+		os.Remove(side)
+	}
+	lf.drop(tk.Name)
+	return tk, writeLock(dir, lf)
+}
+
+// SetEnabled hides or shows a toolkit in ListEntries, GetInfo and Run
+// without deleting it.
+func SetEnabled(baseDir, name string, enabled bool) (Toolkit, error) {
+	dir := filepath.Join(baseDir, "plugins")
+	tk, err := FindToolkit(baseDir, name)
+	if err != nil {
+		return Toolkit{}, err
+	}
+	lf, err := readLock(dir)
+	if err != nil {
+		return tk, err
+	}
+	entry := tk.Lock
+	if entry.Name == "" {
+		rel, _ := filepath.Rel(dir, tk.Path)
+		entry = LockEntry{Name: tk.Name, Path: filepath.ToSlash(rel)}
+	}
+	entry.Disabled = !enabled
+	if enabled && entry.Type == "" {
+		lf.drop(entry.Name)
+	} else {
+		lf.put(entry)
+	}
+	tk.Enabled, tk.Lock = enabled, entry
+	return tk, writeLock(dir, lf)
+}
+
+// LockEntries returns the toolkits recorded in the lockfile.
+func LockEntries(baseDir string) ([]LockEntry, error) {
+	lf, err := readLock(filepath.Join(baseDir, "plugins"))
+	if err != nil {
+		return nil, err
+	}
+	return lf.Toolkits, nil
+}
+
+func toolkitSourceType(source string) (string, error) {
+	if source == "" {
+		return "", fmt.Errorf("source is required (path, git URL or zip)")
+	}
+	lc := strings.ToLower(source)
+	if strings.HasPrefix(lc, "git@") {
+		return "git", nil
+	}
+	for _, scheme := range []string{"http://", "https://", "git://", "ssh://", "file://"} {
+		if strings.HasPrefix(lc, scheme) {
+			if u := strings.SplitN(lc, "?", 2)[0]; strings.HasSuffix(u, ".zip") && scheme != "git://" && scheme != "ssh://" {
+				return "zip", nil
+			}
+			return "git", nil
+		}
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("source not found: %s", source)
+		}
+		return "", err
+	}
+	switch {
+	case info.IsDir():
+		return "dir", nil
+	case strings.HasSuffix(lc, ".zip"):
+		return "zip", nil
+	case isToolkitFile(source) || isSupportedPlugin(filepath.Base(source)):
+		return "file", nil
+	}
+	return "", fmt.Errorf("unsupported toolkit file: %s", source)
+}
+
+func defaultToolkitName(typ, source string) string {
+	s := strings.TrimRight(strings.SplitN(source, "?", 2)[0], `/\`)
+	if i := strings.LastIndexAny(s, `/\:`); i >= 0 {
+		s = s[i+1:]
+	}
+	switch typ {
+	case "file":
+		return pluginName(s)
+	case "git":
+		return strings.TrimSuffix(s, ".git")
+	case "zip":
+		return strings.TrimSuffix(strings.TrimSuffix(s, ".zip"), ".ZIP")
+	}
+	return s
+}
+
+// fetchToolkitSource returns the local file or directory to copy from,
+// cloning or extracting into tmp when needed, and the version it found.
+func fetchToolkitSource(typ, source, ref, tmp string) (string, string, error) {
+	switch typ {
+	case "git":
+		dst := filepath.Join(tmp, "src")
+		if err := gitClone(source, ref, dst); err != nil {
+			return "", "", err
+		}
+		version, _ := gitOutput(dst, "describe", "--tags", "--always")
+		if err := os.RemoveAll(filepath.Join(dst, ".git")); err != nil {
+			return "", "", err
+		}
+		return dst, version, nil
+	case "zip":
+		archive := source
+		lc := strings.ToLower(source)
+		if strings.HasPrefix(lc, "http://") || strings.HasPrefix(lc, "https://") {
+			archive = filepath.Join(tmp, "download.zip")
+			if err := downloadFile(source, archive); err != nil {
+				return "", "", err
+			}
+		} else if strings.HasPrefix(lc, "file://") {
+			archive = source[len("file://"):]
+		}
+		dst := filepath.Join(tmp, "src")
+		if err := extractZip(archive, dst); err != nil {
+			return "", "", err
+		}
+		return singleTopDir(dst), "", nil
+	}
+	return source, "", nil
+}
+
+func gitClone(source, ref, dst string) error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return fmt.Errorf("git is not installed")
+	}
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	args = append(args, source, dst)
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git clone %s failed: %v\n%s", source, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func gitOutput(dir string, args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+func downloadFile(url, dst string) error {
+	client := &http.Client{Timeout: 2 * time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download %s failed: %s", url, resp.Status)
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, io.LimitReader(resp.Body, toolkitDownloadMaxBytes+1))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if n > toolkitDownloadMaxBytes {
+		return fmt.Errorf("download %s is larger than %d MB", url, toolkitDownloadMaxBytes/(1024*1024))
+	}
+	return nil
+}
+
+// extractZip unpacks archive into dst, rejecting entries that would land
+// outside it. Symlinks are skipped.
+func extractZip(archive, dst string) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("cannot open zip %s: %w", archive, err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		name := filepath.Clean(filepath.FromSlash(f.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) || filepath.VolumeName(name) != "" {
+			return fmt.Errorf("unsafe path in zip: %s", f.Name)
+		}
+		target := filepath.Join(dst, name)
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		case !mode.IsRegular():
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := extractZipFile(f, target, mode.Perm()|0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipFile(f *zip.File, target string, perm os.FileMode) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// singleTopDir unwraps archives that hold everything in one folder.
+func singleTopDir(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return dir
+	}
+	var keep []os.DirEntry
+	for _, e := range entries {
+		if e.Name() != "__MACOSX" {
+			keep = append(keep, e)
+		}
+	}
+	if len(keep) == 1 && keep[0].IsDir() {
+		return filepath.Join(dir, keep[0].Name())
+	}
+	return dir
+}
+
+func isToolkitFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".ps1", ".psm1", ".sh", ".cmd", ".bat":
+		return true
+	}
+	return false
+}
+
+func containsToolkitFiles(root string) bool {
+	found := false
+	filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil || found {
+			return fs.SkipAll
+		}
+		if !d.IsDir() && isToolkitFile(d.Name()) {
+			found = true
+		}
+		return nil
+	})
+	return found
+}
+
+// toolkitSidecars lists the files that travel with a toolkit file.
+func toolkitSidecars(toolkitPath string) []string {
+	var out []string
+	for _, p := range []string{ToolkitPromptSidecar(toolkitPath), ToolkitManifestSidecar(toolkitPath)} {
+		if _, err := os.Stat(p); err == nil {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func copyToolkit(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		if err := copyFileMode(src, dst); err != nil {
+			return err
+		}
+		for _, side := range toolkitSidecars(src) {
+			target := strings.TrimSuffix(dst, filepath.Ext(dst)) + strings.TrimPrefix(side, strings.TrimSuffix(src, filepath.Ext(src)))
+			if err := copyFileMode(side, target); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return filepath.WalkDir(src, func(p string, d os.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, _ := filepath.Rel(src, p)
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return copyFileMode(p, filepath.Join(dst, rel))
+	})
+}
+
+func copyFileMode(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// toolkitChecksum hashes a toolkit file, or every file below a toolkit
+// directory together with its relative path, as "sha256:<hex>".
+func toolkitChecksum(root string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(root, func(p string, d os.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, p)
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(h, "%s\x00%x\n", filepath.ToSlash(rel), sum)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// toolkitHeaderVersion returns the manifest version of a toolkit file, or
+// of the first toolkit file below a directory that declares one.
+func toolkitHeaderVersion(p string) string {
+	version := ""
+	filepath.WalkDir(p, func(fp string, d os.DirEntry, err error) error {
+		if err != nil || version != "" {
+			return fs.SkipAll
+		}
+		if d.IsDir() || !isToolkitFile(d.Name()) {
+			return nil
+		}
+		m, _ := ParseManifest(fp)
+		version = m.Version
+		return nil
+	})
+	return version
+}
@@ -0,0 +1,301 @@
+package plugins
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func entryNames(t *testing.T, baseDir string) []string {
+	t.Helper()
+	items, err := ListEntries(baseDir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, it := range items {
+		out = append(out, it.Name)
+	}
+	return out
+}
+
+func TestInstallFileAndDisable(t *testing.T) {
+	clearPluginCacheForTest()
+	baseDir := t.TempDir()
+	src := filepath.Join(t.TempDir(), "Jira_Toolkit.ps1")
+	writeTestFile(t, src, "# JIRA TOOLKIT\n# Version: 1.4.0\nfunction jira_issue { param([string]$Key) }\n")
+	writeTestFile(t, ToolkitPromptSidecar(src), "Use the staging project.")
+
+	entry, err := Install(baseDir, src, InstallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Name != "Jira_Toolkit" || entry.Type != "file" || entry.Version != "1.4.0" || !strings.HasPrefix(entry.Checksum, "sha256:") {
+		t.Fatalf("unexpected lock entry: %+v", entry)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "plugins", "Jira_Toolkit.prompt.md")); err != nil {
+		t.Fatalf("expected prompt sidecar to be copied: %v", err)
+	}
+	if _, err := Install(baseDir, src, InstallOptions{}); err == nil {
+		t.Fatal("expected second install to fail")
+	}
+	if names := entryNames(t, baseDir); !strings.Contains(strings.Join(names, ","), "jira_issue") {
+		t.Fatalf("expected jira_issue in entries, got %v", names)
+	}
+
+	if _, err := SetEnabled(baseDir, "jira_toolkit", false); err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(t, baseDir); len(names) != 0 {
+		t.Fatalf("expected disabled toolkit to be hidden, got %v", names)
+	}
+	if _, err := GetInfo(baseDir, "jira_issue"); !IsNotFound(err) {
+		t.Fatalf("expected disabled function to be not found, got %v", err)
+	}
+	tks, err := Toolkits(baseDir)
+	if err != nil || len(tks) != 1 || tks[0].Enabled {
+		t.Fatalf("expected one disabled toolkit, got %+v (%v)", tks, err)
+	}
+
+	if _, err := SetEnabled(baseDir, "Jira_Toolkit", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetInfo(baseDir, "jira_issue"); err != nil {
+		t.Fatalf("expected function after enable, got %v", err)
+	}
+
+	if _, err := Remove(baseDir, "Jira_Toolkit"); err != nil {
+		t.Fatal(err)
+	}
+	left, _ := os.ReadDir(filepath.Join(baseDir, "plugins"))
+	if len(left) != 0 {
+		t.Fatalf("expected empty plugins dir after remove, got %d entries", len(left))
+	}
+}
+
+func TestInstalledToolkitRuns(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell plugin")
+	}
+	clearPluginCacheForTest()
+	baseDir := t.TempDir()
+	srcDir := filepath.Join(t.TempDir(), "hello-kit")
+	writeTestFile(t, filepath.Join(srcDir, "hello.sh"), "echo hello from kit\n")
+	if _, err := Install(baseDir, srcDir, InstallOptions{}); err == nil || !strings.Contains(err.Error(), "single files") {
+		t.Fatalf("expected a script-only folder to be refused, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "plugins", "hello-kit")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing installed, got %v", err)
+	}
+
+	if _, err := Install(baseDir, filepath.Join(srcDir, "hello.sh"), InstallOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetInfo(baseDir, "hello"); err != nil {
+		t.Fatalf("expected the installed script to be found, got %v", err)
+	}
+	res := RunStructured(context.Background(), baseDir, "hello", nil)
+	if res.Err != nil || strings.TrimSpace(res.Stdout) != "hello from kit" {
+		t.Fatalf("expected the installed script to run, got %q (%v)", res.Stdout, res.Err)
+	}
+}
+
+func TestDisableDomainDirectory(t *testing.T) {
+	clearPluginCacheForTest()
+	baseDir := t.TempDir()
+	writeTestFile(t, filepath.Join(baseDir, "plugins", "M365", "Auth_Toolkit.ps1"), "function m365_login {}\n")
+	writeTestFile(t, filepath.Join(baseDir, "plugins", "Text_Toolkit.ps1"), "function txt_upper {}\n")
+
+	if got := strings.Join(entryNames(t, baseDir), ","); got != "Text_Toolkit,m365_login,txt_upper" {
+		t.Fatalf("unexpected entries before disable: %s", got)
+	}
+	if _, err := SetEnabled(baseDir, "M365", false); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(entryNames(t, baseDir), ","); got != "Text_Toolkit,txt_upper" {
+		t.Fatalf("unexpected entries after disable: %s", got)
+	}
+	files, err := ListFunctionFiles(baseDir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected only Text_Toolkit in function files, got %+v (%v)", files, err)
+	}
+	if _, err := SetEnabled(baseDir, "M365", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "plugins", LockFileName)); !os.IsNotExist(err) {
+		t.Fatalf("expected lockfile to be dropped once nothing is recorded, got %v", err)
+	}
+}
+
+func TestInstallZipUnwrapsTopFolder(t *testing.T) {
+	clearPluginCacheForTest()
+	baseDir := t.TempDir()
+	archive := filepath.Join(t.TempDir(), "vendor-kit.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, body := range map[string]string{
+		"vendor-kit/Vendor_Toolkit.ps1": "function vendor_ping {}\n",
+		"vendor-kit/README.md":          "docs\n",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(body))
+	}
+	zw.Close()
+	f.Close()
+
+	entry, err := Install(baseDir, archive, InstallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Name != "vendor-kit" || entry.Path != "vendor-kit" || entry.Type != "zip" {
+		t.Fatalf("unexpected lock entry: %+v", entry)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "plugins", "vendor-kit", "Vendor_Toolkit.ps1")); err != nil {
+		t.Fatalf("expected unwrapped toolkit file: %v", err)
+	}
+	if _, _, err := Update(baseDir, "vendor-kit", false); err == nil || !strings.Contains(err.Error(), "only git") {
+		t.Fatalf("expected update of zip toolkit to be refused, got %v", err)
+	}
+}
+
+func TestExtractZipRejectsTraversal(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "evil.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, _ := zw.Create("../evil.ps1")
+	w.Write([]byte("x"))
+	zw.Close()
+	f.Close()
+	if err := extractZip(archive, filepath.Join(t.TempDir(), "out")); err == nil {
+		t.Fatal("expected unsafe path error")
+	}
+}
+
+// newGitToolkitRepo creates a git repo holding a one-function toolkit and
+// returns it with a helper that runs git commands in it.
+func newGitToolkitRepo(t *testing.T) (string, func(args ...string)) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := filepath.Join(t.TempDir(), "team-kit")
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	writeTestFile(t, filepath.Join(repo, "Team_Toolkit.ps1"), "function team_a {}\n")
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "one")
+	return repo, git
+}
+
+func TestInstallAndUpdateGit(t *testing.T) {
+	clearPluginCacheForTest()
+	repo, git := newGitToolkitRepo(t)
+
+	baseDir := t.TempDir()
+	entry, err := Install(baseDir, "file://"+filepath.ToSlash(repo), InstallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Name != "team-kit" || entry.Type != "git" || entry.Version == "" {
+		t.Fatalf("unexpected lock entry: %+v", entry)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "plugins", "team-kit", ".git")); !os.IsNotExist(err) {
+		t.Fatalf("expected .git to be left out of the install, got %v", err)
+	}
+	if _, changed, err := Update(baseDir, "team-kit", false); err != nil || changed {
+		t.Fatalf("expected up to date, got changed=%v err=%v", changed, err)
+	}
+
+	writeTestFile(t, filepath.Join(repo, "Team_Toolkit.ps1"), "function team_a {}\nfunction team_b {}\n")
+	git("commit", "-q", "-am", "two")
+	installed := filepath.Join(baseDir, "plugins", "team-kit", "Team_Toolkit.ps1")
+	writeTestFile(t, installed, "function team_local {}\n")
+	if _, _, err := Update(baseDir, "team-kit", false); err == nil || !strings.Contains(err.Error(), "local changes") {
+		t.Fatalf("expected local changes to block update, got %v", err)
+	}
+	updated, changed, err := Update(baseDir, "team-kit", true)
+	if err != nil || !changed {
+		t.Fatalf("expected forced update, got changed=%v err=%v", changed, err)
+	}
+	if updated.Version == entry.Version || updated.Checksum == entry.Checksum {
+		t.Fatalf("expected new version and checksum, got %+v", updated)
+	}
+	data, _ := os.ReadFile(installed)
+	if !strings.Contains(string(data), "team_b") {
+		t.Fatalf("expected updated toolkit content, got %q", data)
+	}
+}
+
+func TestUpdateGitAcrossFilesystems(t *testing.T) {
+	clearPluginCacheForTest()
+	// The base dir lives on another filesystem than the temp dir, as with
+	// a tmpfs /tmp, so a rename between the two would fail.
+	baseDir, err := os.MkdirTemp("/dev/shm", "dm-update-test-")
+	if err != nil {
+		t.Skip("no /dev/shm")
+	}
+	t.Cleanup(func() { os.RemoveAll(baseDir) })
+	probe := filepath.Join(t.TempDir(), "probe")
+	writeTestFile(t, probe, "x")
+	if err := os.Rename(probe, filepath.Join(baseDir, "probe")); err == nil {
+		t.Skip("/dev/shm and the temp dir share a filesystem")
+	}
+	repo, git := newGitToolkitRepo(t)
+	if _, err := Install(baseDir, "file://"+filepath.ToSlash(repo), InstallOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestFile(t, filepath.Join(repo, "Team_Toolkit.ps1"), "function team_a {}\nfunction team_b {}\n")
+	git("commit", "-q", "-am", "two")
+	if _, changed, err := Update(baseDir, "team-kit", false); err != nil || !changed {
+		t.Fatalf("expected the update to apply, got changed=%v err=%v", changed, err)
+	}
+
+	git("rm", "-q", "Team_Toolkit.ps1")
+	writeTestFile(t, filepath.Join(repo, "run.sh"), "echo hi\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "scripts only")
+	if _, _, err := Update(baseDir, "team-kit", false); err == nil || !strings.Contains(err.Error(), "no PowerShell functions") {
+		t.Fatalf("expected an update without functions to be refused, got %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(baseDir, "plugins", "team-kit", "Team_Toolkit.ps1"))
+	if err != nil || !strings.Contains(string(data), "team_b") {
+		t.Fatalf("expected the installed toolkit to be kept, got %q (%v)", data, err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(baseDir, "plugins"))
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".dm-update-") {
+			t.Fatalf("staging dir %s was left behind", e.Name())
+		}
+	}
+}
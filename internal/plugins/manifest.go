@@ -0,0 +1,326 @@
+package plugins
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Manifest is the structured metadata of a toolkit. It comes from a sidecar
+// <Toolkit>.json, else from a "# ---" front-matter block in the header, and
+// is completed from the legacy "# Safety:", "# Version:", "# Timeout:" and
+// "# Entry point:" header lines.
+type Manifest struct {
+	Name         string            `json:"name,omitempty"`
+	Version      string            `json:"version,omitempty"`
+	Prefix       string            `json:"prefix,omitempty"`
+	Safety       string            `json:"safety,omitempty"`
+	Requires     []string          `json:"requires,omitempty"` // commands that must be on PATH
+	Env          []string          `json:"env,omitempty"`
+	MinDMVersion string            `json:"min_dm_version,omitempty"`
+	Risk         map[string]string `json:"risk,omitempty"`     // function -> low|medium|high
+	Timeout      string            `json:"timeout,omitempty"`  // default for every function, e.g. "2m"
+	Timeouts     map[string]string `json:"timeouts,omitempty"` // function -> duration
+	Source       string            `json:"-"`                  // sidecar|front-matter|header
+}
+
+var (
+	psEntryPointLine  = regexp.MustCompile(`(?i)^#\s*Entry point:\s*([a-z0-9_-]+?)_?\*`)
+	psTimeoutLine     = regexp.MustCompile(`(?i)^#\s*Timeout:\s*(\S+)`)
+	psFrontMatterRule = regexp.MustCompile(`^#\s*---\s*$`)
+	psFrontMatterPair = regexp.MustCompile(`^#\s*([a-z_]+)\s*:\s*(.*)$`)
+)
+
+// ToolkitManifestSidecar is the JSON manifest next to a toolkit, e.g.
+// Docker_Toolkit.json.
+func ToolkitManifestSidecar(filePath string) string {
+	return strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".json"
+}
+
+// ParseManifest reads the manifest of a toolkit file. A toolkit without any
+// metadata yields a Manifest with only Name set and an empty Source.
+func ParseManifest(filePath string) (Manifest, error) {
+	m := Manifest{}
+	var err error
+	if data, readErr := os.ReadFile(ToolkitManifestSidecar(filePath)); readErr == nil {
+		m.Source = "sidecar"
+		if jsonErr := json.Unmarshal(data, &m); jsonErr != nil {
+			err = fmt.Errorf("invalid manifest %s: %w", filepath.Base(ToolkitManifestSidecar(filePath)), jsonErr)
+		}
+	} else {
+		m, err = parseFrontMatter(filePath)
+	}
+	if m.Safety == "" {
+		m.Safety = parseHeaderField(filePath, psSafetyLine)
+	}
+	if m.Version == "" {
+		m.Version = parseHeaderField(filePath, psVersionLine)
+	}
+	if m.Prefix == "" {
+		m.Prefix = parseHeaderField(filePath, psEntryPointLine)
+	}
+	if m.Timeout == "" {
+		m.Timeout = parseHeaderField(filePath, psTimeoutLine)
+	}
+	if m.Source == "" && (m.Safety != "" || m.Version != "" || m.Prefix != "" || m.Timeout != "") {
+		m.Source = "header"
+	}
+	if m.Name == "" {
+		m.Name = pluginName(filepath.Base(filePath))
+	}
+	if err == nil {
+		err = m.validate()
+	}
+	return m, err
+}
+
+func parseFrontMatter(filePath string) (Manifest, error) {
+	m := Manifest{}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return m, nil
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	inBlock := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		if psFrontMatterRule.MatchString(line) {
+			if inBlock {
+				return m, nil
+			}
+			inBlock, m.Source = true, "front-matter"
+			continue
+		}
+		if !inBlock {
+			continue
+		}
+		pair := psFrontMatterPair.FindStringSubmatch(line)
+		if pair == nil {
+			continue
+		}
+		key, value := strings.ToLower(pair[1]), strings.TrimSpace(pair[2])
+		switch key {
+		case "name":
+			m.Name = value
+		case "version":
+			m.Version = value
+		case "prefix":
+			m.Prefix = strings.TrimSuffix(strings.TrimSuffix(value, "*"), "_")
+		case "safety":
+			m.Safety = value
+		case "requires":
+			m.Requires = append(m.Requires, splitManifestList(value)...)
+		case "env":
+			m.Env = append(m.Env, splitManifestList(value)...)
+		case "min_dm_version":
+			m.MinDMVersion = value
+		case "risk":
+			for _, item := range splitManifestList(value) {
+				fn, level, ok := strings.Cut(item, "=")
+				if !ok {
+					return m, fmt.Errorf("front-matter risk %q must be function=level", item)
+				}
+				if m.Risk == nil {
+					m.Risk = map[string]string{}
+				}
+				m.Risk[strings.TrimSpace(fn)] = strings.ToLower(strings.TrimSpace(level))
+			}
+		case "timeout":
+			m.Timeout = value
+		case "timeouts":
+			for _, item := range splitManifestList(value) {
+				fn, d, ok := strings.Cut(item, "=")
+				if !ok {
+					return m, fmt.Errorf("front-matter timeouts %q must be function=duration", item)
+				}
+				if m.Timeouts == nil {
+					m.Timeouts = map[string]string{}
+				}
+				m.Timeouts[strings.TrimSpace(fn)] = strings.TrimSpace(d)
+			}
+		default:
+			return m, fmt.Errorf("unknown front-matter key %q", key)
+		}
+	}
+	if inBlock {
+		return m, fmt.Errorf("front-matter block is not closed with \"# ---\"")
+	}
+	return Manifest{}, nil
+}
+
+func splitManifestList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func (m Manifest) validate() error {
+	var problems []string
+	for fn, level := range m.Risk {
+		switch level {
+		case "low", "medium", "high":
+		default:
+			problems = append(problems, fmt.Sprintf("risk for %s is %q (use low|medium|high)", fn, level))
+		}
+	}
+	if m.MinDMVersion != "" {
+		if _, ok := parseVersion(m.MinDMVersion); !ok {
+			problems = append(problems, fmt.Sprintf("min_dm_version %q is not a version", m.MinDMVersion))
+		}
+	}
+	if m.Timeout != "" {
+		if _, ok := parseManifestDuration(m.Timeout); !ok {
+			problems = append(problems, fmt.Sprintf("timeout %q is not a duration (e.g. 30s, 10m)", m.Timeout))
+		}
+	}
+	for fn, d := range m.Timeouts {
+		if _, ok := parseManifestDuration(d); !ok {
+			problems = append(problems, fmt.Sprintf("timeout for %s is %q (use e.g. 30s, 10m)", fn, d))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("%s", strings.Join(problems, "; "))
+}
+
+// FunctionRisk returns the risk level for a function of the toolkit and the
+// reason: a per-function override, else the level implied by Safety. Both
+// are empty when the manifest says nothing.
+func (m Manifest) FunctionRisk(function string) (string, string) {
+	if level, ok := m.RiskOverride(function); ok {
+		return level, "toolkit manifest risk for " + function
+	}
+	if m.Safety != "" {
+		return ToolkitRiskLevel(m.Safety), m.Safety
+	}
+	return "", ""
+}
+
+// RiskOverride returns the per-function risk declared in the manifest.
+func (m Manifest) RiskOverride(function string) (string, bool) {
+	for fn, level := range m.Risk {
+		if strings.EqualFold(fn, function) {
+			return level, true
+		}
+	}
+	return "", false
+}
+
+// FunctionTimeout returns how long a function of the toolkit may run: its
+// own entry in Timeouts, else Timeout, else zero for the default.
+func (m Manifest) FunctionTimeout(function string) time.Duration {
+	for fn, d := range m.Timeouts {
+		if strings.EqualFold(fn, function) {
+			if v, ok := parseManifestDuration(d); ok {
+				return v
+			}
+		}
+	}
+	if v, ok := parseManifestDuration(m.Timeout); ok {
+		return v
+	}
+	return 0
+}
+
+func parseManifestDuration(v string) (time.Duration, bool) {
+	d, err := time.ParseDuration(strings.TrimSpace(v))
+	if err != nil || d <= 0 {
+		return 0, false
+	}
+	return d, true
+}
+
+// Check reports unmet requirements: missing commands, unset environment
+// variables and a dm version below MinDMVersion. Development builds
+// skip the version check.
+func (m Manifest) Check(dmVersion string) []string {
+	var problems []string
+	for _, cmd := range m.Requires {
+		if _, err := exec.LookPath(cmd); err != nil {
+			problems = append(problems, fmt.Sprintf("command %s not found", cmd))
+		}
+	}
+	for _, name := range m.Env {
+		if strings.TrimSpace(os.Getenv(name)) == "" {
+			problems = append(problems, fmt.Sprintf("env %s is not set", name))
+		}
+	}
+	if m.MinDMVersion != "" {
+		want, okWant := parseVersion(m.MinDMVersion)
+		have, okHave := parseVersion(dmVersion)
+		if okWant && okHave && compareVersions(have, want) < 0 {
+			problems = append(problems, fmt.Sprintf("needs dm %s or newer (running %s)", m.MinDMVersion, dmVersion))
+		}
+	}
+	return problems
+}
+
+// parseVersion reads "v1.2.3" style versions; missing parts count as 0 and
+// pre-release suffixes are ignored.
+func parseVersion(v string) ([3]int, bool) {
+	var out [3]int
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	v, _, _ = strings.Cut(v, "-")
+	v, _, _ = strings.Cut(v, "+")
+	parts := strings.Split(v, ".")
+	if v == "" || len(parts) > 3 {
+		return out, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return out, false
+		}
+		out[i] = n
+	}
+	return out, true
+}
+
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func cloneManifest(m Manifest) Manifest {
+	out := m
+	out.Requires = append([]string(nil), m.Requires...)
+	out.Env = append([]string(nil), m.Env...)
+	if m.Risk != nil {
+		out.Risk = make(map[string]string, len(m.Risk))
+		for k, v := range m.Risk {
+			out.Risk[k] = v
+		}
+	}
+	if m.Timeouts != nil {
+		out.Timeouts = make(map[string]string, len(m.Timeouts))
+		for k, v := range m.Timeouts {
+			out.Timeouts[k] = v
+		}
+	}
+	return out
+}
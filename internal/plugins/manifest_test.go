@@ -0,0 +1,147 @@
+package plugins
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseManifestFrontMatter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Docker_Toolkit.ps1")
+	writeTestFile(t, path, strings.Join([]string{
+		"# DOCKER TOOLKIT",
+		"# Safety: Non-destructive defaults.",
+		"# Entry point: dc_*",
+		"#",
+		"# ---",
+		"# version: 1.2.0",
+		"# requires: docker, az",
+		"# env: DM_DOCKER_COMPOSE_FILE",
+		"# min_dm_version: v0.9",
+		"# risk: dc_kill=high, dc_down=Medium",
+		"# ---",
+		"function dc_kill {}",
+	}, "\n"))
+	m, err := ParseManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Manifest{
+		Name: "Docker_Toolkit", Version: "1.2.0", Prefix: "dc", Safety: "Non-destructive defaults.",
+		Requires: []string{"docker", "az"}, Env: []string{"DM_DOCKER_COMPOSE_FILE"}, MinDMVersion: "v0.9",
+		Risk: map[string]string{"dc_kill": "high", "dc_down": "medium"}, Source: "front-matter",
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("unexpected manifest:\n got %+v\nwant %+v", m, want)
+	}
+	if risk, _ := m.FunctionRisk("DC_KILL"); risk != "high" {
+		t.Fatalf("expected override for dc_kill, got %q", risk)
+	}
+	if risk, reason := m.FunctionRisk("dc_ps"); risk != "medium" || reason != m.Safety {
+		t.Fatalf("expected safety-derived risk for dc_ps, got %q (%s)", risk, reason)
+	}
+}
+
+func TestParseManifestSidecarWinsAndHeaderFallback(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Text_Toolkit.ps1")
+	writeTestFile(t, path, "# TEXT TOOLKIT\n# Safety: Read-only.\n# Entry point: txt_*\n# ---\n# version: 9.9.9\n# ---\n")
+	writeTestFile(t, ToolkitManifestSidecar(path), `{"name":"Text","version":"2.0.0","requires":["iconv"]}`)
+	m, err := ParseManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Source != "sidecar" || m.Name != "Text" || m.Version != "2.0.0" || m.Prefix != "txt" || m.Safety != "Read-only." {
+		t.Fatalf("unexpected manifest: %+v", m)
+	}
+
+	os.Remove(ToolkitManifestSidecar(path))
+	writeTestFile(t, path, "# TEXT TOOLKIT\n# Version: 0.3.1\n# Entry point: txt_*\n")
+	m, err = ParseManifest(path)
+	if err != nil || m.Source != "header" || m.Version != "0.3.1" || m.Prefix != "txt" {
+		t.Fatalf("unexpected header manifest: %+v (%v)", m, err)
+	}
+}
+
+func TestParseManifestRejectsBadValues(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string]string{
+		"level":   "# ---\n# risk: x_run=extreme\n# ---\n",
+		"version": "# ---\n# min_dm_version: latest\n# ---\n",
+		"key":     "# ---\n# author: me\n# ---\n",
+		"open":    "# ---\n# version: 1.0\n",
+		"timeout": "# ---\n# timeouts: x_run=forever\n# ---\n",
+	}
+	for name, header := range cases {
+		path := filepath.Join(dir, name+".ps1")
+		writeTestFile(t, path, header+"function x_run {}\n")
+		if _, err := ParseManifest(path); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestManifestFunctionTimeout(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Build_Toolkit.ps1")
+	writeTestFile(t, path, "# ---\n# timeout: 2m\n# timeouts: b_release=30m, b_lint=10s\n# ---\nfunction b_lint {}\n")
+	m, err := ParseManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.FunctionTimeout("B_LINT"); got != 10*time.Second {
+		t.Fatalf("expected per-function timeout, got %s", got)
+	}
+	if got := m.FunctionTimeout("b_test"); got != 2*time.Minute {
+		t.Fatalf("expected toolkit timeout, got %s", got)
+	}
+
+	header := filepath.Join(dir, "Old_Toolkit.ps1")
+	writeTestFile(t, header, "# OLD TOOLKIT\n# Timeout: 45s\nfunction o_run {}\n")
+	if m, err = ParseManifest(header); err != nil || m.Source != "header" || m.FunctionTimeout("o_run") != 45*time.Second {
+		t.Fatalf("unexpected header timeout: %+v (%v)", m, err)
+	}
+	if got := (Manifest{}).FunctionTimeout("o_run"); got != 0 {
+		t.Fatalf("expected no timeout without a manifest, got %s", got)
+	}
+}
+
+func TestManifestCheck(t *testing.T) {
+	t.Setenv("DM_TEST_SET", "1")
+	t.Setenv("DM_TEST_UNSET", "")
+	m := Manifest{
+		Requires:     []string{"definitely-not-a-real-command-dm"},
+		Env:          []string{"DM_TEST_SET", "DM_TEST_UNSET"},
+		MinDMVersion: "1.4.0",
+	}
+	got := strings.Join(m.Check("v1.3.9"), "; ")
+	want := "command definitely-not-a-real-command-dm not found; env DM_TEST_UNSET is not set; needs dm 1.4.0 or newer (running v1.3.9)"
+	if got != want {
+		t.Fatalf("unexpected problems:\n got %s\nwant %s", got, want)
+	}
+	if problems := (Manifest{MinDMVersion: "1.4.0"}).Check("dev"); len(problems) != 0 {
+		t.Fatalf("expected dev builds to skip the version check, got %v", problems)
+	}
+}
+
+func TestGetInfoExposesManifest(t *testing.T) {
+	clearPluginCacheForTest()
+	baseDir := t.TempDir()
+	path := filepath.Join(baseDir, "plugins", "Kit_Toolkit.ps1")
+	writeTestFile(t, path, "# ---\n# version: 1.0.0\n# requires: docker\n# ---\nfunction kit_up {}\n")
+	info, err := GetInfo(baseDir, "kit_up")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Manifest.Version != "1.0.0" || !reflect.DeepEqual(info.Manifest.Requires, []string{"docker"}) {
+		t.Fatalf("unexpected manifest on info: %+v", info.Manifest)
+	}
+	writeTestFile(t, ToolkitManifestSidecar(path), `{"version":"1.1.0"}`)
+	info, err = GetInfo(baseDir, "kit_up")
+	if err != nil || info.Manifest.Version != "1.1.0" {
+		t.Fatalf("expected sidecar to refresh cached info, got %+v (%v)", info.Manifest, err)
+	}
+}
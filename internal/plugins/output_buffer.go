@@ -0,0 +1,153 @@
+package plugins
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"unicode/utf8"
+)
+
+// DefaultOutputLimit is how many bytes of a plugin's output are kept when
+// no limit is configured.
+const DefaultOutputLimit = 256 * 1024
+
+var (
+	outputLimitMu sync.Mutex
+	outputLimit   = DefaultOutputLimit
+)
+
+// SetOutputLimit caps the captured output of each plugin run; the head and
+// the tail are kept and the middle is elided. n <= 0 restores the default.
+func SetOutputLimit(n int) {
+	outputLimitMu.Lock()
+	defer outputLimitMu.Unlock()
+	if n <= 0 {
+		n = DefaultOutputLimit
+	}
+	outputLimit = n
+}
+
+func currentOutputLimit() int {
+	outputLimitMu.Lock()
+	defer outputLimitMu.Unlock()
+	return outputLimit
+}
+
+// headTailBuffer keeps the first half of its limit verbatim and the most
+// recent bytes in a ring, counting what falls in between.
+type headTailBuffer struct {
+	limit int
+	head  []byte
+	ring  []byte
+	next  int // write position in ring once it is full
+	full  bool
+	total int64
+}
+
+func newHeadTailBuffer(limit int) *headTailBuffer {
+	if limit < 2 {
+		limit = 2
+	}
+	return &headTailBuffer{limit: limit}
+}
+
+func (b *headTailBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	b.total += int64(n)
+	if room := b.limit/2 - len(b.head); room > 0 {
+		take := min(room, len(p))
+		b.head = append(b.head, p[:take]...)
+		p = p[take:]
+	}
+	size := b.limit - b.limit/2
+	for len(p) > 0 {
+		if !b.full {
+			take := min(size-len(b.ring), len(p))
+			b.ring = append(b.ring, p[:take]...)
+			p = p[take:]
+			b.full = len(b.ring) == size
+			continue
+		}
+		if len(p) >= size {
+			copy(b.ring, p[len(p)-size:])
+			b.next = 0
+			break
+		}
+		c := copy(b.ring[b.next:], p)
+		p = p[c:]
+		b.next = (b.next + c) % size
+	}
+	return n, nil
+}
+
+func (b *headTailBuffer) tail() []byte {
+	if !b.full || b.next == 0 {
+		return b.ring
+	}
+	out := make([]byte, 0, len(b.ring))
+	out = append(out, b.ring[b.next:]...)
+	return append(out, b.ring[:b.next]...)
+}
+
+// Result returns the kept output and how many bytes were left out. When
+// something was dropped, the cut is marked and moved to a nearby line
+// boundary, or at least to a character boundary.
+func (b *headTailBuffer) Result() (string, int64) {
+	head, tail := b.head, b.tail()
+	dropped := b.total - int64(len(head)) - int64(len(tail))
+	if dropped == 0 {
+		return string(head) + string(tail), 0
+	}
+	return joinElided(head, tail, dropped)
+}
+
+func joinElided(head, tail []byte, dropped int64) (string, int64) {
+	if i := bytes.LastIndexByte(head, '\n'); i >= len(head)/2 {
+		dropped += int64(len(head) - i - 1)
+		head = head[:i+1]
+	}
+	if i := bytes.IndexByte(tail, '\n'); i >= 0 && i < len(tail)/2 {
+		dropped += int64(i + 1)
+		tail = tail[i+1:]
+	}
+	if i := lastRuneStart(head); !utf8.FullRune(head[i:]) {
+		dropped += int64(len(head) - i)
+		head = head[:i]
+	}
+	i := 0
+	for i < len(tail) && i < utf8.UTFMax-1 && !utf8.RuneStart(tail[i]) {
+		i++
+	}
+	dropped += int64(i)
+	tail = tail[i:]
+	var out bytes.Buffer
+	out.Write(head)
+	if len(head) > 0 && head[len(head)-1] != '\n' {
+		out.WriteByte('\n')
+	}
+	fmt.Fprintf(&out, "... [%d bytes elided] ...\n", dropped)
+	out.Write(tail)
+	return out.String(), dropped
+}
+
+// lastRuneStart returns where the last, possibly incomplete, UTF-8
+// sequence of p begins.
+func lastRuneStart(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			return i
+		}
+	}
+	return len(p)
+}
+
+// HeadTail shortens s to about limit bytes, keeping its beginning and end
+// around an elision marker, and reports how many bytes were dropped.
+func HeadTail(s string, limit int) (string, int64) {
+	if len(s) <= limit {
+		return s, 0
+	}
+	b := newHeadTailBuffer(limit)
+	_, _ = b.Write([]byte(s))
+	return b.Result()
+}
@@ -1,13 +1,16 @@
 package plugins
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
 	"sort"
 	"strings"
+	"time"
 )
 
 type Plugin struct {
@@ -26,13 +29,22 @@ type FunctionFile struct {
 	Functions []string
 }
 
+// ParamDetail is one parameter of a PowerShell function's param block.
+// Mandatory holds in every parameter set the caller may pick; Position is
+// -1 for parameters that can only be passed by name. ParameterSets is
+// empty when the parameter belongs to all sets.
 type ParamDetail struct {
-	Name        string
-	Type        string
-	Mandatory   bool
-	Switch      bool
-	ValidateSet []string
-	Default     string
+	Name              string
+	Type              string
+	Mandatory         bool
+	Switch            bool
+	ValidateSet       []string
+	Default           string
+	Aliases           []string
+	ValueFromPipeline bool
+	HelpMessage       string
+	Position          int
+	ParameterSets     []string
 }
 
 type Info struct {
@@ -46,6 +58,7 @@ type Info struct {
 	Parameters   []string
 	ParamDetails []ParamDetail
 	Examples     []string
+	Manifest     Manifest
 }
 
 type RunError struct {
@@ -63,9 +76,19 @@ func (e *RunError) Unwrap() error {
 
 var ErrNotFound = errors.New("plugin not found")
 
+// RunResult is what a plugin run produced. Output is stdout and stderr
+// interleaved as they were shown; Data is the decoded return value of a
+// PowerShell function run in agent mode (nil for scripts, interactive runs
+// and functions that returned only text). Dropped counts the bytes of
+// Output elided by the output limit.
 type RunResult struct {
-	Output string
-	Err    error
+	Output   string
+	Stdout   string
+	Stderr   string
+	Data     any
+	Dropped  int64
+	Duration time.Duration
+	Err      error
 }
 
 func List(baseDir string) ([]Plugin, error) {
@@ -97,13 +120,14 @@ func ListEntries(baseDir string, includeFunctions bool) ([]Entry, error) {
 		return nil, err
 	}
 
+	disabled := disabledPaths(dir)
 	bestByName := map[string]Entry{}
 	for _, e := range entries {
 		if e.IsDir() {
 			continue
 		}
 		name := e.Name()
-		if !isSupportedPlugin(name) {
+		if !isSupportedPlugin(name) || isDisabledPath(filepath.Join(dir, name), disabled) {
 			continue
 		}
 		baseName := pluginName(name)
@@ -139,6 +163,7 @@ func ListEntries(baseDir string, includeFunctions bool) ([]Entry, error) {
 		return out[i].Name < out[j].Name
 	})
 	stamps := buildEntryListFileStamps(out)
+	stamps[lockPath(dir)] = statStamp(lockPath(dir))
 	setCachedEntryList(cacheKey, dir, out, dirStamp, stamps)
 	return out, nil
 }
@@ -190,7 +215,8 @@ func GetInfo(baseDir, name string) (Info, error) {
 			Sources: []string{candidate},
 			Runner:  runnerForPath(candidate),
 		}
-		setCachedInfo(cacheKey, dir, out, dirStamp, buildInfoFileStamps(out))
+		out.Manifest, _ = ParseManifest(candidate)
+		setCachedInfo(cacheKey, dir, out, dirStamp, buildInfoFileStamps(dir, out))
 		return out, nil
 	}
 
@@ -202,8 +228,8 @@ func GetInfo(baseDir, name string) (Info, error) {
 		return Info{}, fmt.Errorf("%w: %s", ErrNotFound, name)
 	}
 
-	help, _ := parsePowerShellFunctionHelp(fnPath, name)
-	paramDetails := parsePowerShellParamBlock(fnPath, name)
+	fn, _ := parsePowerShellFunction(fnPath, name)
+	help, paramDetails := fn.Help, fn.Params
 	sources := sourcesForFunction(loadFiles, name)
 	if len(sources) == 0 {
 		sources = []string{fnPath}
@@ -221,7 +247,8 @@ func GetInfo(baseDir, name string) (Info, error) {
 		ParamDetails: paramDetails,
 		Examples:     help.Examples,
 	}
-	setCachedInfo(cacheKey, dir, out, dirStamp, buildInfoFileStamps(out))
+	out.Manifest, _ = ParseManifest(fnPath)
+	setCachedInfo(cacheKey, dir, out, dirStamp, buildInfoFileStamps(dir, out))
 	return out, nil
 }
 
@@ -240,8 +267,8 @@ func buildEntryListFileStamps(items []Entry) map[string]int64 {
 	return stamps
 }
 
-func buildInfoFileStamps(info Info) map[string]int64 {
-	stamps := map[string]int64{}
+func buildInfoFileStamps(dir string, info Info) map[string]int64 {
+	stamps := map[string]int64{lockPath(dir): statStamp(lockPath(dir))}
 	add := func(path string) {
 		p := strings.TrimSpace(path)
 		if p == "" {
@@ -253,26 +280,54 @@ func buildInfoFileStamps(info Info) map[string]int64 {
 		stamps[p] = statStamp(p)
 	}
 	add(info.Path)
+	add(ToolkitManifestSidecar(info.Path))
 	for _, src := range info.Sources {
 		add(src)
 	}
 	return stamps
 }
 
-func Run(baseDir, name string, args []string) error {
-	r := runPluginInternal(baseDir, name, args, true)
+// Run runs a plugin or function attached to the terminal. Canceling ctx
+// stops it; the toolkit manifest may set a timeout per function.
+func Run(ctx context.Context, baseDir, name string, args []string) error {
+	r := runPluginInternal(ctx, baseDir, name, args, execOptions{interactive: true})
 	return r.Err
 }
 
-func RunWithOutput(baseDir, name string, args []string) RunResult {
-	return runPluginInternal(baseDir, name, args, true)
+func RunWithOutput(ctx context.Context, baseDir, name string, args []string) RunResult {
+	return runPluginInternal(ctx, baseDir, name, args, execOptions{interactive: true})
+}
+
+func RunWithOutputAgent(ctx context.Context, baseDir, name string, args []string) RunResult {
+	return runPluginInternal(ctx, baseDir, name, args, execOptions{})
+}
+
+// RunLive runs like the agent does but sends the echo of stdout and stderr
+// to w, e.g. a live pane, instead of the console.
+func RunLive(ctx context.Context, baseDir, name string, args []string, w io.Writer) RunResult {
+	return runPluginInternal(ctx, baseDir, name, args, execOptions{live: w})
+}
+
+// RunStructured runs like the agent does but without echoing to the
+// console, for callers that print the result themselves.
+func RunStructured(ctx context.Context, baseDir, name string, args []string) RunResult {
+	return runPluginInternal(ctx, baseDir, name, args, execOptions{quiet: true})
 }
 
-func RunWithOutputAgent(baseDir, name string, args []string) RunResult {
-	return runPluginInternal(baseDir, name, args, false)
+func runPluginInternal(ctx context.Context, baseDir, name string, args []string, opts execOptions) RunResult {
+	start := time.Now()
+	res := runPluginEntry(ctx, baseDir, name, args, opts)
+	res.Duration = time.Since(start)
+	return res
 }
 
-func runPluginInternal(baseDir, name string, args []string, interactive bool) RunResult {
+// IsCanceled reports whether err comes from a run whose context was
+// canceled, as opposed to a plugin failure or a timeout.
+func IsCanceled(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
+
+func runPluginEntry(ctx context.Context, baseDir, name string, args []string, opts execOptions) RunResult {
 	dir := filepath.Join(baseDir, "plugins")
 	candidate, err := findPlugin(dir, name)
 	if err != nil {
@@ -287,16 +342,17 @@ func runPluginInternal(baseDir, name string, args []string, interactive bool) Ru
 			return RunResult{Err: fmt.Errorf("%w: %s", ErrNotFound, name)}
 		}
 		var sources []string
-		if !interactive {
+		if !opts.interactive {
 			sources = []string{fnPath}
 		} else {
 			sources = loadFiles
 		}
-		out, runErr := runPowerShellFunctionCapture(sources, name, args, interactive)
-		return RunResult{Output: out, Err: runErr}
+		if m, mErr := ParseManifest(fnPath); mErr == nil {
+			opts.timeout = m.FunctionTimeout(name)
+		}
+		return runPowerShellFunctionCapture(ctx, sources, name, args, opts)
 	}
-	out, runErr := execPluginCapture(candidate, args, interactive)
-	return RunResult{Output: out, Err: runErr}
+	return execPluginCapture(ctx, candidate, args, opts)
 }
 
 func IsNotFound(err error) bool {
@@ -320,12 +376,13 @@ func findPlugin(dir, name string) (string, error) {
 		return "", err
 	}
 
+	disabled := disabledPaths(dir)
 	var matches []string
 	for _, e := range entries {
 		if e.IsDir() {
 			continue
 		}
-		if !isSupportedPlugin(e.Name()) {
+		if !isSupportedPlugin(e.Name()) || isDisabledPath(filepath.Join(dir, e.Name()), disabled) {
 			continue
 		}
 		if pluginName(e.Name()) == name {
@@ -3,17 +3,65 @@ package plugins
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 )
 
-const pluginExecTimeout = 5 * time.Minute
+// pluginExecTimeout bounds a run unless the toolkit manifest sets its own.
+var pluginExecTimeout = 5 * time.Minute
+
+// pluginDataDepth is the -Depth passed to ConvertTo-Json for structured results.
+const pluginDataDepth = 8
+
+// psConvertDataFunc turns the objects a function returned into compact JSON.
+// Plain strings are left to the console output, so it returns $null when
+// nothing but strings came back.
+var psConvertDataFunc = `function ConvertTo-DmData($dmItems){
+  $dmItems=@($dmItems)
+  if($dmItems.Count -eq 0 -or @($dmItems | Where-Object { $_ -isnot [string] }).Count -eq 0){ return $null }
+  $dmValue=$dmItems
+  if($dmItems.Count -eq 1){ $dmValue=$dmItems[0] }
+  try { return (ConvertTo-Json -InputObject $dmValue -Depth ` + fmt.Sprint(pluginDataDepth) + ` -Compress -WarningAction SilentlyContinue) } catch { return $null }
+}`
+
+// execOptions selects how a plugin process is wired to the terminal.
+// Non-interactive runs also collect the structured return value; quiet
+// runs do not echo output to the console, and live, when set, gets the
+// echo instead of the console. A zero timeout means pluginExecTimeout.
+type execOptions struct {
+	interactive bool
+	quiet       bool
+	timeout     time.Duration
+	live        io.Writer
+}
+
+func (o execOptions) runTimeout() time.Duration {
+	if o.timeout > 0 {
+		return o.timeout
+	}
+	return pluginExecTimeout
+}
+
+// runContextErr explains why ctx ended a run: the timeout or a cancel from
+// the caller (Ctrl+C). It is nil while ctx is still live.
+func runContextErr(ctx context.Context, timeout time.Duration) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return errors.New("plugin execution timed out after " + timeout.String())
+	case ctx.Err() != nil:
+		return fmt.Errorf("plugin execution canceled: %w", ctx.Err())
+	}
+	return nil
+}
 
 type psNamedArg struct {
 	Name     string
@@ -78,7 +126,10 @@ func splitPowerShellSplatArgs(args []string) ([]psNamedArg, []string) {
 	return named, positional
 }
 
-func buildPowerShellFunctionScript(profilePaths []string, functionName string, args []string) string {
+// buildPowerShellFunctionScript writes the wrapper that loads the toolkit
+// files and calls the function. With a dataPath the return value is also
+// serialized to that file while the formatted output still goes to the console.
+func buildPowerShellFunctionScript(profilePaths []string, functionName string, args []string, dataPath string) string {
 	quotedPaths := make([]string, 0, len(profilePaths))
 	for _, p := range profilePaths {
 		quotedPaths = append(quotedPaths, quotePowerShellArg(p))
@@ -108,56 +159,129 @@ func buildPowerShellFunctionScript(profilePaths []string, functionName string, a
 		"if(-not(Get-Command -Name "+quotePowerShellArg(functionName)+" -CommandType Function -ErrorAction SilentlyContinue)){",
 		"  throw \"Function '"+functionName+"' was not loaded from plugin sources.\"",
 		"}",
-		"& "+quotePowerShellArg(functionName)+" @dmNamedArgs @dmPositionalArgs",
+	)
+	call := "& " + quotePowerShellArg(functionName) + " @dmNamedArgs @dmPositionalArgs"
+	if dataPath == "" {
+		lines = append(lines, call)
+		return strings.Join(lines, "\n") + "\n"
+	}
+	lines = append(lines,
+		psConvertDataFunc,
+		"$dmResult=$null",
+		call+" | Tee-Object -Variable dmResult | Out-Host",
+		"$dmData=ConvertTo-DmData $dmResult",
+		"if($null -ne $dmData){ [System.IO.File]::WriteAllText("+quotePowerShellArg(dataPath)+",$dmData) }",
 	)
 	return strings.Join(lines, "\n") + "\n"
 }
 
-func runPowerShellFunctionCapture(profilePaths []string, functionName string, args []string, interactive bool) (string, error) {
+func runPowerShellFunctionCapture(ctx context.Context, profilePaths []string, functionName string, args []string, opts execOptions) RunResult {
+	if !opts.interactive && usePersistentHost() {
+		return runInPersistentHost(ctx, profilePaths, functionName, args, opts)
+	}
 	ps := firstAvailableBinary("pwsh", "powershell")
 	if ps == "" {
-		return "", errors.New("pwsh/powershell executable not found")
+		return RunResult{Err: errors.New("pwsh/powershell executable not found")}
 	}
 
-	scriptBody := buildPowerShellFunctionScript(profilePaths, functionName, args)
+	dataPath := ""
+	if !opts.interactive {
+		dataFile, err := os.CreateTemp("", "dm-plugin-*.json")
+		if err != nil {
+			return RunResult{Err: err}
+		}
+		dataPath = dataFile.Name()
+		_ = dataFile.Close()
+		defer func() { _ = os.Remove(dataPath) }()
+	}
+	scriptBody := buildPowerShellFunctionScript(profilePaths, functionName, args, dataPath)
 
 	tmp, tmpErr := os.CreateTemp("", "dm-plugin-*.ps1")
 	if tmpErr != nil {
-		return "", tmpErr
+		return RunResult{Err: tmpErr}
 	}
 	tmpPath := tmp.Name()
 	_ = tmp.Close()
 	defer func() { _ = os.Remove(tmpPath) }()
 	if writeErr := os.WriteFile(tmpPath, []byte(scriptBody), 0600); writeErr != nil {
-		return "", writeErr
+		return RunResult{Err: writeErr}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), pluginExecTimeout)
+	ctx, cancel := context.WithTimeout(ctx, opts.runTimeout())
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, ps, "-NoProfile", "-NonInteractive", "-File", tmpPath)
-	var output bytes.Buffer
-	cmd.Stdout = io.MultiWriter(os.Stdout, &output)
-	cmd.Stderr = io.MultiWriter(os.Stderr, &output)
-	if interactive {
+	res := runCaptured(ctx, cmd, opts)
+	if dataPath != "" && res.Err == nil {
+		res.Data = readPluginData(dataPath)
+	}
+	return res
+}
+
+// readPluginData decodes the JSON a structured run left in path; a missing,
+// empty or unreadable file means the function returned no data.
+func readPluginData(path string) any {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return decodePluginData(raw)
+}
+
+func decodePluginData(raw []byte) any {
+	raw = bytes.TrimSpace(bytes.TrimPrefix(raw, []byte("\xef\xbb\xbf")))
+	if len(raw) == 0 {
+		return nil
+	}
+	var data any
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil
+	}
+	return data
+}
+
+// runCaptured runs cmd, keeping stdout, stderr and their interleaving
+// apart while echoing to the console unless the run is quiet. Each stream
+// keeps at most the output limit, head and tail.
+func runCaptured(ctx context.Context, cmd *exec.Cmd, opts execOptions) RunResult {
+	limit := currentOutputLimit()
+	output, stdout, stderr := newHeadTailBuffer(limit), newHeadTailBuffer(limit), newHeadTailBuffer(limit)
+	combined := &lockedWriter{w: output}
+	switch {
+	case opts.quiet:
+		cmd.Stdout = io.MultiWriter(stdout, combined)
+		cmd.Stderr = io.MultiWriter(stderr, combined)
+	case opts.live != nil:
+		live := &lockedWriter{w: opts.live}
+		cmd.Stdout = io.MultiWriter(live, stdout, combined)
+		cmd.Stderr = io.MultiWriter(live, stderr, combined)
+	default:
+		cmd.Stdout = io.MultiWriter(os.Stdout, stdout, combined)
+		cmd.Stderr = io.MultiWriter(os.Stderr, stderr, combined)
+	}
+	if opts.interactive {
 		cmd.Stdin = os.Stdin
 	}
-	if err := cmd.Run(); err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			return output.String(), &RunError{
-				Err:    errors.New("plugin execution timed out after " + pluginExecTimeout.String()),
-				Output: output.String(),
-			}
+	// Grandchildren may keep the pipes open after a kill; do not wait on them.
+	cmd.WaitDelay = 2 * time.Second
+	res := RunResult{}
+	err := cmd.Run()
+	res.Output, res.Dropped = output.Result()
+	res.Stdout, _ = stdout.Result()
+	res.Stderr, _ = stderr.Result()
+	if err != nil {
+		if ctxErr := runContextErr(ctx, opts.runTimeout()); ctxErr != nil {
+			err = ctxErr
 		}
-		return output.String(), &RunError{Err: err, Output: output.String()}
+		res.Err = &RunError{Err: err, Output: res.Output}
 	}
-	return output.String(), nil
+	return res
 }
 
-func execPluginCapture(path string, args []string, interactive bool) (string, error) {
+func execPluginCapture(ctx context.Context, path string, args []string, opts execOptions) RunResult {
 	ext := strings.ToLower(filepath.Ext(path))
 
-	ctx, cancel := context.WithTimeout(context.Background(), pluginExecTimeout)
+	ctx, cancel := context.WithTimeout(ctx, opts.runTimeout())
 	defer cancel()
 
 	var cmd *exec.Cmd
@@ -168,13 +292,13 @@ func execPluginCapture(path string, args []string, interactive bool) (string, er
 		case ".ps1":
 			ps := firstAvailableBinary("pwsh", "powershell")
 			if ps == "" {
-				return "", errors.New("powershell executable not found")
+				return RunResult{Err: errors.New("powershell executable not found")}
 			}
 			cmd = exec.CommandContext(ctx, ps, "-NoProfile", "-NonInteractive", "-File", path)
 		case ".sh":
 			sh := firstAvailableBinary("sh", "bash")
 			if sh == "" {
-				return "", errors.New("sh/bash executable not found")
+				return RunResult{Err: errors.New("sh/bash executable not found")}
 			}
 			cmd = exec.CommandContext(ctx, sh, path)
 		case ".cmd", ".bat":
@@ -182,14 +306,14 @@ func execPluginCapture(path string, args []string, interactive bool) (string, er
 		case ".exe", "", ".out":
 			cmd = exec.CommandContext(ctx, path)
 		default:
-			return "", errors.New("unsupported plugin type on windows")
+			return RunResult{Err: errors.New("unsupported plugin type on windows")}
 		}
 	default:
 		switch ext {
 		case ".ps1":
 			ps := firstAvailableBinary("pwsh", "powershell")
 			if ps == "" {
-				return "", errors.New("pwsh/powershell executable not found")
+				return RunResult{Err: errors.New("pwsh/powershell executable not found")}
 			}
 			cmd = exec.CommandContext(ctx, ps, "-File", path)
 		case ".sh":
@@ -203,22 +327,7 @@ func execPluginCapture(path string, args []string, interactive bool) (string, er
 		cmd.Args = append(cmd.Args, args...)
 	}
 
-	var output bytes.Buffer
-	cmd.Stdout = io.MultiWriter(os.Stdout, &output)
-	cmd.Stderr = io.MultiWriter(os.Stderr, &output)
-	if interactive {
-		cmd.Stdin = os.Stdin
-	}
-	if err := cmd.Run(); err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			return output.String(), &RunError{
-				Err:    errors.New("plugin execution timed out after " + pluginExecTimeout.String()),
-				Output: output.String(),
-			}
-		}
-		return output.String(), &RunError{Err: err, Output: output.String()}
-	}
-	return output.String(), nil
+	return runCaptured(ctx, cmd, opts)
 }
 
 func runnerForPath(path string) string {
@@ -260,3 +369,15 @@ func preferredPluginExtOrder() []string {
 	}
 	return []string{".sh", "", ".out", ".ps1"}
 }
+
+// lockedWriter serializes writes from the stdout and stderr copiers.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
@@ -11,17 +11,24 @@ import (
 )
 
 var (
-	psFunctionLine    = regexp.MustCompile(`(?i)^\s*function\s+([a-z0-9_-]+)\b`)
-	psNamedTag        = regexp.MustCompile(`(?i)^\.(synopsis|description|example|parameter)\b(?:\s+([a-z0-9_-]+))?\s*$`)
-	psParamMandatory  = regexp.MustCompile(`(?i)\[Parameter\s*\([^)]*Mandatory\b`)
-	psParamVarLine    = regexp.MustCompile(`(?i)^\s*(?:\[[^\]]*\([^\)]*\)[^\]]*\]\s*)*(?:\[([^\]]+)\])?\s*\$(\w+)`)
-	psValidateSetLine = regexp.MustCompile(`(?i)\[ValidateSet\s*\(([^)]+)\)\]`)
-	psDefaultValue    = regexp.MustCompile(`\$\w+\s*=\s*(.+)`)
+	psFunctionLine = regexp.MustCompile(`(?i)^\s*function\s+([a-z0-9_-]+)\b`)
+	// psNamedTag matches the comment-based help keywords; only synopsis,
+	// description, parameter and example are kept, the others end a section.
+	psNamedTag = regexp.MustCompile(`(?i)^\.(synopsis|description|parameter|example|inputs|outputs|notes|link|component|role|functionality|forwardhelptargetname|forwardhelpcategory|remotehelprunspace|externalhelp)\b\s*(.*)$`)
 )
 
-var psSafetyLine = regexp.MustCompile(`(?i)^#\s*Safety:\s*(.+)`)
+var (
+	psSafetyLine  = regexp.MustCompile(`(?i)^#\s*Safety:\s*(.+)`)
+	psVersionLine = regexp.MustCompile(`(?i)^#\s*Version:\s*(.+)`)
+)
 
 func ParseToolkitSafety(filePath string) string {
+	return parseHeaderField(filePath, psSafetyLine)
+}
+
+// parseHeaderField returns the first capture of re within the first lines
+// of a toolkit header.
+func parseHeaderField(filePath string, re *regexp.Regexp) string {
 	f, err := os.Open(filePath)
 	if err != nil {
 		return ""
@@ -29,13 +36,59 @@ func ParseToolkitSafety(filePath string) string {
 	defer f.Close()
 	scanner := bufio.NewScanner(f)
 	for i := 0; i < 10 && scanner.Scan(); i++ {
-		if m := psSafetyLine.FindStringSubmatch(scanner.Text()); len(m) == 2 {
+		if m := re.FindStringSubmatch(scanner.Text()); len(m) == 2 {
 			return strings.TrimSpace(m[1])
 		}
 	}
 	return ""
 }
 
+var (
+	psNotesHeading = regexp.MustCompile(`(?i)^#\s*AGENT NOTES\s*:?\s*$`)
+	psHeaderRule   = regexp.MustCompile(`^#\s*[=\-]{3,}\s*$`)
+)
+
+// ToolkitPromptSidecar is the file next to a toolkit that holds its agent
+// prompt fragment, e.g. STIBS_DB_Toolkit.prompt.md.
+func ToolkitPromptSidecar(filePath string) string {
+	return strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".prompt.md"
+}
+
+// ParseToolkitPrompt returns the agent prompt fragment for a toolkit: the
+// sidecar file when present, else the "AGENT NOTES" section of the header.
+func ParseToolkitPrompt(filePath string) string {
+	if data, err := os.ReadFile(ToolkitPromptSidecar(filePath)); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	var notes []string
+	inNotes := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		if psNotesHeading.MatchString(line) {
+			inNotes = true
+			continue
+		}
+		if !inNotes {
+			continue
+		}
+		text := strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		if text == "" || psHeaderRule.MatchString(line) {
+			break
+		}
+		notes = append(notes, text)
+	}
+	return strings.Join(notes, "\n")
+}
+
 func ToolkitRiskLevel(safety string) string {
 	lc := strings.ToLower(safety)
 	if strings.Contains(lc, "read-only") {
@@ -75,30 +128,35 @@ func functionSourceScore(path string) int {
 	}
 }
 
+// readPowerShellFunctionNames lists the public functions a file defines. A
+// file the tokenizer cannot read is scanned line by line instead, so one
+// broken toolkit still shows up.
 func readPowerShellFunctionNames(path string) ([]string, error) {
-	file, err := os.Open(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, nil
 		}
 		return nil, err
 	}
-	defer file.Close()
+	var names []string
+	if fns, parseErr := parsePowerShellScript(string(data)); parseErr == nil {
+		for _, fn := range fns {
+			names = append(names, fn.Name)
+		}
+	} else {
+		for _, line := range strings.Split(string(data), "\n") {
+			if m := psFunctionLine.FindStringSubmatch(line); len(m) == 2 {
+				names = append(names, m[1])
+			}
+		}
+	}
 
 	var out []string
 	seen := map[string]struct{}{}
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		m := psFunctionLine.FindStringSubmatch(line)
-		if len(m) != 2 {
-			continue
-		}
-		name := strings.TrimSpace(m[1])
-		if name == "" {
-			continue
-		}
-		if !isPublicFunctionName(name) {
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || !isPublicFunctionName(name) {
 			continue
 		}
 		if _, ok := seen[name]; ok {
@@ -107,9 +165,6 @@ func readPowerShellFunctionNames(path string) ([]string, error) {
 		seen[name] = struct{}{}
 		out = append(out, name)
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
 	return out, nil
 }
 
@@ -117,41 +172,28 @@ func isPublicFunctionName(name string) bool {
 	return !strings.HasPrefix(name, "_")
 }
 
-func parsePowerShellFunctionHelp(path, functionName string) (functionHelp, error) {
+// parsePowerShellFunction parses path and returns the first definition of
+// functionName.
+func parsePowerShellFunction(path, functionName string) (psFunction, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return functionHelp{}, err
+		return psFunction{}, err
 	}
-	lines := strings.Split(string(data), "\n")
-	fnIdx := -1
-	for i, line := range lines {
-		m := psFunctionLine.FindStringSubmatch(line)
-		if len(m) == 2 && strings.EqualFold(strings.TrimSpace(m[1]), functionName) {
-			fnIdx = i
-			break
+	fns, parseErr := parsePowerShellScript(string(data))
+	for _, fn := range fns {
+		if strings.EqualFold(fn.Name, functionName) {
+			return fn, nil
 		}
 	}
-	if fnIdx == -1 {
-		return functionHelp{}, nil
-	}
-
-	end := fnIdx - 1
-	for end >= 0 && strings.TrimSpace(lines[end]) == "" {
-		end--
-	}
-	if end < 0 || strings.TrimSpace(lines[end]) != "#>" {
-		return functionHelp{}, nil
-	}
-	start := end - 1
-	for start >= 0 && strings.TrimSpace(lines[start]) != "<#" {
-		start--
-	}
-	if start < 0 {
-		return functionHelp{}, nil
+	if parseErr != nil {
+		return psFunction{}, fmt.Errorf("%s: %w", filepath.Base(path), parseErr)
 	}
+	return psFunction{}, nil
+}
 
-	block := lines[start+1 : end]
-	return parseCommentBlockHelp(block), nil
+func parsePowerShellFunctionHelp(path, functionName string) (functionHelp, error) {
+	fn, err := parsePowerShellFunction(path, functionName)
+	return fn.Help, err
 }
 
 func parseCommentBlockHelp(lines []string) functionHelp {
@@ -167,7 +209,10 @@ func parseCommentBlockHelp(lines []string) functionHelp {
 		if m := psNamedTag.FindStringSubmatch(line); len(m) >= 2 {
 			mode = strings.ToLower(m[1])
 			if mode == "parameter" {
-				paramName = strings.TrimSpace(m[2])
+				paramName = ""
+				if fields := strings.Fields(m[2]); len(fields) > 0 {
+					paramName = fields[0]
+				}
 				if paramName != "" {
 					if _, ok := paramText[paramName]; !ok {
 						paramText[paramName] = []string{}
@@ -210,107 +255,8 @@ func parseCommentBlockHelp(lines []string) functionHelp {
 }
 
 func parsePowerShellParamBlock(path, functionName string) []ParamDetail {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil
-	}
-	lines := strings.Split(string(data), "\n")
-	fnIdx := -1
-	for i, line := range lines {
-		m := psFunctionLine.FindStringSubmatch(line)
-		if len(m) == 2 && strings.EqualFold(strings.TrimSpace(m[1]), functionName) {
-			fnIdx = i
-			break
-		}
-	}
-	if fnIdx == -1 {
-		return nil
-	}
-
-	paramStart := -1
-	for i := fnIdx + 1; i < len(lines) && i < fnIdx+10; i++ {
-		trimmed := strings.TrimSpace(lines[i])
-		if strings.HasPrefix(strings.ToLower(trimmed), "param") && strings.Contains(trimmed, "(") {
-			paramStart = i
-			break
-		}
-	}
-	if paramStart == -1 {
-		return nil
-	}
-
-	depth := 0
-	var blockLines []string
-	for i := paramStart; i < len(lines); i++ {
-		line := lines[i]
-		depth += strings.Count(line, "(") - strings.Count(line, ")")
-		blockLines = append(blockLines, line)
-		if depth <= 0 {
-			break
-		}
-	}
-
-	for i, raw := range blockLines {
-		line := strings.TrimSpace(raw)
-		if i == 0 {
-			lc := strings.ToLower(line)
-			if strings.HasPrefix(lc, "param") {
-				if idx := strings.Index(line, "("); idx >= 0 {
-					line = strings.TrimSpace(line[idx+1:])
-				}
-			}
-		}
-		blockLines[i] = line
-	}
-	if n := len(blockLines); n > 0 {
-		last := strings.TrimRight(blockLines[n-1], ")")
-		blockLines[n-1] = strings.TrimSpace(last)
-	}
-
-	var params []ParamDetail
-	var pendingMandatory bool
-	var pendingValidateSet []string
-	for _, line := range blockLines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		if psParamMandatory.MatchString(line) {
-			pendingMandatory = true
-		}
-		if m := psValidateSetLine.FindStringSubmatch(line); len(m) == 2 {
-			vals := strings.Split(m[1], ",")
-			for _, v := range vals {
-				v = strings.TrimSpace(v)
-				v = strings.Trim(v, `"'`)
-				if v != "" {
-					pendingValidateSet = append(pendingValidateSet, v)
-				}
-			}
-		}
-		if m := psParamVarLine.FindStringSubmatch(line); len(m) == 3 {
-			typeName := strings.TrimSpace(m[1])
-			paramName := strings.TrimSpace(m[2])
-			if paramName == "" {
-				continue
-			}
-			pd := ParamDetail{
-				Name:        paramName,
-				Type:        typeName,
-				Mandatory:   pendingMandatory,
-				Switch:      strings.EqualFold(typeName, "switch"),
-				ValidateSet: pendingValidateSet,
-			}
-			if dm := psDefaultValue.FindStringSubmatch(line); len(dm) == 2 {
-				pd.Default = strings.TrimSpace(strings.TrimRight(dm[1], ","))
-				pd.Default = strings.Trim(pd.Default, `"'`)
-			}
-			params = append(params, pd)
-			pendingMandatory = false
-			pendingValidateSet = nil
-		}
-	}
-	return params
+	fn, _ := parsePowerShellFunction(path, functionName)
+	return fn.Params
 }
 
 func findPowerShellFunction(pluginsDir, name string) (string, []string, bool, error) {
@@ -348,14 +294,18 @@ func collectPowerShellFunctions(pluginsDir string) (map[string]string, []string,
 
 func listPowerShellFunctionFiles(dir string) ([]string, error) {
 	var files []string
+	disabled := disabledPaths(dir)
 	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, walkErr error) error {
 		if walkErr != nil {
 			return walkErr
 		}
 		if d.IsDir() {
+			if path != dir && (strings.HasPrefix(d.Name(), ".") || isDisabledPath(path, disabled)) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
-		if !isPowerShellFunctionSource(d.Name()) {
+		if !isPowerShellFunctionSource(d.Name()) || isDisabledPath(path, disabled) {
 			return nil
 		}
 		files = append(files, path)
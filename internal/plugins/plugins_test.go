@@ -1,13 +1,17 @@
 package plugins
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 )
 
 func clearPluginCacheForTest() {
@@ -19,7 +23,7 @@ func clearPluginCacheForTest() {
 
 func TestRunNotFound(t *testing.T) {
 	baseDir := t.TempDir()
-	err := Run(baseDir, "missing_plugin", nil)
+	err := Run(context.Background(), baseDir, "missing_plugin", nil)
 	if err == nil {
 		t.Fatal("expected not found error")
 	}
@@ -396,6 +400,7 @@ func TestBuildPowerShellFunctionScript_UsesNamedAndPositionalSplat(t *testing.T)
 		[]string{`C:\plugins\system.ps1`},
 		"sys_ping",
 		[]string{"-ComputerName", "192.168.50.235", "-Count", "4"},
+		"",
 	)
 
 	if !strings.Contains(script, "$dmNamedArgs['ComputerName']='192.168.50.235'") {
@@ -408,3 +413,149 @@ func TestBuildPowerShellFunctionScript_UsesNamedAndPositionalSplat(t *testing.T)
 		t.Fatalf("missing final named/positional splat invocation:\n%s", script)
 	}
 }
+
+func TestBuildPowerShellFunctionScript_WritesStructuredData(t *testing.T) {
+	script := buildPowerShellFunctionScript([]string{"/p/kit.ps1"}, "kit_ls", nil, "/tmp/it's.json")
+	for _, want := range []string{
+		"function ConvertTo-DmData(",
+		"& 'kit_ls' @dmNamedArgs @dmPositionalArgs | Tee-Object -Variable dmResult | Out-Host",
+		"[System.IO.File]::WriteAllText('/tmp/it''s.json',$dmData)",
+	} {
+		if !strings.Contains(script, want) {
+			t.Fatalf("missing %q:\n%s", want, script)
+		}
+	}
+	if plain := buildPowerShellFunctionScript(nil, "kit_ls", nil, ""); strings.Contains(plain, "ConvertTo-DmData") {
+		t.Fatalf("interactive script should not serialize data:\n%s", plain)
+	}
+}
+
+func TestDecodePluginData(t *testing.T) {
+	if got := decodePluginData([]byte("\xef\xbb\xbf{\"ok\":true}\r\n")); !reflect.DeepEqual(got, map[string]any{"ok": true}) {
+		t.Fatalf("unexpected data: %#v", got)
+	}
+	if got := decodePluginData([]byte("not json")); got != nil {
+		t.Fatalf("expected nil for invalid JSON, got %#v", got)
+	}
+}
+
+func TestRunStructuredSeparatesStreams(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell plugin")
+	}
+	baseDir := t.TempDir()
+	writeTestFile(t, filepath.Join(baseDir, "plugins", "streams.sh"), "echo out\necho err >&2\n")
+	res := RunStructured(context.Background(), baseDir, "streams", nil)
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	if res.Stdout != "out\n" || res.Stderr != "err\n" || !strings.Contains(res.Output, "out\n") || !strings.Contains(res.Output, "err\n") {
+		t.Fatalf("unexpected streams: %+v", res)
+	}
+	if res.Data != nil || res.Duration <= 0 {
+		t.Fatalf("expected no data and a duration, got %+v", res)
+	}
+}
+
+func TestHeadTailBufferKeepsBothEnds(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 200; i++ {
+		lines = append(lines, fmt.Sprintf("line %03d", i))
+	}
+	text := strings.Join(lines, "\n") + "\n"
+	b := newHeadTailBuffer(200)
+	for i := 0; i < len(text); i += 7 {
+		_, _ = b.Write([]byte(text[i:min(i+7, len(text))]))
+	}
+	got, dropped := b.Result()
+	if !strings.HasPrefix(got, "line 001\n") || !strings.HasSuffix(got, "line 200\n") {
+		t.Fatalf("expected head and tail, got %q", got)
+	}
+	if !strings.Contains(got, fmt.Sprintf("... [%d bytes elided] ...\n", dropped)) {
+		t.Fatalf("expected an elision marker for %d bytes, got %q", dropped, got)
+	}
+	kept := strings.Replace(got, fmt.Sprintf("... [%d bytes elided] ...\n", dropped), "", 1)
+	if int64(len(kept))+dropped != int64(len(text)) || !strings.Contains(text, kept[len(kept)-40:]) {
+		t.Fatalf("kept %d + dropped %d bytes, want %d", len(kept), dropped, len(text))
+	}
+
+	if got, dropped := HeadTail("short", 100); got != "short" || dropped != 0 {
+		t.Fatalf("expected short text untouched, got %q (%d)", got, dropped)
+	}
+}
+
+func TestHeadTailCutsOnCharacterBoundaries(t *testing.T) {
+	text := strings.Repeat("日本語", 100)
+	for limit := 20; limit < 30; limit++ {
+		got, dropped := HeadTail(text, limit)
+		if !utf8.ValidString(got) {
+			t.Fatalf("limit %d split a character: %q", limit, got)
+		}
+		head, tail, ok := strings.Cut(got, fmt.Sprintf("\n... [%d bytes elided] ...\n", dropped))
+		if !ok || int64(len(head)+len(tail))+dropped != int64(len(text)) {
+			t.Fatalf("limit %d: kept %q and dropped %d of %d bytes", limit, got, dropped, len(text))
+		}
+		if !strings.HasPrefix(text, head) || !strings.HasSuffix(text, tail) {
+			t.Fatalf("limit %d: expected head and tail of the text, got %q", limit, got)
+		}
+	}
+}
+
+func TestRunCapturedAppliesOutputLimit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell plugin")
+	}
+	SetOutputLimit(64)
+	t.Cleanup(func() { SetOutputLimit(0) })
+	baseDir := t.TempDir()
+	writeTestFile(t, filepath.Join(baseDir, "plugins", "logs.sh"), "i=0\nwhile [ $i -lt 50 ]; do echo \"log line $i\"; i=$((i+1)); done\necho FATAL: disk full >&2\n")
+	var live strings.Builder
+	res := RunLive(context.Background(), baseDir, "logs", nil, &live)
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	// The two streams are copied concurrently, so only each stream's own
+	// head and tail are reliable, not their order in Output.
+	if res.Dropped == 0 || !strings.HasPrefix(res.Stdout, "log line 0\n") || !strings.HasSuffix(res.Stdout, "log line 49\n") {
+		t.Fatalf("expected head, tail and dropped bytes, got %q (%d)", res.Stdout, res.Dropped)
+	}
+	if res.Stderr != "FATAL: disk full\n" {
+		t.Fatalf("expected the stderr tail, got %q", res.Stderr)
+	}
+	if !strings.Contains(live.String(), "log line 25\n") {
+		t.Fatal("expected the live writer to get the full output")
+	}
+}
+
+func TestParseToolkitPrompt(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "DB_Toolkit.ps1")
+	src := `# =============================================================================
+# DB TOOLKIT – queries
+# Safety: Read-only.
+#
+# AGENT NOTES
+#   Always target the staging database (db-staging).
+#   Never export production dumps.
+#
+# FUNCTIONS
+#   db_query
+# =============================================================================
+
+function db_query {}
+`
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := "Always target the staging database (db-staging).\nNever export production dumps."
+	if got := ParseToolkitPrompt(path); got != want {
+		t.Fatalf("header notes: got %q, want %q", got, want)
+	}
+
+	if err := os.WriteFile(ToolkitPromptSidecar(path), []byte("\nUse the sidecar.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := ParseToolkitPrompt(path); got != "Use the sidecar." {
+		t.Fatalf("sidecar should win, got %q", got)
+	}
+}
@@ -0,0 +1,813 @@
+package plugins
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// This file is a tokenizer and parser for the subset of PowerShell that
+// toolkits use: function definitions, comment-based help and param blocks
+// with their attributes. Expressions are not evaluated; a default value or
+// attribute argument is kept as its source text unless it is a literal.
+
+type psTokenKind int
+
+const (
+	psWord     psTokenKind = iota // bareword, keyword, number, command or -parameter
+	psVariable                    // $name, ${name} or @name (splat); value is the name
+	psString                      // quoted or here-string; value is the unquoted text
+	psPunct                       // ( ) { } [ ] , ; | & = < > and $( @( @{
+	psComment                     // # line or <# block #>; value is the text inside
+	psNewline
+)
+
+type psToken struct {
+	kind       psTokenKind
+	text       string
+	value      string
+	start, end int // byte offsets in the source
+	line       int // 1-based line of the first character
+}
+
+func (t psToken) is(kind psTokenKind, text string) bool {
+	return t.kind == kind && strings.EqualFold(t.text, text)
+}
+
+// opens reports whether t opens a group closed by ")", "]" or "}".
+func (t psToken) opens() byte {
+	if t.kind != psPunct {
+		return 0
+	}
+	switch t.text {
+	case "(", "$(", "@(":
+		return ')'
+	case "[":
+		return ']'
+	case "{", "@{":
+		return '}'
+	}
+	return 0
+}
+
+type psLexer struct {
+	src    string
+	pos    int
+	line   int
+	tokens []psToken
+	err    error
+}
+
+// tokenizePowerShell splits src into tokens. An unterminated string or
+// comment is reported as an error; the tokens read up to it are returned.
+func tokenizePowerShell(src string) ([]psToken, error) {
+	lx := &psLexer{src: src, line: 1}
+	for lx.pos < len(lx.src) && lx.err == nil {
+		lx.scan()
+	}
+	return lx.tokens, lx.err
+}
+
+func (lx *psLexer) fail(line int, format string, args ...any) {
+	if lx.err == nil {
+		lx.err = fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+	}
+	lx.pos = len(lx.src)
+}
+
+func (lx *psLexer) emit(kind psTokenKind, start, line int, value string) {
+	lx.tokens = append(lx.tokens, psToken{kind: kind, text: lx.src[start:lx.pos], value: value, start: start, end: lx.pos, line: line})
+}
+
+// advance moves to end, counting the newlines passed.
+func (lx *psLexer) advance(end int) {
+	lx.line += strings.Count(lx.src[lx.pos:end], "\n")
+	lx.pos = end
+}
+
+func (lx *psLexer) peek(offset int) byte {
+	if lx.pos+offset < len(lx.src) {
+		return lx.src[lx.pos+offset]
+	}
+	return 0
+}
+
+func (lx *psLexer) scan() {
+	start, line := lx.pos, lx.line
+	rest := lx.src[lx.pos:]
+	r, size := utf8.DecodeRuneInString(rest)
+	switch {
+	case r == '\n':
+		lx.advance(lx.pos + 1)
+		lx.emit(psNewline, start, line, "")
+	case r == '`' && (strings.HasPrefix(rest[1:], "\n") || strings.HasPrefix(rest[1:], "\r\n")):
+		lx.advance(lx.pos + 1 + strings.IndexByte(rest, '\n'))
+	case unicode.IsSpace(r):
+		lx.pos += size
+	case strings.HasPrefix(rest, "<#"):
+		end := strings.Index(rest[2:], "#>")
+		if end < 0 {
+			lx.fail(line, "block comment is not closed with #>")
+			return
+		}
+		lx.advance(lx.pos + 2 + end + 2)
+		lx.emit(psComment, start, line, rest[2:2+end])
+	case r == '#':
+		end := strings.IndexByte(rest, '\n')
+		if end < 0 {
+			end = len(rest)
+		}
+		lx.pos += end
+		lx.emit(psComment, start, line, strings.TrimRight(rest[1:end], "\r"))
+	case r == '@' && (lx.peek(1) == '"' || lx.peek(1) == '\'') && isHereStringOpener(rest[2:]):
+		lx.scanHereString(start, line, rest[1])
+	case r == '@' && (lx.peek(1) == '(' || lx.peek(1) == '{'):
+		lx.pos += 2
+		lx.emit(psPunct, start, line, "")
+	case r == '@' && isPSVariableStart(lx.src[lx.pos+1:]):
+		lx.pos++
+		name := lx.scanVariableName()
+		lx.emit(psVariable, start, line, name)
+	case r == '$':
+		lx.scanVariable(start, line)
+	case isPSSingleQuote(r):
+		lx.scanSingleQuoted(start, line, size)
+	case isPSDoubleQuote(r):
+		lx.scanDoubleQuoted(start, line, size)
+	case strings.ContainsRune("(){}[],;|&=<>", r):
+		lx.pos++
+		lx.emit(psPunct, start, line, "")
+	default:
+		lx.scanWord(start, line)
+	}
+}
+
+func isHereStringOpener(afterQuote string) bool {
+	for _, r := range afterQuote {
+		if r == '\n' {
+			return true
+		}
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return false
+}
+
+func isPSSingleQuote(r rune) bool {
+	return r == '\'' || r == '‘' || r == '’' || r == '‚' || r == '‛'
+}
+
+func isPSDoubleQuote(r rune) bool {
+	return r == '"' || r == '“' || r == '”' || r == '„'
+}
+
+func isPSNameRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isPSVariableStart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return isPSNameRune(r)
+}
+
+// scanHereString reads @"..."@ or @'...'@; the closing quote and @ must
+// start a line.
+func (lx *psLexer) scanHereString(start, line int, quote byte) {
+	rest := lx.src[lx.pos:]
+	open := strings.IndexByte(rest, '\n')
+	closer := "\n" + string(quote) + "@"
+	end := strings.Index(rest[open:], closer)
+	if end < 0 {
+		lx.fail(line, "here-string is not closed with %c@ at the start of a line", quote)
+		return
+	}
+	end += open
+	value := strings.TrimSuffix(rest[open+1:max(end, open+1)], "\r")
+	lx.advance(lx.pos + end + len(closer))
+	lx.emit(psString, start, line, value)
+}
+
+func (lx *psLexer) scanVariable(start, line int) {
+	lx.pos++
+	switch c := lx.peek(0); {
+	case c == '(':
+		lx.pos++
+		lx.emit(psPunct, start, line, "")
+	case c == '{':
+		end := lx.pos + 1
+		for end < len(lx.src) && lx.src[end] != '}' {
+			if lx.src[end] == '`' {
+				end++
+			}
+			end++
+		}
+		if end >= len(lx.src) {
+			lx.fail(line, "variable name is not closed with }")
+			return
+		}
+		name := lx.src[lx.pos+1 : end]
+		lx.advance(end + 1)
+		lx.emit(psVariable, start, line, name)
+	case c == '$' || c == '?' || c == '^':
+		lx.pos++
+		lx.emit(psVariable, start, line, string(c))
+	case isPSVariableStart(lx.src[lx.pos:]):
+		name := lx.scanVariableName()
+		lx.emit(psVariable, start, line, name)
+	default:
+		lx.emit(psWord, start, line, "")
+	}
+}
+
+// scanVariableName reads a name such as Path, _ or env:USERPROFILE.
+func (lx *psLexer) scanVariableName() string {
+	start := lx.pos
+	for lx.pos < len(lx.src) {
+		r, size := utf8.DecodeRuneInString(lx.src[lx.pos:])
+		if r == ':' && lx.peek(1) != ':' && isPSVariableStart(lx.src[lx.pos+1:]) {
+			lx.pos++
+			continue
+		}
+		if !isPSNameRune(r) {
+			break
+		}
+		lx.pos += size
+	}
+	return lx.src[start:lx.pos]
+}
+
+func (lx *psLexer) scanSingleQuoted(start, line, quoteSize int) {
+	var b strings.Builder
+	i := lx.pos + quoteSize
+	for i < len(lx.src) {
+		r, size := utf8.DecodeRuneInString(lx.src[i:])
+		if isPSSingleQuote(r) {
+			if next, nsize := utf8.DecodeRuneInString(lx.src[i+size:]); isPSSingleQuote(next) {
+				b.WriteRune(r)
+				i += size + nsize
+				continue
+			}
+			lx.advance(i + size)
+			lx.emit(psString, start, line, b.String())
+			return
+		}
+		b.WriteString(lx.src[i : i+size])
+		i += size
+	}
+	lx.fail(line, "string is missing the closing quote")
+}
+
+// scanDoubleQuoted reads an expandable string. Backtick escapes and doubled
+// quotes are unescaped; $(...) subexpressions are skipped as code, so quotes
+// and parentheses inside them do not end the string.
+func (lx *psLexer) scanDoubleQuoted(start, line, quoteSize int) {
+	var b strings.Builder
+	lx.pos += quoteSize
+	for lx.pos < len(lx.src) {
+		r, size := utf8.DecodeRuneInString(lx.src[lx.pos:])
+		switch {
+		case r == '`' && lx.pos+1 < len(lx.src):
+			next, nsize := utf8.DecodeRuneInString(lx.src[lx.pos+1:])
+			b.WriteString(psEscape(next))
+			lx.advance(lx.pos + 1 + nsize)
+		case isPSDoubleQuote(r):
+			if next, nsize := utf8.DecodeRuneInString(lx.src[lx.pos+size:]); isPSDoubleQuote(next) {
+				b.WriteRune(r)
+				lx.pos += size + nsize
+				continue
+			}
+			lx.pos += size
+			lx.emit(psString, start, line, b.String())
+			return
+		case r == '$' && lx.peek(1) == '(':
+			from := lx.pos
+			if !lx.skipSubexpression() {
+				return
+			}
+			b.WriteString(lx.src[from:lx.pos])
+		default:
+			b.WriteString(lx.src[lx.pos : lx.pos+size])
+			lx.advance(lx.pos + size)
+		}
+	}
+	lx.fail(line, "string is missing the closing quote")
+}
+
+// skipSubexpression moves past a $( ... ) inside a string by tokenizing its
+// contents until the matching parenthesis.
+func (lx *psLexer) skipSubexpression() bool {
+	sub := &psLexer{src: lx.src, pos: lx.pos, line: lx.line}
+	depth := 0
+	for sub.pos < len(sub.src) && sub.err == nil {
+		n := len(sub.tokens)
+		sub.scan()
+		if len(sub.tokens) == n {
+			continue
+		}
+		t := sub.tokens[len(sub.tokens)-1]
+		if t.opens() == ')' {
+			depth++
+		} else if t.is(psPunct, ")") {
+			depth--
+			if depth == 0 {
+				lx.pos, lx.line = sub.pos, sub.line
+				return true
+			}
+		}
+	}
+	if sub.err != nil {
+		lx.err = sub.err
+	} else {
+		lx.fail(lx.line, "subexpression is not closed with )")
+	}
+	lx.pos = len(lx.src)
+	return false
+}
+
+func psEscape(r rune) string {
+	switch r {
+	case 'n':
+		return "\n"
+	case 't':
+		return "\t"
+	case 'r':
+		return "\r"
+	case '0':
+		return "\x00"
+	case 'a':
+		return "\a"
+	case 'b':
+		return "\b"
+	case 'e':
+		return "\x1b"
+	case 'f':
+		return "\f"
+	case 'v':
+		return "\v"
+	}
+	return string(r)
+}
+
+func (lx *psLexer) scanWord(start, line int) {
+	for lx.pos < len(lx.src) {
+		r, size := utf8.DecodeRuneInString(lx.src[lx.pos:])
+		if r == '`' && lx.pos+1 < len(lx.src) {
+			if next := lx.peek(1); next == '\n' || next == '\r' {
+				break
+			}
+			_, nsize := utf8.DecodeRuneInString(lx.src[lx.pos+1:])
+			lx.pos += 1 + nsize
+			continue
+		}
+		if unicode.IsSpace(r) || strings.ContainsRune("(){}[],;|&=<>", r) || isPSSingleQuote(r) || isPSDoubleQuote(r) {
+			break
+		}
+		lx.pos += size
+	}
+	if lx.pos == start {
+		lx.pos++
+	}
+	lx.emit(psWord, start, line, "")
+}
+
+// psFunction is a function definition with its help and parameters.
+type psFunction struct {
+	Name    string
+	Line    int
+	Help    functionHelp
+	HasHelp bool
+	Params  []ParamDetail
+}
+
+type psParser struct {
+	src  string
+	toks []psToken
+	err  error
+}
+
+// parsePowerShellScript returns the functions defined in src, nested ones
+// included, in source order. Syntax errors are returned along with
+// whatever could be parsed.
+func parsePowerShellScript(src string) ([]psFunction, error) {
+	toks, err := tokenizePowerShell(src)
+	p := &psParser{src: src, toks: toks}
+	fns := p.functions()
+	if err == nil {
+		err = p.err
+	}
+	return fns, err
+}
+
+func (p *psParser) fail(line int, format string, args ...any) {
+	if p.err == nil {
+		p.err = fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+	}
+}
+
+// skip returns the index of the first token from i on that is not a
+// newline or a comment, or limit.
+func (p *psParser) skip(i, limit int) int {
+	for i < limit && (p.toks[i].kind == psNewline || p.toks[i].kind == psComment) {
+		i++
+	}
+	return i
+}
+
+// match returns the index of the token closing the group opened at i, or
+// len(p.toks) when it is never closed.
+func (p *psParser) match(i int) int {
+	var stack []byte
+	for j := i; j < len(p.toks); j++ {
+		t := p.toks[j]
+		if c := t.opens(); c != 0 {
+			stack = append(stack, c)
+			continue
+		}
+		if t.kind != psPunct || len(stack) == 0 || len(t.text) != 1 || t.text[0] != stack[len(stack)-1] {
+			continue
+		}
+		stack = stack[:len(stack)-1]
+		if len(stack) == 0 {
+			return j
+		}
+	}
+	return len(p.toks)
+}
+
+func (p *psParser) atStatementStart(i int) bool {
+	for j := i - 1; j >= 0; j-- {
+		t := p.toks[j]
+		switch {
+		case t.kind == psComment:
+			continue
+		case t.kind == psNewline:
+			return true
+		case t.kind == psPunct && (t.text == ";" || t.text == "{" || t.text == "}" || t.text == "@{"):
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+func (p *psParser) functions() []psFunction {
+	var out []psFunction
+	for i, t := range p.toks {
+		if t.kind != psWord || !p.atStatementStart(i) {
+			continue
+		}
+		switch strings.ToLower(t.text) {
+		case "function", "filter":
+		default:
+			continue
+		}
+		if fn, ok := p.function(i); ok {
+			out = append(out, fn)
+		}
+	}
+	return out
+}
+
+func (p *psParser) function(kw int) (psFunction, bool) {
+	n := len(p.toks)
+	nameIdx := kw + 1
+	if nameIdx >= n || p.toks[nameIdx].kind != psWord {
+		return psFunction{}, false
+	}
+	fn := psFunction{Name: trimPSScope(p.toks[nameIdx].text), Line: p.toks[kw].line}
+
+	i := p.skip(nameIdx+1, n)
+	var inlineParams []ParamDetail
+	hasInline := false
+	if i < n && p.toks[i].is(psPunct, "(") {
+		end := p.match(i)
+		inlineParams, hasInline = p.params(i+1, end), true
+		i = p.skip(end+1, n)
+	}
+	if i >= n || !p.toks[i].is(psPunct, "{") {
+		p.fail(fn.Line, "function %s has no body", fn.Name)
+		return psFunction{}, false
+	}
+	open, close := i, p.match(i)
+	if close >= n {
+		p.fail(fn.Line, "function %s is missing its closing }", fn.Name)
+	}
+
+	if lines, ok := p.helpBefore(kw); ok {
+		fn.Help, fn.HasHelp = parseCommentBlockHelp(lines), true
+	} else if lines, ok := p.helpAt(open+1, close, 1); ok {
+		fn.Help, fn.HasHelp = parseCommentBlockHelp(lines), true
+	} else if lines, ok := p.helpAt(close-1, open, -1); ok {
+		fn.Help, fn.HasHelp = parseCommentBlockHelp(lines), true
+	}
+
+	if hasInline {
+		fn.Params = inlineParams
+	} else {
+		fn.Params = p.paramBlock(open+1, close)
+	}
+	return fn, true
+}
+
+func trimPSScope(name string) string {
+	for _, scope := range []string{"global:", "script:", "local:", "private:"} {
+		if len(name) > len(scope) && strings.EqualFold(name[:len(scope)], scope) {
+			return name[len(scope):]
+		}
+	}
+	return name
+}
+
+// helpBefore finds comment-based help right above the function keyword at
+// kw: a block comment or a run of line comments, blank lines allowed.
+func (p *psParser) helpBefore(kw int) ([]string, bool) {
+	i := kw - 1
+	for i >= 0 && p.toks[i].kind == psNewline {
+		i--
+	}
+	if i < 0 || p.toks[i].kind != psComment {
+		return nil, false
+	}
+	return p.helpComment(i, -1)
+}
+
+// helpAt reads comment-based help starting at the first token after
+// skipping newlines from i in direction step (1 forward, -1 backward),
+// without passing limit.
+func (p *psParser) helpAt(i, limit, step int) ([]string, bool) {
+	for i != limit && i >= 0 && i < len(p.toks) && p.toks[i].kind == psNewline {
+		i += step
+	}
+	if i == limit || i < 0 || i >= len(p.toks) || p.toks[i].kind != psComment {
+		return nil, false
+	}
+	return p.helpComment(i, step)
+}
+
+// helpComment collects the comment at i, extended over adjacent line
+// comments in direction step, and reports whether it is help, i.e. has a
+// .SYNOPSIS-style keyword.
+func (p *psParser) helpComment(i, step int) ([]string, bool) {
+	t := p.toks[i]
+	var lines []string
+	if strings.HasPrefix(t.text, "<#") {
+		lines = strings.Split(strings.ReplaceAll(t.value, "\r\n", "\n"), "\n")
+	} else {
+		run := []string{t.value}
+		for j := i + 2*step; j >= 0 && j < len(p.toks); j += 2 * step {
+			between := p.toks[j-step]
+			c := p.toks[j]
+			if between.kind != psNewline || c.kind != psComment || strings.HasPrefix(c.text, "<#") {
+				break
+			}
+			if step < 0 {
+				run = append([]string{c.value}, run...)
+			} else {
+				run = append(run, c.value)
+			}
+		}
+		lines = run
+	}
+	for _, line := range lines {
+		if m := psNamedTag.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			return lines, true
+		}
+	}
+	return nil, false
+}
+
+// paramBlock parses the param(...) statement at the start of a function
+// body, after any [CmdletBinding()]-style attributes.
+func (p *psParser) paramBlock(from, to int) []ParamDetail {
+	i := p.skip(from, to)
+	for i < to && p.toks[i].is(psPunct, "[") {
+		i = p.skip(p.match(i)+1, to)
+	}
+	if i >= to || !p.toks[i].is(psWord, "param") {
+		return nil
+	}
+	i = p.skip(i+1, to)
+	if i >= to || !p.toks[i].is(psPunct, "(") {
+		return nil
+	}
+	return p.params(i+1, p.match(i))
+}
+
+// psParamAttr is one [Parameter(...)] attribute; a parameter has one per
+// parameter set it belongs to.
+type psParamAttr struct {
+	mandatory bool
+	set       string
+	position  int
+	pipeline  bool
+	help      string
+}
+
+// params parses the parameter declarations between from and to, the
+// inside of param(...) or of an inline function(...) list.
+func (p *psParser) params(from, to int) []ParamDetail {
+	var out []ParamDetail
+	var attrs [][]psParamAttr
+	allSets := map[string]bool{}
+	for _, seg := range p.split(from, to) {
+		d, pa, ok := p.param(seg[0], seg[1])
+		if !ok {
+			continue
+		}
+		out = append(out, d)
+		attrs = append(attrs, pa)
+		for _, set := range d.ParameterSets {
+			allSets[strings.ToLower(set)] = true
+		}
+	}
+	for i := range out {
+		out[i].Mandatory = len(attrs[i]) > 0
+		for _, a := range attrs[i] {
+			out[i].Mandatory = out[i].Mandatory && a.mandatory
+		}
+		// Mandatory in some parameter sets only is not mandatory for a caller
+		// that picks another set.
+		if len(allSets) > 1 && len(out[i].ParameterSets) > 0 && len(out[i].ParameterSets) < len(allSets) {
+			out[i].Mandatory = false
+		}
+	}
+	return out
+}
+
+// split cuts the tokens between from and to at top-level commas.
+func (p *psParser) split(from, to int) [][2]int {
+	var out [][2]int
+	start := from
+	for i := from; i < to; i++ {
+		t := p.toks[i]
+		if t.opens() != 0 {
+			i = min(p.match(i), to)
+			continue
+		}
+		if t.is(psPunct, ",") {
+			out = append(out, [2]int{start, i})
+			start = i + 1
+		}
+	}
+	if p.skip(start, to) < to {
+		out = append(out, [2]int{start, to})
+	}
+	return out
+}
+
+func (p *psParser) param(from, to int) (ParamDetail, []psParamAttr, bool) {
+	d := ParamDetail{Position: -1}
+	var attrs []psParamAttr
+	i := p.skip(from, to)
+	for i < to && p.toks[i].is(psPunct, "[") {
+		end := min(p.match(i), to)
+		name, args, isAttr := p.attribute(i+1, end)
+		switch {
+		case !isAttr && end < len(p.toks):
+			d.Type = strings.TrimSpace(p.src[p.toks[i].end:p.toks[end].start])
+		case strings.EqualFold(name, "Parameter"):
+			attrs = append(attrs, p.parameterAttr(args))
+		case strings.EqualFold(name, "Alias"):
+			d.Aliases = append(d.Aliases, p.values(args)...)
+		case strings.EqualFold(name, "ValidateSet"):
+			d.ValidateSet = append(d.ValidateSet, p.values(args)...)
+		}
+		i = p.skip(end+1, to)
+	}
+	if i >= to || p.toks[i].kind != psVariable {
+		return ParamDetail{}, nil, false
+	}
+	d.Name = p.toks[i].value
+	if eq := p.skip(i+1, to); eq < to && p.toks[eq].is(psPunct, "=") {
+		d.Default = p.exprValue(eq+1, to)
+	}
+	d.Switch = strings.EqualFold(d.Type, "switch") || strings.EqualFold(d.Type, "System.Management.Automation.SwitchParameter")
+
+	setNames := map[string]bool{}
+	inAllSets := len(attrs) == 0
+	for _, a := range attrs {
+		if a.position >= 0 && d.Position < 0 {
+			d.Position = a.position
+		}
+		d.ValueFromPipeline = d.ValueFromPipeline || a.pipeline
+		if d.HelpMessage == "" {
+			d.HelpMessage = a.help
+		}
+		if a.set == "" || strings.EqualFold(a.set, "__AllParameterSets") {
+			inAllSets = true
+		} else if !setNames[strings.ToLower(a.set)] {
+			setNames[strings.ToLower(a.set)] = true
+			d.ParameterSets = append(d.ParameterSets, a.set)
+		}
+	}
+	if inAllSets {
+		d.ParameterSets = nil
+	}
+	return d, attrs, true
+}
+
+// attribute reads the inside of [...]: an attribute such as Parameter(...)
+// with its argument ranges, or a type constraint when there is no (...).
+func (p *psParser) attribute(from, to int) (string, [][2]int, bool) {
+	i := p.skip(from, to)
+	if i >= to || p.toks[i].kind != psWord {
+		return "", nil, false
+	}
+	open := p.skip(i+1, to)
+	if open >= to || !p.toks[open].is(psPunct, "(") {
+		return "", nil, false
+	}
+	return p.toks[i].text, p.split(open+1, min(p.match(open), to)), true
+}
+
+func (p *psParser) parameterAttr(args [][2]int) psParamAttr {
+	a := psParamAttr{position: -1}
+	for _, arg := range args {
+		key, value, named := p.namedArg(arg[0], arg[1])
+		if !named {
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "mandatory":
+			a.mandatory = psBool(value)
+		case "parametersetname":
+			a.set = value
+		case "position":
+			if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+				a.position = n
+			}
+		case "valuefrompipeline":
+			a.pipeline = psBool(value)
+		case "helpmessage":
+			a.help = value
+		}
+	}
+	return a
+}
+
+// namedArg reads Name = value or a bare Name, which means $true.
+func (p *psParser) namedArg(from, to int) (string, string, bool) {
+	i := p.skip(from, to)
+	if i >= to || p.toks[i].kind != psWord {
+		return "", "", false
+	}
+	eq := p.skip(i+1, to)
+	if eq >= to {
+		return p.toks[i].text, "true", true
+	}
+	if !p.toks[eq].is(psPunct, "=") {
+		return "", "", false
+	}
+	return p.toks[i].text, p.exprValue(eq+1, to), true
+}
+
+// values flattens positional attribute arguments such as ("a", "b") or
+// (@("a", "b")) into their literal values.
+func (p *psParser) values(args [][2]int) []string {
+	var out []string
+	for _, arg := range args {
+		i := p.skip(arg[0], arg[1])
+		if i < arg[1] && p.toks[i].is(psPunct, "@(") {
+			out = append(out, p.values(p.split(i+1, min(p.match(i), arg[1])))...)
+			continue
+		}
+		if v := p.exprValue(arg[0], arg[1]); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// exprValue is the value of a literal (a string, $true/$false or a bare
+// word such as a number) or the source text of any other expression.
+func (p *psParser) exprValue(from, to int) string {
+	first := p.skip(from, to)
+	if first >= to {
+		return ""
+	}
+	last := to - 1
+	for last > first && (p.toks[last].kind == psNewline || p.toks[last].kind == psComment) {
+		last--
+	}
+	if first == last {
+		t := p.toks[first]
+		switch {
+		case t.kind == psString:
+			return t.value
+		case t.kind == psVariable && (strings.EqualFold(t.value, "true") || strings.EqualFold(t.value, "false")):
+			return strings.ToLower(t.value)
+		}
+	}
+	return strings.TrimSpace(p.src[p.toks[first].start:p.toks[last].end])
+}
+
+func psBool(v string) bool {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "true", "1":
+		return true
+	}
+	return false
+}
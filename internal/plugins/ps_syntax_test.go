@@ -0,0 +1,196 @@
+package plugins
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenizePowerShellStringsAndComments(t *testing.T) {
+	src := "$a = \"x $(Get-Item \"(\" ) y\" # trailing (\n" +
+		"$b = @'\nfunction fake { param($x) }\n'@\n" +
+		"<# block ) #>$c = 'it''s'\n"
+	toks, err := tokenizePowerShell(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var strs []string
+	comments := 0
+	for _, tok := range toks {
+		switch tok.kind {
+		case psString:
+			strs = append(strs, tok.value)
+		case psComment:
+			comments++
+		case psPunct:
+			if tok.text == "(" || tok.text == ")" {
+				t.Fatalf("parens inside strings and comments must not be tokens: %+v", tok)
+			}
+		}
+	}
+	want := []string{`x $(Get-Item "(" ) y`, "function fake { param($x) }", "it's"}
+	if !reflect.DeepEqual(strs, want) || comments != 2 {
+		t.Fatalf("unexpected strings %q or %d comments", strs, comments)
+	}
+
+	if _, err := tokenizePowerShell("$a = 'open\n"); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("expected an unterminated string error on line 1, got %v", err)
+	}
+}
+
+func TestParsePowerShellScriptAttributes(t *testing.T) {
+	src := `function Get-Thing {
+    [CmdletBinding(DefaultParameterSetName = "ByName")]
+    param(
+        [Parameter(Mandatory, Position = 0, ParameterSetName = "ByName", HelpMessage = "Name (or pattern)")]
+        [Alias("N", 'Label')]
+        [ValidateScript({ $_ -match '^[a-z(]+$' -and ($_.Length -lt 10) })]
+        [string]$Name,
+
+        [Parameter(Mandatory = $true, ParameterSetName = "ById", ValueFromPipeline = $true)]
+        [int]$Id,
+
+        [Parameter(ParameterSetName = "ByName")]
+        [Parameter(Mandatory = $true, ParameterSetName = "ById")]
+        [ValidateSet("a,b", "c")]
+        [string]$Mode = "c",
+
+        [Parameter(Mandatory = $true)]
+        [hashtable]$Options = @{ depth = 2 }
+    )
+}
+`
+	fns, err := parsePowerShellScript(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fns) != 1 || fns[0].Name != "Get-Thing" || len(fns[0].Params) != 4 {
+		t.Fatalf("unexpected functions: %+v", fns)
+	}
+	name, id, mode, options := fns[0].Params[0], fns[0].Params[1], fns[0].Params[2], fns[0].Params[3]
+	if name.Name != "Name" || name.Type != "string" || name.Mandatory || name.Position != 0 || name.HelpMessage != "Name (or pattern)" {
+		t.Fatalf("unexpected Name param: %+v", name)
+	}
+	if !reflect.DeepEqual(name.Aliases, []string{"N", "Label"}) || !reflect.DeepEqual(name.ParameterSets, []string{"ByName"}) {
+		t.Fatalf("unexpected Name aliases or sets: %+v", name)
+	}
+	if id.Mandatory || !id.ValueFromPipeline || id.Position != -1 || !reflect.DeepEqual(id.ParameterSets, []string{"ById"}) {
+		t.Fatalf("unexpected Id param: %+v", id)
+	}
+	if mode.Mandatory || mode.Default != "c" || !reflect.DeepEqual(mode.ValidateSet, []string{"a,b", "c"}) || len(mode.ParameterSets) != 2 {
+		t.Fatalf("unexpected Mode param: %+v", mode)
+	}
+	if !options.Mandatory || options.Type != "hashtable" || options.Default != "@{ depth = 2 }" || len(options.ParameterSets) != 0 {
+		t.Fatalf("unexpected Options param: %+v", options)
+	}
+}
+
+func TestParsePowerShellScriptHelpPlacement(t *testing.T) {
+	src := `$template = @"
+function not_real {
+    <#
+    .SYNOPSIS
+    Inside a here-string
+    #>
+}
+"@
+
+function help_at_start {
+    <#
+    .SYNOPSIS
+    Help at the start of the body
+    .PARAMETER Path
+    Where to look
+    #>
+    param([string]$Path)
+}
+
+function help_at_end {
+    param([switch]$Force)
+    # ignored comment
+
+    <#
+    .SYNOPSIS
+    Help at the end of the body
+    #>
+}
+
+# .SYNOPSIS
+# Help in line comments
+function line_help { }
+
+# Just a note, not help
+function no_help { }
+`
+	fns, err := parsePowerShellScript(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, fn := range fns {
+		names = append(names, fn.Name)
+	}
+	if want := []string{"help_at_start", "help_at_end", "line_help", "no_help"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("expected functions %v, got %v", want, names)
+	}
+	if fns[0].Help.Synopsis != "Help at the start of the body" || !reflect.DeepEqual(fns[0].Help.Parameters, []string{"Path: Where to look"}) {
+		t.Fatalf("unexpected help_at_start help: %+v", fns[0].Help)
+	}
+	if fns[1].Help.Synopsis != "Help at the end of the body" || len(fns[1].Params) != 1 || !fns[1].Params[0].Switch {
+		t.Fatalf("unexpected help_at_end: %+v", fns[1])
+	}
+	if fns[2].Help.Synopsis != "Help in line comments" {
+		t.Fatalf("unexpected line_help help: %+v", fns[2].Help)
+	}
+	if fns[3].HasHelp {
+		t.Fatalf("a plain comment is not help: %+v", fns[3].Help)
+	}
+}
+
+func TestParsePowerShellScriptBundledToolkits(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "plugins", "*.ps1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested, _ := filepath.Glob(filepath.Join("..", "..", "plugins", "*", "*.ps1"))
+	files = append(files, nested...)
+	if len(files) == 0 {
+		t.Skip("no bundled toolkits")
+	}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fns, err := parsePowerShellScript(string(data))
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if len(fns) == 0 {
+			t.Fatalf("%s: no functions found", path)
+		}
+		for _, fn := range fns {
+			if !isPublicFunctionName(fn.Name) {
+				continue
+			}
+			if strings.TrimSpace(fn.Help.Synopsis) == "" {
+				t.Fatalf("%s: %s has no synopsis", path, fn.Name)
+			}
+			declared := map[string]bool{}
+			for _, p := range fn.Params {
+				if p.Name == "" || p.Type == "" {
+					t.Fatalf("%s: %s has an incomplete param: %+v", path, fn.Name, p)
+				}
+				declared[strings.ToLower(p.Name)] = true
+			}
+			for _, doc := range fn.Help.Parameters {
+				name, _, _ := strings.Cut(doc, ":")
+				if !declared[strings.ToLower(name)] {
+					t.Fatalf("%s: %s documents undeclared parameter %q", path, fn.Name, name)
+				}
+			}
+		}
+	}
+}
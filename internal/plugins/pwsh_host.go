@@ -0,0 +1,433 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pwshHostMarker prefixes every protocol line the host writes, so stray
+// console output from a toolkit cannot be mistaken for a response.
+const pwshHostMarker = "\x1edm-host "
+
+// pwshHostScript is the worker loop. It reads one JSON request per line on
+// stdin, dot-sources the requested files into its script scope and calls
+// the function in a child scope with the same StrictMode and error
+// preference as buildPowerShellFunctionScript, so a toolkit or an earlier
+// call cannot change them for the next one. Like a fresh process, only
+// errors are captured apart from the output; warnings, verbose and host
+// text reach the console and come back as stray output. The returned
+// objects travel as a JSON string in "data", apart from the formatted text.
+var pwshHostScript = `[Console]::InputEncoding=[System.Text.UTF8Encoding]::new()
+[Console]::OutputEncoding=[System.Text.UTF8Encoding]::new()
+Set-StrictMode -Version Latest
+$ErrorActionPreference='Stop'
+$ProgressPreference='SilentlyContinue'
+$dmHostMarker=[string][char]0x1e+'dm-host '
+` + psConvertDataFunc + `
+[Console]::Out.WriteLine($dmHostMarker+'{"id":0,"ok":true}')
+while($null -ne ($dmHostLine=[Console]::In.ReadLine())){
+  if($dmHostLine.Trim() -eq ''){ continue }
+  $dmHostReq=$dmHostLine | ConvertFrom-Json
+  $dmHostOut=[System.Collections.Generic.List[object]]::new()
+  $dmHostStderr=[System.Collections.Generic.List[object]]::new()
+  $dmHostItems=[System.Collections.Generic.List[object]]::new()
+  $dmHostErr=$null
+  try {
+    foreach($dmHostPath in @($dmHostReq.load)){ if($dmHostPath -and (Test-Path -LiteralPath $dmHostPath)){ . $dmHostPath } }
+    if(-not(Get-Command -Name $dmHostReq.function -CommandType Function -ErrorAction SilentlyContinue)){
+      throw "Function '$($dmHostReq.function)' was not loaded from plugin sources."
+    }
+    $dmNamedArgs=@{}
+    if($dmHostReq.named){ foreach($dmHostProp in $dmHostReq.named.PSObject.Properties){ $dmNamedArgs[$dmHostProp.Name]=$dmHostProp.Value } }
+    $dmPositionalArgs=@()
+    if($dmHostReq.positional){ $dmPositionalArgs=@($dmHostReq.positional) }
+    & {
+      Set-StrictMode -Version Latest
+      $ErrorActionPreference='Stop'
+      & $dmHostReq.function @dmNamedArgs @dmPositionalArgs
+    } 2>&1 | ForEach-Object {
+      $dmHostOut.Add($_)
+      if($_ -is [System.Management.Automation.ErrorRecord]){ $dmHostStderr.Add($_) } else { $dmHostItems.Add($_) }
+    }
+  } catch {
+    $dmHostErr=$_.Exception.Message
+  }
+  $dmHostData=$null
+  if($null -eq $dmHostErr){ $dmHostData=ConvertTo-DmData $dmHostItems }
+  $dmHostResp=[ordered]@{
+    id=$dmHostReq.id; ok=($null -eq $dmHostErr); error=$dmHostErr; data=$dmHostData
+    output=($dmHostOut | Out-String -Width 4096)
+    stdout=($dmHostItems | Out-String -Width 4096)
+    stderr=($dmHostStderr | Out-String -Width 4096)
+  }
+  [Console]::Out.WriteLine($dmHostMarker+($dmHostResp | ConvertTo-Json -Compress -Depth 3))
+}
+`
+
+// pwshHostRequest always carries every field, even empty ones: the worker
+// runs under StrictMode, where reading a missing property is an error.
+type pwshHostRequest struct {
+	ID         int            `json:"id"`
+	Load       []string       `json:"load"`
+	Function   string         `json:"function"`
+	Named      map[string]any `json:"named"`
+	Positional []string       `json:"positional"`
+}
+
+type pwshHostResponse struct {
+	ID     int    `json:"id"`
+	OK     bool   `json:"ok"`
+	Output string `json:"output"`
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+	Data   string `json:"data,omitempty"`
+	Error  string `json:"error"`
+}
+
+// pwshHost is a long-lived pwsh process that keeps toolkits loaded between
+// calls. It is restarted when a loaded file changes or the process dies.
+type pwshHost struct {
+	cmd        *exec.Cmd
+	stdin      io.WriteCloser
+	stdout     io.ReadCloser
+	lines      chan string
+	done       chan struct{} // closed by stop so readLines never blocks
+	exited     chan struct{}
+	stderr     *tailBuffer
+	scriptPath string
+	loaded     map[string]int64 // dot-sourced file -> statStamp at load time
+	nextID     int
+}
+
+// errPwshHostGone means the request never reached the worker, so it is
+// safe to start a new one and send it again.
+var errPwshHostGone = errors.New("plugin host is not accepting requests")
+
+var (
+	persistentHostMu      sync.Mutex
+	persistentHostEnabled bool
+	persistentHost        *pwshHost
+)
+
+// newPwshHostCmd builds the worker process; tests replace it.
+var newPwshHostCmd = func(scriptPath string) (*exec.Cmd, error) {
+	ps := firstAvailableBinary("pwsh", "powershell")
+	if ps == "" {
+		return nil, errors.New("pwsh/powershell executable not found")
+	}
+	return exec.Command(ps, "-NoProfile", "-NonInteractive", "-File", scriptPath), nil
+}
+
+// SetPersistentHost turns the long-lived pwsh worker for non-interactive
+// function calls on or off. Turning it off stops a running worker.
+func SetPersistentHost(enabled bool) {
+	persistentHostMu.Lock()
+	defer persistentHostMu.Unlock()
+	persistentHostEnabled = enabled
+	if !enabled && persistentHost != nil {
+		persistentHost.stop()
+		persistentHost = nil
+	}
+}
+
+// ClosePersistentHost stops the worker if one is running.
+func ClosePersistentHost() {
+	persistentHostMu.Lock()
+	defer persistentHostMu.Unlock()
+	if persistentHost != nil {
+		persistentHost.stop()
+		persistentHost = nil
+	}
+}
+
+func usePersistentHost() bool {
+	persistentHostMu.Lock()
+	defer persistentHostMu.Unlock()
+	return persistentHostEnabled
+}
+
+// runInPersistentHost runs one function in the shared worker, starting or
+// restarting it as needed. There is a single worker, so calls are
+// serialized: plugins batched by the agent wait for each other here.
+func runInPersistentHost(ctx context.Context, profilePaths []string, functionName string, args []string, opts execOptions) RunResult {
+	persistentHostMu.Lock()
+	defer persistentHostMu.Unlock()
+
+	named, positional := splitPowerShellSplatArgs(args)
+	req := pwshHostRequest{Function: functionName, Positional: positional}
+	if len(named) > 0 {
+		req.Named = map[string]any{}
+		for _, a := range named {
+			if a.IsSwitch {
+				req.Named[a.Name] = true
+			} else {
+				req.Named[a.Name] = a.Value
+			}
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		h := persistentHost
+		if h != nil && (!h.alive() || !h.fresh()) {
+			h.stop()
+			h = nil
+		}
+		if h == nil {
+			var err error
+			if h, err = startPwshHost(ctx); err != nil {
+				return RunResult{Err: err}
+			}
+			persistentHost = h
+		}
+
+		req.Load = nil
+		stamps := map[string]int64{}
+		for _, p := range profilePaths {
+			if _, ok := h.loaded[p]; !ok {
+				req.Load = append(req.Load, p)
+				stamps[p] = statStamp(p)
+			}
+		}
+
+		resp, stray, err := h.call(ctx, req, opts.runTimeout())
+		if err != nil {
+			h.kill()
+			persistentHost = nil
+			if errors.Is(err, errPwshHostGone) && attempt == 0 {
+				continue
+			}
+		}
+		limit := currentOutputLimit()
+		res := RunResult{}
+		res.Output, res.Dropped = HeadTail(stray+resp.Output, limit)
+		res.Stdout, _ = HeadTail(stray+resp.Stdout, limit)
+		res.Stderr, _ = HeadTail(resp.Stderr, limit)
+		if res.Output != "" && !opts.quiet {
+			echo := io.Writer(os.Stdout)
+			if opts.live != nil {
+				echo = opts.live
+			}
+			fmt.Fprint(echo, res.Output)
+		}
+		if err != nil {
+			res.Err = &RunError{Err: err, Output: res.Output}
+			return res
+		}
+		for p, stamp := range stamps {
+			h.loaded[p] = stamp
+		}
+		if !resp.OK {
+			res.Err = &RunError{Err: errors.New(strings.TrimSpace(resp.Error)), Output: res.Output}
+			return res
+		}
+		res.Data = decodePluginData([]byte(resp.Data))
+		return res
+	}
+}
+
+func startPwshHost(ctx context.Context) (*pwshHost, error) {
+	tmp, err := os.CreateTemp("", "dm-pwsh-host-*.ps1")
+	if err != nil {
+		return nil, err
+	}
+	_, writeErr := tmp.WriteString(pwshHostScript)
+	if closeErr := tmp.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr != nil {
+		os.Remove(tmp.Name())
+		return nil, writeErr
+	}
+	cmd, err := newPwshHostCmd(tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	h := &pwshHost{
+		cmd:        cmd,
+		lines:      make(chan string, 64),
+		done:       make(chan struct{}),
+		exited:     make(chan struct{}),
+		stderr:     &tailBuffer{max: 4096},
+		scriptPath: tmp.Name(),
+		loaded:     map[string]int64{},
+	}
+	cmd.Stderr = h.stderr
+	if h.stdin, err = cmd.StdinPipe(); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	if h.stdout, err = cmd.StdoutPipe(); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	readDone := make(chan struct{})
+	go func() {
+		h.readLines(h.stdout)
+		close(readDone)
+	}()
+	go func() {
+		// Wait closes stdout, so let the reader drain it first
+		<-readDone
+		cmd.Wait()
+		close(h.exited)
+	}()
+
+	// the host announces itself with response id 0 once it is ready
+	if _, _, err := h.await(ctx, 0, pluginExecTimeout); err != nil {
+		h.kill()
+		return nil, fmt.Errorf("plugin host did not start: %w", err)
+	}
+	return h, nil
+}
+
+func (h *pwshHost) readLines(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	defer close(h.lines)
+	for scanner.Scan() {
+		select {
+		case h.lines <- scanner.Text():
+		case <-h.done:
+			io.Copy(io.Discard, r)
+			return
+		}
+	}
+}
+
+func (h *pwshHost) call(ctx context.Context, req pwshHostRequest, timeout time.Duration) (pwshHostResponse, string, error) {
+	h.nextID++
+	req.ID = h.nextID
+	data, err := json.Marshal(req)
+	if err != nil {
+		return pwshHostResponse{}, "", err
+	}
+	if _, err := h.stdin.Write(append(data, '\n')); err != nil {
+		return pwshHostResponse{}, "", fmt.Errorf("%w: %v", errPwshHostGone, err)
+	}
+	return h.await(ctx, req.ID, timeout)
+}
+
+// await reads lines until the response with the given id arrives. Lines
+// without the protocol marker are returned as stray output. A timeout or a
+// canceled ctx gives up on the call; the caller then kills the worker.
+func (h *pwshHost) await(ctx context.Context, id int, timeout time.Duration) (pwshHostResponse, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var stray strings.Builder
+	for {
+		select {
+		case line, ok := <-h.lines:
+			if !ok {
+				return pwshHostResponse{}, stray.String(), h.exitError()
+			}
+			payload, isProtocol := strings.CutPrefix(line, pwshHostMarker)
+			if !isProtocol {
+				stray.WriteString(line + "\n")
+				continue
+			}
+			var resp pwshHostResponse
+			if err := json.Unmarshal([]byte(payload), &resp); err != nil {
+				return resp, stray.String(), fmt.Errorf("invalid plugin host response: %w", err)
+			}
+			if resp.ID != id {
+				continue
+			}
+			return resp, stray.String(), nil
+		case <-ctx.Done():
+			return pwshHostResponse{}, stray.String(), runContextErr(ctx, timeout)
+		}
+	}
+}
+
+func (h *pwshHost) exitError() error {
+	<-h.exited
+	msg := "plugin host exited"
+	if h.cmd.ProcessState != nil {
+		msg += " (" + h.cmd.ProcessState.String() + ")"
+	}
+	if tail := strings.TrimSpace(h.stderr.String()); tail != "" {
+		msg += ": " + tail
+	}
+	return errors.New(msg)
+}
+
+func (h *pwshHost) alive() bool {
+	select {
+	case <-h.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// fresh reports whether every dot-sourced file is unchanged since it was
+// loaded; redefining functions in place would keep removed ones around.
+func (h *pwshHost) fresh() bool {
+	return fingerprintsValid("", -1, h.loaded)
+}
+
+// stop closes stdin so the worker loop ends, killing it if it does not
+// exit within two seconds.
+func (h *pwshHost) stop() {
+	close(h.done)
+	h.stdin.Close()
+	select {
+	case <-h.exited:
+	case <-time.After(2 * time.Second):
+		if h.cmd.Process != nil {
+			h.cmd.Process.Kill()
+		}
+		<-h.exited
+	}
+	os.Remove(h.scriptPath)
+}
+
+// kill ends a worker that is busy or broken. Closing stdout unblocks the
+// reader even when a child process still holds the pipe.
+func (h *pwshHost) kill() {
+	close(h.done)
+	h.stdin.Close()
+	if h.cmd.Process != nil {
+		h.cmd.Process.Kill()
+	}
+	h.stdout.Close()
+	<-h.exited
+	os.Remove(h.scriptPath)
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = t.buf[len(t.buf)-t.max:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
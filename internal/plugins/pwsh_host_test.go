@@ -0,0 +1,267 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPwshHostHelperProcess is not a real test: it is the fake pwsh worker
+// started by withFakePwshHost. It speaks the same protocol as
+// pwshHostScript and implements a few canned functions.
+func TestPwshHostHelperProcess(t *testing.T) {
+	if os.Getenv("DM_FAKE_PWSH_HOST") != "1" {
+		return
+	}
+	respond := func(resp pwshHostResponse) {
+		data, _ := json.Marshal(resp)
+		fmt.Println(pwshHostMarker + string(data))
+	}
+	respond(pwshHostResponse{OK: true})
+	loads := 0
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req pwshHostRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			os.Exit(2)
+		}
+		loads += len(req.Load)
+		resp := pwshHostResponse{ID: req.ID, OK: true}
+		switch req.Function {
+		case "echo":
+			var named []string
+			for k, v := range req.Named {
+				named = append(named, fmt.Sprintf("%s=%v", k, v))
+			}
+			sort.Strings(named)
+			resp.Output = fmt.Sprintf("args=%s named=%s pid=%d loads=%d\n",
+				strings.Join(req.Positional, ","), strings.Join(named, ","), os.Getpid(), loads)
+		case "stray":
+			fmt.Println("written straight to the console")
+			resp.Output = "done\n"
+		case "data":
+			resp.Stdout = "Name Size\n---- ----\ndb      3\n"
+			resp.Stderr = "WARNING: slow disk\n"
+			resp.Output = resp.Stderr + resp.Stdout
+			resp.Data = `[{"Name":"db","Size":3}]`
+		case "fail":
+			resp.OK, resp.Error = false, "boom"
+		case "sleep":
+			time.Sleep(30 * time.Second)
+		case "crash":
+			fmt.Fprintln(os.Stderr, "fatal: host crashed")
+			os.Exit(3)
+		}
+		respond(resp)
+	}
+	os.Exit(0)
+}
+
+func withFakePwshHost(tb testing.TB) {
+	tb.Helper()
+	prevCmd, prevTimeout := newPwshHostCmd, pluginExecTimeout
+	newPwshHostCmd = func(string) (*exec.Cmd, error) {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestPwshHostHelperProcess$")
+		cmd.Env = append(os.Environ(), "DM_FAKE_PWSH_HOST=1")
+		return cmd, nil
+	}
+	SetPersistentHost(true)
+	tb.Cleanup(func() {
+		SetPersistentHost(false)
+		newPwshHostCmd, pluginExecTimeout = prevCmd, prevTimeout
+	})
+}
+
+func hostField(t *testing.T, output, key string) string {
+	t.Helper()
+	for _, f := range strings.Fields(output) {
+		if v, ok := strings.CutPrefix(f, key+"="); ok {
+			return v
+		}
+	}
+	t.Fatalf("no %s in output %q", key, output)
+	return ""
+}
+
+func TestPersistentHostReusesWorkerAndLoadsOnce(t *testing.T) {
+	withFakePwshHost(t)
+	toolkit := filepath.Join(t.TempDir(), "Kit_Toolkit.ps1")
+	writeTestFile(t, toolkit, "function echo {}\n")
+
+	first := runPowerShellFunctionCapture(context.Background(), []string{toolkit}, "echo", []string{"x", "-Name", "db", "-Force"}, execOptions{quiet: true})
+	if first.Err != nil {
+		t.Fatal(first.Err)
+	}
+	if got := hostField(t, first.Output, "named"); got != "Force=true,Name=db" {
+		t.Fatalf("unexpected named args: %q", got)
+	}
+	if got := hostField(t, first.Output, "args"); got != "x" {
+		t.Fatalf("unexpected positional args: %q", got)
+	}
+	second := runPowerShellFunctionCapture(context.Background(), []string{toolkit}, "echo", nil, execOptions{quiet: true})
+	if second.Err != nil {
+		t.Fatal(second.Err)
+	}
+	if hostField(t, first.Output, "pid") != hostField(t, second.Output, "pid") {
+		t.Fatal("expected the second call to reuse the worker")
+	}
+	if got := hostField(t, second.Output, "loads"); got != "1" {
+		t.Fatalf("expected the toolkit to be loaded once, got %s loads", got)
+	}
+
+	later := time.Now().Add(2 * time.Second)
+	if err := os.Chtimes(toolkit, later, later); err != nil {
+		t.Fatal(err)
+	}
+	third := runPowerShellFunctionCapture(context.Background(), []string{toolkit}, "echo", nil, execOptions{quiet: true})
+	if third.Err != nil {
+		t.Fatal(third.Err)
+	}
+	if hostField(t, third.Output, "pid") == hostField(t, second.Output, "pid") {
+		t.Fatal("expected a changed toolkit to restart the worker")
+	}
+}
+
+func TestPersistentHostRestartsAfterCrashAndTimeout(t *testing.T) {
+	withFakePwshHost(t)
+	before := runPowerShellFunctionCapture(context.Background(), nil, "echo", nil, execOptions{quiet: true})
+	if before.Err != nil {
+		t.Fatal(before.Err)
+	}
+
+	err := runPowerShellFunctionCapture(context.Background(), nil, "crash", nil, execOptions{quiet: true}).Err
+	if err == nil || !strings.Contains(err.Error(), "plugin host exited") || !strings.Contains(err.Error(), "fatal: host crashed") {
+		t.Fatalf("expected crash error with stderr tail, got %v", err)
+	}
+	after := runPowerShellFunctionCapture(context.Background(), nil, "echo", nil, execOptions{quiet: true})
+	if after.Err != nil {
+		t.Fatalf("expected a new worker after the crash, got %v", after.Err)
+	}
+	if hostField(t, before.Output, "pid") == hostField(t, after.Output, "pid") {
+		t.Fatal("expected a different worker after the crash")
+	}
+
+	pluginExecTimeout = 300 * time.Millisecond
+	start := time.Now()
+	err = runPowerShellFunctionCapture(context.Background(), nil, "sleep", nil, execOptions{quiet: true}).Err
+	if err == nil || !strings.Contains(err.Error(), "timed out after 300ms") {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("timeout took too long: %s", time.Since(start))
+	}
+	if err := runPowerShellFunctionCapture(context.Background(), nil, "echo", nil, execOptions{quiet: true}).Err; err != nil {
+		t.Fatalf("expected a new worker after the timeout, got %v", err)
+	}
+}
+
+func TestPersistentHostStrayOutputAndErrors(t *testing.T) {
+	withFakePwshHost(t)
+	var echo strings.Builder
+	res := runPowerShellFunctionCapture(context.Background(), nil, "stray", nil, execOptions{live: &echo})
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	if res.Output != "written straight to the console\ndone\n" {
+		t.Fatalf("unexpected output: %q", res.Output)
+	}
+	if echo.String() != res.Output {
+		t.Fatalf("expected stray output to be echoed with the result, got %q", echo.String())
+	}
+	before := runPowerShellFunctionCapture(context.Background(), nil, "echo", nil, execOptions{quiet: true})
+	err := runPowerShellFunctionCapture(context.Background(), nil, "fail", nil, execOptions{quiet: true}).Err
+	if err == nil || err.Error() != "boom" {
+		t.Fatalf("expected function error, got %v", err)
+	}
+	after := runPowerShellFunctionCapture(context.Background(), nil, "echo", nil, execOptions{quiet: true})
+	if hostField(t, before.Output, "pid") != hostField(t, after.Output, "pid") {
+		t.Fatal("a failing function must not restart the worker")
+	}
+}
+
+func TestPersistentHostReturnsStructuredData(t *testing.T) {
+	withFakePwshHost(t)
+	res := runPowerShellFunctionCapture(context.Background(), nil, "data", nil, execOptions{quiet: true})
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	want := []any{map[string]any{"Name": "db", "Size": float64(3)}}
+	if !reflect.DeepEqual(res.Data, want) {
+		t.Fatalf("unexpected data: %#v", res.Data)
+	}
+	if res.Stdout != "Name Size\n---- ----\ndb      3\n" || res.Stderr != "WARNING: slow disk\n" {
+		t.Fatalf("unexpected streams: stdout %q stderr %q", res.Stdout, res.Stderr)
+	}
+	if text := runPowerShellFunctionCapture(context.Background(), nil, "echo", nil, execOptions{quiet: true}); text.Data != nil {
+		t.Fatalf("expected no data for text output, got %#v", text.Data)
+	}
+}
+
+func TestPersistentHostHonorsCancelAndFunctionTimeout(t *testing.T) {
+	withFakePwshHost(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	start := time.Now()
+	err := runPowerShellFunctionCapture(ctx, nil, "sleep", nil, execOptions{quiet: true}).Err
+	if !IsCanceled(err) {
+		t.Fatalf("expected a canceled run, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("cancel took too long: %s", time.Since(start))
+	}
+
+	err = runPowerShellFunctionCapture(context.Background(), nil, "sleep", nil, execOptions{quiet: true, timeout: 250 * time.Millisecond}).Err
+	if err == nil || !strings.Contains(err.Error(), "timed out after 250ms") || IsCanceled(err) {
+		t.Fatalf("expected the per-function timeout, got %v", err)
+	}
+	if err := runPowerShellFunctionCapture(context.Background(), nil, "echo", nil, execOptions{quiet: true}).Err; err != nil {
+		t.Fatalf("expected a new worker after the cancel, got %v", err)
+	}
+}
+
+// TestPersistentHostMatchesFreshProcess runs the real worker script: a
+// function must see the same StrictMode, error preference and streams with
+// and without the persistent host.
+func TestPersistentHostMatchesFreshProcess(t *testing.T) {
+	if firstAvailableBinary("pwsh", "powershell") == "" {
+		t.Skip("pwsh not installed")
+	}
+	toolkit := filepath.Join(t.TempDir(), "Probe_Toolkit.ps1")
+	writeTestFile(t, toolkit, `function probe_strict { try { $null = $dmProbeUndefined; 'lenient' } catch { 'strict' } }
+function probe_eap { "eap=$ErrorActionPreference" }
+function probe_leak { $global:ErrorActionPreference = 'Continue'; 'changed' }
+function probe_errors { Write-Error 'disk slow' -ErrorAction Continue; 'done' }
+`)
+	for _, persistent := range []bool{false, true} {
+		SetPersistentHost(persistent)
+		run := func(fn string) RunResult {
+			t.Helper()
+			res := runPowerShellFunctionCapture(context.Background(), []string{toolkit}, fn, nil, execOptions{quiet: true})
+			if res.Err != nil {
+				t.Fatalf("persistent=%v %s: %v", persistent, fn, res.Err)
+			}
+			return res
+		}
+		if got := strings.TrimSpace(run("probe_strict").Stdout); got != "strict" {
+			t.Fatalf("persistent=%v: expected StrictMode, got %q", persistent, got)
+		}
+		run("probe_leak")
+		if got := strings.TrimSpace(run("probe_eap").Stdout); got != "eap=Stop" {
+			t.Fatalf("persistent=%v: expected the error preference to be Stop, got %q", persistent, got)
+		}
+		res := run("probe_errors")
+		if !strings.Contains(res.Stdout, "done") || strings.Contains(res.Stdout, "disk slow") || !strings.Contains(res.Stderr, "disk slow") {
+			t.Fatalf("persistent=%v: expected separate streams, got stdout %q stderr %q", persistent, res.Stdout, res.Stderr)
+		}
+	}
+	SetPersistentHost(false)
+}
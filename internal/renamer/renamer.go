@@ -118,6 +118,12 @@ func BuildPlan(opts Options) ([]PlanItem, error) {
 }
 
 func ApplyPlan(plan []PlanItem) error {
+	return ApplyPlanProgress(plan, nil)
+}
+
+// ApplyPlanProgress is ApplyPlan with a callback after each completed rename,
+// so callers can tell how far a failed plan got.
+func ApplyPlanProgress(plan []PlanItem, done func(i int)) error {
 	seen := map[string]struct{}{}
 	for _, item := range plan {
 		if _, ok := seen[item.NewPath]; ok {
@@ -128,10 +134,13 @@ func ApplyPlan(plan []PlanItem) error {
 			return fmt.Errorf("target already exists: %s", item.NewPath)
 		}
 	}
-	for _, item := range plan {
+	for i, item := range plan {
 		if err := os.Rename(item.OldPath, item.NewPath); err != nil {
 			return err
 		}
+		if done != nil {
+			done(i)
+		}
 	}
 	return nil
 }
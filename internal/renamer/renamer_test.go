@@ -225,6 +225,25 @@ func TestApplyPlan_TargetExists(t *testing.T) {
 	}
 }
 
+func TestApplyPlanProgress_ReportsDoneItems(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	missing := filepath.Join(dir, "missing.txt")
+	createFile(t, a)
+
+	var done []int
+	err := ApplyPlanProgress([]PlanItem{
+		{OldPath: a, NewPath: filepath.Join(dir, "a2.txt")},
+		{OldPath: missing, NewPath: filepath.Join(dir, "m2.txt")},
+	}, func(i int) { done = append(done, i) })
+	if err == nil {
+		t.Fatal("expected error for missing source")
+	}
+	if len(done) != 1 || done[0] != 0 {
+		t.Fatalf("done = %v, want [0]", done)
+	}
+}
+
 func TestBuildPlan_Regex(t *testing.T) {
 	dir := t.TempDir()
 	createFile(t, filepath.Join(dir, "img001.png"))
@@ -0,0 +1,373 @@
+package ui
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ANSI colors per token kind.
+const (
+	hlKeyword  = "35" // magenta
+	hlString   = "32" // green
+	hlComment  = "90" // gray
+	hlNumber   = "33" // yellow
+	hlVariable = "36" // cyan
+	hlType     = "34" // blue
+	hlParam    = "94" // bright blue
+)
+
+type hlLang struct {
+	lineComments []string
+	// commentNeedsSpace: a line comment must start the line or follow
+	// whitespace (bash's $#, yaml's a#b).
+	commentNeedsSpace bool
+	blockComment      [2]string
+	// multiline strings as {open, close}, checked before plain quotes
+	blockStrings    [][2]string
+	quotes          string
+	escape          byte
+	rawSingle       bool // no escapes inside '...'
+	keywords        map[string]bool
+	types           map[string]bool
+	caseInsensitive bool
+	firstWordOnly   bool
+	variables       bool
+	dashedWords     bool
+	keyColon        bool
+	yamlKeys        bool
+}
+
+func wordSet(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+var hlLanguages = map[string]*hlLang{
+	"powershell": {
+		lineComments:      []string{"#"},
+		commentNeedsSpace: true,
+		blockComment:      [2]string{"<#", "#>"},
+		blockStrings:      [][2]string{{`@"`, `"@`}, {`@'`, `'@`}},
+		quotes:            `"'`,
+		escape:            '`',
+		rawSingle:         true,
+		keywords: wordSet("begin break catch class continue data do dynamicparam else elseif end enum exit filter finally for " +
+			"foreach function if in param process return switch throw trap try until using while $true $false $null"),
+		types:           wordSet("string int long bool switch double decimal datetime array hashtable object pscustomobject void"),
+		caseInsensitive: true,
+		variables:       true,
+		dashedWords:     true,
+	},
+	"go": {
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		blockStrings: [][2]string{{"`", "`"}},
+		quotes:       `"'`,
+		escape:       '\\',
+		keywords: wordSet("break case chan const continue default defer else fallthrough for func go goto if import " +
+			"interface map package range return select struct switch type var true false nil iota"),
+		types: wordSet("any bool byte comparable complex64 complex128 error float32 float64 int int8 int16 int32 int64 " +
+			"rune string uint uint8 uint16 uint32 uint64 uintptr append cap close copy delete len make max min new panic print println recover"),
+	},
+	"json": {
+		quotes:   `"`,
+		escape:   '\\',
+		keywords: wordSet("true false null"),
+		keyColon: true,
+	},
+	"yaml": {
+		lineComments:      []string{"#"},
+		commentNeedsSpace: true,
+		quotes:            `"'`,
+		escape:            '\\',
+		rawSingle:         true,
+		keywords:          wordSet("true false null yes no on off ~"),
+		caseInsensitive:   true,
+		keyColon:          true,
+		yamlKeys:          true,
+	},
+	"sql": {
+		lineComments: []string{"--"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `'"`,
+		keywords: wordSet("add alter and as asc begin between by case check commit constraint create cross database default delete " +
+			"desc distinct drop else end exists foreign from full group having if in index inner insert into is join key left like " +
+			"limit not null offset on or order outer primary references returning right rollback select set table then top " +
+			"transaction truncate union unique update values view when where with"),
+		types: wordSet("int integer bigint smallint tinyint bit decimal numeric float real char varchar nvarchar text date " +
+			"datetime datetime2 timestamp boolean uuid count sum avg min max coalesce"),
+		caseInsensitive: true,
+	},
+	"bash": {
+		lineComments:      []string{"#"},
+		commentNeedsSpace: true,
+		quotes:            `"'`,
+		escape:            '\\',
+		rawSingle:         true,
+		keywords: wordSet("if then else elif fi for while until do done case esac function in return local export " +
+			"readonly declare select break continue exit source set unset shift"),
+		variables: true,
+	},
+	"dockerfile": {
+		lineComments:      []string{"#"},
+		commentNeedsSpace: true,
+		quotes:            `"'`,
+		escape:            '\\',
+		rawSingle:         true,
+		keywords: wordSet("from run cmd label maintainer expose env add copy entrypoint volume user workdir arg " +
+			"onbuild stopsignal healthcheck shell as"),
+		caseInsensitive: true,
+		firstWordOnly:   true,
+		variables:       true,
+	},
+}
+
+var hlAliases = map[string]string{
+	"ps": "powershell", "ps1": "powershell", "psm1": "powershell", "psd1": "powershell", "pwsh": "powershell", "powershell": "powershell",
+	"go": "go", "golang": "go",
+	"json": "json", "jsonc": "json", "jsonl": "json",
+	"yaml": "yaml", "yml": "yaml",
+	"sql": "sql", "mysql": "sql", "postgresql": "sql", "postgres": "sql", "tsql": "sql", "sqlite": "sql",
+	"bash": "bash", "sh": "bash", "shell": "bash", "zsh": "bash", "console": "bash",
+	"dockerfile": "dockerfile", "docker": "dockerfile", "containerfile": "dockerfile",
+}
+
+// HighlightLanguage maps a fence tag or file extension to a supported
+// language name, or "" when there is no highlighter for it.
+func HighlightLanguage(name string) string {
+	return hlAliases[strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "."))]
+}
+
+// LanguageForPath picks the highlighter language for a file name.
+func LanguageForPath(path string) string {
+	base := strings.ToLower(filepath.Base(path))
+	if base == "dockerfile" || base == "containerfile" || strings.HasPrefix(base, "dockerfile.") || strings.HasSuffix(base, ".dockerfile") {
+		return "dockerfile"
+	}
+	return HighlightLanguage(filepath.Ext(base))
+}
+
+// Highlighter colors source code one line at a time; block comments and
+// multi-line strings carry over to the next call.
+type Highlighter struct {
+	lang     *hlLang
+	closeTok string
+	closeClr string
+}
+
+// NewHighlighter returns nil for unsupported languages.
+func NewHighlighter(lang string) *Highlighter {
+	l := hlLanguages[HighlightLanguage(lang)]
+	if l == nil {
+		return nil
+	}
+	return &Highlighter{lang: l}
+}
+
+var yamlKey = regexp.MustCompile(`^(\s*(?:-\s+)?)([^\s#'"{\[][^#:]*?|"[^"]*"|'[^']*')(\s*:)(\s|$)`)
+
+// Line returns line with ANSI colors, or unchanged when color is disabled.
+func (h *Highlighter) Line(line string) string {
+	if h == nil {
+		return line
+	}
+	var b strings.Builder
+	l := h.lang
+	i := 0
+	if h.closeTok != "" {
+		end := strings.Index(line, h.closeTok)
+		if end < 0 {
+			return colorize(h.closeClr, line)
+		}
+		end += len(h.closeTok)
+		b.WriteString(colorize(h.closeClr, line[:end]))
+		h.closeTok = ""
+		i = end
+	} else if l.yamlKeys {
+		if m := yamlKey.FindStringSubmatchIndex(line); m != nil {
+			b.WriteString(line[:m[3]])
+			b.WriteString(colorize(hlVariable, line[m[4]:m[5]]))
+			b.WriteString(line[m[6]:m[7]])
+			i = m[7]
+		}
+	}
+
+	firstWord := true
+	for i < len(line) {
+		rest := line[i:]
+		c := line[i]
+		atBoundary := i == 0 || !isWordByte(line[i-1])
+
+		if open := l.blockComment[0]; open != "" && strings.HasPrefix(rest, open) {
+			if end := strings.Index(rest[len(open):], l.blockComment[1]); end >= 0 {
+				n := len(open) + end + len(l.blockComment[1])
+				b.WriteString(colorize(hlComment, rest[:n]))
+				i += n
+				continue
+			}
+			b.WriteString(colorize(hlComment, rest))
+			h.closeTok, h.closeClr = l.blockComment[1], hlComment
+			break
+		}
+		if lineCommentAt(l, line, i) {
+			b.WriteString(colorize(hlComment, rest))
+			break
+		}
+		if n, open := blockStringAt(l, rest); n > 0 {
+			if end := strings.Index(rest[n:], open[1]); end >= 0 {
+				m := n + end + len(open[1])
+				b.WriteString(colorize(hlString, rest[:m]))
+				i += m
+				continue
+			}
+			b.WriteString(colorize(hlString, rest))
+			h.closeTok, h.closeClr = open[1], hlString
+			break
+		}
+		if strings.IndexByte(l.quotes, c) >= 0 {
+			n := quotedLen(rest, l.escape, l.rawSingle)
+			color := hlString
+			if l.keyColon && strings.HasPrefix(strings.TrimLeft(rest[n:], " \t"), ":") {
+				color = hlVariable
+			}
+			b.WriteString(colorize(color, rest[:n]))
+			i += n
+			firstWord = false
+			continue
+		}
+		if l.variables && c == '$' && i+1 < len(line) {
+			if n := variableLen(rest, l.dashedWords); n > 1 {
+				tok := rest[:n]
+				color := hlVariable
+				if l.keywords[strings.ToLower(tok)] {
+					color = hlKeyword
+				}
+				b.WriteString(colorize(color, tok))
+				i += n
+				firstWord = false
+				continue
+			}
+		}
+		if l.dashedWords && c == '-' && atBoundary && i+1 < len(line) && isLetter(line[i+1]) {
+			n := 1 + wordLen(rest[1:], true)
+			b.WriteString(colorize(hlParam, rest[:n]))
+			i += n
+			continue
+		}
+		if c >= '0' && c <= '9' && atBoundary {
+			n := 1
+			for n < len(rest) && (isWordByte(rest[n]) || rest[n] == '.') {
+				n++
+			}
+			b.WriteString(colorize(hlNumber, rest[:n]))
+			i += n
+			firstWord = false
+			continue
+		}
+		if isLetter(c) || c == '~' && l.keyColon {
+			n := wordLen(rest, l.dashedWords)
+			if c == '~' {
+				n = 1
+			}
+			word := rest[:n]
+			key := word
+			if l.caseInsensitive {
+				key = strings.ToLower(word)
+			}
+			switch {
+			case l.firstWordOnly && !firstWord && key != "as":
+				b.WriteString(word)
+			case l.keywords[key]:
+				b.WriteString(colorize(hlKeyword, word))
+			case l.types[key]:
+				b.WriteString(colorize(hlType, word))
+			case l.dashedWords && strings.Contains(word, "-") && word[0] >= 'A' && word[0] <= 'Z':
+				b.WriteString(colorize(hlType, word)) // Verb-Noun cmdlet
+			default:
+				b.WriteString(word)
+			}
+			i += n
+			firstWord = false
+			continue
+		}
+		b.WriteByte(c)
+		i++
+	}
+	if !supportsColor() {
+		return line
+	}
+	return b.String()
+}
+
+func lineCommentAt(l *hlLang, line string, i int) bool {
+	for _, p := range l.lineComments {
+		if !strings.HasPrefix(line[i:], p) {
+			continue
+		}
+		if !l.commentNeedsSpace || i == 0 || line[i-1] == ' ' || line[i-1] == '\t' {
+			return true
+		}
+	}
+	return false
+}
+
+func blockStringAt(l *hlLang, rest string) (int, [2]string) {
+	for _, s := range l.blockStrings {
+		if strings.HasPrefix(rest, s[0]) {
+			return len(s[0]), s
+		}
+	}
+	return 0, [2]string{}
+}
+
+// quotedLen is the length of the quoted string starting at s[0], or of the
+// rest of the line when it is not closed.
+func quotedLen(s string, escape byte, rawSingle bool) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case escape != 0 && s[i] == escape && !(rawSingle && q == '\''):
+			i++
+		case s[i] == q:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+func variableLen(s string, colon bool) int {
+	if strings.HasPrefix(s, "${") {
+		if end := strings.IndexByte(s, '}'); end > 0 {
+			return end + 1
+		}
+		return len(s)
+	}
+	n := 1
+	for n < len(s) && (isWordByte(s[n]) || colon && s[n] == ':' && n+1 < len(s) && isLetter(s[n+1])) {
+		n++
+	}
+	if n == 1 && len(s) > 1 && strings.IndexByte("?#@*!0123456789_", s[1]) >= 0 {
+		n = 2
+	}
+	return n
+}
+
+func wordLen(s string, dashed bool) int {
+	n := 0
+	for n < len(s) && (isWordByte(s[n]) || dashed && s[n] == '-' && n+1 < len(s) && isLetter(s[n+1])) {
+		n++
+	}
+	return n
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func isWordByte(c byte) bool {
+	return isLetter(c) || c >= '0' && c <= '9'
+}
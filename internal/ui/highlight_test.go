@@ -0,0 +1,110 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHighlightLanguage_Aliases(t *testing.T) {
+	cases := map[string]string{
+		"ps1": "powershell", "PowerShell": "powershell", "golang": "go", ".yml": "yaml",
+		"sh": "bash", "jsonc": "json", "tsql": "sql", "docker": "dockerfile", "rust": "",
+	}
+	for in, want := range cases {
+		if got := HighlightLanguage(in); got != want {
+			t.Errorf("HighlightLanguage(%q) = %q, want %q", in, got, want)
+		}
+	}
+	paths := map[string]string{
+		"scripts/install.ps1": "powershell", "main.go": "go", "Dockerfile": "dockerfile",
+		"build/app.Dockerfile": "dockerfile", "ci.yaml": "yaml", "notes.txt": "",
+	}
+	for in, want := range paths {
+		if got := LanguageForPath(in); got != want {
+			t.Errorf("LanguageForPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if NewHighlighter("rust") != nil {
+		t.Fatal("expected nil highlighter for unsupported language")
+	}
+}
+
+func TestHighlighter_Tokens(t *testing.T) {
+	cases := []struct {
+		lang string
+		line string
+		want []string
+	}{
+		{"go", `func main() { s := "hi" // note`, []string{"\x1b[35mfunc\x1b[0m", "\x1b[32m\"hi\"\x1b[0m", "\x1b[90m// note\x1b[0m"}},
+		{"go", "var n int = 42", []string{"\x1b[35mvar\x1b[0m", "\x1b[34mint\x1b[0m", "\x1b[33m42\x1b[0m"}},
+		{"powershell", `Get-ChildItem -Path $env:TEMP # list`, []string{"\x1b[34mGet-ChildItem\x1b[0m", "\x1b[94m-Path\x1b[0m", "\x1b[36m$env:TEMP\x1b[0m", "\x1b[90m# list\x1b[0m"}},
+		{"powershell", `if ($x -eq $true) { 'a` + "`" + `' }`, []string{"\x1b[35mif\x1b[0m", "\x1b[94m-eq\x1b[0m", "\x1b[35m$true\x1b[0m", "\x1b[32m'a`'\x1b[0m"}},
+		{"json", `{"name": "dm", "ok": true, "n": 3}`, []string{"\x1b[36m\"name\"\x1b[0m", "\x1b[32m\"dm\"\x1b[0m", "\x1b[35mtrue\x1b[0m", "\x1b[33m3\x1b[0m"}},
+		{"yaml", `  - image: "alpine:3" # base`, []string{"\x1b[36mimage\x1b[0m", "\x1b[32m\"alpine:3\"\x1b[0m", "\x1b[90m# base\x1b[0m"}},
+		{"sql", `SELECT count(*) FROM t WHERE a = 'x' -- c`, []string{"\x1b[35mSELECT\x1b[0m", "\x1b[34mcount\x1b[0m", "\x1b[32m'x'\x1b[0m", "\x1b[90m-- c\x1b[0m"}},
+		{"bash", `for f in "$@"; do echo ${f}; done`, []string{"\x1b[35mfor\x1b[0m", "\x1b[32m\"$@\"\x1b[0m", "\x1b[36m${f}\x1b[0m", "\x1b[35mdone\x1b[0m"}},
+		{"bash", `echo $# items#x`, []string{"\x1b[36m$#\x1b[0m", " items#x"}},
+		{"dockerfile", `FROM golang:1.24 AS build`, []string{"\x1b[35mFROM\x1b[0m", "\x1b[35mAS\x1b[0m"}},
+		{"dockerfile", `RUN go build -o /app $FLAGS`, []string{"\x1b[35mRUN\x1b[0m", " go build", "\x1b[36m$FLAGS\x1b[0m"}},
+	}
+	withEnv("NO_COLOR", "", func() {
+		withEnv("TERM", "", func() {
+			for _, tc := range cases {
+				got := NewHighlighter(tc.lang).Line(tc.line)
+				for _, w := range tc.want {
+					if !strings.Contains(got, w) {
+						t.Errorf("%s %q: missing %q in %q", tc.lang, tc.line, w, got)
+					}
+				}
+			}
+		})
+	})
+}
+
+func TestHighlighter_MultiLineState(t *testing.T) {
+	withEnv("NO_COLOR", "", func() {
+		withEnv("TERM", "", func() {
+			h := NewHighlighter("go")
+			h.Line("/* start")
+			if got := h.Line("still comment */ x := 1"); !strings.HasPrefix(got, "\x1b[90mstill comment */\x1b[0m") || !strings.Contains(got, "\x1b[33m1\x1b[0m") {
+				t.Fatalf("block comment not carried over: %q", got)
+			}
+			ps := NewHighlighter("powershell")
+			ps.Line(`$s = @"`)
+			if got := ps.Line("if not a keyword here"); got != "\x1b[32mif not a keyword here\x1b[0m" {
+				t.Fatalf("here-string not carried over: %q", got)
+			}
+			ps.Line(`"@`)
+			if got := ps.Line("if"); got != "\x1b[35mif\x1b[0m" {
+				t.Fatalf("here-string did not end: %q", got)
+			}
+		})
+	})
+}
+
+func TestHighlighter_NoColor(t *testing.T) {
+	withEnv("NO_COLOR", "1", func() {
+		line := `$x = "a" # c`
+		if got := NewHighlighter("powershell").Line(line); got != line {
+			t.Fatalf("expected line unchanged with NO_COLOR, got %q", got)
+		}
+		got := RenderMarkdown("```go\nfunc main() {}\n```")
+		if got != "  go\n  func main() {}" {
+			t.Fatalf("unexpected fenced output %q", got)
+		}
+	})
+}
+
+func TestRenderMarkdown_HighlightsFencedCode(t *testing.T) {
+	withEnv("NO_COLOR", "", func() {
+		withEnv("TERM", "", func() {
+			got := RenderMarkdown("```sql\nSELECT 1\n```\n```\nplain\n```")
+			if !strings.Contains(got, "  \x1b[35mSELECT\x1b[0m \x1b[33m1\x1b[0m") {
+				t.Fatalf("expected highlighted sql, got %q", got)
+			}
+			if !strings.Contains(got, Muted("  plain")) {
+				t.Fatalf("expected untagged fence to stay muted, got %q", got)
+			}
+		})
+	})
+}
@@ -0,0 +1,156 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// livePaneRedraw is the minimum time between two redraws of a pane.
+const livePaneRedraw = 80 * time.Millisecond
+
+// LivePane shows the last lines written to it below a title, redrawn in
+// place on a terminal. Close collapses it into a summary line, optionally
+// keeping the last lines on screen.
+type LivePane struct {
+	mu      sync.Mutex
+	out     io.Writer
+	title   string
+	height  int
+	lines   []string // the last height complete lines
+	partial string
+	count   int // complete lines seen
+	drawn   int // rows the pane occupies on screen
+	last    time.Time
+	closed  bool
+}
+
+// LivePaneSupported reports whether stdout is a terminal that can redraw a
+// pane in place.
+func LivePaneSupported() bool {
+	return term.IsTerminal(int(os.Stdout.Fd())) && strings.ToLower(strings.TrimSpace(os.Getenv("TERM"))) != "dumb"
+}
+
+// NewLivePane starts a pane of height lines on out, which must be a
+// terminal that understands ANSI cursor movement.
+func NewLivePane(out io.Writer, title string, height int) *LivePane {
+	if height < 1 {
+		height = 1
+	}
+	p := &LivePane{out: out, title: title, height: height}
+	p.draw()
+	return p
+}
+
+func (p *LivePane) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return len(b), nil
+	}
+	text := p.partial + strings.ReplaceAll(string(b), "\r\n", "\n")
+	parts := strings.Split(text, "\n")
+	for _, line := range parts[:len(parts)-1] {
+		p.push(line)
+	}
+	p.partial = lastCarriageSegment(parts[len(parts)-1])
+	if time.Since(p.last) >= livePaneRedraw {
+		p.draw()
+	}
+	return len(b), nil
+}
+
+func (p *LivePane) push(line string) {
+	p.count++
+	p.lines = append(p.lines, lastCarriageSegment(line))
+	if len(p.lines) > p.height {
+		p.lines = p.lines[len(p.lines)-p.height:]
+	}
+}
+
+// lastCarriageSegment keeps what a terminal would show of a line that
+// redraws itself with "\r", e.g. a progress counter.
+func lastCarriageSegment(line string) string {
+	if i := strings.LastIndex(line, "\r"); i >= 0 {
+		return line[i+1:]
+	}
+	return line
+}
+
+// Lines reports how many lines were written, counting an unterminated last
+// line.
+func (p *LivePane) Lines() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.partial != "" {
+		return p.count + 1
+	}
+	return p.count
+}
+
+// Close removes the pane and prints summary in its place. With expand the
+// last lines stay on screen above the summary.
+func (p *LivePane) Close(summary string, expand bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	if p.partial != "" {
+		p.push(p.partial)
+		p.partial = ""
+	}
+	p.clear()
+	if expand {
+		for _, line := range p.visible() {
+			fmt.Fprintln(p.out, "  "+Muted("│")+" "+line+"\x1b[0m")
+		}
+	}
+	fmt.Fprintln(p.out, "  "+Muted(summary))
+}
+
+func (p *LivePane) visible() []string {
+	width := TerminalWidth()
+	if width == 0 {
+		width = 80
+	}
+	rows := p.lines
+	if p.partial != "" {
+		rows = append(append([]string(nil), rows...), p.partial)
+		if len(rows) > p.height {
+			rows = rows[len(rows)-p.height:]
+		}
+	}
+	out := make([]string, len(rows))
+	for i, line := range rows {
+		out[i] = truncateVisible(strings.ReplaceAll(line, "\t", "    "), width-4)
+	}
+	return out
+}
+
+func (p *LivePane) clear() {
+	if p.drawn > 0 {
+		fmt.Fprintf(p.out, "\x1b[%dA", p.drawn)
+	}
+	fmt.Fprint(p.out, "\r\x1b[J")
+	p.drawn = 0
+}
+
+func (p *LivePane) draw() {
+	p.clear()
+	var b strings.Builder
+	b.WriteString("  " + Muted("┌ "+p.title) + "\n")
+	rows := p.visible()
+	for _, line := range rows {
+		b.WriteString("  " + Muted("│") + " " + line + "\x1b[0m\n")
+	}
+	fmt.Fprint(p.out, b.String())
+	p.drawn = 1 + len(rows)
+	p.last = time.Now()
+}
@@ -0,0 +1,45 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLivePaneCollapsesToSummary(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("COLUMNS", "40")
+	var out strings.Builder
+	p := NewLivePane(&out, "dc_logs", 2)
+	_, _ = p.Write([]byte("one\ntwo\nthr"))
+	_, _ = p.Write([]byte("ee\nprogress 10%\rprogress 90%"))
+	if got := p.Lines(); got != 4 {
+		t.Fatalf("expected 4 lines, got %d", got)
+	}
+	mark := out.Len()
+	p.Close("▸ 4 lines", false)
+	closing := out.String()[mark:]
+	if !strings.HasSuffix(closing, "  ▸ 4 lines\n") || strings.Contains(closing, "three") {
+		t.Fatalf("expected only the summary after collapsing, got %q", closing)
+	}
+	if !strings.Contains(closing, "\x1b[J") {
+		t.Fatalf("expected the pane to be cleared, got %q", closing)
+	}
+	_, _ = p.Write([]byte("late\n"))
+	if out.Len() != mark+len(closing) {
+		t.Fatal("a closed pane must not draw")
+	}
+}
+
+func TestLivePaneExpandKeepsLastLines(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("COLUMNS", "20")
+	var out strings.Builder
+	p := NewLivePane(&out, "db_doctor", 2)
+	_, _ = p.Write([]byte("ok\nERROR: a very long line that is cut\nprogress 10%\rdone"))
+	mark := out.Len()
+	p.Close("▸ 3 lines", true)
+	closing := out.String()[mark:]
+	if strings.Contains(closing, "ok\n") || !strings.Contains(closing, "│ ERROR: a very l…") || !strings.Contains(closing, "│ done") {
+		t.Fatalf("expected the last two lines, cut to the width, got %q", closing)
+	}
+}
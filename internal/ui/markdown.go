@@ -2,81 +2,377 @@ package ui
 
 import (
 	"regexp"
+	"strconv"
 	"strings"
 )
 
 var (
-	mdBold       = regexp.MustCompile(`\*\*(.+?)\*\*`)
-	mdInlineCode = regexp.MustCompile("`([^`]+)`")
-	mdHeader     = regexp.MustCompile(`^(#{1,3})\s+(.+)$`)
-	mdListItem   = regexp.MustCompile(`^(\s*)[-*]\s+(.+)$`)
-	mdNumbered   = regexp.MustCompile(`^(\s*)\d+\.\s+(.+)$`)
-	mdHR         = regexp.MustCompile(`^---+$`)
+	mdBold        = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	mdStrike      = regexp.MustCompile(`~~(.+?)~~`)
+	mdInlineCode  = regexp.MustCompile("`([^`]+)`")
+	mdCodeSlot    = regexp.MustCompile("\x00(\\d+)\x00")
+	mdImage       = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	mdLink        = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	mdAutoLink    = regexp.MustCompile(`<((?:https?|mailto):[^>\s]+)>`)
+	mdHeader      = regexp.MustCompile(`^(#{1,6})\s+(.+)$`)
+	mdListItem    = regexp.MustCompile(`^(\s*)([-*+]|\d{1,9}[.)])\s+(.*)$`)
+	mdQuote       = regexp.MustCompile(`^\s*((?:>\s?)+)(.*)$`)
+	mdHR          = regexp.MustCompile(`^(-{3,}|\*{3,}|_{3,})$`)
+	mdTableDelim  = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?$`)
+	mdMinColWidth = 3
 )
 
 // RenderMarkdown converts common markdown elements to terminal-friendly output.
-// Syntax markers (**, ##, `, ```) are always stripped.
+// Syntax markers (**, ~~, ##, `, ```) are always stripped.
 // ANSI styling is applied only when the terminal supports color.
 func RenderMarkdown(text string) string {
-	lines := strings.Split(text, "\n")
+	return renderMarkdownWidth(text, TerminalWidth())
+}
+
+func renderMarkdownWidth(text string, width int) string {
+	m := &MarkdownStream{width: width}
 	var out []string
-	inCodeBlock := false
-
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-
-		if strings.HasPrefix(trimmed, "```") {
-			inCodeBlock = !inCodeBlock
-			if inCodeBlock {
-				lang := strings.TrimPrefix(trimmed, "```")
-				if lang != "" {
-					out = append(out, Muted("  "+lang))
-				}
+	for _, line := range strings.Split(text, "\n") {
+		out = m.line(line, out)
+	}
+	out = m.endTable(out)
+	return strings.Join(out, "\n")
+}
+
+// MarkdownStream renders markdown as it arrives. Write returns the rendered
+// lines completed so far and Flush the remainder; tables are held back until
+// their last row so the columns can be aligned.
+type MarkdownStream struct {
+	width   int
+	partial string
+	inCode  bool
+	hl      *Highlighter
+	table   []string
+	pending *string
+	lists   []mdListLevel
+}
+
+type mdListLevel struct {
+	indent  int
+	ordered bool
+	next    int
+}
+
+func NewMarkdownStream() *MarkdownStream {
+	return &MarkdownStream{width: TerminalWidth()}
+}
+
+// Write adds text and returns the newly completed lines, each ending in "\n".
+func (m *MarkdownStream) Write(text string) string {
+	m.partial += text
+	var out []string
+	for {
+		i := strings.IndexByte(m.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := m.partial[:i]
+		m.partial = m.partial[i+1:]
+		out = m.line(line, out)
+	}
+	return joinRendered(out)
+}
+
+// Flush renders whatever is still buffered.
+func (m *MarkdownStream) Flush() string {
+	var out []string
+	if m.partial != "" {
+		out = m.line(m.partial, out)
+		m.partial = ""
+	}
+	return joinRendered(m.endTable(out))
+}
+
+func joinRendered(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (m *MarkdownStream) line(line string, out []string) []string {
+	trimmed := strings.TrimSpace(line)
+	if !m.inCode {
+		switch {
+		case len(m.table) > 0:
+			if isTableRow(trimmed) {
+				m.table = append(m.table, trimmed)
+				return out
 			}
-			continue
+			out = m.endTable(out)
+		case m.pending != nil:
+			header := *m.pending
+			m.pending = nil
+			if mdTableDelim.MatchString(trimmed) && strings.Contains(trimmed, "|") &&
+				len(splitTableCells(header)) == len(splitTableCells(trimmed)) {
+				m.table = []string{strings.TrimSpace(header), trimmed}
+				return out
+			}
+			out = m.block(header, out)
 		}
+		if isTableRow(trimmed) {
+			m.pending = &line
+			return out
+		}
+	}
+	return m.block(line, out)
+}
 
-		if inCodeBlock {
-			out = append(out, Muted("  "+line))
-			continue
+func (m *MarkdownStream) endTable(out []string) []string {
+	if len(m.table) > 0 {
+		out = append(out, m.renderTable()...)
+		m.table = nil
+	}
+	if m.pending != nil {
+		header := *m.pending
+		m.pending = nil
+		out = m.block(header, out)
+	}
+	return out
+}
+
+func (m *MarkdownStream) block(line string, out []string) []string {
+	trimmed := strings.TrimSpace(line)
+
+	if strings.HasPrefix(trimmed, "```") {
+		m.inCode = !m.inCode
+		if m.inCode {
+			m.lists = nil
+			lang := strings.TrimSpace(strings.TrimPrefix(trimmed, "```"))
+			m.hl = NewHighlighter(lang)
+			if lang != "" {
+				out = append(out, Muted("  "+lang))
+			}
 		}
+		return out
+	}
 
-		if mdHR.MatchString(trimmed) {
-			out = append(out, Muted("  ────────────────────"))
-			continue
+	if m.inCode {
+		if m.hl != nil {
+			return append(out, "  "+m.hl.Line(line))
 		}
+		return append(out, Muted("  "+line))
+	}
 
-		if m := mdHeader.FindStringSubmatch(trimmed); len(m) == 3 {
-			out = append(out, bold(Accent(m[2])))
-			continue
+	if trimmed == "" {
+		return append(out, "")
+	}
+
+	if mdHR.MatchString(trimmed) {
+		m.lists = nil
+		return append(out, Muted("  ────────────────────"))
+	}
+
+	if h := mdHeader.FindStringSubmatch(trimmed); len(h) == 3 {
+		m.lists = nil
+		return append(out, bold(Accent(renderInline(h[2]))))
+	}
+
+	if q := mdQuote.FindStringSubmatch(line); len(q) == 3 {
+		m.lists = nil
+		depth := strings.Count(q[1], ">")
+		return append(out, "  "+strings.Repeat(quoteBar()+" ", depth)+renderInline(q[2]))
+	}
+
+	if li := mdListItem.FindStringSubmatch(line); len(li) == 4 {
+		marker := li[2]
+		ordered := marker[0] >= '0' && marker[0] <= '9'
+		start := 0
+		if ordered {
+			start, _ = strconv.Atoi(marker[:len(marker)-1])
+		}
+		depth, n := m.listDepth(indentWidth(li[1]), ordered, start)
+		label := bullet()
+		if ordered {
+			label = strconv.Itoa(n) + "."
 		}
+		return append(out, strings.Repeat("  ", depth+1)+label+" "+renderInline(li[3]))
+	}
 
-		if m := mdListItem.FindStringSubmatch(line); len(m) == 3 {
-			rendered := renderInline(m[2])
-			out = append(out, m[1]+"  "+bullet()+" "+rendered)
-			continue
+	if len(m.lists) > 0 && indentWidth(line) > 0 {
+		// continuation of the last list item
+		return append(out, strings.Repeat("  ", len(m.lists)+1)+renderInline(trimmed))
+	}
+	m.lists = nil
+	return append(out, renderInline(line))
+}
+
+// listDepth places an item with the given indentation in the list stack and
+// returns its nesting depth and, for ordered lists, its number.
+func (m *MarkdownStream) listDepth(indent int, ordered bool, start int) (int, int) {
+	for len(m.lists) > 0 && m.lists[len(m.lists)-1].indent > indent {
+		m.lists = m.lists[:len(m.lists)-1]
+	}
+	if len(m.lists) == 0 || m.lists[len(m.lists)-1].indent < indent {
+		m.lists = append(m.lists, mdListLevel{indent: indent, ordered: ordered, next: start})
+	}
+	top := &m.lists[len(m.lists)-1]
+	if top.ordered != ordered {
+		*top = mdListLevel{indent: indent, ordered: ordered, next: start}
+	}
+	n := top.next
+	top.next++
+	return len(m.lists) - 1, n
+}
+
+func indentWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		switch r {
+		case ' ':
+			w++
+		case '\t':
+			w += 4
+		default:
+			return w
 		}
+	}
+	return w
+}
+
+func isTableRow(trimmed string) bool {
+	return strings.Contains(trimmed, "|") && !strings.HasPrefix(trimmed, ">") && !strings.HasPrefix(trimmed, "```")
+}
+
+// splitTableCells splits a GFM table row on unescaped pipes.
+func splitTableCells(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+		row = row[:len(row)-1]
+	}
+	var cells []string
+	var cur strings.Builder
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cur.WriteByte('|')
+			i++
+		case row[i] == '|':
+			cells = append(cells, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(row[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cur.String()))
+}
+
+func tableAligns(delim string) []string {
+	cells := splitTableCells(delim)
+	aligns := make([]string, len(cells))
+	for i, c := range cells {
+		left, right := strings.HasPrefix(c, ":"), strings.HasSuffix(c, ":")
+		switch {
+		case left && right:
+			aligns[i] = "center"
+		case right:
+			aligns[i] = "right"
+		default:
+			aligns[i] = "left"
+		}
+	}
+	return aligns
+}
 
-		if m := mdNumbered.FindStringSubmatch(line); len(m) == 3 {
-			rendered := renderInline(m[2])
-			out = append(out, m[1]+"  "+rendered)
+func (m *MarkdownStream) renderTable() []string {
+	aligns := tableAligns(m.table[1])
+	cols := len(aligns)
+	rows := make([][]string, 0, len(m.table)-1)
+	for i, raw := range m.table {
+		if i == 1 {
 			continue
 		}
+		cells := splitTableCells(raw)
+		row := make([]string, cols)
+		for c := 0; c < cols && c < len(cells); c++ {
+			row[c] = renderInline(cells[c])
+			if i == 0 {
+				row[c] = bold(row[c])
+			}
+		}
+		rows = append(rows, row)
+	}
 
-		out = append(out, renderInline(line))
+	widths := make([]int, cols)
+	for _, row := range rows {
+		for c, cell := range row {
+			widths[c] = max(widths[c], visibleWidth(cell), 1)
+		}
+	}
+	if m.width > 0 {
+		shrinkColumns(widths, m.width-2-3*(cols-1))
 	}
 
-	return strings.Join(out, "\n")
+	sep, cross, dash := " | ", "-+-", "-"
+	if supportsColor() {
+		sep, cross, dash = Muted(" │ "), Muted("─┼─"), "─"
+	}
+	render := func(row []string) string {
+		parts := make([]string, cols)
+		for c, cell := range row {
+			parts[c] = padVisible(truncateVisible(cell, widths[c]), widths[c], aligns[c])
+		}
+		return strings.TrimRight("  "+strings.Join(parts, sep), " ")
+	}
+	out := []string{render(rows[0])}
+	rules := make([]string, cols)
+	for c, w := range widths {
+		rules[c] = strings.Repeat(dash, w)
+	}
+	if supportsColor() {
+		out = append(out, "  "+Muted(strings.Join(rules, "─┼─")))
+	} else {
+		out = append(out, "  "+strings.Join(rules, cross))
+	}
+	for _, row := range rows[1:] {
+		out = append(out, render(row))
+	}
+	return out
+}
+
+// shrinkColumns narrows the widest columns until their sum fits in budget,
+// keeping every column at least mdMinColWidth wide.
+func shrinkColumns(widths []int, budget int) {
+	total := 0
+	for _, w := range widths {
+		total += w
+	}
+	for total > budget {
+		widest := 0
+		for c, w := range widths {
+			if w > widths[widest] {
+				widest = c
+			}
+		}
+		if widths[widest] <= mdMinColWidth {
+			return
+		}
+		widths[widest]--
+		total--
+	}
 }
 
 func renderInline(line string) string {
+	var codes []string
 	line = mdInlineCode.ReplaceAllStringFunc(line, func(match string) string {
-		inner := mdInlineCode.FindStringSubmatch(match)
-		if len(inner) == 2 {
-			return Muted(inner[1])
-		}
-		return match
+		codes = append(codes, match[1:len(match)-1])
+		return "\x00" + strconv.Itoa(len(codes)-1) + "\x00"
+	})
+	line = mdImage.ReplaceAllStringFunc(line, func(match string) string {
+		inner := mdImage.FindStringSubmatch(match)
+		return renderLink(inner[1], inner[2])
 	})
+	line = mdLink.ReplaceAllStringFunc(line, func(match string) string {
+		inner := mdLink.FindStringSubmatch(match)
+		return renderLink(inner[1], inner[2])
+	})
+	line = mdAutoLink.ReplaceAllString(line, "$1")
 	line = mdBold.ReplaceAllStringFunc(line, func(match string) string {
 		inner := mdBold.FindStringSubmatch(match)
 		if len(inner) == 2 {
@@ -84,9 +380,33 @@ func renderInline(line string) string {
 		}
 		return match
 	})
+	line = mdStrike.ReplaceAllStringFunc(line, func(match string) string {
+		inner := mdStrike.FindStringSubmatch(match)
+		if len(inner) == 2 {
+			return strike(inner[1])
+		}
+		return match
+	})
+	if len(codes) > 0 {
+		line = mdCodeSlot.ReplaceAllStringFunc(line, func(match string) string {
+			i, err := strconv.Atoi(match[1 : len(match)-1])
+			if err != nil || i >= len(codes) {
+				return match
+			}
+			return Muted(codes[i])
+		})
+	}
 	return line
 }
 
+func renderLink(text, url string) string {
+	text = strings.TrimSpace(text)
+	if text == "" || text == url {
+		return url
+	}
+	return text + " " + Muted("("+url+")")
+}
+
 func bold(text string) string {
 	if !supportsColor() {
 		return text
@@ -94,9 +414,23 @@ func bold(text string) string {
 	return "\x1b[1m" + text + "\x1b[22m"
 }
 
+func strike(text string) string {
+	if !supportsColor() {
+		return text
+	}
+	return "\x1b[9m" + text + "\x1b[29m"
+}
+
 func bullet() string {
 	if !supportsColor() {
 		return "-"
 	}
 	return "•"
 }
+
+func quoteBar() string {
+	if !supportsColor() {
+		return "|"
+	}
+	return Muted("│")
+}
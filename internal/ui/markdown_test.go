@@ -153,3 +153,123 @@ func TestRenderMarkdown_PlainText(t *testing.T) {
 		})
 	})
 }
+
+func TestRenderMarkdown_Golden(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "table alignment",
+			in:   "| Name | Size | State |\n|:-----|-----:|:-----:|\n| a.txt | 12 | ok |\n| **big.iso** | 4096 | `new` |",
+			want: "  Name    | Size | State\n" +
+				"  --------+------+------\n" +
+				"  a.txt   |   12 |  ok\n" +
+				"  big.iso | 4096 |  new",
+		},
+		{
+			name: "table wide runes",
+			in:   "| 名前 | n |\n|---|---|\n| 東京 | 1 |\n| x | 22 |",
+			want: "  名前 | n\n" +
+				"  -----+---\n" +
+				"  東京 | 1\n" +
+				"  x    | 22",
+		},
+		{
+			name: "table truncated to width",
+			in:   "| Path | Note |\n|---|---|\n| /very/long/path/to/some/file.txt | short |",
+			want: "  Path               | Note\n" +
+				"  -------------------+------\n" +
+				"  /very/long/path/t… | short",
+		},
+		{
+			name: "pipe line without delimiter",
+			in:   "a | b\nnext",
+			want: "a | b\nnext",
+		},
+		{
+			name: "blockquote",
+			in:   "> quoted **text**\n>> nested",
+			want: "  | quoted text\n  | | nested",
+		},
+		{
+			name: "nested ordered list",
+			in:   "1. first\n1. second\n   1. sub a\n   1. sub b\n     more\n3. third\n- bullet",
+			want: "  1. first\n" +
+				"  2. second\n" +
+				"    1. sub a\n" +
+				"    2. sub b\n" +
+				"      more\n" +
+				"  3. third\n" +
+				"  - bullet",
+		},
+		{
+			name: "links and strikethrough",
+			in:   "See [docs](https://example.com/docs), <https://x.dev> and ~~old~~ new ![logo](l.png)",
+			want: "See docs (https://example.com/docs), https://x.dev and old new logo (l.png)",
+		},
+		{
+			name: "link inside code span",
+			in:   "Run `[a](b)` ~~now~~",
+			want: "Run [a](b) now",
+		},
+		{
+			name: "headers and rules",
+			in:   "###### Deep\n***\ntext",
+			want: "Deep\n  ────────────────────\ntext",
+		},
+	}
+	withEnv("NO_COLOR", "1", func() {
+		for _, tc := range cases {
+			got := renderMarkdownWidth(tc.in, 28)
+			if got != tc.want {
+				t.Errorf("%s:\ngot:\n%s\nwant:\n%s", tc.name, got, tc.want)
+			}
+		}
+	})
+}
+
+func TestRenderMarkdown_ColorTableAndStrike(t *testing.T) {
+	withEnv("NO_COLOR", "", func() {
+		withEnv("TERM", "", func() {
+			got := renderMarkdownWidth("| a | b |\n|---|---|\n| ~~x~~ | y |", 0)
+			if !strings.Contains(got, "┼") || !strings.Contains(got, "│") {
+				t.Fatalf("expected box drawing separators, got %q", got)
+			}
+			if !strings.Contains(got, "\x1b[9mx\x1b[29m") {
+				t.Fatalf("expected strikethrough escape, got %q", got)
+			}
+		})
+	})
+}
+
+func TestMarkdownStream_MatchesRenderMarkdown(t *testing.T) {
+	in := "## Plan\n\nSteps:\n1. scan `C:\\src`\n2. rename\n   - keep ~~old~~\n\n| File | Bytes |\n|---|--:|\n| a | 1 |\n| b | 20 |\n\n> done\n```go\nfmt.Println(1)\n```\nbye"
+	withEnv("NO_COLOR", "1", func() {
+		want := renderMarkdownWidth(in, 40) + "\n"
+		for _, size := range []int{1, 3, 7, 64} {
+			s := &MarkdownStream{width: 40}
+			var got strings.Builder
+			for i := 0; i < len(in); i += size {
+				got.WriteString(s.Write(in[i:min(i+size, len(in))]))
+			}
+			got.WriteString(s.Flush())
+			if got.String() != want {
+				t.Fatalf("chunk size %d:\ngot:\n%q\nwant:\n%q", size, got.String(), want)
+			}
+		}
+	})
+}
+
+func TestMarkdownStream_HoldsTableUntilComplete(t *testing.T) {
+	withEnv("NO_COLOR", "1", func() {
+		s := &MarkdownStream{width: 40}
+		if out := s.Write("intro\n| a | b |\n|---|---|\n| 1 | 2 |\n"); out != "intro\n" {
+			t.Fatalf("expected only the intro before the table ends, got %q", out)
+		}
+		if out := s.Write("after\n"); !strings.HasPrefix(out, "  a | b\n") || !strings.HasSuffix(out, "after\n") {
+			t.Fatalf("expected table then text, got %q", out)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package ui
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/term"
+)
+
+// TerminalWidth returns the width of stdout in columns: COLUMNS when set,
+// the terminal size otherwise, and 0 when unknown (output is not truncated).
+func TerminalWidth() int {
+	if v := strings.TrimSpace(os.Getenv("COLUMNS")); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	if w, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && w > 0 {
+		return w
+	}
+	return 0
+}
+
+// wideRanges are the East Asian wide and fullwidth blocks (plus emoji) that
+// take two terminal columns.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F}, {0x2E80, 0x303E}, {0x3041, 0x33FF}, {0x3400, 0x4DBF},
+	{0x4E00, 0x9FFF}, {0xA000, 0xA4CF}, {0xAC00, 0xD7A3}, {0xF900, 0xFAFF},
+	{0xFE30, 0xFE4F}, {0xFF00, 0xFF60}, {0xFFE0, 0xFFE6}, {0x1F300, 0x1F64F},
+	{0x1F900, 0x1F9FF}, {0x20000, 0x2FFFD}, {0x30000, 0x3FFFD},
+}
+
+func runeWidth(r rune) int {
+	if r == 0 || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) || unicode.Is(unicode.Cf, r) {
+		return 0
+	}
+	if r < 0x1100 {
+		return 1
+	}
+	for _, rg := range wideRanges {
+		if r >= rg[0] && r <= rg[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// visibleWidth is the number of terminal columns s occupies, ignoring ANSI
+// escape sequences.
+func visibleWidth(s string) int {
+	w := 0
+	inEsc := false
+	for _, r := range s {
+		switch {
+		case inEsc:
+			if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+				inEsc = false
+			}
+		case r == '\x1b':
+			inEsc = true
+		default:
+			w += runeWidth(r)
+		}
+	}
+	return w
+}
+
+// truncateVisible shortens s to at most width columns, ending it with "…".
+// Escape sequences are kept and styling is reset after a cut.
+func truncateVisible(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if visibleWidth(s) <= width {
+		return s
+	}
+	var b strings.Builder
+	w := 0
+	inEsc := false
+	styled := false
+	for _, r := range s {
+		if inEsc {
+			b.WriteRune(r)
+			if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+				inEsc = false
+			}
+			continue
+		}
+		if r == '\x1b' {
+			inEsc = true
+			styled = true
+			b.WriteRune(r)
+			continue
+		}
+		rw := runeWidth(r)
+		if w+rw > width-1 {
+			break
+		}
+		b.WriteRune(r)
+		w += rw
+	}
+	b.WriteString("…")
+	if styled {
+		b.WriteString("\x1b[0m")
+	}
+	return b.String()
+}
+
+func padVisible(s string, width int, align string) string {
+	gap := width - visibleWidth(s)
+	if gap <= 0 {
+		return s
+	}
+	switch align {
+	case "right":
+		return strings.Repeat(" ", gap) + s
+	case "center":
+		left := gap / 2
+		return strings.Repeat(" ", left) + s + strings.Repeat(" ", gap-left)
+	}
+	return s + strings.Repeat(" ", gap)
+}
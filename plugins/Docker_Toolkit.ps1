@@ -5,6 +5,12 @@
 # Safety: Non-destructive defaults. Down stops containers but keeps volumes.
 # Entry point: dc_*
 #
+# ---
+# version: 1.0.0
+# requires: docker
+# risk: dc_kill=high, dc_down=medium
+# ---
+#
 # FUNCTIONS
 #   dc_ps
 #   dc_file
@@ -5,6 +5,11 @@
 # Safety: Read-only — no destructive operations.
 # Entry point: stibs_app_*
 #
+# ---
+# version: 1.0.0
+# requires: docker
+# ---
+#
 # FUNCTIONS
 #   stibs_app_health
 #   stibs_app_logs
@@ -5,6 +5,12 @@
 # Safety: Read-only defaults. Import requires -Force or confirmation.
 # Entry point: stibs_db_*
 #
+# ---
+# version: 1.0.0
+# requires: docker
+# risk: stibs_db_import_dump=high
+# ---
+#
 # FUNCTIONS
 #   stibs_db_status
 #   stibs_db_query
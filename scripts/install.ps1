@@ -48,7 +48,7 @@ $agentTarget = Join-Path $InstallDir "dm.agent.json"
 if ((Test-Path $agentExampleSource) -and (-not (Test-Path $agentTarget))) {
   Copy-Item -Force $agentExampleSource $agentTarget
   Write-Host "Created agent config from example: $agentTarget"
-  Write-Host "Update dm.agent.json with your OpenAI API key or set OPENAI_API_KEY."
+  Write-Host "Run 'dm init' to detect Ollama, test your OpenAI key and pick a default provider."
 }
 
 $pluginsSource = Join-Path $scriptDir "plugins"
@@ -8,6 +8,7 @@ import (
 	"sort"
 	"strings"
 
+	"cli/internal/journal"
 	"cli/internal/ui"
 )
 
@@ -80,10 +81,22 @@ func showEmptyDirs(base string) ([]string, int) {
 }
 
 func removeEmptyDirs(dirs []string) int {
-	for _, d := range dirs {
-		_ = os.Remove(d)
+	entries := make([]journal.Entry, len(dirs))
+	for i, d := range dirs {
+		entries[i] = journal.Entry{Kind: journal.KindRemoveDir, OldPath: d}
+	}
+	op, err := journal.Begin("clean", entries)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return 1
+	}
+	for i, d := range dirs {
+		if os.Remove(d) == nil {
+			op.MarkDone(i)
+		}
 	}
 	fmt.Println("Done.")
+	finishJournalOp(op, nil)
 	return 0
 }
 
@@ -137,10 +137,9 @@ func printFileDiff(fileA, fileB string) int {
 		return 1
 	}
 
-	out, err := exec.Command("git", "diff", "--no-index", "--", fileA, fileB).CombinedOutput()
-	result := strings.TrimSpace(string(out))
-	if err != nil && result == "" {
-		fmt.Printf("Error: could not diff files: %s\n", err)
+	result, err := fileDiff("", fileA, fileB)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
 		return 1
 	}
 
@@ -153,6 +152,19 @@ func printFileDiff(fileA, fileB string) int {
 	return 0
 }
 
+// fileDiff returns the unified diff of two files run from dir ("" when the
+// files are identical).
+func fileDiff(dir, fileA, fileB string) (string, error) {
+	cmd := exec.Command("git", "diff", "--no-index", "--", fileA, fileB)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	result := strings.TrimSpace(string(out))
+	if err != nil && result == "" {
+		return "", fmt.Errorf("could not diff files: %s", err)
+	}
+	return result, nil
+}
+
 func isGitRepo() bool {
 	cmd := exec.Command("git", "rev-parse", "--is-inside-work-tree")
 	out, err := cmd.Output()
@@ -0,0 +1,285 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cli/internal/ui"
+)
+
+const (
+	fetchDefaultTimeout  = 15 * time.Second
+	fetchMaxTimeout      = 120 * time.Second
+	fetchDefaultMaxBytes = 256 * 1024 // 256 KB
+	fetchMaxBytes        = 2 * 1024 * 1024
+)
+
+var (
+	fetchDropBlocks = regexp.MustCompile(`(?is)<(script|style|noscript|head|svg|template)\b.*?</(script|style|noscript|head|svg|template)\s*>`)
+	fetchComments   = regexp.MustCompile(`(?s)<!--.*?-->`)
+	fetchBreakTags  = regexp.MustCompile(`(?i)<\s*(br|/p|/div|/h[1-6]|/tr|/table|/section|/article|/header|/footer|/ul|/ol|hr)\b[^>]*>`)
+	fetchItemTags   = regexp.MustCompile(`(?i)<\s*li\b[^>]*>`)
+	fetchCellTags   = regexp.MustCompile(`(?i)<\s*/t[dh]\s*>`)
+	fetchAnyTag     = regexp.MustCompile(`(?s)<[^>]*>`)
+	fetchSpaces     = regexp.MustCompile(`[ \t\f\v\r]+`)
+	fetchBlankLines = regexp.MustCompile(`\n\s*\n\s*\n+`)
+)
+
+type fetchRequest struct {
+	URL      string
+	Method   string
+	Headers  map[string]string
+	Body     string
+	Timeout  time.Duration
+	MaxBytes int64
+	Raw      bool
+}
+
+func RunFetch(r *bufio.Reader) int {
+	rawURL := prompt(r, "URL", "")
+	if strings.TrimSpace(rawURL) == "" {
+		fmt.Println(ui.Error("Error:"), "URL is required.")
+		return 1
+	}
+	method := prompt(r, "Method", http.MethodGet)
+	req := fetchRequest{URL: rawURL, Method: method, Timeout: fetchDefaultTimeout, MaxBytes: fetchDefaultMaxBytes}
+	if !isSafeFetchMethod(req.Method) {
+		req.Body = prompt(r, "Body (optional)", "")
+	}
+	return runFetch(context.Background(), req)
+}
+
+func RunFetchAuto(baseDir string, params map[string]string) int {
+	return RunFetchAutoDetailed(context.Background(), baseDir, params).Code
+}
+
+func RunFetchAutoDetailed(ctx context.Context, _ string, params map[string]string) AutoRunResult {
+	req, err := fetchRequestFromParams(params)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return AutoRunResult{Code: 1}
+	}
+	return AutoRunResult{Code: runFetch(ctx, req)}
+}
+
+func fetchRequestFromParams(params map[string]string) (fetchRequest, error) {
+	req := fetchRequest{
+		URL:      strings.TrimSpace(params["url"]),
+		Method:   strings.ToUpper(strings.TrimSpace(params["method"])),
+		Body:     params["body"],
+		Timeout:  fetchDefaultTimeout,
+		MaxBytes: fetchDefaultMaxBytes,
+	}
+	if req.URL == "" {
+		return req, fmt.Errorf("url is required")
+	}
+	if req.Method == "" {
+		req.Method = http.MethodGet
+	}
+	headers, err := parseFetchHeaders(params["headers"])
+	if err != nil {
+		return req, err
+	}
+	req.Headers = headers
+	if v := strings.TrimSpace(params["timeout"]); v != "" {
+		d, err := parseFetchTimeout(v)
+		if err != nil {
+			return req, err
+		}
+		req.Timeout = d
+	}
+	if req.Timeout > fetchMaxTimeout {
+		req.Timeout = fetchMaxTimeout
+	}
+	if v := strings.TrimSpace(params["max_bytes"]); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 {
+			return req, fmt.Errorf("invalid max_bytes %q", v)
+		}
+		req.MaxBytes = n
+	}
+	if req.MaxBytes > fetchMaxBytes {
+		req.MaxBytes = fetchMaxBytes
+	}
+	switch strings.ToLower(strings.TrimSpace(params["raw"])) {
+	case "1", "true", "yes", "y":
+		req.Raw = true
+	}
+	return req, nil
+}
+
+// parseFetchHeaders accepts a JSON object or "Name: value" pairs separated
+// by newlines or semicolons.
+func parseFetchHeaders(raw string) (map[string]string, error) {
+	raw = strings.TrimSpace(raw)
+	out := map[string]string{}
+	if raw == "" {
+		return out, nil
+	}
+	if strings.HasPrefix(raw, "{") {
+		var m map[string]any
+		if err := json.Unmarshal([]byte(raw), &m); err != nil {
+			return nil, fmt.Errorf("invalid headers JSON: %w", err)
+		}
+		for k, v := range m {
+			out[k] = fmt.Sprint(v)
+		}
+		return out, nil
+	}
+	for _, line := range strings.FieldsFunc(raw, func(r rune) bool { return r == '\n' || r == ';' }) {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid header %q (use Name: value)", strings.TrimSpace(line))
+		}
+		out[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return out, nil
+}
+
+func parseFetchTimeout(v string) (time.Duration, error) {
+	if n, err := strconv.Atoi(v); err == nil && n > 0 {
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q (seconds or duration like 30s)", v)
+	}
+	return d, nil
+}
+
+func isSafeFetchMethod(method string) bool {
+	switch strings.ToUpper(strings.TrimSpace(method)) {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func runFetch(ctx context.Context, req fetchRequest) int {
+	u, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fmt.Printf("Error: invalid URL %q (http or https required)\n", req.URL)
+		return 1
+	}
+	method := strings.ToUpper(strings.TrimSpace(req.Method))
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if req.Body != "" {
+		body = strings.NewReader(req.Body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return 1
+	}
+	httpReq.Header.Set("User-Agent", "dm-fetch")
+	if req.Body != "" && looksLikeJSON(req.Body) {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range req.Headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	client := &http.Client{Timeout: req.Timeout}
+	t0 := time.Now()
+	res, err := client.Do(httpReq)
+	if err != nil {
+		fmt.Printf("Error: %s %s failed: %v\n", method, u.Redacted(), err)
+		return 1
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(res.Body, req.MaxBytes+1))
+	if err != nil {
+		fmt.Println("Error: reading response:", err)
+		return 1
+	}
+	truncated := int64(len(data)) > req.MaxBytes
+	if truncated {
+		data = data[:req.MaxBytes]
+	}
+
+	contentType := res.Header.Get("Content-Type")
+	fmt.Printf("%s %s -> %s (%s, %s, %dms)\n", method, u.Redacted(), res.Status,
+		fetchContentLabel(contentType), formatReadSize(int64(len(data))), time.Since(t0).Milliseconds())
+	if loc := res.Header.Get("Location"); loc != "" {
+		fmt.Printf("Location: %s\n", loc)
+	}
+	if method != http.MethodHead {
+		fmt.Println()
+		fmt.Println(formatFetchBody(data, contentType, req.Raw))
+	}
+	if truncated {
+		fmt.Printf("\n... response truncated at %s\n", formatReadSize(req.MaxBytes))
+	}
+	if res.StatusCode >= 400 {
+		return 1
+	}
+	return 0
+}
+
+func fetchContentLabel(contentType string) string {
+	ct, _, _ := strings.Cut(contentType, ";")
+	ct = strings.TrimSpace(ct)
+	if ct == "" {
+		return "unknown type"
+	}
+	return ct
+}
+
+func formatFetchBody(data []byte, contentType string, raw bool) string {
+	ct := strings.ToLower(contentType)
+	text := string(data)
+	if raw {
+		return text
+	}
+	switch {
+	case strings.Contains(ct, "json") || (ct == "" && looksLikeJSON(text)):
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, bytes.TrimSpace(data), "", "  "); err == nil {
+			return buf.String()
+		}
+		return text
+	case strings.Contains(ct, "html") || (ct == "" && strings.Contains(strings.ToLower(text), "<html")):
+		return htmlToText(text)
+	}
+	return text
+}
+
+func looksLikeJSON(s string) bool {
+	t := strings.TrimSpace(s)
+	return (strings.HasPrefix(t, "{") || strings.HasPrefix(t, "[")) && json.Valid([]byte(t))
+}
+
+// htmlToText extracts readable text from an HTML document: scripts, styles
+// and comments are dropped, block tags become line breaks.
+func htmlToText(doc string) string {
+	s := fetchComments.ReplaceAllString(doc, "")
+	s = fetchDropBlocks.ReplaceAllString(s, "")
+	s = fetchItemTags.ReplaceAllString(s, "\n- ")
+	s = fetchCellTags.ReplaceAllString(s, " | ")
+	s = fetchBreakTags.ReplaceAllString(s, "\n")
+	s = fetchAnyTag.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = strings.ReplaceAll(s, "\u00a0", " ")
+	s = fetchSpaces.ReplaceAllString(s, " ")
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(l)
+	}
+	s = strings.Join(lines, "\n")
+	s = fetchBlankLines.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
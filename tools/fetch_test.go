@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTMLToText(t *testing.T) {
+	doc := `<html><head><title>x</title><style>p{color:red}</style></head>
+<body><script>alert(1)</script><h1>Title</h1><p>Hello &amp; <b>welcome</b></p>
+<!-- note --><ul><li>one</li><li>two</li></ul></body></html>`
+	got := htmlToText(doc)
+	want := "Title\nHello & welcome\n\n- one\n- two"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestFormatFetchBody_JSON(t *testing.T) {
+	got := formatFetchBody([]byte(`{"a":1,"b":[true]}`), "application/json; charset=utf-8", false)
+	want := "{\n  \"a\": 1,\n  \"b\": [\n    true\n  ]\n}"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if raw := formatFetchBody([]byte(`{"a":1}`), "application/json", true); raw != `{"a":1}` {
+		t.Fatalf("raw body changed: %q", raw)
+	}
+}
+
+func TestParseFetchHeaders(t *testing.T) {
+	h, err := parseFetchHeaders("Accept: text/plain; X-Token: a:b")
+	if err != nil || h["Accept"] != "text/plain" || h["X-Token"] != "a:b" {
+		t.Fatalf("unexpected headers %v (err %v)", h, err)
+	}
+	h, err = parseFetchHeaders(`{"Accept":"application/json"}`)
+	if err != nil || h["Accept"] != "application/json" {
+		t.Fatalf("unexpected JSON headers %v (err %v)", h, err)
+	}
+	if _, err := parseFetchHeaders("nocolon"); err == nil {
+		t.Fatal("expected error for malformed header")
+	}
+}
+
+func TestRunFetchCapture(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/big":
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+		case "/missing":
+			http.NotFound(w, r)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"method":"` + r.Method + `","h":"` + r.Header.Get("X-Test") + `"}`))
+		}
+	}))
+	defer srv.Close()
+
+	res := RunByNameWithParamsCapture(context.Background(), "", "fetch", map[string]string{"url": srv.URL + "/ok", "headers": "X-Test: yes"})
+	if res.Code != 0 || !strings.Contains(res.Output, `"method": "GET"`) || !strings.Contains(res.Output, `"h": "yes"`) {
+		t.Fatalf("unexpected result %d: %q", res.Code, res.Output)
+	}
+
+	res = RunByNameWithParamsCapture(context.Background(), "", "curl", map[string]string{"url": srv.URL + "/big", "max_bytes": "10"})
+	if res.Code != 0 || !strings.Contains(res.Output, "xxxxxxxxxx\n") || strings.Contains(res.Output, "xxxxxxxxxxx") ||
+		!strings.Contains(res.Output, "truncated") {
+		t.Fatalf("expected truncated body, got %q", res.Output)
+	}
+
+	res = RunByNameWithParamsCapture(context.Background(), "", "fetch", map[string]string{"url": srv.URL + "/missing"})
+	if res.Code != 1 || !strings.Contains(res.Output, "404") {
+		t.Fatalf("expected 404 failure, got %d: %q", res.Code, res.Output)
+	}
+
+	res = RunByNameWithParamsCapture(context.Background(), "", "fetch", map[string]string{"url": "file:///etc/passwd"})
+	if res.Code != 1 {
+		t.Fatalf("expected non-http URL to fail, got %q", res.Output)
+	}
+}
+
+func TestToolRisk_Fetch(t *testing.T) {
+	if risk, _ := ToolRisk("fetch", map[string]string{"url": "https://example.com"}); risk != "low" {
+		t.Fatalf("GET risk = %s, want low", risk)
+	}
+	if risk, _ := ToolRisk("http", map[string]string{"method": "head"}); risk != "low" {
+		t.Fatalf("HEAD risk = %s, want low", risk)
+	}
+	risk, note := ToolRisk("fetch", map[string]string{"method": "delete"})
+	if risk != "high" || !strings.Contains(note, "DELETE") {
+		t.Fatalf("DELETE risk = %s (%s), want high", risk, note)
+	}
+}
@@ -0,0 +1,34 @@
+package tools
+
+import (
+	"fmt"
+
+	"cli/internal/journal"
+	"cli/internal/renamer"
+	"cli/internal/ui"
+)
+
+// applyRenamePlan records plan in the undo journal and then applies it.
+func applyRenamePlan(plan []renamer.PlanItem) error {
+	entries := make([]journal.Entry, len(plan))
+	for i, item := range plan {
+		entries[i] = journal.Entry{Kind: journal.KindRename, OldPath: item.OldPath, NewPath: item.NewPath}
+	}
+	op, err := journal.Begin("rename", entries)
+	if err != nil {
+		return err
+	}
+	applyErr := renamer.ApplyPlanProgress(plan, op.MarkDone)
+	finishJournalOp(op, applyErr)
+	return applyErr
+}
+
+func finishJournalOp(op *journal.Op, applyErr error) {
+	if err := op.Finish(applyErr); err != nil {
+		fmt.Println(ui.Warn("Warning:"), "cannot update undo journal:", err)
+		return
+	}
+	if op.Status == journal.StatusApplied || op.Status == journal.StatusPartial {
+		fmt.Println(ui.Muted(fmt.Sprintf("Journal: %s (undo with: dm undo %s)", op.ID, op.ID)))
+	}
+}
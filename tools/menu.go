@@ -3,6 +3,7 @@ package tools
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -20,6 +21,9 @@ type ToolDescriptor struct {
 	AgentArgs string
 	RiskLevel string
 	RiskNote  string
+	// SelfConfirm tools show what they will do and ask before acting, so
+	// the agent skips its own confirmation for them.
+	SelfConfirm bool
 }
 
 type AutoRunResult struct {
@@ -39,6 +43,9 @@ var ToolRegistry = []ToolDescriptor{
 	{Key: "f", Name: "read", Synopsis: "Read file contents or list directory", Aliases: []string{"cat", "view"}, AgentArgs: "path (required), offset (start line, default 1), limit (max lines, default 100)", RiskLevel: "low", RiskNote: "read/inspect operation"},
 	{Key: "g", Name: "grep", Synopsis: "Search file contents for a pattern", Aliases: []string{"find", "rg"}, AgentArgs: "pattern (required), base (directory, default cwd), ext (filter extension e.g. go/ps1), limit (max results, default 20), case_sensitive (default false)", RiskLevel: "low", RiskNote: "read/inspect operation"},
 	{Key: "d", Name: "diff", Synopsis: "Show git changes or compare two files", Aliases: []string{"changes"}, AgentArgs: "mode (git|files, default git), limit (max diff lines, default 80), file_a (for files mode), file_b (for files mode)", RiskLevel: "low", RiskNote: "read/inspect operation"},
+	{Key: "w", Name: "fetch", Synopsis: "Fetch a URL over HTTP (HTML as text, JSON pretty-printed)", Aliases: []string{"http", "curl"}, AgentArgs: "url (required), method (GET|HEAD|POST|PUT|PATCH|DELETE, default GET), headers (Name: value; ... or JSON object), body, timeout (seconds, default 15), max_bytes (default 262144), raw (true to keep HTML as-is)", RiskLevel: "low", RiskNote: "read-only HTTP request"},
+	{Key: "t", Name: "shell", Synopsis: "Run a shell command (allow/deny lists, timeout, output cap)", Aliases: []string{"sh", "exec", "run_shell"}, AgentArgs: "command (required), cwd (directory inside the workspace, default cwd), timeout (seconds, default 60), max_bytes (output cap, default 65536)", RiskLevel: "medium", RiskNote: "runs a shell command"},
+	{Key: "p", Name: "write", Synopsis: "Write or patch a file (diff preview, confirmation, undo journal)", Aliases: []string{"patch", "edit"}, AgentArgs: "path (required), content (full new file content), search (exact text to replace, must match once), replace (replacement for search), hunks (JSON list of {\"search\",\"replace\"} for several edits), create (true to allow a new file)", RiskLevel: "medium", RiskNote: "writes a file after diff preview and confirmation", SelfConfirm: true},
 }
 
 func RunMenu(baseDir string) int {
@@ -87,15 +94,15 @@ func RunByName(baseDir, name string) int {
 	return RunByNameWithReader(baseDir, name, bufio.NewReader(os.Stdin))
 }
 
-func RunByNameWithParams(baseDir, name string, params map[string]string) int {
-	return RunByNameWithParamsDetailed(baseDir, name, params).Code
+func RunByNameWithParams(ctx context.Context, baseDir, name string, params map[string]string) int {
+	return RunByNameWithParamsDetailed(ctx, baseDir, name, params).Code
 }
 
-func RunByNameWithParamsCapture(baseDir, name string, params map[string]string) AutoRunResult {
+func RunByNameWithParamsCapture(ctx context.Context, baseDir, name string, params map[string]string) AutoRunResult {
 	old := os.Stdout
 	r, w, err := os.Pipe()
 	if err != nil {
-		res := RunByNameWithParamsDetailed(baseDir, name, params)
+		res := RunByNameWithParamsDetailed(ctx, baseDir, name, params)
 		return res
 	}
 	os.Stdout = w
@@ -107,7 +114,7 @@ func RunByNameWithParamsCapture(baseDir, name string, params map[string]string)
 		close(done)
 	}()
 
-	res := RunByNameWithParamsDetailed(baseDir, name, params)
+	res := RunByNameWithParamsDetailed(ctx, baseDir, name, params)
 
 	w.Close()
 	<-done
@@ -117,7 +124,9 @@ func RunByNameWithParamsCapture(baseDir, name string, params map[string]string)
 	return res
 }
 
-func RunByNameWithParamsDetailed(baseDir, name string, params map[string]string) AutoRunResult {
+// RunByNameWithParamsDetailed runs a tool non-interactively. Tools that
+// wait on something external (shell, fetch) stop when ctx is canceled.
+func RunByNameWithParamsDetailed(ctx context.Context, baseDir, name string, params map[string]string) AutoRunResult {
 	switch normalizeToolName(name) {
 	case "search":
 		return RunSearchAutoDetailed(baseDir, params)
@@ -135,6 +144,12 @@ func RunByNameWithParamsDetailed(baseDir, name string, params map[string]string)
 		return RunGrepAutoDetailed(baseDir, params)
 	case "diff":
 		return RunDiffAutoDetailed(baseDir, params)
+	case "fetch":
+		return RunFetchAutoDetailed(ctx, baseDir, params)
+	case "shell":
+		return RunShellAutoDetailed(ctx, baseDir, params)
+	case "write":
+		return RunWriteAutoDetailed(baseDir, params)
 	default:
 		return AutoRunResult{Code: RunByName(baseDir, name)}
 	}
@@ -158,9 +173,15 @@ func RunByNameWithReader(baseDir, name string, reader *bufio.Reader) int {
 		return RunGrep(reader)
 	case "diff":
 		return RunDiff(reader)
+	case "fetch":
+		return RunFetch(reader)
+	case "shell":
+		return RunShell(reader)
+	case "write":
+		return RunWrite(reader)
 	default:
 		fmt.Println(ui.Error("Invalid tool:"), name)
-		fmt.Println(ui.Muted("Use: search|rename|recent|clean|system|read|grep|diff"))
+		fmt.Println(ui.Muted("Use: search|rename|recent|clean|system|read|grep|diff|fetch|shell|write"))
 		return 1
 	}
 }
@@ -266,6 +287,18 @@ func IsKnownTool(name string) bool {
 	return normalizeToolName(name) != ""
 }
 
+// ConfirmsItself reports whether a tool asks the user before acting, after
+// showing a preview such as a diff.
+func ConfirmsItself(name string) bool {
+	canonical := normalizeToolName(name)
+	for _, t := range ToolRegistry {
+		if t.Name == canonical {
+			return t.SelfConfirm
+		}
+	}
+	return false
+}
+
 func BuildAgentCatalog() string {
 	lines := make([]string, 0, len(ToolRegistry))
 	for _, t := range ToolRegistry {
@@ -280,6 +313,62 @@ func BuildAgentCatalog() string {
 	return strings.Join(lines, "\n")
 }
 
+// ToolParam is one agent argument of a tool, parsed from AgentArgs.
+type ToolParam struct {
+	Name        string
+	Description string
+	Required    bool
+	Enum        []string
+}
+
+// AgentParams parses AgentArgs ("name (hint), other (a|b, default a)") into
+// parameters. A hint starting with "required" marks the parameter required
+// and a leading a|b|c list becomes the allowed values.
+func (t ToolDescriptor) AgentParams() []ToolParam {
+	var out []ToolParam
+	for _, part := range splitAgentArgs(t.AgentArgs) {
+		name, hint, _ := strings.Cut(part, "(")
+		p := ToolParam{Name: strings.TrimSpace(name)}
+		if p.Name == "" {
+			continue
+		}
+		hint = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(hint), ")"))
+		p.Description = hint
+		first, rest, _ := strings.Cut(hint, ",")
+		first = strings.TrimSpace(first)
+		if strings.EqualFold(first, "required") {
+			p.Required = true
+			p.Description = strings.TrimSpace(rest)
+		} else if strings.Contains(first, "|") && !strings.ContainsAny(first, " ()") {
+			p.Enum = strings.Split(first, "|")
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func splitAgentArgs(s string) []string {
+	var out []string
+	depth, start := 0, 0
+	for i, ch := range s {
+		switch ch {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				out = append(out, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if tail := strings.TrimSpace(s[start:]); tail != "" {
+		out = append(out, tail)
+	}
+	return out
+}
+
 func ToolRisk(name string, args map[string]string) (string, string) {
 	canonical := normalizeToolName(name)
 	for _, t := range ToolRegistry {
@@ -292,6 +381,12 @@ func ToolRisk(name string, args map[string]string) (string, string) {
 				return "high", "delete empty directories"
 			}
 		}
+		if t.Name == "shell" {
+			return ShellRisk(args["command"])
+		}
+		if t.Name == "fetch" && !isSafeFetchMethod(args["method"]) {
+			return "high", "mutating HTTP request (" + strings.ToUpper(strings.TrimSpace(args["method"])) + ")"
+		}
 		return t.RiskLevel, t.RiskNote
 	}
 	return "low", "read/inspect operation"
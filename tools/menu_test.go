@@ -0,0 +1,23 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAgentParams(t *testing.T) {
+	td := ToolDescriptor{AgentArgs: "path (required), mode (git|files, default git), apply (true for delete, otherwise preview), limit"}
+	got := td.AgentParams()
+	want := []ToolParam{
+		{Name: "path", Required: true},
+		{Name: "mode", Description: "git|files, default git", Enum: []string{"git", "files"}},
+		{Name: "apply", Description: "true for delete, otherwise preview"},
+		{Name: "limit"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+	if len((ToolDescriptor{}).AgentParams()) != 0 {
+		t.Fatal("expected no params for empty AgentArgs")
+	}
+}
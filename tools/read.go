@@ -10,6 +10,8 @@ import (
 	"unicode/utf8"
 
 	"cli/internal/ui"
+
+	"golang.org/x/term"
 )
 
 const (
@@ -140,10 +142,20 @@ func printFileContents(path string, startLine, limit int) int {
 	}
 	window := lines[from:to]
 
+	// Only highlight for a terminal: agent runs capture stdout into the
+	// model context, where escape codes are noise.
+	var hl *ui.Highlighter
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		hl = ui.NewHighlighter(ui.LanguageForPath(path))
+		for _, line := range lines[:from] {
+			hl.Line(line) // carry block comments and multi-line strings into the window
+		}
+	}
+
 	fmt.Printf("File: %s (%d lines total, showing %d-%d)\n", filepath.Base(path), totalLines, startLine, from+len(window))
 	for i, line := range window {
 		lineNum := from + i + 1
-		fmt.Printf("%4d | %s\n", lineNum, line)
+		fmt.Printf("%4d | %s\n", lineNum, hl.Line(line))
 	}
 
 	remaining := totalLines - to
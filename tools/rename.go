@@ -53,7 +53,7 @@ func RunRename(baseDir string, r *bufio.Reader) int {
 		return 0
 	}
 
-	if err := renamer.ApplyPlan(plan); err != nil {
+	if err := applyRenamePlan(plan); err != nil {
 		fmt.Println("Error:", err)
 		return 1
 	}
@@ -135,7 +135,7 @@ func RunRenameAutoDetailed(baseDir string, params map[string]string) AutoRunResu
 		return AutoRunResult{Code: 0}
 	}
 
-	if err := renamer.ApplyPlan(plan); err != nil {
+	if err := applyRenamePlan(plan); err != nil {
 		fmt.Println("Error:", err)
 		return AutoRunResult{Code: 1}
 	}
@@ -0,0 +1,537 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"cli/internal/ui"
+)
+
+const (
+	shellDefaultTimeout  = 60 * time.Second
+	shellMaxTimeout      = 10 * time.Minute
+	shellDefaultMaxBytes = 64 * 1024 // 64 KB
+	shellMaxBytes        = 1024 * 1024
+)
+
+// ShellPolicy restricts the shell tool. Allow and Deny hold command
+// prefixes matched word by word ("git push" matches "git push origin");
+// an empty Allow list permits every command that is not denied.
+type ShellPolicy struct {
+	Allow    []string
+	Deny     []string
+	Timeout  time.Duration
+	MaxBytes int
+}
+
+var shellPolicy ShellPolicy
+
+// SetShellPolicy installs the configured allow/deny lists and limits.
+func SetShellPolicy(p ShellPolicy) {
+	shellPolicy = p
+}
+
+// shellAlwaysDenied are refused regardless of configuration.
+var shellAlwaysDenied = []string{
+	"rm -rf /", "rm -rf /*", "rm -rf ~", "mkfs", "format", "shutdown", "reboot", "halt", "poweroff",
+	"stop-computer", "restart-computer",
+}
+
+var shellHighRisk = []string{
+	"rm", "rmdir", "del", "erase", "rd", "remove-item", "ri", "unlink", "shred", "dd", "truncate",
+	"git push", "git reset --hard", "git clean", "git branch -d", "git branch -D", "git checkout --", "git restore", "git stash drop", "git rebase",
+	"docker rm", "docker rmi", "docker kill", "docker volume rm", "docker system prune", "docker image prune",
+	"docker container prune", "docker volume prune", "docker compose down", "docker-compose down",
+	"kubectl delete", "kubectl apply", "helm uninstall", "terraform apply", "terraform destroy",
+	"kill", "pkill", "killall", "taskkill", "stop-process", "stop-service",
+	"sudo", "su", "runas", "chmod", "chown", "icacls", "takeown", "reg delete", "set-executionpolicy",
+	"npm publish", "go clean -modcache",
+}
+
+var shellLowRisk = []string{
+	"ls", "dir", "pwd", "cat", "type", "head", "tail", "less", "echo", "grep", "rg", "find", "wc", "which", "where",
+	"whoami", "hostname", "uname", "date", "printenv", "df", "du", "ps", "tree", "stat", "file",
+	"git status", "git log", "git diff", "git show", "git branch", "git remote -v", "git rev-parse", "git blame",
+	"go version", "go env", "go list", "go vet", "go doc", "docker ps", "docker images", "docker logs", "docker inspect",
+	"docker compose ps", "kubectl get", "kubectl describe", "kubectl logs",
+	"get-childitem", "gci", "get-content", "gc", "get-location", "get-process", "get-service", "get-item", "select-string",
+	"test-path", "resolve-path", "get-command", "get-date",
+}
+
+var shellRedirect = regexp.MustCompile(`>>?\s*[^&\s]`)
+
+type shellRequest struct {
+	Command  string
+	Dir      string
+	Timeout  time.Duration
+	MaxBytes int
+}
+
+func RunShell(r *bufio.Reader) int {
+	command := prompt(r, "Command", "")
+	if strings.TrimSpace(command) == "" {
+		fmt.Println(ui.Error("Error:"), "command is required.")
+		return 1
+	}
+	req := shellRequest{Command: command, Dir: currentWorkingDir("."), Timeout: shellTimeout(""), MaxBytes: shellOutputCap("")}
+	if risk, note := ShellRisk(command); risk == "high" {
+		fmt.Println(ui.Error("High risk:"), note)
+		if answer := strings.ToLower(prompt(r, "Run it? [y/N]", "")); answer != "y" && answer != "yes" {
+			fmt.Println(ui.Muted("Canceled."))
+			return 0
+		}
+	}
+	return runShell(context.Background(), req)
+}
+
+func RunShellAuto(baseDir string, params map[string]string) int {
+	return RunShellAutoDetailed(context.Background(), baseDir, params).Code
+}
+
+func RunShellAutoDetailed(ctx context.Context, baseDir string, params map[string]string) AutoRunResult {
+	req, err := shellRequestFromParams(baseDir, params)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return AutoRunResult{Code: 1}
+	}
+	return AutoRunResult{Code: runShell(ctx, req)}
+}
+
+func shellRequestFromParams(baseDir string, params map[string]string) (shellRequest, error) {
+	req := shellRequest{
+		Command:  strings.TrimSpace(params["command"]),
+		Timeout:  shellTimeout(params["timeout"]),
+		MaxBytes: shellOutputCap(params["max_bytes"]),
+	}
+	if req.Command == "" {
+		return req, fmt.Errorf("command is required")
+	}
+	dir, err := shellWorkDir(currentWorkingDir(baseDir), params["cwd"])
+	if err != nil {
+		return req, err
+	}
+	req.Dir = dir
+	return req, nil
+}
+
+func shellTimeout(raw string) time.Duration {
+	d := shellDefaultTimeout
+	if shellPolicy.Timeout > 0 {
+		d = shellPolicy.Timeout
+	}
+	if secs, err := strconv.Atoi(strings.TrimSpace(raw)); err == nil && secs > 0 {
+		d = time.Duration(secs) * time.Second
+	}
+	return min(d, shellMaxTimeout)
+}
+
+func shellOutputCap(raw string) int {
+	n := shellDefaultMaxBytes
+	if shellPolicy.MaxBytes > 0 {
+		n = shellPolicy.MaxBytes
+	}
+	if v, err := strconv.Atoi(strings.TrimSpace(raw)); err == nil && v > 0 {
+		n = v
+	}
+	return min(n, shellMaxBytes)
+}
+
+// shellWorkDir resolves cwd against root and refuses directories outside it.
+func shellWorkDir(root, cwd string) (string, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	dir := root
+	if c := strings.Trim(strings.TrimSpace(cwd), `"'`); c != "" {
+		if !filepath.IsAbs(c) {
+			c = filepath.Join(root, c)
+		}
+		dir = filepath.Clean(c)
+	}
+	if err := validateExistingDir(dir, "cwd"); err != nil {
+		return "", err
+	}
+	if err := checkWorkspacePath(root, dir); err != nil {
+		return "", fmt.Errorf("cwd must be inside the workspace: %w", err)
+	}
+	return dir, nil
+}
+
+// CheckShellCommand returns an error when any part of command is denied or
+// missing from a configured allowlist. Wrappers such as sudo or xargs are
+// checked against the deny lists; the allowlist applies to the commands
+// they run.
+func CheckShellCommand(command string) error {
+	segments := shellSegments(command)
+	if len(segments) == 0 {
+		return fmt.Errorf("command is required")
+	}
+	for _, seg := range segments {
+		if p := matchShellPrefix(seg.words, shellAlwaysDenied); p != "" {
+			return fmt.Errorf("%q is never allowed", p)
+		}
+		if p := matchShellPrefix(seg.words, shellPolicy.Deny); p != "" {
+			return fmt.Errorf("%q is denied by the shell.deny config", p)
+		}
+		if !seg.wrapper && len(shellPolicy.Allow) > 0 && matchShellPrefix(seg.words, shellPolicy.Allow) == "" {
+			return fmt.Errorf("%q is not in the shell.allow config", strings.Join(seg.words, " "))
+		}
+	}
+	return nil
+}
+
+// ShellRisk classifies a command line by its riskiest part.
+func ShellRisk(command string) (string, string) {
+	segments := shellSegments(command)
+	if len(segments) == 0 {
+		return "low", "empty command"
+	}
+	level, note := "low", "read-only command"
+	for _, seg := range segments {
+		words := seg.words
+		if p := matchShellPrefix(words, shellHighRisk); p != "" {
+			return "high", "destructive or privileged command (" + p + ")"
+		}
+		if containsWord(words, "-delete") {
+			return "high", "deletes files (" + words[0] + " -delete)"
+		}
+		if words[0] == "find" {
+			for _, action := range shellFindExec {
+				if containsWord(words, action) {
+					return "high", "runs a command on every match (find " + action + ")"
+				}
+			}
+		}
+		if !seg.wrapper && matchShellPrefix(words, shellLowRisk) == "" {
+			level, note = "medium", "runs "+words[0]
+		}
+	}
+	if level == "low" && shellRedirect.MatchString(command) {
+		return "medium", "redirects output to a file"
+	}
+	return level, note
+}
+
+// shellSegment is one command of a command line. A wrapper (sudo, env,
+// xargs, sh -c ...) runs the segments that follow it.
+type shellSegment struct {
+	words   []string
+	wrapper bool
+}
+
+// shellWrappers maps commands that run another command to their options
+// that take a separate value.
+var shellWrappers = map[string][]string{
+	"env":     {"-u", "-C", "-S", "--unset", "--chdir", "--split-string"},
+	"nice":    {"-n", "--adjustment"},
+	"nohup":   nil,
+	"time":    {"-f", "-o", "--format", "--output"},
+	"timeout": {"-s", "-k", "--signal", "--kill-after"},
+	"command": nil,
+	"exec":    {"-a"},
+	"xargs":   {"-a", "-d", "-E", "-I", "-L", "-n", "-P", "-s", "--arg-file", "--delimiter", "--max-args", "--max-procs"},
+	"sudo":    {"-u", "-g", "-C", "-D", "-h", "-p", "-r", "-t", "-U", "--user", "--group"},
+	"doas":    {"-u", "-C"},
+}
+
+// shellGitValueFlags are git options before the subcommand that take a
+// separate value.
+var shellGitValueFlags = []string{"-C", "-c", "--git-dir", "--work-tree", "--namespace", "--config-env"}
+
+var shellFindExec = []string{"-exec", "-execdir", "-ok", "-okdir"}
+
+// shellUnwrapDepth bounds nesting such as sudo env sh -c "...".
+const shellUnwrapDepth = 8
+
+// shellSegments splits a command line on ;, &&, ||, | and newlines and
+// returns each part as words, skipping leading VAR=value assignments and
+// reducing the command name to its base name (/bin/rm, rm.exe -> rm).
+// Wrappers, sh -c scripts and find -exec commands are unwrapped into
+// segments of their own, and git options before the subcommand are dropped.
+func shellSegments(command string) []shellSegment {
+	var out []shellSegment
+	for _, part := range splitShellOperators(command) {
+		out = appendShellSegment(out, strings.Fields(part), 0)
+	}
+	return out
+}
+
+func appendShellSegment(out []shellSegment, words []string, depth int) []shellSegment {
+	for len(words) > 0 && strings.Contains(words[0], "=") && !strings.HasPrefix(words[0], "=") {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return out
+	}
+	words = append([]string{shellCommandName(words[0])}, words[1:]...)
+	if depth < shellUnwrapDepth {
+		if script, ok := shellScriptArg(words); ok {
+			out = append(out, shellSegment{words: words[:1], wrapper: true})
+			for _, part := range splitShellOperators(script) {
+				out = appendShellSegment(out, strings.Fields(part), depth+1)
+			}
+			return out
+		}
+		if n := shellWrapperLen(words); n > 0 {
+			out = append(out, shellSegment{words: words[:n], wrapper: true})
+			return appendShellSegment(out, words[n:], depth+1)
+		}
+	}
+	if words[0] == "git" {
+		words = skipGitGlobalFlags(words)
+	}
+	out = append(out, shellSegment{words: words})
+	if words[0] == "find" && depth < shellUnwrapDepth {
+		for i := 1; i < len(words); i++ {
+			if !containsWord(shellFindExec, words[i]) {
+				continue
+			}
+			end := i + 1
+			for end < len(words) && words[end] != "+" && words[end] != ";" && words[end] != `\;` {
+				end++
+			}
+			out = appendShellSegment(out, words[i+1:end], depth+1)
+			i = end
+		}
+	}
+	return out
+}
+
+func shellCommandName(word string) string {
+	name := strings.Trim(word, `"'&(`)
+	name = strings.ToLower(filepath.Base(strings.ReplaceAll(name, `\`, "/")))
+	return strings.TrimSuffix(name, ".exe")
+}
+
+// shellWrapperLen returns how many words of a wrapper such as sudo or
+// xargs come before the command it runs, or 0 when words is not a wrapper
+// or runs nothing.
+func shellWrapperLen(words []string) int {
+	valueFlags, ok := shellWrappers[words[0]]
+	if !ok {
+		return 0
+	}
+	i := 1
+	for i < len(words) {
+		w := words[i]
+		if w == "--" {
+			i++
+			break
+		}
+		if strings.HasPrefix(w, "-") && len(w) > 1 {
+			if containsWord(valueFlags, w) {
+				i++
+			}
+			i++
+			continue
+		}
+		if words[0] == "env" && strings.Contains(w, "=") {
+			i++
+			continue
+		}
+		break
+	}
+	if words[0] == "timeout" {
+		i++ // the duration
+	}
+	if i >= len(words) {
+		return 0
+	}
+	return i
+}
+
+// shellScriptArg returns the script a shell runs with -c (sh, bash),
+// -Command (pwsh, powershell) or /c (cmd).
+func shellScriptArg(words []string) (string, bool) {
+	var isFlag func(string) bool
+	switch words[0] {
+	case "sh", "bash", "dash", "zsh", "ksh", "ash":
+		isFlag = func(w string) bool {
+			return len(w) > 1 && w[0] == '-' && w[1] != '-' && strings.Contains(w, "c")
+		}
+	case "pwsh", "powershell":
+		isFlag = func(w string) bool {
+			w = strings.ToLower(w)
+			return len(w) > 1 && strings.HasPrefix("-command", w)
+		}
+	case "cmd":
+		isFlag = func(w string) bool { return strings.EqualFold(w, "/c") || strings.EqualFold(w, "/k") }
+	default:
+		return "", false
+	}
+	for i := 1; i < len(words); i++ {
+		if isFlag(words[i]) {
+			script := strings.Join(words[i+1:], " ")
+			if len(script) >= 2 && (script[0] == '"' || script[0] == '\'') && script[len(script)-1] == script[0] {
+				script = script[1 : len(script)-1]
+			}
+			return script, true
+		}
+	}
+	return "", false
+}
+
+func skipGitGlobalFlags(words []string) []string {
+	i := 1
+	for i < len(words) && strings.HasPrefix(words[i], "-") {
+		if containsWord(shellGitValueFlags, words[i]) {
+			i++
+		}
+		i++
+	}
+	if i == 1 || i > len(words) {
+		return words
+	}
+	return append([]string{words[0]}, words[i:]...)
+}
+
+func splitShellOperators(command string) []string {
+	var parts []string
+	var cur strings.Builder
+	var quote byte
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+			cur.WriteByte(c)
+		case c == '"' || c == '\'':
+			quote = c
+			cur.WriteByte(c)
+		case c == '&' && (i > 0 && command[i-1] == '>' || i+1 < len(command) && command[i+1] == '>'):
+			cur.WriteByte(c) // 2>&1, &>file
+		case c == ';' || c == '|' || c == '&' || c == '\n' || c == '`' || c == '(' || c == ')' || c == '$' && i+1 < len(command) && command[i+1] == '(':
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	return append(parts, cur.String())
+}
+
+// matchShellPrefix returns the first prefix whose words start seg. The
+// command name is compared case-insensitively, arguments exactly.
+func matchShellPrefix(seg []string, prefixes []string) string {
+	for _, p := range prefixes {
+		words := strings.Fields(p)
+		if len(words) == 0 || len(words) > len(seg) {
+			continue
+		}
+		ok := strings.EqualFold(words[0], seg[0])
+		for i := 1; ok && i < len(words); i++ {
+			ok = words[i] == seg[i]
+		}
+		if ok {
+			return p
+		}
+	}
+	return ""
+}
+
+func containsWord(words []string, w string) bool {
+	for _, x := range words {
+		if x == w {
+			return true
+		}
+	}
+	return false
+}
+
+func runShell(ctx context.Context, req shellRequest) int {
+	if err := CheckShellCommand(req.Command); err != nil {
+		fmt.Println("Error: command not allowed:", err)
+		return 1
+	}
+	ctx, cancel := context.WithTimeout(ctx, req.Timeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, req.Command)
+	cmd.Dir = req.Dir
+	cmd.WaitDelay = 2 * time.Second
+	out := &cappedWriter{w: os.Stdout, left: req.MaxBytes}
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	fmt.Println(ui.Muted("$ " + req.Command + "  (" + req.Dir + ")"))
+	start := time.Now()
+	err := cmd.Run()
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if out.dropped > 0 {
+		fmt.Printf("\n... output truncated (%d bytes dropped, max_bytes=%d)\n", out.dropped, req.MaxBytes)
+	} else if out.written > 0 && !out.endsWithNewline {
+		fmt.Println()
+	}
+
+	code := 0
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		fmt.Printf("Error: command timed out after %s\n", req.Timeout)
+		return 1
+	case errors.Is(ctx.Err(), context.Canceled):
+		fmt.Println("Error: command canceled")
+		return 1
+	case errors.As(err, &exitErr):
+		code = exitErr.ExitCode()
+	case err != nil:
+		fmt.Println("Error:", err)
+		return 1
+	}
+	fmt.Println(ui.Muted(fmt.Sprintf("exit %d (%s)", code, elapsed)))
+	return code
+}
+
+func shellCommand(ctx context.Context, line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		ps := "powershell"
+		if _, err := exec.LookPath("pwsh"); err == nil {
+			ps = "pwsh"
+		}
+		return exec.CommandContext(ctx, ps, "-NoProfile", "-Command", line)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", line)
+}
+
+// cappedWriter forwards at most left bytes and counts the rest.
+type cappedWriter struct {
+	w               io.Writer
+	left            int
+	written         int
+	dropped         int
+	endsWithNewline bool
+}
+
+func (c *cappedWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if c.left <= 0 {
+		c.dropped += n
+		return n, nil
+	}
+	chunk := p
+	if len(chunk) > c.left {
+		chunk = chunk[:c.left]
+		c.dropped += n - len(chunk)
+	}
+	if _, err := c.w.Write(chunk); err != nil {
+		return 0, err
+	}
+	c.left -= len(chunk)
+	c.written += len(chunk)
+	c.endsWithNewline = chunk[len(chunk)-1] == '\n'
+	return n, nil
+}
@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShellRisk(t *testing.T) {
+	cases := map[string]string{
+		"git status":                   "low",
+		"ls -la | grep go":             "low",
+		"go test ./... 2>&1":           "medium",
+		"echo hi > out.txt":            "medium",
+		"rm -rf build":                 "high",
+		"/bin/rm x":                    "high",
+		"go build && git push origin":  "high",
+		"docker rm -f web":             "high",
+		"docker ps -a":                 "low",
+		"git branch -D old":            "high",
+		"find . -name '*.tmp' -delete": "high",
+		"FOO=1 sudo make install":      "high",
+		"Remove-Item -Recurse x":       "high",
+		"echo $(rm -rf x)":             "high",
+		"env rm -rf x":                 "high",
+		"env":                          "medium",
+		"nice -n 5 rm -rf x":           "high",
+		"time ls":                      "low",
+		"command rm -rf x":             "high",
+		"ls | xargs -I {} rm {}":       "high",
+		"sudo -u web ls":               "high",
+		"sh -c 'ls; rm -rf x'":         "high",
+		"bash -lc \"git status\"":      "low",
+		"pwsh -Command Remove-Item x":  "high",
+		"find . -exec rm -rf {} +":     "high",
+		"find . -execdir cat {} \\;":   "high",
+		"git -C . push --force":        "high",
+		"git -c core.pager=cat log":    "low",
+	}
+	for cmd, want := range cases {
+		if got, note := ShellRisk(cmd); got != want {
+			t.Errorf("ShellRisk(%q) = %s (%s), want %s", cmd, got, note, want)
+		}
+	}
+	if risk, _ := ToolRisk("run_shell", map[string]string{"command": "git push"}); risk != "high" {
+		t.Fatalf("ToolRisk for git push = %s, want high", risk)
+	}
+}
+
+func TestCheckShellCommand_AllowDeny(t *testing.T) {
+	defer SetShellPolicy(ShellPolicy{})
+	SetShellPolicy(ShellPolicy{Allow: []string{"go", "git status"}, Deny: []string{"go clean"}})
+	for _, ok := range []string{"go test ./...", "git status -s", "go vet ./... && git status"} {
+		if err := CheckShellCommand(ok); err != nil {
+			t.Errorf("CheckShellCommand(%q) = %v, want allowed", ok, err)
+		}
+	}
+	for _, bad := range []string{"git push", "go clean -cache", "go test; curl x", "gofmt -l ."} {
+		if err := CheckShellCommand(bad); err == nil {
+			t.Errorf("CheckShellCommand(%q) allowed, want refused", bad)
+		}
+	}
+	SetShellPolicy(ShellPolicy{Deny: []string{"rm", "git push"}})
+	for _, bad := range []string{"env rm -rf x", "nice rm x", "ls | xargs rm", "command rm x", "sh -c 'rm x'", "find . -exec rm {} +", "git -C . push"} {
+		if err := CheckShellCommand(bad); err == nil {
+			t.Errorf("CheckShellCommand(%q) allowed, want denied", bad)
+		}
+	}
+	SetShellPolicy(ShellPolicy{Allow: []string{"git status"}})
+	if err := CheckShellCommand("time git status"); err != nil {
+		t.Errorf("a wrapped allowed command should pass: %v", err)
+	}
+	if err := CheckShellCommand("time git push"); err == nil {
+		t.Error("a wrapped command must still be in the allowlist")
+	}
+	SetShellPolicy(ShellPolicy{})
+	if err := CheckShellCommand("sudo rm -rf /"); err == nil {
+		t.Fatal("expected sudo rm -rf / to be refused without config")
+	}
+	if err := CheckShellCommand("rm -rf /"); err == nil {
+		t.Fatal("expected rm -rf / to be refused without config")
+	}
+}
+
+func TestShellWorkDir_StaysInWorkspace(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if dir, err := shellWorkDir(root, "sub"); err != nil || filepath.Base(dir) != "sub" {
+		t.Fatalf("shellWorkDir(sub) = %q, %v", dir, err)
+	}
+	if _, err := shellWorkDir(filepath.Join(root, "sub"), ".."); err == nil {
+		t.Fatal("expected cwd outside the workspace to be refused")
+	}
+}
+
+func TestRunShellCapture(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	t.Chdir(dir)
+	res := RunByNameWithParamsCapture(context.Background(), dir, "shell", map[string]string{"command": "printf 'abcdefghij'; exit 3", "cwd": dir, "max_bytes": "4"})
+	if res.Code != 3 {
+		t.Fatalf("exit code = %d, want 3", res.Code)
+	}
+	if !strings.Contains(res.Output, "\nabcd\n... output truncated") || !strings.Contains(res.Output, "6 bytes dropped") {
+		t.Fatalf("unexpected output %q", res.Output)
+	}
+	res = RunByNameWithParamsCapture(context.Background(), dir, "shell", map[string]string{"command": "sleep 5", "cwd": dir, "timeout": "1"})
+	if res.Code != 1 || !strings.Contains(res.Output, "timed out") {
+		t.Fatalf("expected timeout, got %d %q", res.Code, res.Output)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	start := time.Now()
+	res = RunByNameWithParamsCapture(ctx, dir, "shell", map[string]string{"command": "sleep 5", "cwd": dir})
+	if res.Code != 1 || !strings.Contains(res.Output, "command canceled") || time.Since(start) > 3*time.Second {
+		t.Fatalf("expected cancel, got %d %q after %s", res.Code, res.Output, time.Since(start))
+	}
+}
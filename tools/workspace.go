@@ -0,0 +1,51 @@
+package tools
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var workspaceRootList []string
+
+// SetWorkspaceRoots sets the directories the shell and write tools may work
+// in. Without roots the current working directory is the workspace.
+func SetWorkspaceRoots(roots []string) {
+	workspaceRootList = roots
+}
+
+func workspaceRoots(cwd string) []string {
+	if len(workspaceRootList) > 0 {
+		return workspaceRootList
+	}
+	return []string{cwd}
+}
+
+// checkWorkspacePath returns an error unless path (or, for files that do not
+// exist yet, its nearest existing parent) is inside a workspace root.
+func checkWorkspacePath(cwd, path string) error {
+	target := resolveSymlinks(path)
+	for _, root := range workspaceRoots(cwd) {
+		if abs, err := filepath.Abs(root); err == nil && pathWithin(resolveSymlinks(abs), target) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is outside the workspace (%s)", path, strings.Join(workspaceRoots(cwd), ", "))
+}
+
+// resolveSymlinks evaluates symlinks in the longest existing prefix of p.
+func resolveSymlinks(p string) string {
+	if r, err := filepath.EvalSymlinks(p); err == nil {
+		return r
+	}
+	parent := filepath.Dir(p)
+	if parent == p {
+		return p
+	}
+	return filepath.Join(resolveSymlinks(parent), filepath.Base(p))
+}
+
+func pathWithin(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
@@ -0,0 +1,321 @@
+package tools
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"cli/internal/journal"
+	"cli/internal/ui"
+)
+
+const writeMaxFileBytes = 1024 * 1024 // 1 MB
+
+type writeHunk struct {
+	Search  string `json:"search"`
+	Replace string `json:"replace"`
+}
+
+type writeRequest struct {
+	Path    string
+	Content *string
+	Hunks   []writeHunk
+	Create  bool
+}
+
+// writeConfirm asks before a write is applied; tests replace it.
+var writeConfirm = func(question string) bool {
+	fmt.Print(ui.Prompt(question + " [y/N] "))
+	answer := strings.ToLower(readLine(bufio.NewReader(os.Stdin)))
+	return answer == "y" || answer == "yes"
+}
+
+func RunWrite(r *bufio.Reader) int {
+	path := prompt(r, "File path", "")
+	if strings.TrimSpace(path) == "" {
+		fmt.Println(ui.Error("Error:"), "file path is required.")
+		return 1
+	}
+	search := prompt(r, "Search text", "")
+	if search == "" {
+		fmt.Println(ui.Error("Error:"), "search text is required.")
+		return 1
+	}
+	replace := prompt(r, "Replace with", "")
+	req := writeRequest{
+		Path:  normalizeInputPath(path, currentWorkingDir(".")),
+		Hunks: []writeHunk{{Search: search, Replace: replace}},
+	}
+	return runWrite(currentWorkingDir("."), req)
+}
+
+func RunWriteAuto(baseDir string, params map[string]string) int {
+	return RunWriteAutoDetailed(baseDir, params).Code
+}
+
+func RunWriteAutoDetailed(baseDir string, params map[string]string) AutoRunResult {
+	req, err := writeRequestFromParams(baseDir, params)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return AutoRunResult{Code: 1}
+	}
+	return AutoRunResult{Code: runWrite(currentWorkingDir(baseDir), req)}
+}
+
+func writeRequestFromParams(baseDir string, params map[string]string) (writeRequest, error) {
+	raw := strings.TrimSpace(params["path"])
+	if raw == "" {
+		return writeRequest{}, fmt.Errorf("path is required")
+	}
+	req := writeRequest{Path: resolveReadPath(raw, baseDir), Create: parseBoolParam(params["create"])}
+	if content, ok := params["content"]; ok {
+		req.Content = &content
+	}
+	if h := strings.TrimSpace(params["hunks"]); h != "" {
+		if err := json.Unmarshal([]byte(h), &req.Hunks); err != nil {
+			return req, fmt.Errorf("hunks must be a JSON list of {\"search\":...,\"replace\":...}: %v", err)
+		}
+	}
+	if search, ok := params["search"]; ok {
+		req.Hunks = append(req.Hunks, writeHunk{Search: search, Replace: params["replace"]})
+	}
+	switch {
+	case req.Content != nil && len(req.Hunks) > 0:
+		return req, fmt.Errorf("pass either content or search/replace hunks, not both")
+	case req.Content == nil && len(req.Hunks) == 0:
+		return req, fmt.Errorf("content or search/replace hunks are required")
+	}
+	return req, nil
+}
+
+func parseBoolParam(v string) bool {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "1", "true", "yes", "y":
+		return true
+	}
+	return false
+}
+
+func runWrite(cwd string, req writeRequest) int {
+	if err := checkWorkspacePath(cwd, req.Path); err != nil {
+		fmt.Println("Error: write refused:", err)
+		return 1
+	}
+	old, exists, err := readWriteTarget(req.Path)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return 1
+	}
+	if !exists && req.Content == nil {
+		fmt.Printf("Error: file not found: %s (pass content and create=true to create it)\n", req.Path)
+		return 1
+	}
+	if !exists && !req.Create {
+		fmt.Printf("Error: file not found: %s (set create=true to create it)\n", req.Path)
+		return 1
+	}
+
+	updated, err := applyWriteRequest(old, req)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return 1
+	}
+	if exists && updated == old {
+		fmt.Printf("No changes: %s already has this content.\n", req.Path)
+		return 0
+	}
+
+	added, removed := printWritePreview(req.Path, old, updated)
+	if !writeConfirm(fmt.Sprintf("Write %s?", req.Path)) {
+		fmt.Println("Canceled by the user: no changes written to", req.Path)
+		return 0
+	}
+
+	op, err := journal.Begin("write", []journal.Entry{{
+		Kind: journal.KindWrite, OldPath: req.Path, Checksum: journal.Checksum([]byte(updated)),
+	}})
+	if err != nil {
+		fmt.Println("Error:", err)
+		return 1
+	}
+	writeErr := writeFileAtomic(req.Path, []byte(updated))
+	if writeErr == nil {
+		op.MarkDone(0)
+	}
+	finishJournalOp(op, writeErr)
+	if writeErr != nil {
+		fmt.Println("Error: write failed:", writeErr)
+		return 1
+	}
+	verb := "Updated"
+	if !exists {
+		verb = "Created"
+	}
+	fmt.Printf("%s %s (+%d -%d lines, %d bytes)\n", verb, req.Path, added, removed, len(updated))
+	return 0
+}
+
+func readWriteTarget(path string) (string, bool, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if info.IsDir() {
+		return "", false, fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > writeMaxFileBytes {
+		return "", false, fmt.Errorf("file too large to edit (%s, max %s): %s",
+			formatReadSize(info.Size()), formatReadSize(writeMaxFileBytes), path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, err
+	}
+	if !utf8.Valid(data) {
+		return "", false, fmt.Errorf("file appears to be binary: %s", path)
+	}
+	return string(data), true, nil
+}
+
+// applyWriteRequest returns the new content. Every search text must occur
+// exactly once in the file as it is when the hunk is applied.
+func applyWriteRequest(old string, req writeRequest) (string, error) {
+	if req.Content != nil {
+		content := *req.Content
+		// tool args are trimmed, so restore the final newline
+		if content != "" && !strings.HasSuffix(content, "\n") && (old == "" || strings.HasSuffix(old, "\n")) {
+			content += "\n"
+		}
+		if strings.Contains(old, "\r\n") && !strings.Contains(content, "\r\n") {
+			content = strings.ReplaceAll(content, "\n", "\r\n")
+		}
+		return content, nil
+	}
+	out := old
+	for i, h := range req.Hunks {
+		if h.Search == "" {
+			return "", fmt.Errorf("hunk %d: search text is empty", i+1)
+		}
+		search, replace := h.Search, h.Replace
+		if strings.Contains(out, "\r\n") && !strings.Contains(search, "\r\n") {
+			search = strings.ReplaceAll(search, "\n", "\r\n")
+			replace = strings.ReplaceAll(replace, "\n", "\r\n")
+		}
+		switch n := strings.Count(out, search); n {
+		case 0:
+			return "", fmt.Errorf("hunk %d: search text not found in %s", i+1, filepath.Base(req.Path))
+		case 1:
+			out = strings.Replace(out, search, replace, 1)
+		default:
+			return "", fmt.Errorf("hunk %d: search text matches %d times, add surrounding lines to make it unique", i+1, n)
+		}
+	}
+	return out, nil
+}
+
+// printWritePreview shows the change as a unified diff and returns the
+// number of added and removed lines.
+func printWritePreview(path, old, updated string) (int, int) {
+	fmt.Println(ui.Accent("Proposed change:"), path)
+	diff, err := writeDiff(path, old, updated)
+	if err != nil {
+		fmt.Println(ui.Muted("(diff unavailable: " + err.Error() + ")"))
+		return strings.Count(updated, "\n"), strings.Count(old, "\n")
+	}
+	added, removed := 0, 0
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		switch {
+		case i < 2 && (strings.HasPrefix(line, "---") || strings.HasPrefix(line, "+++")):
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	if len(lines) > diffMaxDiffLines {
+		fmt.Println(strings.Join(lines[:diffMaxDiffLines], "\n"))
+		fmt.Printf("... %d more diff lines\n", len(lines)-diffMaxDiffLines)
+	} else {
+		fmt.Println(diff)
+	}
+	return added, removed
+}
+
+// writeDiff diffs old and updated through fileDiff, using a/<name> and
+// b/<name> copies in a temp dir so the headers show the file name.
+func writeDiff(path, old, updated string) (string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", fmt.Errorf("git is not installed")
+	}
+	tmp, err := os.MkdirTemp("", "dm-write-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	name := filepath.Base(path)
+	for dir, content := range map[string]string{"a": old, "b": updated} {
+		if err := os.MkdirAll(filepath.Join(tmp, dir), 0755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filepath.Join(tmp, dir, name), []byte(content), 0644); err != nil {
+			return "", err
+		}
+	}
+	diff, err := fileDiff(tmp, filepath.Join("a", name), filepath.Join("b", name))
+	if err != nil {
+		return "", err
+	}
+	// drop the "diff --git" and "index" lines: they only name the temp copies
+	lines := strings.Split(diff, "\n")
+	for len(lines) > 0 && !strings.HasPrefix(lines[0], "---") {
+		lines = lines[1:]
+	}
+	if len(lines) >= 2 {
+		lines[0] = strings.Replace(lines[0], "a/a/", "a/", 1)
+		lines[1] = strings.Replace(lines[1], "b/b/", "b/", 1)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// writeFileAtomic replaces path through a temp file in the same directory,
+// keeping the file mode.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".dm-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
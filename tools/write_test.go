@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cli/internal/journal"
+)
+
+func withWriteConfirm(t *testing.T, answer bool) {
+	t.Helper()
+	orig := writeConfirm
+	writeConfirm = func(string) bool { return answer }
+	t.Cleanup(func() { writeConfirm = orig })
+}
+
+func TestApplyWriteRequest_Hunks(t *testing.T) {
+	old := "a := 1\nb := 2\nc := 1\n"
+	got, err := applyWriteRequest(old, writeRequest{Hunks: []writeHunk{{Search: "b := 2", Replace: "b := 3"}, {Search: "c := 1", Replace: ""}}})
+	if err != nil || got != "a := 1\nb := 3\n\n" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	if _, err := applyWriteRequest(old, writeRequest{Hunks: []writeHunk{{Search: ":= 1"}}}); err == nil || !strings.Contains(err.Error(), "2 times") {
+		t.Fatalf("expected ambiguous match error, got %v", err)
+	}
+	if _, err := applyWriteRequest(old, writeRequest{Hunks: []writeHunk{{Search: "zzz"}}}); err == nil {
+		t.Fatal("expected not found error")
+	}
+	crlf, _ := applyWriteRequest("x\r\ny\r\n", writeRequest{Hunks: []writeHunk{{Search: "x\ny", Replace: "x\nz"}}})
+	if crlf != "x\r\nz\r\n" {
+		t.Fatalf("CRLF not preserved: %q", crlf)
+	}
+	content := "new"
+	if got, _ := applyWriteRequest("old\n", writeRequest{Content: &content}); got != "new\n" {
+		t.Fatalf("expected final newline restored, got %q", got)
+	}
+}
+
+func TestRunWrite_PatchAndUndo(t *testing.T) {
+	t.Setenv("DM_JOURNAL_DIR", t.TempDir())
+	dir := t.TempDir()
+	t.Chdir(dir)
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n\nfunc main() {}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	withWriteConfirm(t, true)
+
+	res := RunByNameWithParamsCapture(context.Background(), dir, "patch", map[string]string{"path": "main.go", "search": "func main() {}", "replace": "func main() { run() }"})
+	if res.Code != 0 || !strings.Contains(res.Output, "-func main() {}") || !strings.Contains(res.Output, "+func main() { run() }") ||
+		!strings.Contains(res.Output, "Updated "+path+" (+1 -1 lines") {
+		t.Fatalf("unexpected result %d %q", res.Code, res.Output)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "package main\n\nfunc main() { run() }\n" {
+		t.Fatalf("file not patched: %q", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Fatalf("mode changed to %v", info.Mode().Perm())
+	}
+
+	op, err := journal.Find("last")
+	if err != nil || op.Tool != "write" || op.Entries[0].Backup == "" {
+		t.Fatalf("journal op = %+v, %v", op, err)
+	}
+	if _, err := journal.Undo(op); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(path)
+	if string(data) != "package main\n\nfunc main() {}\n" {
+		t.Fatalf("undo did not restore the file: %q", data)
+	}
+}
+
+func TestRunWrite_CreateCancelAndWorkspace(t *testing.T) {
+	t.Setenv("DM_JOURNAL_DIR", t.TempDir())
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	withWriteConfirm(t, false)
+	res := RunByNameWithParamsCapture(context.Background(), dir, "write", map[string]string{"path": "new.txt", "content": "hi", "create": "true"})
+	if res.Code != 0 || !strings.Contains(res.Output, "Canceled by the user") {
+		t.Fatalf("unexpected result %d %q", res.Code, res.Output)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.txt")); err == nil {
+		t.Fatal("file written despite cancel")
+	}
+
+	withWriteConfirm(t, true)
+	if res := RunByNameWithParamsCapture(context.Background(), dir, "write", map[string]string{"path": "new.txt", "content": "hi"}); res.Code == 0 {
+		t.Fatal("expected create=true to be required for new files")
+	}
+	res = RunByNameWithParamsCapture(context.Background(), dir, "write", map[string]string{"path": "sub/new.txt", "content": "hi", "create": "true"})
+	if res.Code != 0 || !strings.Contains(res.Output, "Created") {
+		t.Fatalf("unexpected result %d %q", res.Code, res.Output)
+	}
+	op, _ := journal.Find("last")
+	if _, err := journal.Undo(op); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sub", "new.txt")); !os.IsNotExist(err) {
+		t.Fatal("undo did not remove the created file")
+	}
+
+	outside := filepath.Join(t.TempDir(), "x.txt")
+	res = RunByNameWithParamsCapture(context.Background(), dir, "write", map[string]string{"path": outside, "content": "x", "create": "true"})
+	if res.Code != 1 || !strings.Contains(res.Output, "outside the workspace") {
+		t.Fatalf("expected workspace refusal, got %d %q", res.Code, res.Output)
+	}
+	SetWorkspaceRoots([]string{filepath.Dir(outside)})
+	defer SetWorkspaceRoots(nil)
+	if res := RunByNameWithParamsCapture(context.Background(), dir, "write", map[string]string{"path": outside, "content": "x", "create": "true"}); res.Code != 0 {
+		t.Fatalf("expected write inside configured root, got %d %q", res.Code, res.Output)
+	}
+}